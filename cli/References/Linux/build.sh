@@ -114,10 +114,9 @@ echo "Preparing service..."
 fpm -v $VERSION -n ivpn-service -s pleaserun -t dir --deb-no-default-config-files /usr/bin/ivpn-service
 
 OBFSPXY_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/obfs4proxy_inst/obfs4proxy
-WG_QUICK_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/wireguard-tools_inst/wg-quick
 WG_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/wireguard-tools_inst/wg
-DNSCRYPT_PROXY_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/dnscryptproxy_inst/dnscrypt-proxy
 V2RAY_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/v2ray_inst/v2ray
+AWG_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/amneziawg_inst/amneziawg-go
 KEM_HELPER_BIN=$DAEMON_REPO_ABS_PATH/References/Linux/_deps/kem-helper/kem-helper-bin/kem-helper
 
 #if [ "$(find ${DNSCRYPT_PROXY_BIN} -perm 755)" != "${DNSCRYPT_PROXY_BIN}" ] || [ "$(find ${OBFSPXY_BIN} -perm 755)" != "${OBFSPXY_BIN}" ] || [ "$(find ${WG_QUICK_BIN} -perm 755)" != "${WG_QUICK_BIN}" ] || [ "$(find ${WG_BIN} -perm 755)" != "${WG_BIN}" ]
@@ -226,9 +225,8 @@ CreatePackage()
     $OUT_DIR/ivpn.bash-completion=/opt/ivpn/etc/ivpn.bash-completion \
     $OBFSPXY_BIN=/opt/ivpn/obfsproxy/obfs4proxy \
     $V2RAY_BIN=/opt/ivpn/v2ray/v2ray \
-    $WG_QUICK_BIN=/opt/ivpn/wireguard-tools/wg-quick \
+    $AWG_BIN=/opt/ivpn/amneziawg/amneziawg-go \
     $WG_BIN=/opt/ivpn/wireguard-tools/wg \
-    ${DNSCRYPT_PROXY_BIN}=/opt/ivpn/dnscrypt-proxy/dnscrypt-proxy \
     ${KEM_HELPER_BIN}=/opt/ivpn/kem/kem-helper \
     $TMPDIRSRVC/ivpn-service.dir/usr/share/pleaserun/=/usr/share/pleaserun
 }
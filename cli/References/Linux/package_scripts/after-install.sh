@@ -131,9 +131,8 @@ silent chmod 0755 /usr/bin/ivpn           # can change only owner (root)
 silent chmod 0755 /usr/bin/ivpn-service   # can change only owner (root)
 silent chmod 0755 $IVPN_OPT/obfsproxy/obfs4proxy          # can change only owner (root)
 silent chmod 0755 $IVPN_OPT/v2ray/v2ray                   # can change only owner (root)
-silent chmod 0755 $IVPN_OPT/wireguard-tools/wg-quick      # can change only owner (root)
+silent chmod 0755 $IVPN_OPT/amneziawg/amneziawg-go        # can change only owner (root)
 silent chmod 0755 $IVPN_OPT/wireguard-tools/wg            # can change only owner (root)
-silent chmod 0755 $IVPN_OPT/dnscrypt-proxy/dnscrypt-proxy # can change only owner (root)
 silent chmod 0755 $IVPN_OPT/kem/kem-helper                # can change only owner (root)
 
 if [ -f "${SERVERS_FILE_BUNDLED}" ] && [ -f "${SERVERS_FILE_DEST}" ]; then 
@@ -27,6 +27,7 @@ import (
 	"os"
 	"regexp"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -34,6 +35,7 @@ import (
 	"github.com/ivpn/desktop-app/cli/protocol"
 	apitypes "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
+	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/splittun"
 	"github.com/ivpn/desktop-app/daemon/v2r"
 	"github.com/ivpn/desktop-app/daemon/vpn"
@@ -94,9 +96,13 @@ func printState(w *tabwriter.Writer, state vpn.State, connected types.ConnectedR
 	protocol := fmt.Sprintf("%v", connected.VpnType)
 	if connected.V2RayProxy != v2r.None {
 		protocol += fmt.Sprintf(" (V2Ray: VMESS/%s)", connected.V2RayProxy.ToString())
+	} else if connected.IsAmneziaWG {
+		protocol += " (AmneziaWG)"
 	} else if connected.VpnType == vpn.OpenVPN {
 		if connected.Obfsproxy.IsObfsproxy() {
 			protocol += fmt.Sprintf(" (Obfsproxy: %s)", connected.Obfsproxy.ToString())
+		} else if len(connected.PluggableTransport) > 0 {
+			protocol += fmt.Sprintf(" (Pluggable transport: %s)", connected.PluggableTransport)
 		}
 	}
 
@@ -129,9 +135,11 @@ func printDNSState(w *tabwriter.Writer, dnsStatus types.DnsStatus, servers *apit
 
 	if dnsStatus.AntiTrackerStatus.Enabled {
 		fmt.Fprintf(w, "AntiTracker\t:\t%v\n", GetAntiTrackerStatusText(dnsStatus.AntiTrackerStatus))
+		printSplitDnsRules(w, dnsStatus.Dns)
 	} else {
 		if dnsStatus.Dns.IsEmpty() {
 			fmt.Fprintf(w, "DNS\t:\tDefault (auto)\n")
+			printSplitDnsRules(w, dnsStatus.Dns)
 		} else {
 			fmt.Fprintf(w, "DNS\t:\t%v\n", dnsStatus.Dns.InfoString())
 		}
@@ -291,6 +299,41 @@ func printSplitTunState(w *tabwriter.Writer, isShortPrint, isFullPrint, isEnable
 	return w
 }
 
+func printSplitTunUsers(w *tabwriter.Writer, users, groups []string) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	if len(users) > 0 {
+		fmt.Fprintf(w, "Split Tunnel users\t:\t%v\n", strings.Join(users, ", "))
+	}
+	if len(groups) > 0 {
+		fmt.Fprintf(w, "Split Tunnel groups\t:\t%v\n", strings.Join(groups, ", "))
+	}
+
+	return w
+}
+
+func printSplitTunDestinations(w *tabwriter.Writer, destinations []service_types.SplitTunnelDestinationStatus) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	for i, d := range destinations {
+		info := d.Destination
+		if len(d.Resolved) > 0 {
+			info += fmt.Sprintf(" (%s)", strings.Join(d.Resolved, ", "))
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "Split Tunnel routes\t:\t%v\n", info)
+		} else {
+			fmt.Fprintf(w, "\t\t%v\n", info)
+		}
+	}
+
+	return w
+}
+
 func printParanoidModeState(w *tabwriter.Writer, helloResp types.HelloResp) *tabwriter.Writer {
 	if w == nil {
 		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -27,13 +27,16 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/ivpn/desktop-app/cli/flags"
 	apitypes "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/awg"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
+	"github.com/ivpn/desktop-app/daemon/ptransport"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/srverrors"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
@@ -105,6 +108,17 @@ func parseObfsproxyParam(param string) (obfsproxy.Config, error) {
 	return obfsproxy.Config{}, fmt.Errorf("unsupported obfsproxy value '%s' (acceptable values: %s)", param, AllowedObfsproxyValues)
 }
 
+// AmneziaWG obfuscation is defined by the same option as obfsproxy ('-obfsproxy awg'), but it is applicable only for WireGuard
+const AllowedAmneziaWGValues = "'awg' (or 'amneziawg')"
+
+func parseAmneziaWGParam(param string) awg.AmneziaWGTransportType {
+	switch strings.ToLower(param) {
+	case "awg", "amneziawg":
+		return awg.UDP
+	}
+	return awg.None
+}
+
 func parseV2RayParam(param string) (v2r.V2RayTransportType, error) {
 	switch strings.ToLower(param) {
 	case "":
@@ -125,15 +139,20 @@ type CmdConnect struct {
 	port            string
 	portsShow       bool
 	any             bool
-	obfsproxy       string // 'obfs4' (default), 'obfs3', 'obfs4_iat' (or 'obfs4_iat1'), 'obfs4_iat_paranoid' (or 'obfs4_iat2')
+	obfsproxy       string // 'obfs4' (default), 'obfs3', 'obfs4_iat' (or 'obfs4_iat1'), 'obfs4_iat_paranoid' (or 'obfs4_iat2'); 'awg' - AmneziaWG (WireGuard only)
 	v2rayProxy      string // `quic` or `tcp`
+	ptName          string // pluggable transport name (applicable only for OpenVPN)
+	ptPath          string // path to pluggable transport binary
+	ptArgs          string // pluggable transport arguments ("key=value;key=value")
+	ptPort          int    // server port of the pluggable transport
 	firewallOff     bool
 	dns             string
 	antitracker     bool
 	antitrackerHard bool
 	isIPv6Tunnel    bool
 
-	mtu int // MTU value (applicable only for WireGuard)
+	mtu     int  // MTU value (applicable only for WireGuard)
+	mtuAuto bool // automatic MTU detection (applicable only for WireGuard)
 
 	filter_proto       string
 	filter_location    bool
@@ -177,6 +196,7 @@ func (c *CmdConnect) Init() {
 	c.StringVar(&c.filter_proto, "protocol", "", "PROTOCOL", "Protocol type (OpenVPN|ovpn|WireGuard|wg)")
 	c.StringVar(&c.filter_proto, "p", "", "PROTOCOL", "Protocol type (OpenVPN|ovpn|WireGuard|wg)")
 	c.IntVar(&c.mtu, "mtu", 0, "MTU", "Maximum transmission unit (applicable only for WireGuard connections)")
+	c.BoolVar(&c.mtuAuto, "mtu_auto", false, "Detect optimal MTU by probing the path to the server (applicable only for WireGuard connections)\n  (the detected value is remembered for the current network; ignored when '-mtu' is defined)")
 	c.BoolVar(&c.isIPv6Tunnel, "ipv6tunnel", false, "Enable IPv6 in VPN tunnel (WireGuard connections only)\n  (IPv6 addresses are preferred when a host has a dual stack IPv6/IPv4; IPv4-only hosts are unaffected)")
 
 	// Port flags
@@ -192,10 +212,14 @@ func (c *CmdConnect) Init() {
 	c.BoolVar(&c.antitrackerHard, "antitracker_hard", false, "Enable 'Hard Core' AntiTracker for this connection")
 
 	// Obfuscation flags
-	obfsproxyUsage := fmt.Sprintf("Use obfsproxy (OpenVPN only)\n  Acceptable values: %s", AllowedObfsproxyValues)
+	obfsproxyUsage := fmt.Sprintf("Use obfsproxy (OpenVPN only) or AmneziaWG obfuscation (WireGuard only)\n  Acceptable values: %s\n  AmneziaWG: %s (junk packets and randomized headers make WireGuard handshake not recognizable; the server must support it)", AllowedObfsproxyValues, AllowedAmneziaWGValues)
 	c.StringVar(&c.obfsproxy, "o", "", "TYPE", obfsproxyUsage)
 	c.StringVar(&c.obfsproxy, "obfsproxy", "", "TYPE", obfsproxyUsage)
 	c.StringVar(&c.v2rayProxy, "v2ray", "", "TYPE", "Use V2Ray obfuscation (this option takes precedence over the '-obfsproxy' option)\n  Acceptable values: 'quic' (VMESS/QUIC) or 'tcp' (VMESS/TCP)")
+	c.StringVar(&c.ptName, "pt", "", "NAME", "Use pluggable transport (OpenVPN only)\n  Any Tor PT v1 compliant client binary can be used (e.g. 'obfs4' by lyrebird)\n  The hosts defined by the transport arguments ('url', 'front', 'fronts', 'ice') are allowed by the firewall and routed outside the VPN tunnel\n  The deprecated transports 'obfs2' and 'scramblesuit' are not supported\n  The transport binary must be defined by '-pt_path' option")
+	c.StringVar(&c.ptPath, "pt_path", "", "PATH", "Path to the pluggable transport client binary\n  (the binary must be owned by root and not writable by other users)")
+	c.StringVar(&c.ptArgs, "pt_args", "", "ARGS", "Arguments of the pluggable transport in format 'key=value;key=value'\n  (the same as in Tor bridge line, e.g. 'cert=...;iat-mode=0')")
+	c.IntVar(&c.ptPort, "pt_port", 0, "PORT", "Server port of the pluggable transport\n  (by default, the port of the OpenVPN connection is used; it must be a TCP port)")
 }
 
 func (c *CmdConnect) preParse(arguments []string) ([]string, error) {
@@ -247,6 +271,29 @@ func (c *CmdConnect) Run() (retError error) {
 	if c.v2rayProxy != "" && c.obfsproxy != "" {
 		return flags.BadParameter{Message: "cannot use both '-v2ray' and '-obfsproxy' options"}
 	}
+	if c.ptName != "" {
+		if c.v2rayProxy != "" || c.obfsproxy != "" {
+			return flags.BadParameter{Message: "cannot use '-pt' together with '-v2ray' or '-obfsproxy' options"}
+		}
+		if len(c.filter_proto) == 0 {
+			c.filter_proto = ProtoName_OpenVPN
+		} else if t, err := getVpnTypeByFlag(c.filter_proto); err != nil || t != vpn.OpenVPN {
+			return flags.BadParameter{Message: "'-pt' option is applicable only for OpenVPN"}
+		}
+	}
+	awgCfg := parseAmneziaWGParam(c.obfsproxy)
+	if awgCfg != awg.None {
+		if len(c.filter_proto) == 0 {
+			c.filter_proto = ProtoName_WireGuard
+		} else if t, err := getVpnTypeByFlag(c.filter_proto); err != nil || t != vpn.WireGuard {
+			return flags.BadParameter{Message: "AmneziaWG obfuscation is applicable only for WireGuard"}
+		}
+	}
+
+	ptCfg, err := c.getPluggableTransportConfig()
+	if err != nil {
+		return err
+	}
 
 	// connection request
 	req := types.Connect{}
@@ -261,9 +308,11 @@ func (c *CmdConnect) Run() (retError error) {
 	customHostEntryServer := c.gateway
 	customHostExitServer := c.multihopExitSvr
 
-	obfsproxyCfg, err := parseObfsproxyParam(c.obfsproxy)
-	if err != nil {
-		return flags.BadParameter{Message: err.Error()}
+	var obfsproxyCfg obfsproxy.Config
+	if awgCfg == awg.None {
+		if obfsproxyCfg, err = parseObfsproxyParam(c.obfsproxy); err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
 	}
 
 	v2rayCfg, err := parseV2RayParam(c.v2rayProxy)
@@ -515,6 +564,12 @@ func (c *CmdConnect) Run() (retError error) {
 					if v2rayCfg != v2r.None {
 						fmt.Println("V2Ray configuration: " + v2rayCfg.ToString())
 						req.Params.WireGuardParameters.V2RayProxy = v2rayCfg
+					} else if awgCfg != awg.None {
+						if len(helloResp.DisabledFunctions.AmneziaWGError) > 0 {
+							return fmt.Errorf("AmneziaWG functionality disabled: %s", helloResp.DisabledFunctions.AmneziaWGError)
+						}
+						fmt.Println("AmneziaWG configuration: " + awgCfg.ToString())
+						req.Params.WireGuardParameters.AmneziaWG = awgCfg
 					}
 
 					req.Params.VpnType = vpn.WireGuard
@@ -524,6 +579,9 @@ func (c *CmdConnect) Run() (retError error) {
 					if c.mtu > 0 {
 						fmt.Printf("[!] Using custom MTU: %d\n", c.mtu)
 						req.Params.WireGuardParameters.Mtu = c.mtu
+					} else if c.mtuAuto {
+						fmt.Println("[!] Using automatic MTU detection")
+						req.Params.WireGuardParameters.MtuAutoDetect = true
 					}
 
 					var destPort port
@@ -610,6 +668,9 @@ func (c *CmdConnect) Run() (retError error) {
 					} else if obfsproxyCfg.IsObfsproxy() { // Set obfsproxy config
 						fmt.Println("obfsproxy configuration: " + obfsproxyCfg.ToString())
 						req.Params.OpenVpnParameters.Obfs4proxy = obfsproxyCfg
+					} else if ptCfg.IsDefined() { // Set pluggable transport config
+						fmt.Printf("Pluggable transport: %s (%s)\n", ptCfg.ToString(), ptCfg.BinaryPath)
+						req.Params.OpenVpnParameters.PluggableTransport = ptCfg
 					}
 
 					req.Params.VpnType = vpn.OpenVPN
@@ -662,6 +723,13 @@ func (c *CmdConnect) Run() (retError error) {
 				}
 				portStrInfo = "TCP"
 				destPort.tcp = true
+			} else if ptCfg.IsDefined() {
+				if ptCfg.RemotePort > 0 {
+					portStrInfo = fmt.Sprintf("TCP:%d", ptCfg.RemotePort)
+					destPort.tcp = true
+				} else if !destPort.tcp && len(c.multihopExitSvr) == 0 {
+					return flags.BadParameter{Message: "pluggable transport requires TCP port (use '-port tcp:PORT' or '-pt_port' option)"}
+				}
 			}
 
 			if len(c.multihopExitSvr) == 0 {
@@ -740,6 +808,29 @@ func (c *CmdConnect) Run() (retError error) {
 	return nil
 }
 
+func (c *CmdConnect) getPluggableTransportConfig() (ptransport.Config, error) {
+	if len(c.ptName) == 0 {
+		if len(c.ptPath) > 0 || len(c.ptArgs) > 0 || c.ptPort != 0 {
+			return ptransport.Config{}, flags.BadParameter{Message: "pluggable transport name is not defined (use '-pt' option)"}
+		}
+		return ptransport.Config{}, nil
+	}
+
+	if len(c.ptPath) == 0 {
+		return ptransport.Config{}, flags.BadParameter{Message: "path to the pluggable transport binary is not defined (use '-pt_path' option)"}
+	}
+	binPath, err := filepath.Abs(c.ptPath)
+	if err != nil {
+		return ptransport.Config{}, err
+	}
+
+	cfg := ptransport.Config{Name: c.ptName, BinaryPath: binPath, Args: c.ptArgs, RemotePort: c.ptPort}
+	if err := cfg.Validate(); err != nil {
+		return ptransport.Config{}, flags.BadParameter{Message: err.Error()}
+	}
+	return cfg, nil
+}
+
 func getPort(portInfo string, allowedPorts []apitypes.PortInfo) (port, error) {
 	var err error
 	var portPtr *int
@@ -28,6 +28,7 @@ import (
 	"runtime"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ivpn/desktop-app/cli/cliplatform"
 	"github.com/ivpn/desktop-app/cli/flags"
@@ -44,41 +45,81 @@ type CmdDns struct {
 	dns                  string
 	dohTemplate          string
 	dotTemplate          string
+	stamp                string
 	linuxManagementStyle string // LinuxDnsMgmt
+	splitAdd             string
+	splitRemove          string
+	stats                string
 }
 
 type LinuxDnsMgmt string
 
 const (
-	LinuxDnsMgmt_Auto       = "auto"
-	LinuxDnsMgmt_Resolvconf = "resolvconf"
+	LinuxDnsMgmt_Auto           = "auto"
+	LinuxDnsMgmt_Resolvconf     = "resolvconf"
+	LinuxDnsMgmt_Resolvectl     = dns.LinuxDnsMgmtResolvectl
+	LinuxDnsMgmt_ResolvedDbus   = dns.LinuxDnsMgmtResolvedDbus
+	LinuxDnsMgmt_NetworkManager = dns.LinuxDnsMgmtNetworkManager
 )
 const (
 	ArgName_Off        = "off"
 	ArgName_DoH        = "doh"
 	ArgName_DoT        = "dot"
+	ArgName_Stamp      = "stamp"
 	ArgName_Management = "management"
 	ArgName_Add        = "add"
+	ArgName_SplitAdd   = "split_add"
+	ArgName_SplitRem   = "split_remove"
+	ArgName_Stats      = "stats"
 )
 
-func IsParamApplicable_LinuxForceModifyResolvconf() (bool, error) {
-	// "force_use_resolvconf" is applicable only for linux AND only if both types of DNS management can be applied
+const statsShow = "show"
+
+// linuxDnsMgmtMethodError returns an error if the DNS management method is not applicable to the current environment
+func linuxDnsMgmtMethodError(method string) error {
 	if runtime.GOOS != "linux" {
-		return false, fmt.Errorf(fmt.Sprintf("functionality not applicable for %s", runtime.GOOS))
+		return fmt.Errorf("functionality not applicable for %s", runtime.GOOS)
+	}
+	if _proto == nil {
+		return nil
 	}
 
-	if _proto != nil {
-		hr := _proto.GetHelloResponse()
+	linuxFuncs := _proto.GetHelloResponse().DisabledFunctions.Platform.Linux
+	errText := ""
+	switch method {
+	case LinuxDnsMgmt_Auto:
+	case LinuxDnsMgmt_Resolvconf:
+		errText = linuxFuncs.DnsMgmtOldResolvconfError
+	case LinuxDnsMgmt_Resolvectl:
+		errText = linuxFuncs.DnsMgmtNewResolvectlError
+	case LinuxDnsMgmt_ResolvedDbus:
+		errText = linuxFuncs.DnsMgmtResolvedDbusError
+	case LinuxDnsMgmt_NetworkManager:
+		errText = linuxFuncs.DnsMgmtNetworkManagerError
+	default:
+		return fmt.Errorf("unknown DNS management method '%s'", method)
+	}
+	if len(errText) > 0 {
+		return fmt.Errorf(errText)
+	}
+	return nil
+}
 
-		if len(hr.DisabledFunctions.Platform.Linux.DnsMgmtOldResolvconfError) > 0 {
-			return false, fmt.Errorf(hr.DisabledFunctions.Platform.Linux.DnsMgmtOldResolvconfError)
-		}
+// IsParamApplicable_LinuxDnsManagement returns true when more than one DNS management method can be applied
+func IsParamApplicable_LinuxDnsManagement() (bool, error) {
+	if runtime.GOOS != "linux" {
+		return false, fmt.Errorf(fmt.Sprintf("functionality not applicable for %s", runtime.GOOS))
+	}
 
-		if len(hr.DisabledFunctions.Platform.Linux.DnsMgmtNewResolvectlError) > 0 {
-			return false, fmt.Errorf(hr.DisabledFunctions.Platform.Linux.DnsMgmtNewResolvectlError)
+	cnt := 0
+	for _, m := range []string{LinuxDnsMgmt_Resolvconf, LinuxDnsMgmt_Resolvectl, LinuxDnsMgmt_ResolvedDbus, LinuxDnsMgmt_NetworkManager} {
+		if linuxDnsMgmtMethodError(m) == nil {
+			cnt++
 		}
 	}
-
+	if cnt < 2 {
+		return false, fmt.Errorf("only one DNS management method is supported by the system")
+	}
 	return true, nil
 }
 
@@ -99,40 +140,67 @@ func getCurrentCustomDnsSettings() (dns.DnsSettings, error) {
 }
 
 func (c *CmdDns) Init() {
+	c.SetPreParseFunc(c.preParse)
 	c.Initialize("dns", "DNS management for VPN connection\nDNS_IP - optional parameter used to set custom dns value (ignored when AntiTracker enabled)")
 	c.DefaultStringVar(&c.dns, "DNS_IP")
 	c.BoolVar(&c.reset, ArgName_Off, false, "Reset DNS server to a default")
 
 	c.BoolVar(&c.add, ArgName_Add, false, "Add a custom DNS server to the existing custom DNS configuration (if any)\nExample: ivpn dns -add 8.8.8.8\n         ivpn dns -add -doh https://cloudflare-dns.com/dns-query 1.1.1.1")
 
+	c.StringVar(&c.splitAdd, ArgName_SplitAdd, "", "DOMAIN=DNS_IP[,DNS_IP]", "Add split DNS rule: queries for the domain (and all its subdomains) are sent only to the specified DNS servers\n  All other queries are sent to the VPN DNS (custom DNS or AntiTracker, if enabled)\n  Example: ivpn dns -split_add corp.example=192.168.1.10,192.168.1.11\n           ivpn dns -split_add lan=192.168.1.1")
+	c.StringVar(&c.splitRemove, ArgName_SplitRem, "", "DOMAIN", "Remove split DNS rule for the domain (use 'all' to remove all rules)\n  Example: ivpn dns -split_remove corp.example")
+
+	c.StringVar(&c.stats, ArgName_Stats, "", "[on|off]", "Show DNS query statistics of the current connection session (queries, blocked queries, top blocked domains, DNS servers latency)\n  The statistics are collected only when DNS queries are processed by the local DNS forwarder\n  (encrypted DNS, split DNS, local DNS filter)\n  on  - process all DNS queries by the local DNS forwarder to collect statistics (e.g. for AntiTracker)\n  off - use the local DNS forwarder only when required by DNS configuration\n  Example: ivpn dns -stats\n           ivpn dns -stats on")
+
 	if cliplatform.IsDnsOverHttpsSupported() {
 		c.StringVar(&c.dohTemplate, ArgName_DoH, "", "URI", "DNS-over-HTTPS URI template\n  Example: ivpn dns -doh https://cloudflare-dns.com/dns-query 1.1.1.1")
 	}
 	if cliplatform.IsDnsOverTlsSupported() {
 		c.StringVar(&c.dotTemplate, ArgName_DoT, "", "URI", "DNS-over-TLS URI template")
 	}
+	c.StringVar(&c.stamp, ArgName_Stamp, "", "STAMP", "DNS stamp of DNSCrypt, Oblivious DoH (ODoH) or DoH server (DNS_IP is optional when defined by the stamp)\n  Use 'sdns://RELAY/SERVER' to send queries via Anonymized DNSCrypt relay or ODoH relay\n  (the relay knows your IP address but can not read the queries; the server can read the queries but does not know your IP address)\n  ODoH target IP address must be defined by DNS_IP (it is in use only to request the target public key)\n  Example: ivpn dns -stamp sdns://AQcAAAAAAAAAEj...\n           ivpn dns -stamp sdns://hQcAAAAAAAAA.../BQcAAAAAAAAA... 1.2.3.4")
 
-	// "force_use_resolvconf" is applicable only for linux AND only if both types of DNS management can be applied
+	// "management" is applicable only for linux AND only if more than one type of DNS management can be applied
 	if runtime.GOOS == "linux" {
 		c.StringVarEx(&c.linuxManagementStyle, ArgName_Management, "", "METHOD",
 			fmt.Sprintf(`By default IVPN manages DNS resolvers using the 'systemd-resolved' daemon 
 		which is the correct method for systems based on Systemd. 
-		This option enables you to override this behavior and allow the IVPN app 
-		to directly modify the '/etc/resolv.conf' file. 		
+		This option enables you to override this behavior:
+		  %s - directly modify the '/etc/resolv.conf' file
+		  %s - use the 'resolvectl' utility of 'systemd-resolved'
+		  %s - use the D-Bus API of 'systemd-resolved'
+		  %s - use the global DNS configuration of NetworkManager (D-Bus API)
 		Note: This option is not applicable if there is only one DNS management method supported by the system.
-		Possible values: %s (default); %s
+		Possible values: %s (default); %s; %s; %s; %s
 			Example: 
 				'ivpn dns -management=%s' 
 				'ivpn dns -management=%s'`,
-				LinuxDnsMgmt_Auto, LinuxDnsMgmt_Resolvconf, LinuxDnsMgmt_Resolvconf, LinuxDnsMgmt_Auto),
+				LinuxDnsMgmt_Resolvconf, LinuxDnsMgmt_Resolvectl, LinuxDnsMgmt_ResolvedDbus, LinuxDnsMgmt_NetworkManager,
+				LinuxDnsMgmt_Auto, LinuxDnsMgmt_Resolvconf, LinuxDnsMgmt_Resolvectl, LinuxDnsMgmt_ResolvedDbus, LinuxDnsMgmt_NetworkManager,
+				LinuxDnsMgmt_NetworkManager, LinuxDnsMgmt_Auto),
 			func() bool {
-				ret, _ := IsParamApplicable_LinuxForceModifyResolvconf()
+				ret, _ := IsParamApplicable_LinuxDnsManagement()
 				return ret
 			})
 	}
 }
 
+func (c *CmdDns) preParse(arguments []string) ([]string, error) {
+	arguments, isArgRemoved := flags.RemoveArgIfNoValue(arguments, "-"+ArgName_Stats)
+	if isArgRemoved {
+		c.stats = statsShow
+	}
+	return arguments, nil
+}
+
 func (c *CmdDns) Run() error {
+	if len(c.stats) > 0 {
+		if c.NFlag() > 1 || len(c.dns) > 0 {
+			return flags.BadParameter{Message: fmt.Sprintf("Not allowed to combine '-%s' with other arguments", ArgName_Stats)}
+		}
+		return c.runStats()
+	}
+
 	if c.reset && len(c.dns) > 0 {
 		return flags.BadParameter{}
 	}
@@ -141,26 +209,39 @@ func (c *CmdDns) Run() error {
 		return flags.BadParameter{}
 	}
 
+	if len(c.stamp) > 0 && (c.reset || len(c.dohTemplate) > 0 || len(c.dotTemplate) > 0) {
+		return flags.BadParameter{Message: fmt.Sprintf("Not allowed to combine '-%s' with '-%s', '-%s' or '-%s'", ArgName_Stamp, ArgName_Off, ArgName_DoH, ArgName_DoT)}
+	}
+
 	hr := _proto.GetHelloResponse()
 	uPrefs := hr.DaemonSettings.UserPrefs
 
 	if len(c.linuxManagementStyle) > 0 {
-		if ret, err := IsParamApplicable_LinuxForceModifyResolvconf(); !ret {
+		if ret, err := IsParamApplicable_LinuxDnsManagement(); !ret {
 			return flags.BadParameter{Message: fmt.Sprintf("Option '-%s' is not applicable for current environment: %v", ArgName_Management, err)}
 		}
 
 		val := strings.TrimSpace(strings.ToLower(c.linuxManagementStyle))
-		if val != LinuxDnsMgmt_Auto && val != LinuxDnsMgmt_Resolvconf {
-			return flags.BadParameter{}
+		if err := linuxDnsMgmtMethodError(val); err != nil {
+			return flags.BadParameter{Message: fmt.Sprintf("Option '-%s=%s' is not applicable: %v", ArgName_Management, val, err)}
 		}
+
 		isForceResolvconf := val == LinuxDnsMgmt_Resolvconf
-		if uPrefs.Linux.IsDnsMgmtOldStyle != isForceResolvconf {
-			if isForceResolvconf {
+		method := ""
+		if val != LinuxDnsMgmt_Auto && !isForceResolvconf {
+			method = val
+		}
+		if uPrefs.Linux.IsDnsMgmtOldStyle != isForceResolvconf || uPrefs.Linux.DnsMgmtMethod != method {
+			switch {
+			case isForceResolvconf:
 				fmt.Print("Applying configuration: force the IVPN app to directly modify the '/etc/resolv.conf' file (when VPN connected)...\n\n")
-			} else {
+			case len(method) > 0:
+				fmt.Printf("Applying configuration: use '%s' DNS configuration management method (when VPN connected)...\n\n", method)
+			default:
 				fmt.Print("Applying configuration: use default DNS configuration management style (when VPN connected)...\n\n")
 			}
 			uPrefs.Linux.IsDnsMgmtOldStyle = isForceResolvconf
+			uPrefs.Linux.DnsMgmtMethod = method
 			if err := _proto.SetUserPreferences(uPrefs); err != nil {
 				return err
 			}
@@ -172,13 +253,25 @@ func (c *CmdDns) Run() error {
 		}
 	}
 
+	if len(c.splitAdd) > 0 || len(c.splitRemove) > 0 {
+		if c.reset || len(c.dns) > 0 || len(c.stamp) > 0 {
+			return flags.BadParameter{}
+		}
+		if err := c.updateSplitDnsRules(); err != nil {
+			return err
+		}
+	}
+
 	var servers *apitypes.ServersInfoResponse
 	// do we have to change custom DNS configuration ?
-	if c.reset || len(c.dns) > 0 {
-		// erase DNS settings
+	if c.reset || len(c.dns) > 0 || len(c.stamp) > 0 {
+		// erase DNS settings (split DNS rules are kept)
 		defManualDns := dns.DnsSettings{}
+		if defConnCfg, err := _proto.GetDefConnectionParams(); err == nil {
+			defManualDns.DomainRules = defConnCfg.Params.ManualDNS.DomainRules
+		}
 
-		if len(c.dns) > 0 {
+		if len(c.dns) > 0 || len(c.stamp) > 0 {
 			if c.add {
 				dns, err := getCurrentCustomDnsSettings()
 				if err != nil {
@@ -200,6 +293,10 @@ func (c *CmdDns) Run() error {
 				dnsSvr.Encryption = dns.EncryptionDnsOverTls
 				dnsSvr.Template = c.dotTemplate
 			}
+			if len(c.stamp) > 0 {
+				dnsSvr.Encryption = dns.EncryptionDnsStamp
+				dnsSvr.Template = c.stamp
+			}
 
 			if len(defManualDns.Servers) >= 4 {
 				return fmt.Errorf("the maximum allowed number of custom DNS servers is 4")
@@ -227,6 +324,9 @@ func (c *CmdDns) Run() error {
 			servers = &svrs
 		}
 		w = printDNSState(w, connected.Dns, servers)
+		if resolvers, err := _proto.DnsResolversHealthGet(); err == nil {
+			w = printDnsResolversHealth(w, resolvers)
+		}
 	} else {
 		defConnCfg, err := _proto.GetDefConnectionParams()
 		if err != nil {
@@ -239,6 +339,141 @@ func (c *CmdDns) Run() error {
 	return nil
 }
 
+func (c *CmdDns) runStats() error {
+	var (
+		stats dns.DnsStats
+		err   error
+	)
+	switch strings.ToLower(strings.TrimSpace(c.stats)) {
+	case statsShow:
+		stats, err = _proto.DnsStatsGet(0)
+	case "on":
+		stats, err = _proto.DnsStatsSetCollection(true)
+	case "off":
+		stats, err = _proto.DnsStatsSetCollection(false)
+	default:
+		return flags.BadParameter{Message: fmt.Sprintf("unexpected value of '-%s' argument (expected: on|off)", ArgName_Stats)}
+	}
+	if err != nil {
+		return err
+	}
+
+	printDnsStats(stats)
+	return nil
+}
+
+func printDnsStats(stats dns.DnsStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+
+	collection := "Only when the local DNS forwarder is required"
+	if stats.IsForced {
+		collection = "All DNS queries"
+	}
+	fmt.Fprintf(w, "Statistics collection\t:\t%s\n", collection)
+	if !stats.IsCollecting && stats.Queries == 0 {
+		fmt.Fprintf(w, "Statistics\t:\tNot available (DNS queries are not processed by the local DNS forwarder)\n")
+		w.Flush()
+		fmt.Printf("\nTip: use 'ivpn dns -%s on' to collect statistics for all DNS queries\n", ArgName_Stats)
+		return
+	}
+
+	fmt.Fprintf(w, "Since\t:\t%s\n", time.Unix(stats.Since, 0).Format(time.RFC1123))
+	fmt.Fprintf(w, "Queries\t:\t%d\n", stats.Queries)
+	fmt.Fprintf(w, "Answered from cache\t:\t%d\n", stats.CacheHits)
+	fmt.Fprintf(w, "Blocked by AntiTracker/DNS server\t:\t%d\n", stats.BlockedUpstream)
+	fmt.Fprintf(w, "Blocked by local DNS filter\t:\t%d\n", stats.BlockedLocal)
+	fmt.Fprintf(w, "NXDOMAIN answers\t:\t%d\n", stats.NxDomain)
+	fmt.Fprintf(w, "Failed\t:\t%d\n", stats.Failed)
+
+	for _, u := range stats.Upstreams {
+		fmt.Fprintf(w, "DNS server\t:\t%s\t(queries: %d; failures: %d; latency avg/max: %.1f/%.1f ms)\n", u.Server, u.Queries, u.Failures, u.AvgLatencyMs, u.MaxLatencyMs)
+	}
+	for _, d := range stats.TopBlocked {
+		fmt.Fprintf(w, "Top blocked\t:\t%s\t%d\n", d.Domain, d.Count)
+	}
+	w.Flush()
+}
+
+// printDnsResolversHealth prints the health status of DNS servers used by the local DNS forwarder (if it is in use)
+func printDnsResolversHealth(w *tabwriter.Writer, resolvers []dns.DnsResolverHealth) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	for _, r := range resolvers {
+		status := "OK"
+		if !r.IsHealthy {
+			status = "Unreachable"
+			if len(r.LastError) > 0 {
+				status += ": " + r.LastError
+			}
+		} else if r.LatencyMs > 0 {
+			status = fmt.Sprintf("OK (%.1f ms)", r.LatencyMs)
+		}
+		name := "    DNS server"
+		if r.IsFallback {
+			name = "    Fallback DNS"
+		}
+		active := ""
+		if r.IsActive {
+			active = " [active]"
+		}
+		fmt.Fprintf(w, "%s\t:\t%s%s\t%s\n", name, r.Server, active, status)
+	}
+
+	return w
+}
+
+// updateSplitDnsRules adds/removes split DNS rules (other DNS and AntiTracker settings are kept)
+func (c *CmdDns) updateSplitDnsRules() error {
+	defConnCfg, err := _proto.GetDefConnectionParams()
+	if err != nil {
+		return err
+	}
+	manualDns := defConnCfg.Params.ManualDNS
+
+	normalizeDomain := func(d string) string {
+		return strings.Trim(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*."), ".")
+	}
+
+	if len(c.splitRemove) > 0 {
+		domain := normalizeDomain(c.splitRemove)
+		var rules []dns.DnsDomainRule
+		if domain != "all" {
+			for _, r := range manualDns.DomainRules {
+				if normalizeDomain(r.Domain) != domain {
+					rules = append(rules, r)
+				}
+			}
+			if len(rules) == len(manualDns.DomainRules) {
+				return fmt.Errorf("split DNS rule for domain '%s' not found", c.splitRemove)
+			}
+		}
+		manualDns.DomainRules = rules
+	}
+
+	if len(c.splitAdd) > 0 {
+		cols := strings.SplitN(c.splitAdd, "=", 2)
+		if len(cols) != 2 {
+			return flags.BadParameter{Message: fmt.Sprintf("wrong format of '-%s' argument (expected: DOMAIN=DNS_IP[,DNS_IP])", ArgName_SplitAdd)}
+		}
+		newRule := dns.DnsDomainRule{Domain: normalizeDomain(cols[0]), Servers: splitList(cols[1])}
+		if err := newRule.ValidateAndNormalize(); err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
+		// replace existing rule for the same domain (if exists)
+		rules := []dns.DnsDomainRule{}
+		for _, r := range manualDns.DomainRules {
+			if normalizeDomain(r.Domain) != newRule.Domain {
+				rules = append(rules, r)
+			}
+		}
+		manualDns.DomainRules = append(rules, newRule)
+	}
+
+	return _proto.SetManualDNS(manualDns, defConnCfg.Params.Metadata.AntiTracker)
+}
+
 //----------------------------------------------------------------------------------------
 
 type CmdAntitracker struct {
@@ -247,6 +482,15 @@ type CmdAntitracker struct {
 	off        bool
 	hardcore   string
 	blocklists bool
+
+	localList        bool
+	localListAdd     string
+	localListRemove  string
+	localListUpdate  bool
+	localAllow       string
+	localAllowRemove string
+	localHost        string
+	localHostRemove  string
 }
 
 const EmptyBlockListName = "<default>"
@@ -265,6 +509,15 @@ func (c *CmdAntitracker) Init() {
 	c.StringVar(&c.hardcore, "on_hardcore", "", "[BLOCK_LIST]", "Enable AntiTracker 'hardcore' mode\n BLOCK_LIST - optional parameter used to set custom DNS block list\n "+tipText)
 	c.BoolVar(&c.off, "off", false, "Disable AntiTracker")
 	c.BoolVar(&c.blocklists, argNameShowBlocklists, false, "Show all supported DNS block lists")
+
+	c.BoolVar(&c.localList, "local-list", false, "Show local DNS filter configuration (user-defined block lists, allowlist and static hosts)\n The local DNS filter is applied by the daemon to all DNS requests while connected to VPN")
+	c.StringVar(&c.localListAdd, "local-list-add", "", "SOURCE", "Add local DNS block list\n SOURCE - absolute path to the file or http(s) URL (hosts-file format or list of domains)\n The remote block lists are cached on disk and updated once a day")
+	c.StringVar(&c.localListRemove, "local-list-remove", "", "SOURCE", "Remove local DNS block list\n SOURCE - block list path/URL or 'all'")
+	c.BoolVar(&c.localListUpdate, "local-list-update", false, "Download the remote DNS block lists now (even if the cached copies are up to date)")
+	c.StringVar(&c.localAllow, "local-allow", "", "DOMAIN", "Never block the domain (and its subdomains)\n The domain is not blocked by local block lists nor by AntiTracker")
+	c.StringVar(&c.localAllowRemove, "local-allow-remove", "", "DOMAIN", "Remove the domain from allowlist\n DOMAIN - domain name or 'all'")
+	c.StringVar(&c.localHost, "local-host", "", "DOMAIN=IP[,IP]", "Add static hosts entry\n (example: -local-host router.lan=192.168.1.1)")
+	c.StringVar(&c.localHostRemove, "local-host-remove", "", "DOMAIN", "Remove static hosts entry\n DOMAIN - domain name or 'all'")
 }
 
 func (c *CmdAntitracker) preParse(arguments []string) ([]string, error) {
@@ -281,6 +534,10 @@ func (c *CmdAntitracker) preParse(arguments []string) ([]string, error) {
 }
 
 func (c *CmdAntitracker) Run() error {
+	if c.isLocalFilterCommand() {
+		return c.runLocalFilter()
+	}
+
 	if c.NFlag() > 1 {
 		return flags.BadParameter{Message: "Not allowed to use more than one argument for this command"}
 	}
@@ -347,6 +604,140 @@ func (c *CmdAntitracker) Run() error {
 	return nil
 }
 
+func (c *CmdAntitracker) isLocalFilterCommand() bool {
+	return c.localList || c.localListUpdate ||
+		len(c.localListAdd) > 0 || len(c.localListRemove) > 0 ||
+		len(c.localAllow) > 0 || len(c.localAllowRemove) > 0 ||
+		len(c.localHost) > 0 || len(c.localHostRemove) > 0
+}
+
+// runLocalFilter modifies (if required) and prints the local DNS filter configuration
+func (c *CmdAntitracker) runLocalFilter() error {
+	if c.off || c.on != "" || c.hardcore != "" || c.blocklists {
+		return flags.BadParameter{Message: "Not allowed to combine local DNS filter arguments with other arguments"}
+	}
+
+	resp, err := _proto.DnsLocalFilterGet()
+	if err != nil {
+		return err
+	}
+
+	filter := resp.Filter
+	isChanged := false
+
+	removeFromList := func(list []string, val string, name string) ([]string, error) {
+		if strings.ToLower(strings.TrimSpace(val)) == "all" {
+			return nil, nil
+		}
+		ret := []string{}
+		for _, v := range list {
+			if !strings.EqualFold(v, strings.TrimSpace(val)) {
+				ret = append(ret, v)
+			}
+		}
+		if len(ret) == len(list) {
+			return nil, fmt.Errorf("%s '%s' not found", name, val)
+		}
+		return ret, nil
+	}
+
+	if len(c.localListRemove) > 0 {
+		if filter.Blocklists, err = removeFromList(filter.Blocklists, c.localListRemove, "block list"); err != nil {
+			return err
+		}
+		isChanged = true
+	}
+	if len(c.localListAdd) > 0 {
+		filter.Blocklists = append(filter.Blocklists, c.localListAdd)
+		isChanged = true
+	}
+
+	if len(c.localAllowRemove) > 0 {
+		domain := strings.Trim(strings.TrimPrefix(c.localAllowRemove, "*."), ".")
+		if filter.Allowlist, err = removeFromList(filter.Allowlist, domain, "allowlist domain"); err != nil {
+			return err
+		}
+		isChanged = true
+	}
+	if len(c.localAllow) > 0 {
+		filter.Allowlist = append(filter.Allowlist, c.localAllow)
+		isChanged = true
+	}
+
+	if len(c.localHostRemove) > 0 {
+		domain := strings.Trim(c.localHostRemove, ".")
+		var hosts []service_types.DnsHostEntry
+		if strings.ToLower(domain) != "all" {
+			for _, h := range filter.Hosts {
+				if !strings.EqualFold(h.Domain, domain) {
+					hosts = append(hosts, h)
+				}
+			}
+			if len(hosts) == len(filter.Hosts) {
+				return fmt.Errorf("hosts entry '%s' not found", c.localHostRemove)
+			}
+		}
+		filter.Hosts = hosts
+		isChanged = true
+	}
+	if len(c.localHost) > 0 {
+		cols := strings.SplitN(c.localHost, "=", 2)
+		if len(cols) != 2 || len(splitList(cols[1])) == 0 {
+			return flags.BadParameter{Message: "wrong format of '-local-host' argument (expected: DOMAIN=IP[,IP])"}
+		}
+		filter.Hosts = append(filter.Hosts, service_types.DnsHostEntry{Domain: cols[0], IPs: splitList(cols[1])})
+		isChanged = true
+	}
+
+	if isChanged {
+		if resp, err = _proto.DnsLocalFilterSet(filter); err != nil {
+			return err
+		}
+	}
+	if c.localListUpdate {
+		if resp, err = _proto.DnsLocalFilterUpdate(); err != nil {
+			return err
+		}
+	}
+
+	printDnsLocalFilter(resp.Filter, resp.Blocklists)
+	return nil
+}
+
+func printDnsLocalFilter(filter service_types.DnsLocalFilter, blocklists []service_types.DnsBlocklistStatus) {
+	if filter.IsEmpty() {
+		fmt.Println("Local DNS filter is not configured")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	for _, src := range filter.Blocklists {
+		status := ""
+		for _, s := range blocklists {
+			if s.Source != src {
+				continue
+			}
+			if len(s.Error) > 0 {
+				status = fmt.Sprintf("ERROR: %s", s.Error)
+			} else {
+				status = fmt.Sprintf("%d domains", s.Domains)
+			}
+			if s.Updated > 0 {
+				status += fmt.Sprintf(" (updated: %s)", time.Unix(s.Updated, 0).Format(time.RFC1123))
+			}
+			break
+		}
+		fmt.Fprintf(w, "Block list\t:\t%s\t%s\n", src, status)
+	}
+	for _, d := range filter.Allowlist {
+		fmt.Fprintf(w, "Allowed\t:\t%s\t\n", d)
+	}
+	for _, h := range filter.Hosts {
+		fmt.Fprintf(w, "Host\t:\t%s\t%s\n", h.Domain, strings.Join(h.IPs, ", "))
+	}
+	w.Flush()
+}
+
 //----------------------------------------------------------------------------------------
 
 func printDNSConfigInfo(w *tabwriter.Writer, customDNS dns.DnsSettings) *tabwriter.Writer {
@@ -358,18 +749,27 @@ func printDNSConfigInfo(w *tabwriter.Writer, customDNS dns.DnsSettings) *tabwrit
 		fmt.Fprintf(w, "Default config\t:\tCustom DNS %v\n", customDNS.InfoString())
 	} else {
 		fmt.Fprintf(w, "Default config\t:\tCustom DNS not defined\n")
+		printSplitDnsRules(w, customDNS)
 	}
 
-	if ret, _ := IsParamApplicable_LinuxForceModifyResolvconf(); ret && _proto != nil {
+	if ret, _ := IsParamApplicable_LinuxDnsManagement(); ret && _proto != nil {
 		hr := _proto.GetHelloResponse()
 		if hr.DaemonSettings.UserPrefs.Linux.IsDnsMgmtOldStyle {
 			fmt.Fprintf(w, "Management method\t:\tForce to modify the '/etc/resolv.conf' file\n")
+		} else if m := hr.DaemonSettings.UserPrefs.Linux.DnsMgmtMethod; len(m) > 0 {
+			fmt.Fprintf(w, "Management method\t:\t%s\n", m)
 		}
 	}
 
 	return w
 }
 
+func printSplitDnsRules(w *tabwriter.Writer, dnsCfg dns.DnsSettings) {
+	for _, r := range dnsCfg.DomainRules {
+		fmt.Fprintf(w, "    Split DNS\t:\t%s\n", r.InfoString())
+	}
+}
+
 func printAntitrackerConfigInfo(w *tabwriter.Writer, antitracker service_types.AntiTrackerMetadata) *tabwriter.Writer {
 	if w == nil {
 		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
@@ -24,8 +24,18 @@ package commands
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/ivpn/desktop-app/cli/flags"
+	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 )
 
 type CmdFirewall struct {
@@ -40,6 +50,13 @@ type CmdFirewall struct {
 	persistentOn       bool
 	persistentOff      bool
 	exceptions         string
+	drops              bool
+	appBlock           string
+	appAllow           string
+	appRemove          string
+	appsReset          bool
+	localIf            string
+	localIfRemove      string
 	//allowLanMulticast bool
 	//blockLanMulticast bool
 }
@@ -58,6 +75,13 @@ func (c *CmdFirewall) Init() {
 	c.BoolVar(&c.persistentOff, "persistent_off", false, "Persistent firewall (Always-on firewall): disable")
 	c.BoolVar(&c.persistentOn, "persistent_on", false, "Persistent firewall (Always-on firewall): enable. When the option is enabled the IVPN Firewall is started during system boot")
 	c.StringVar(&c.exceptions, "exceptions", StringValueNoData, "EXCEPTIONS", "Set configuration: comma-separated list of IP addresses or subnets (using CIDR notation)\nthat will be allowed through the firewall when enabled\nExamples:\n\tivpn firewall -exceptions '192.0.2.0/24, 198.51.100.1'\n\tivpn firewall -exceptions ''")
+	c.StringVarEx(&c.appBlock, "app_block", "", "PATH", "Block any outgoing communication of the application (take effect when firewall enabled)\nExample: ivpn firewall -app_block /usr/bin/telemetry-app", isFirewallAppsSupported)
+	c.StringVarEx(&c.appAllow, "app_allow", "", "PATH", "Allow outgoing communication of the application even when VPN is disconnected (take effect when firewall enabled)\nExample: ivpn firewall -app_allow /usr/bin/thunderbird", isFirewallAppsSupported)
+	c.StringVarEx(&c.appRemove, "app_remove", "", "PATH", "Remove the application from the blocked/allowed applications", isFirewallAppsSupported)
+	c.BoolVarEx(&c.appsReset, "apps_reset", false, "Remove all applications from the blocked/allowed applications", isFirewallAppsSupported)
+	c.StringVarEx(&c.localIf, "local_if", "", "NAME:MODE", "Set configuration for trusted local interface (e.g. bridge interface of containers or VMs)\nMODE:\n\tallow-local - allow communication between the interface and the host only\n\ttunnel - allow communication with the host and forward the interface traffic through the VPN tunnel\n\tblock - block any communication of the interface\nThe '+' suffix in NAME matches all interfaces with such name prefix\nExamples:\n\tivpn firewall -local_if docker0:tunnel\n\tivpn firewall -local_if virbr0:allow-local\n\tivpn firewall -local_if br-+:block", isFirewallLocalIfSupported)
+	c.StringVarEx(&c.localIfRemove, "local_if_remove", "", "NAME", "Remove the configuration for trusted local interface", isFirewallLocalIfSupported)
+	c.BoolVarEx(&c.drops, "drops", false, "Show recent outgoing packets blocked by the firewall (destination, process, packets count)", isFirewallDropsSupported)
 	//c.BoolVar(&c.allowLanMulticast, "lan_multicast_allow", false, "Same as 'lan_allow' + allow multicast communication ")
 	//c.BoolVar(&c.blockLanMulticast, "lan_multicast_block", false, "Same as 'lan_block' + block multicast communication")
 }
@@ -82,6 +106,10 @@ func (c *CmdFirewall) Run() error {
 	//	return flags.BadParameter{}
 	//}
 
+	if c.drops {
+		return c.printDrops()
+	}
+
 	if c.ivpnSvrAccessAllow {
 		if err := _proto.FirewallAllowApiServers(true); err != nil {
 			return err
@@ -112,6 +140,18 @@ func (c *CmdFirewall) Run() error {
 	//	}
 	//}
 
+	if len(c.appBlock) > 0 || len(c.appAllow) > 0 || len(c.appRemove) > 0 || c.appsReset {
+		if err := c.setAppsRules(); err != nil {
+			return err
+		}
+	}
+
+	if len(c.localIf) > 0 || len(c.localIfRemove) > 0 {
+		if err := c.setLocalInterfaces(); err != nil {
+			return err
+		}
+	}
+
 	if c.exceptions != StringValueNoData {
 		if err := _proto.FirewallSetUserExceptions(c.exceptions); err != nil {
 			return err
@@ -154,6 +194,8 @@ func (c *CmdFirewall) Run() error {
 	}
 
 	w := printFirewallState(nil, state.IsEnabled, state.IsPersistent, state.IsAllowLAN, state.IsAllowMulticast, state.IsAllowApiServers, state.UserExceptions, nil)
+	printFirewallAppsState(w, state.AppsBlocked, state.AppsAllowed)
+	printFirewallLocalInterfaces(w, state.LocalInterfaces)
 	w.Flush()
 
 	// TIPS
@@ -166,3 +208,173 @@ func (c *CmdFirewall) Run() error {
 	PrintTips(tips)
 	return nil
 }
+
+func isFirewallAppsSupported() bool {
+	return runtime.GOOS == "linux"
+}
+
+func (c *CmdFirewall) setAppsRules() error {
+	state, err := _proto.FirewallStatus()
+	if err != nil {
+		return err
+	}
+
+	removeApp := func(apps []string, app string) []string {
+		ret := make([]string, 0, len(apps))
+		for _, a := range apps {
+			if a != app {
+				ret = append(ret, a)
+			}
+		}
+		return ret
+	}
+	absPath := func(app string) (string, error) {
+		if filepath.IsAbs(app) {
+			return app, nil
+		}
+		// the application name: looking for the binary in PATH
+		return exec.LookPath(app)
+	}
+
+	blocked, allowed := state.AppsBlocked, state.AppsAllowed
+	if c.appsReset {
+		blocked, allowed = nil, nil
+	}
+	if len(c.appRemove) > 0 {
+		app, err := absPath(c.appRemove)
+		if err != nil {
+			return err
+		}
+		blocked, allowed = removeApp(blocked, app), removeApp(allowed, app)
+	}
+	if len(c.appBlock) > 0 {
+		app, err := absPath(c.appBlock)
+		if err != nil {
+			return err
+		}
+		blocked, allowed = append(removeApp(blocked, app), app), removeApp(allowed, app)
+	}
+	if len(c.appAllow) > 0 {
+		app, err := absPath(c.appAllow)
+		if err != nil {
+			return err
+		}
+		blocked, allowed = removeApp(blocked, app), append(removeApp(allowed, app), app)
+	}
+
+	return _proto.FirewallSetAppsRules(blocked, allowed)
+}
+
+func printFirewallAppsState(w *tabwriter.Writer, blockedApps, allowedApps []string) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	for i, app := range blockedApps {
+		title := ""
+		if i == 0 {
+			title = "    Blocked apps"
+		}
+		fmt.Fprintf(w, "%s\t:\t%v\n", title, app)
+	}
+	for i, app := range allowedApps {
+		title := ""
+		if i == 0 {
+			title = "    Allowed apps"
+		}
+		fmt.Fprintf(w, "%s\t:\t%v\n", title, app)
+	}
+
+	return w
+}
+
+func isFirewallLocalIfSupported() bool {
+	return runtime.GOOS == "linux"
+}
+
+func (c *CmdFirewall) setLocalInterfaces() error {
+	state, err := _proto.FirewallStatus()
+	if err != nil {
+		return err
+	}
+
+	ifaces := make([]service_types.LocalInterface, 0, len(state.LocalInterfaces)+1)
+	for _, i := range state.LocalInterfaces {
+		if i.Name != c.localIfRemove {
+			ifaces = append(ifaces, i)
+		}
+	}
+
+	if len(c.localIf) > 0 {
+		cols := strings.Split(c.localIf, ":")
+		if len(cols) != 2 {
+			return flags.BadParameter{Message: "local interface configuration expected in format NAME:MODE"}
+		}
+		mode, err := service_types.ParseLocalInterfaceMode(cols[1])
+		if err != nil {
+			return flags.BadParameter{Message: err.Error()}
+		}
+		// the daemon keeps the last configuration for the interface
+		ifaces = append(ifaces, service_types.LocalInterface{Name: strings.TrimSpace(cols[0]), Mode: mode})
+	}
+
+	return _proto.FirewallSetLocalInterfaces(ifaces)
+}
+
+func printFirewallLocalInterfaces(w *tabwriter.Writer, ifaces []service_types.LocalInterface) *tabwriter.Writer {
+	if w == nil {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	}
+
+	for i, iface := range ifaces {
+		title := ""
+		if i == 0 {
+			title = "    Local interfaces"
+		}
+		fmt.Fprintf(w, "%s\t:\t%s (%s)\n", title, iface.Name, iface.Mode)
+	}
+
+	return w
+}
+
+func isFirewallDropsSupported() bool {
+	return runtime.GOOS == "linux"
+}
+
+func (c *CmdFirewall) printDrops() error {
+	drops, err := _proto.FirewallBlockedTraffic()
+	if err != nil {
+		return err
+	}
+
+	if len(drops) == 0 {
+		fmt.Println("No blocked packets detected")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "LAST SEEN\tPROTOCOL\tDESTINATION\tPACKETS\tUID\tPID\tPROCESS\n")
+	for _, d := range drops {
+		dst := d.DstIP
+		if d.DstPort > 0 {
+			dst = net.JoinHostPort(d.DstIP, strconv.Itoa(d.DstPort))
+		}
+		uid := "-"
+		if d.Uid >= 0 {
+			uid = strconv.Itoa(d.Uid)
+		}
+		pid := "-"
+		if d.Pid > 0 {
+			pid = strconv.Itoa(d.Pid)
+		}
+		process := d.ProcessPath
+		if len(process) == 0 {
+			process = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			time.Unix(d.LastSeen, 0).Format("15:04:05"), d.Protocol, dst, d.Count, uid, pid, process)
+	}
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,105 @@
+//
+//  IVPN command line interface (CLI)
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the IVPN command line interface.
+//
+//  The IVPN command line interface is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The IVPN command line interface is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the IVPN command line interface. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ivpn/desktop-app/cli/flags"
+)
+
+type CmdLeakTest struct {
+	flags.CmdInfo
+	dnsServers string
+	probeHosts string
+	iface      string
+	query      string
+}
+
+func (c *CmdLeakTest) Init() {
+	c.Initialize("leaktest", "Check the current VPN connection for leaks (DNS, IPv6, routing, kill-switch)")
+	c.StringVar(&c.dnsServers, "dns_servers", "", "IP1,IP2...", "Comma-separated list of DNS resolvers to probe (default: public resolvers)")
+	c.StringVar(&c.probeHosts, "probe_hosts", "", "IP:PORT,...", "Comma-separated list of TCP hosts to probe (default: public hosts)")
+	c.StringVar(&c.iface, "interface", "", "NAME", "Name of the non-tunnel network interface (default: the interface of default gateway)")
+	c.StringVar(&c.query, "query", "", "DOMAIN", "Domain name to resolve in DNS probes")
+}
+
+func (c *CmdLeakTest) Run() error {
+	fmt.Println("Running leak test...")
+
+	report, err := _proto.LeakTest(splitList(c.dnsServers), splitList(c.probeHosts), c.iface, c.query)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "Firewall\t:\t%s\n", map[bool]string{true: "Enabled", false: "Disabled"}[report.IsFirewallEnabled])
+	if len(report.TunnelIPv4) > 0 {
+		fmt.Fprintf(w, "Tunnel IPv4\t:\t%s\n", report.TunnelIPv4)
+	}
+	if len(report.TunnelIPv6) > 0 {
+		fmt.Fprintf(w, "Tunnel IPv6\t:\t%s\n", report.TunnelIPv6)
+	}
+	if len(report.Interface) > 0 {
+		fmt.Fprintf(w, "Non-tunnel interface\t:\t%s\n", report.Interface)
+	}
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "CHECK\tSTATUS\tDETAILS\n")
+	for _, chk := range report.Checks {
+		details := chk.Details
+		if len(details) == 0 {
+			details = []string{""}
+		}
+		for i, d := range details {
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", chk.Name, chk.Status, d)
+			} else {
+				fmt.Fprintf(w, "\t\t%s\n", d)
+			}
+		}
+	}
+	w.Flush()
+	fmt.Println()
+
+	if !report.IsPassed() {
+		return fmt.Errorf("leak test FAILED")
+	}
+	fmt.Println("Leak test PASSED")
+	return nil
+}
+
+func splitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -173,6 +174,15 @@ type SplitTun struct {
 	appremove  string
 	appadd     string // this parameter is not in use. We need it just for help info (using 'appaddArgs' parsed with specific logic)
 	appaddArgs []string
+	appaddPath string
+
+	routeAdd    string
+	routeRemove string
+
+	userAdd     string
+	userRemove  string
+	groupAdd    string
+	groupRemove string
 }
 
 const (
@@ -201,9 +211,24 @@ func (c *SplitTun) Init() {
 		c.BoolVar(&c.statusFull, "status_full", false, "(extended status info) Show detailed Split Tunnel status")
 		c.BoolVar(&c.reset, "clean", false, "Erase configuration (remove applications from configuration and disable Split Tunnel)")
 		c.StringVar(&c.appadd, "appadd", "", "COMMAND", "Execute command (binary) in Split Tunnel environment\nInfo: short version of this command is 'ivpn exclude <command>'\nExamples:\n    ivpn splittun -appadd firefox\n    ivpn splittun -appadd ping 1.1.1.1\n    ivpn splittun -appadd /usr/bin/google-chrome")
-		c.StringVar(&c.appremove, "appremove", "", "PID", "Remove application from Split Tunnel environment\n(argument: Process ID)")
+		c.StringVar(&c.appaddPath, "appadd_path", "", "PATH", "Add application to configuration (path to binary or '.desktop' file)\nThe application will be added to Split Tunnel environment automatically each time it starts\nFlatpak and Snap applications can be defined by the sandbox identity ('flatpak:<application ID>' or 'snap:<snap name>')\nExamples:\n    ivpn splittun -appadd_path /usr/bin/firefox\n    ivpn splittun -appadd_path /usr/share/applications/firefox.desktop\n    ivpn splittun -appadd_path flatpak:org.mozilla.firefox\n    ivpn splittun -appadd_path snap:firefox")
+		c.StringVar(&c.appremove, "appremove", "", "PID|PATH", "Remove application from Split Tunnel environment (argument: Process ID)\nor remove application from configuration (argument: path defined by '-appadd_path')")
+		c.StringVar(&c.userAdd, "useradd", "", "USER", "Add user (name or UID) to configuration: all traffic of the user's processes\nbypasses the VPN tunnel (in Inverse mode: only such traffic uses the VPN tunnel)")
+		c.StringVar(&c.userRemove, "userremove", "", "USER", "Remove user from configuration")
+		c.StringVar(&c.groupAdd, "groupadd", "", "GROUP", "Add group (name or GID) to configuration: all traffic of the processes of the group\nbypasses the VPN tunnel (in Inverse mode: only such traffic uses the VPN tunnel)\n  The processes running with the group as primary group and the processes of the group members\n  (local members defined in '/etc/group', resolved when the configuration is applied) are affected")
+		c.StringVar(&c.groupRemove, "groupremove", "", "GROUP", "Remove group from configuration")
 	}
 
+	c.StringVar(&c.routeAdd, "route-add", "", "CIDR|DOMAIN",
+		`Add destination rule: the traffic to the network (or domain) always bypasses the VPN tunnel
+		(in Inverse mode: the traffic to the network always uses the VPN tunnel)
+		Domain names (including subdomains) are matched with the DNS answers while the VPN is connected:
+		the rule applies to the IP addresses from the answers until their TTL expires.
+		Examples:
+		    ivpn splittun -route-add 192.168.100.0/24
+		    ivpn splittun -route-add zoom.us`)
+	c.StringVar(&c.routeRemove, "route-remove", "", "CIDR|DOMAIN", "Remove destination rule")
+
 	c.BoolVar(&c.onInverse, cmd_name_on_inverse, false,
 		`Enable inverse mode. Only specified applications utilize the VPN connection,
 		while all other traffic circumvents the VPN, using the default connection.`)
@@ -238,7 +263,10 @@ func (c *SplitTun) Run() error {
 		return flags.ConflictingParameters{}
 	}
 
-	if len(c.appadd) > 0 && len(c.appremove) > 0 {
+	if (len(c.appadd) > 0 || len(c.appaddPath) > 0) && len(c.appremove) > 0 {
+		return flags.ConflictingParameters{}
+	}
+	if len(c.routeAdd) > 0 && len(c.routeRemove) > 0 {
 		return flags.ConflictingParameters{}
 	}
 
@@ -309,11 +337,73 @@ func (c *SplitTun) Run() error {
 		return c.doShowStatusShort(cfg)
 	}
 
-	if len(c.appaddArgs) > 0 || len(c.appremove) > 0 {
+	if len(c.userAdd) > 0 || len(c.userRemove) > 0 || len(c.groupAdd) > 0 || len(c.groupRemove) > 0 {
+		users, err := updateNamesList(cfg.Users, c.userAdd, c.userRemove, "user")
+		if err != nil {
+			return err
+		}
+		groups, err := updateNamesList(cfg.Groups, c.groupAdd, c.groupRemove, "group")
+		if err != nil {
+			return err
+		}
+		if err = _proto.SetSplitTunnelUsers(cfg.IsEnabled, cfg.IsInversed, cfg.IsAnyDns, cfg.IsAllowWhenNoVpn, users, groups); err != nil {
+			return err
+		}
+		cfg, err = _proto.GetSplitTunnelStatus()
+		if err != nil {
+			return err
+		}
+		return c.doShowStatus(cfg, false)
+	}
+
+	if len(c.routeAdd) > 0 || len(c.routeRemove) > 0 {
+		destinations := make([]string, 0, len(cfg.Destinations)+1)
+		isFound := false
+		for _, d := range cfg.Destinations {
+			if strings.EqualFold(d.Destination, c.routeRemove) {
+				isFound = true
+				continue
+			}
+			destinations = append(destinations, d.Destination)
+		}
+		if len(c.routeRemove) > 0 && !isFound {
+			return fmt.Errorf("destination rule '%s' not found", c.routeRemove)
+		}
+		if len(c.routeAdd) > 0 {
+			destinations = append(destinations, c.routeAdd)
+		}
+
+		if err = _proto.SetSplitTunnelDestinations(destinations); err != nil {
+			return err
+		}
+		cfg, err = _proto.GetSplitTunnelStatus()
+		if err != nil {
+			return err
+		}
+		return c.doShowStatus(cfg, false)
+	}
+
+	if len(c.appaddArgs) > 0 || len(c.appaddPath) > 0 || len(c.appremove) > 0 {
 		if len(c.appaddArgs) > 0 {
 			if err = doAddApp(c.appaddArgs, "", false); err != nil {
 				return err
 			}
+		} else if len(c.appaddPath) > 0 {
+			appPath := c.appaddPath
+			// sandbox identity of Flatpak/Snap application is passed to the daemon as is
+			if !strings.HasPrefix(appPath, "flatpak:") && !strings.HasPrefix(appPath, "snap:") {
+				if !strings.HasSuffix(appPath, ".desktop") {
+					if appPath, err = exec.LookPath(appPath); err != nil {
+						return err
+					}
+				}
+				if appPath, err = filepath.Abs(appPath); err != nil {
+					return err
+				}
+			}
+			if err = _proto.SplitTunnelAddAppPath(appPath); err != nil {
+				return err
+			}
 		} else if len(c.appremove) > 0 {
 			if err = _proto.SplitTunnelRemoveApp(c.appremove); err != nil {
 				return err
@@ -329,8 +419,30 @@ func (c *SplitTun) Run() error {
 	return c.doShowStatus(cfg, c.statusFull)
 }
 
+// updateNamesList returns the list with added and removed elements
+func updateNamesList(list []string, toAdd, toRemove, elementType string) ([]string, error) {
+	ret := make([]string, 0, len(list)+1)
+	isFound := false
+	for _, n := range list {
+		if n == toRemove {
+			isFound = true
+			continue
+		}
+		ret = append(ret, n)
+	}
+	if len(toRemove) > 0 && !isFound {
+		return nil, fmt.Errorf("%s '%s' not found in Split Tunnel configuration", elementType, toRemove)
+	}
+	if len(toAdd) > 0 {
+		ret = append(ret, toAdd)
+	}
+	return ret, nil
+}
+
 func (c *SplitTun) doShowStatus(cfg types.SplitTunnelStatus, isFull bool) error {
 	w := printSplitTunState(nil, false, isFull, cfg.IsEnabled, cfg.IsInversed, cfg.IsAnyDns, cfg.IsAllowWhenNoVpn, cfg.SplitTunnelApps, cfg.RunningApps)
+	printSplitTunUsers(w, cfg.Users, cfg.Groups)
+	printSplitTunDestinations(w, cfg.Destinations)
 	w.Flush()
 	return nil
 }
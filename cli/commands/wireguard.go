@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/ivpn/desktop-app/cli/flags"
+	service_types "github.com/ivpn/desktop-app/daemon/protocol/types"
 	"github.com/ivpn/desktop-app/daemon/service/srverrors"
 )
 
@@ -37,6 +38,7 @@ type CmdWireGuard struct {
 	state            bool
 	regenerate       bool
 	rotationInterval int
+	kemPolicy        string
 }
 
 func (c *CmdWireGuard) Init() {
@@ -44,6 +46,7 @@ func (c *CmdWireGuard) Init() {
 	c.BoolVar(&c.state, "status", false, "(default) Show WireGuard configuration")
 	c.IntVar(&c.rotationInterval, "rotation_interval", 0, "DAYS", "Set WireGuard keys rotation interval. [1-30] days")
 	c.BoolVar(&c.regenerate, "regenerate", false, "Regenerate WireGuard keys")
+	c.StringVar(&c.kemPolicy, "kem_policy", "", "POLICY", "Set KEM algorithms for post-quantum PresharedKey exchange (applied on next keys generation)\n  Format: <lattice>[+mceliece]; <lattice> is one of: mlkem1024, mlkem768, kyber1024\n  Examples: 'mlkem1024+mceliece', 'mlkem768', 'default' (kyber1024+mceliece)\n  ML-KEM is in use only when the server confirms it supports the algorithm (otherwise no PresharedKey)")
 }
 func (c *CmdWireGuard) Run() error {
 	if c.rotationInterval < 0 || c.rotationInterval > 30 {
@@ -68,6 +71,13 @@ func (c *CmdWireGuard) Run() error {
 		return fmt.Errorf("WireGuard functionality disabled:\n\t" + resp.DisabledFunctions.WireGuardError)
 	}
 
+	if len(c.kemPolicy) > 0 {
+		fmt.Printf("Changing KEM policy to '%s' ...\n", c.kemPolicy)
+		if err := _proto.SetPreferences(string(service_types.Prefs_WgKemPolicy), c.kemPolicy); err != nil {
+			return err
+		}
+	}
+
 	if c.regenerate {
 		fmt.Println("Regenerating WG keys...")
 		if err := c.generate(); err != nil {
@@ -117,6 +127,12 @@ func (c *CmdWireGuard) getState() error {
 	fmt.Fprintf(w, "Local IP:\t%v\n", resp.Session.WgLocalIP)
 	fmt.Fprintf(w, "Public KEY:\t%v\n", resp.Session.WgPublicKey)
 	fmt.Fprintf(w, "Quantum Resistance:\t%v\n", quantumResistanceStatus)
+	if kemPolicy := resp.DaemonSettings.WgKemPolicy; len(kemPolicy) > 0 {
+		fmt.Fprintf(w, "KEM policy:\t%v\n", kemPolicy)
+	}
+	if kemError := resp.Session.WgKemError; len(kemError) > 0 {
+		fmt.Fprintf(w, "KEM warning:\t%v\n", kemError)
+	}
 	fmt.Fprintf(w, "Generated:\t%v\n", time.Unix(resp.Session.WgKeyGenerated, 0))
 	fmt.Fprintf(w, "Rotation interval:\t%v\n", time.Duration(time.Second*time.Duration(resp.Session.WgKeysRegenInerval)))
 	w.Flush()
@@ -110,6 +110,7 @@ func main() {
 	addCommand(&commands.CmdWireGuard{})
 	addCommand(&commands.CmdDns{})
 	addCommand(&commands.CmdAntitracker{})
+	addCommand(&commands.CmdLeakTest{})
 	addCommand(&commands.CmdLogs{})
 	addCommand(&commands.CmdLogin{})
 	addCommand(&commands.CmdLogout{})
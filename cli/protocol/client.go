@@ -370,6 +370,153 @@ func (c *Client) FirewallStatus() (state types.KillSwitchStatusResp, err error)
 	return state, nil
 }
 
+// FirewallSetAppsRules set the lists of applications which outgoing communication must be blocked or allowed by the firewall
+func (c *Client) FirewallSetAppsRules(blockedApps, allowedApps []string) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.KillSwitchSetAppsRules{BlockedApps: blockedApps, AllowedApps: allowedApps}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FirewallSetLocalInterfaces set the configuration for trusted local interfaces
+func (c *Client) FirewallSetLocalInterfaces(ifaces []service_types.LocalInterface) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.KillSwitchSetLocalInterfaces{LocalInterfaces: ifaces}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FirewallBlockedTraffic requests info about recent outgoing packets dropped by the firewall
+func (c *Client) FirewallBlockedTraffic() ([]service_types.BlockedTrafficInfo, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	req := types.KillSwitchGetBlockedTraffic{}
+	var resp types.KillSwitchBlockedTrafficResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.BlockedTraffic, nil
+}
+
+// LeakTest runs diagnostic checks of the current VPN connection
+func (c *Client) LeakTest(probeDnsServers, probeHosts []string, iface, dnsQueryName string) (report service_types.LeakTestReport, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return report, err
+	}
+
+	req := types.LeakTest{ProbeDnsServers: probeDnsServers, ProbeHosts: probeHosts, Interface: iface, DnsQueryName: dnsQueryName}
+	var resp types.LeakTestResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return report, err
+	}
+
+	return resp.Report, nil
+}
+
+// DnsLocalFilterGet requests the local DNS filter configuration and the status of the block lists
+func (c *Client) DnsLocalFilterGet() (resp types.DnsLocalFilterResp, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return resp, err
+	}
+
+	req := types.DnsLocalFilterGet{}
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// DnsLocalFilterSet sets the local DNS filter configuration
+func (c *Client) DnsLocalFilterSet(filter service_types.DnsLocalFilter) (resp types.DnsLocalFilterResp, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return resp, err
+	}
+
+	req := types.DnsLocalFilterSet{Filter: filter}
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// DnsLocalFilterUpdate requests to download the remote DNS block lists
+func (c *Client) DnsLocalFilterUpdate() (resp types.DnsLocalFilterResp, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return resp, err
+	}
+
+	req := types.DnsLocalFilterUpdate{}
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// DnsStatsGet requests DNS query statistics of the current connection session
+func (c *Client) DnsStatsGet(topBlockedCount int) (stats dns.DnsStats, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return stats, err
+	}
+
+	req := types.DnsStatsGet{TopBlockedCount: topBlockedCount}
+	var resp types.DnsStatsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return stats, err
+	}
+
+	return resp.Stats, nil
+}
+
+// DnsResolversHealthGet requests the health status of DNS servers used by the local DNS forwarder
+func (c *Client) DnsResolversHealthGet() (resolvers []dns.DnsResolverHealth, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return resolvers, err
+	}
+
+	req := types.DnsResolversHealthGet{}
+	var resp types.DnsResolversHealthResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return resolvers, err
+	}
+
+	return resp.Resolvers, nil
+}
+
+// DnsStatsSetCollection enables/disables forced collection of DNS statistics
+func (c *Client) DnsStatsSetCollection(enable bool) (stats dns.DnsStats, err error) {
+	if err := c.ensureConnected(); err != nil {
+		return stats, err
+	}
+
+	req := types.DnsStatsSetCollection{Enable: enable}
+	var resp types.DnsStatsResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return stats, err
+	}
+
+	return resp.Stats, nil
+}
+
 // GetSplitTunnelStatus requests the Split-Tunnelling configuration
 func (c *Client) GetSplitTunnelStatus() (cfg types.SplitTunnelStatus, err error) {
 	if err := c.ensureConnected(); err != nil {
@@ -407,6 +554,36 @@ func (c *Client) SetSplitTunnelConfig(isEnable, isInversed, isAnyDns, isAllowWhe
 	return nil
 }
 
+// SetSplitTunnelUsers sets the users and groups which traffic is 'splitted' (the rest of configuration parameters are also required)
+func (c *Client) SetSplitTunnelUsers(isEnable, isInversed, isAnyDns, isAllowWhenNoVpn bool, users, groups []string) (err error) {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetConfig{IsEnabled: isEnable, IsInversed: isInversed, IsAnyDns: isAnyDns, IsAllowWhenNoVpn: isAllowWhenNoVpn, Users: &users, Groups: &groups}
+	resp := types.EmptyResp{}
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetSplitTunnelDestinations sets the Split Tunnel destination rules (CIDRs or domain names)
+func (c *Client) SetSplitTunnelDestinations(destinations []string) (err error) {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelSetDestinations{Destinations: destinations}
+	resp := types.EmptyResp{}
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) SplitTunnelAddApp(execCmd string) (isRequiredToExecuteCommand bool, retErr error) {
 	if err := c.ensureConnected(); err != nil {
 		return false, err
@@ -476,6 +653,21 @@ func (c *Client) SplitTunnelAddApp(execCmd string) (isRequiredToExecuteCommand b
 	return true, nil
 }
 
+// SplitTunnelAddAppPath adds the application (path to binary; Linux: or '.desktop' file) to the Split Tunnel configuration
+func (c *Client) SplitTunnelAddAppPath(path string) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+
+	req := types.SplitTunnelAddAppPath{Path: path}
+	var resp types.EmptyResp
+	if err := c.sendRecv(&req, &resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) SplitTunnelRemoveApp(cmdOrPid string) error {
 	if err := c.ensureConnected(); err != nil {
 		return err
@@ -59,6 +59,23 @@ OUT_IVPN_STAT_USER_EXP=IVPN-OUT-STAT-USER-EXP
 IN_IVPN_ICMP_EXP=IVPN-IN-ICMP-EXP
 OUT_IVPN_ICMP_EXP=IVPN-OUT-ICMP-EXP
 
+# chain for logging dropped packets (NFLOG); processed just before the final DROP rule
+# and before the DNS DROP rules (to log DNS leak attempts)
+# (the daemon collects info about dropped packets to show why the connectivity is blocked)
+OUT_IVPN_LOG=IVPN-OUT-LOG
+
+# chain for per-application rules (block/allow all outgoing communication of specific applications)
+# processed before any other IVPN rule
+IN_IVPN_APPS=IVPN-IN-APPS
+OUT_IVPN_APPS=IVPN-OUT-APPS
+
+# chains for trusted local interfaces (e.g. bridge interfaces of containers and VMs: docker0, virbr0, lxcbr0 ...)
+IN_IVPN_LOCAL_IF=IVPN-IN-LOCAL-IF
+OUT_IVPN_LOCAL_IF=IVPN-OUT-LOCAL-IF
+FORWARD_IVPN_LOCAL_IF=IVPN-FORWARD-LOCAL-IF
+# NAT chain for trusted local interfaces which traffic is forwarded through the VPN tunnel
+POSTROUTING_IVPN_LOCAL_IF=IVPN-POSTROUTING-LOCAL-IF
+
 # Chain to allow only specific DNS IP
 # (chain rules can be applied when the general "firewall" disabled, for example for Inverse Split Tunnel mode )
 IVPN_OUT_DNSONLY=IVPN-OUT-DNSONLY
@@ -72,6 +89,30 @@ _splittun_comment="IVPN Split Tunneling"
 # Split Tunnel cgroup id
 _splittun_cgroup_classid=0x4956504e
 
+# ### Per-application rules ###
+# The daemon moves processes of blocked/allowed applications into the dedicated cgroups (cgroup v2)
+# and applies nftables rules: packets of blocked applications are dropped,
+# connections of allowed applications are marked by the connection mark (must be the same as in the daemon sources)
+_apps_allowed_connmark=0x49564641
+# Per-application rules comment
+_apps_comment="IVPN App Firewall"
+
+# ### Trusted local interfaces ###
+# The mark for forwarded packets coming from local interfaces in 'tunnel' mode (such packets are masqueraded)
+_local_if_tunnel_mark=0x49564c49
+# Trusted local interfaces rules comment
+_local_if_comment="IVPN Local Interface"
+
+# ### Dropped packets logging ###
+# NFLOG group used to send info about dropped packets to the daemon
+# (must be the same as 'blockedTrafficNflogGroup' in the daemon sources)
+_drops_nflog_group=4956
+# NFLOG prefix for outgoing packets (must be the same as 'blockedTrafficNflogPrefixOut' in the daemon sources)
+_drops_nflog_prefix_out="IVPN-DROP-OUT"
+# Limit the number of logged packets (to avoid high CPU usage in case of intensive blocked traffic)
+_drops_log_limit="20/sec"
+_drops_log_limit_burst=50
+
 # returns 0 if chain exists
 function chain_exists()
 {
@@ -94,6 +135,95 @@ function clean_chain() {
   ${BIN} -w ${LOCKWAITTIME} -F ${CH}
 }
 
+# Add NFLOG rule for dropped packets
+# (not critical: NFLOG may be not supported by the kernel)
+function add_drops_log_rule() {
+  local bin=$1
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_LOG} -m limit --limit ${_drops_log_limit} --limit-burst ${_drops_log_limit_burst} -j NFLOG --nflog-group ${_drops_nflog_group} --nflog-prefix "${_drops_nflog_prefix_out}" || echo "Failed to add NFLOG rule (dropped packets logging)"
+}
+
+# Block all DNS requests which were not allowed by previous rules of OUT_IVPN_DNS chain
+# (the dropped requests are logged to OUT_IVPN_LOG)
+function add_dns_drop_rules() {
+  local bin=$1
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j ${OUT_IVPN_LOG}
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j ${OUT_IVPN_LOG}
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j DROP
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j DROP
+}
+
+# Add rules for allowed applications
+# (not critical: 'connmark' iptables module may be not available)
+function add_apps_rules() {
+  local bin=$1
+  ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_APPS} -m connmark --mark ${_apps_allowed_connmark} -m comment --comment "${_apps_comment}" -j ACCEPT || echo "Failed to add OUTPUT (connmark) rule for allowed applications"
+  ${bin} -w ${LOCKWAITTIME} -A ${IN_IVPN_APPS} -m connmark --mark ${_apps_allowed_connmark} -m comment --comment "${_apps_comment}" -j ACCEPT || echo "Failed to add INPUT (connmark) rule for allowed applications"
+}
+
+# Apply rules for trusted local interfaces
+# Arguments: list of "<interface>:<mode>" pairs; mode: "allow-local", "tunnel" or "block"
+function set_local_interfaces() {
+  clean_chain ${IPv4BIN} ${IN_IVPN_LOCAL_IF}
+  clean_chain ${IPv4BIN} ${OUT_IVPN_LOCAL_IF}
+  clean_chain ${IPv4BIN} ${FORWARD_IVPN_LOCAL_IF}
+  ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -F ${POSTROUTING_IVPN_LOCAL_IF} &>/dev/null
+  if [ -f /proc/net/if_inet6 ]; then
+    clean_chain ${IPv6BIN} ${IN_IVPN_LOCAL_IF}
+    clean_chain ${IPv6BIN} ${OUT_IVPN_LOCAL_IF}
+    clean_chain ${IPv6BIN} ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -F ${POSTROUTING_IVPN_LOCAL_IF} &>/dev/null
+  fi
+
+  local is_tunnel_mode=0
+  for iface_cfg in "$@"; do
+    local iface=${iface_cfg%%:*}
+    local mode=${iface_cfg#*:}
+    [ -z "${iface}" ] && continue
+
+    local bins=${IPv4BIN}
+    if [ -f /proc/net/if_inet6 ]; then
+      bins="${IPv4BIN} ${IPv6BIN}"
+    fi
+
+    for bin in ${bins}; do
+      if [[ ${mode} = "block" ]]; then
+        ${bin} -w ${LOCKWAITTIME} -A ${IN_IVPN_LOCAL_IF} -i ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+        ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_LOCAL_IF} -o ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+        ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -i ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+        ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -o ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+        continue
+      fi
+
+      # "allow-local" and "tunnel": allow communication between the host and the interface
+      ${bin} -w ${LOCKWAITTIME} -A ${IN_IVPN_LOCAL_IF} -i ${iface} -m comment --comment "${_local_if_comment}" -j ACCEPT
+      ${bin} -w ${LOCKWAITTIME} -A ${OUT_IVPN_LOCAL_IF} -o ${iface} -m comment --comment "${_local_if_comment}" -j ACCEPT
+      # allow communication inside the interface network (e.g. container-to-container when 'br_netfilter' is loaded)
+      ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -i ${iface} -o ${iface} -m comment --comment "${_local_if_comment}" -j ACCEPT
+
+      if [[ ${mode} = "tunnel" ]]; then
+        # mark forwarded packets; they will be masqueraded (if the VPN interface rules allow them to be forwarded)
+        ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -i ${iface} -m comment --comment "${_local_if_comment}" -j MARK --set-mark ${_local_if_tunnel_mark}
+        is_tunnel_mode=1
+      else
+        # "allow-local": no forwarding to/from other interfaces (including the VPN interface)
+        ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -i ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+        ${bin} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -o ${iface} -m comment --comment "${_local_if_comment}" -j DROP
+      fi
+    done
+  done
+
+  if (( ${is_tunnel_mode} == 1 )); then
+    ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -A ${POSTROUTING_IVPN_LOCAL_IF} -m mark --mark ${_local_if_tunnel_mark} -m comment --comment "${_local_if_comment}" -j MASQUERADE || echo "Failed to add NAT rule for local interfaces"
+    if [ -f /proc/net/if_inet6 ]; then
+      # IPv6 'nat' table may be not available: block IPv6 forwarding in this case (otherwise, it is not masqueraded)
+      if ! ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -A ${POSTROUTING_IVPN_LOCAL_IF} -m mark --mark ${_local_if_tunnel_mark} -m comment --comment "${_local_if_comment}" -j MASQUERADE; then
+        echo "Failed to add IPv6 NAT rule for local interfaces (IPv6 forwarding blocked)"
+        ${IPv6BIN} -w ${LOCKWAITTIME} -A ${FORWARD_IVPN_LOCAL_IF} -m mark --mark ${_local_if_tunnel_mark} -m comment --comment "${_local_if_comment}" -j DROP
+      fi
+    fi
+  fi
+}
+
 # Checks if the IVPN Firewall is enabled
 # 0 - if enabled
 # 1 - if not enabled
@@ -187,13 +317,21 @@ function enable_firewall {
       create_chain ${IPv6BIN} ${IN_IVPN_STAT_USER_EXP}
       create_chain ${IPv6BIN} ${OUT_IVPN_STAT_USER_EXP}
 
+      create_chain ${IPv6BIN} ${OUT_IVPN_LOG}
+
+      create_chain ${IPv6BIN} ${IN_IVPN_APPS}
+      create_chain ${IPv6BIN} ${OUT_IVPN_APPS}
+
+      create_chain ${IPv6BIN} ${IN_IVPN_LOCAL_IF}
+      create_chain ${IPv6BIN} ${OUT_IVPN_LOCAL_IF}
+      create_chain ${IPv6BIN} ${FORWARD_IVPN_LOCAL_IF}
+
       # block DNS for IPv6
       #
       # Important: Block DNS before allowing link-local and unique-localaddresses!
       # It will prevent potential DNS leaking in some situations (for example, from VM to a host machine)
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_DNS}
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j DROP
-      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j DROP
+      add_dns_drop_rules ${IPv6BIN}
 
       # IPv6: allow  local (lo) interface
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -o lo -j ACCEPT
@@ -222,6 +360,20 @@ function enable_firewall {
       ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
       ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
       ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT  || echo "Failed to add INPUT (mark) rule for split-tunnel"
+      # Split Tunnel (native implementation for cgroup v2): packets of split tunnel processes are marked by nftables rules
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (mark) rule for split-tunnel"
+
+      # IPv6: trusted local interfaces
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -j ${OUT_IVPN_LOCAL_IF}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -j ${IN_IVPN_LOCAL_IF}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOCAL_IF}
+      # IPv6: NAT for local interfaces in 'tunnel' mode (not critical: 'nat' table may be not available)
+      (${IPv6BIN} -w ${LOCKWAITTIME} -t nat -N ${POSTROUTING_IVPN_LOCAL_IF} 2>/dev/null; ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -I POSTROUTING -j ${POSTROUTING_IVPN_LOCAL_IF}) || echo "Failed to add IPv6 NAT chain for local interfaces"
+
+      # IPv6: per-application rules (must be processed before any other rule)
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -j ${OUT_IVPN_APPS}
+      ${IPv6BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -j ${IN_IVPN_APPS}
+      add_apps_rules ${IPv6BIN}
 
       # exceptions
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF0}
@@ -233,6 +385,10 @@ function enable_firewall {
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
       ${IPv6BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
 
+      # IPv6: log dropped packets
+      ${IPv6BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+      add_drops_log_rule ${IPv6BIN}
+
       # IPv6: block everything by default
       ${IPv6BIN} -w ${LOCKWAITTIME} -P INPUT DROP
       ${IPv6BIN} -w ${LOCKWAITTIME} -P OUTPUT DROP
@@ -276,6 +432,15 @@ function enable_firewall {
     create_chain ${IPv4BIN} ${IN_IVPN_ICMP_EXP}
     create_chain ${IPv4BIN} ${OUT_IVPN_ICMP_EXP}
 
+    create_chain ${IPv4BIN} ${OUT_IVPN_LOG}
+
+    create_chain ${IPv4BIN} ${IN_IVPN_APPS}
+    create_chain ${IPv4BIN} ${OUT_IVPN_APPS}
+
+    create_chain ${IPv4BIN} ${IN_IVPN_LOCAL_IF}
+    create_chain ${IPv4BIN} ${OUT_IVPN_LOCAL_IF}
+    create_chain ${IPv4BIN} ${FORWARD_IVPN_LOCAL_IF}
+
     # allow  local (lo) interface
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -o lo -j ACCEPT
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -i lo -j ACCEPT
@@ -301,6 +466,20 @@ function enable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (cgroup) rule for split-tunnel"
     ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m cgroup --cgroup ${_splittun_cgroup_classid} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (cgroup) rule for split-tunnel"  # this rule is not effective, so we use 'mark' (see the next rule)
     ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add INPUT (mark) rule for split-tunnel"
+    # Split Tunnel (native implementation for cgroup v2): packets of split tunnel processes are marked by nftables rules
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -m mark --mark ${_splittun_packets_fwmark_value} -m comment --comment  "${_splittun_comment}" -j ACCEPT || echo "Failed to add OUTPUT (mark) rule for split-tunnel"
+
+    # trusted local interfaces
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -j ${OUT_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -j ${IN_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOCAL_IF}
+    # NAT for local interfaces in 'tunnel' mode (not critical: 'nat' table may be not available)
+    (${IPv4BIN} -w ${LOCKWAITTIME} -t nat -N ${POSTROUTING_IVPN_LOCAL_IF} 2>/dev/null; ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -I POSTROUTING -j ${POSTROUTING_IVPN_LOCAL_IF}) || echo "Failed to add NAT chain for local interfaces"
+
+    # per-application rules (must be processed before any other rule)
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${OUT_IVPN} -j ${OUT_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -I ${IN_IVPN} -j ${IN_IVPN_APPS}
+    add_apps_rules ${IPv4BIN}
 
     # exceptions (must be processed before OUT_IVPN_DNS!)
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF0}
@@ -309,8 +488,7 @@ function enable_firewall {
     # block DNS by default
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_DNS}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_DNS}
-    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j DROP
-    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j DROP
+    add_dns_drop_rules ${IPv4BIN}
 
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_IF1}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_IF1}
@@ -323,6 +501,10 @@ function enable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -A ${IN_IVPN} -j ${IN_IVPN_ICMP_EXP}
 
+    # log dropped packets
+    ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    add_drops_log_rule ${IPv4BIN}
+
     # block everything by default
     ${IPv4BIN} -w ${LOCKWAITTIME} -P INPUT DROP
     ${IPv4BIN} -w ${LOCKWAITTIME} -P OUTPUT DROP
@@ -375,6 +557,13 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_ICMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -D POSTROUTING -j ${POSTROUTING_IVPN_LOCAL_IF}
 
     # '-F' Delete all rules in  chain or all chains
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_IF0}
@@ -393,6 +582,13 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_ICMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -F ${POSTROUTING_IVPN_LOCAL_IF}
     # '-X' Delete a user-defined chain
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_IF0}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_IF0}    
@@ -410,6 +606,13 @@ function disable_firewall {
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_USER_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_ICMP_EXP}
     ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_ICMP_EXP}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOG}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_APPS}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv4BIN} -w ${LOCKWAITTIME} -t nat -X ${POSTROUTING_IVPN_LOCAL_IF}
 
     ### IPv6 ###
     ${IPv6BIN} -w ${LOCKWAITTIME} -D OUTPUT -j ${OUT_IVPN}
@@ -425,6 +628,13 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${OUT_IVPN} -j ${OUT_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${IN_IVPN} -j ${IN_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -D ${FORWARD_IVPN} -j ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -D POSTROUTING -j ${POSTROUTING_IVPN_LOCAL_IF}
 
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_IF0}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_IF0}    
@@ -439,6 +649,13 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${OUT_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${IN_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -F ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -F ${POSTROUTING_IVPN_LOCAL_IF}
 
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_IF0}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_IF0}    
@@ -453,6 +670,14 @@ function disable_firewall {
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_STAT_USER_EXP}
     ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_STAT_USER_EXP}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOG}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_APPS}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${OUT_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${IN_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -X ${FORWARD_IVPN_LOCAL_IF}
+    ${IPv6BIN} -w ${LOCKWAITTIME} -t nat -X ${POSTROUTING_IVPN_LOCAL_IF}
+
     echo "IVPN Firewall disabled"
 }
 
@@ -647,8 +872,7 @@ function main {
       fi
 
       # then block everything else
-      ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p udp --dport 53 -j DROP
-      ${IPv4BIN} -w ${LOCKWAITTIME} -A ${OUT_IVPN_DNS} -p tcp --dport 53 -j DROP
+      add_dns_drop_rules ${IPv4BIN}
 
     # icmp exceptions
     elif [[ $1 = "-add_exceptions_icmp" ]]; then
@@ -697,6 +921,13 @@ function main {
     elif [[ $1 = "-only_dns_off" ]]; then
       only_dns_off
 
+    elif [[ $1 = "-set_local_interfaces" ]]; then
+
+      get_firewall_enabled || return 0
+
+      shift
+      set_local_interfaces $@
+
     else
         echo "Unknown command"
         return 2
@@ -28,7 +28,7 @@ else
   then
       echo "[!] GLIBC version '${GLIBC_VER}' is newer than required '${GLIBC_VER_MAX_REQUIRED}'"
       echo "[!]     Binaries compiled with newer GLIBC will not run on systems with older GLIBC versions."
-      echo "[!]     e.g. this affects: 'wg', 'obfs4proxy', 'dnscrypt-proxy', 'v2ray', and 'kem-helper' binaries"
+      echo "[!]     e.g. this affects: 'wg', 'obfs4proxy', 'v2ray', 'amneziawg-go' and 'kem-helper' binaries"
       echo "[ ]     (to skip this check, set environment variable: IVPN_BUILD_SKIP_GLIBC_VER_CHECK=1)"
       read -p "[?] Continue with potentially incompatible build? [y/N]: " yn
       case $yn in
@@ -56,7 +56,7 @@ else
 fi
 
 # check if we need to compile wireguard-tools
-if [[ ! -f "../_deps/wireguard-tools_inst/wg-quick" ]] || [[ ! -f "../_deps/wireguard-tools_inst/wg" ]]
+if [[ ! -f "../_deps/wireguard-tools_inst/wg" ]]
 then
   echo "======================================================"
   echo "========== Compiling wireguard-tools ================="
@@ -67,36 +67,44 @@ else
   echo " - 'wireguard-tools' already compiled. Skipping build."
 fi
 
-# check if we need to compile dnscrypt-proxy
-if [[ ! -f "../_deps/dnscryptproxy_inst/dnscrypt-proxy" ]] 
+# check if we need to compile v2ray
+if [[ ! -f "../_deps/v2ray_inst/v2ray" ]]
 then
   echo "======================================================"
-  echo "========== Compiling dnscrypt-proxy =================="
+  echo "========== Compiling v2ray ==========================="
   echo "======================================================"
   cd $SCRIPT_DIR
-  ./build-dnscrypt-proxy.sh
+
+  if [ ! -z "$GITHUB_ACTIONS" ]; 
+  then
+    echo "! GITHUB_ACTIONS detected ! It is just a build test."
+    echo "! Skipped compilation of V2Ray !"
+  else
+    ./build-v2ray.sh
+  fi
+
 else
-  echo " - 'dnscrypt-proxy' already compiled. Skipping build."
+  echo " - 'v2ray' already compiled. Skipping build."
 fi
 
-# check if we need to compile v2ray
-if [[ ! -f "../_deps/v2ray_inst/v2ray" ]]
+# check if we need to compile amneziawg-go
+if [[ ! -f "../_deps/amneziawg_inst/amneziawg-go" ]]
 then
   echo "======================================================"
-  echo "========== Compiling v2ray ==========================="
+  echo "========== Compiling amneziawg-go ===================="
   echo "======================================================"
   cd $SCRIPT_DIR
 
   if [ ! -z "$GITHUB_ACTIONS" ]; 
   then
     echo "! GITHUB_ACTIONS detected ! It is just a build test."
-    echo "! Skipped compilation of V2Ray !"
+    echo "! Skipped compilation of amneziawg-go !"
   else
-    ./build-v2ray.sh
+    ./build-amneziawg.sh
   fi
 
 else
-  echo " - 'v2ray' already compiled. Skipping build."
+  echo " - 'amneziawg-go' already compiled. Skipping build."
 fi
 
 # check if we need to compile kem-helper
@@ -129,8 +137,8 @@ echo "[+] Checking GLIBC version required by compiled binaries ..."
 BINARIES=(
     "$SCRIPT_DIR/../_deps/wireguard-tools_inst/wg"
     "$SCRIPT_DIR/../_deps/obfs4proxy_inst/obfs4proxy" 
-    "$SCRIPT_DIR/../_deps/dnscryptproxy_inst/dnscrypt-proxy"
     "$SCRIPT_DIR/../_deps/v2ray_inst/v2ray"
+    "$SCRIPT_DIR/../_deps/amneziawg_inst/amneziawg-go"
     "$SCRIPT_DIR/../_deps/kem-helper/kem-helper-bin/kem-helper"
     "$SCRIPT_DIR/_out_bin/ivpn-service"
 )
@@ -31,7 +31,6 @@ make
 
 echo "******** Copying 'wireguard-tools' binaries..."
 cp ${BUILD_DIR}/wireguard-tools/src/wg ${INSTALL_DIR}
-cp ${BUILD_DIR}/wireguard-tools/src/wg-quick/linux.bash ${INSTALL_DIR}/wg-quick
 
 echo "********************************"
 echo "******** BUILD COMPLETE ********"
@@ -80,12 +80,12 @@ function BuildV2Ray
   ./build-v2ray.sh
 }
 
-function BuildDnscryptProxy
+function BuildAmneziaWG
 {
   echo "############################################"
-  echo "### dnscrypt-proxy"
+  echo "### AmneziaWG"
   echo "############################################"
-  ./build-dnscrypt-proxy.sh
+  ./build-amneziawg.sh
 }
 
 function BuildKemHelper
@@ -98,7 +98,7 @@ function BuildKemHelper
 
 if [ ! -z "$GITHUB_ACTIONS" ]; then
   echo "! GITHUB_ACTIONS detected ! It is just a build test."
-  echo "! Skipped compilation of third-party dependencies: OpenVPN, WireGuard, obfs4proxy, dnscrypt-proxy ..."
+  echo "! Skipped compilation of third-party dependencies: OpenVPN, WireGuard, obfs4proxy ..."
 else
   if [[ "$@" == *"-norebuild"* ]]
   then
@@ -138,13 +138,13 @@ else
         echo "V2Ray already compiled. Skipping build."
       fi
 
-      # check if we need to compile dnscrypt-proxy
-      if [[ ! -f "../_deps/dnscryptproxy_inst/dnscrypt-proxy" ]]
+      # check if we need to compile amneziawg-go
+      if [[ ! -f "../_deps/amneziawg_inst/amneziawg-go" ]]
       then
-        echo "dnscrypt-proxy not compiled"
-        BuildDnscryptProxy
+        echo "AmneziaWG not compiled"
+        BuildAmneziaWG
       else
-        echo "dnscrypt-proxy already compiled. Skipping build."
+        echo "AmneziaWG already compiled. Skipping build."
       fi
 
       # check if we need to compile kem-helper
@@ -157,12 +157,12 @@ else
       fi
 
   else
-    # recompile openvpn, WireGuard, obfs4proxy, dnscrypt-proxy ...
+    # recompile openvpn, WireGuard, obfs4proxy ...
     BuildOpenVPN
     BuildWireGuard
     BuildObfs4proxy
     BuildV2Ray
-    BuildDnscryptProxy
+    BuildAmneziaWG
     BuildKemHelper
   fi
 fi
@@ -0,0 +1,30 @@
+#!/bin/sh
+
+AMNEZIAWG_VER=v0.2.12
+
+# Exit immediately if a command exits with a non-zero status.
+set -e
+
+cd "$(dirname "$0")"
+BASE_DIR="$(pwd)" #set base folder of script location
+
+BUILD_DIR=${BASE_DIR}/../_deps/amneziawg_build # work directory
+INSTALL_DIR=${BUILD_DIR}/../amneziawg_inst
+
+echo "******** Creating work-folder (${BUILD_DIR})..."
+rm -rf ${BUILD_DIR}
+rm -rf ${INSTALL_DIR}
+mkdir -pv ${BUILD_DIR}
+mkdir -pv ${INSTALL_DIR}
+
+echo "******** Cloning amneziawg-go sources..."
+cd ${BUILD_DIR}
+git clone  --depth 1 --branch ${AMNEZIAWG_VER} https://github.com/amnezia-vpn/amneziawg-go.git
+cd amneziawg-go
+
+echo "******** Compiling 'amneziawg-go'..."
+go build -o ${INSTALL_DIR}/amneziawg-go -trimpath -ldflags "-s -w"
+
+echo "********************************"
+echo "******** BUILD COMPLETE ********"
+echo "********************************"
@@ -24,9 +24,10 @@ package types
 
 // KemPublicKeys in use for KEM: to exchange WG PresharedKey
 type KemPublicKeys struct {
-	KemPublicKey_Kyber1024             string `json:"kem_public_key1,omitempty"`
+	KemPublicKey_Lattice               string `json:"kem_public_key1,omitempty"` // lattice-based KEM (ML-KEM-1024, ML-KEM-768 or Kyber1024)
+	KemAlgorithm_Lattice               string `json:"kem_algorithm1,omitempty"`  // algorithm of 'kem_public_key1' (empty for Kyber1024, for compatibility; servers which do not know the field are using Kyber1024)
 	KemPublicKey_ClassicMcEliece348864 string `json:"kem_public_key2,omitempty"`
-	KemLibraryVersion                  string `json:"kem_library_version,omitempty"`
+	KemLibraryVersion                  string `json:"kem_library_version,omitempty"` // KEM implementation (see kem.KemHelper.GetLibraryVersion())
 }
 
 // SessionNewRequest request to create new session
@@ -53,8 +53,9 @@ type ServiceStatusAPIResp struct {
 
 // KemCiphers in use for KEM: to exchange WG PresharedKey
 type KemCiphers struct {
-	KemCipher_Kyber1024             string `json:"kem_cipher1,omitempty"` // (Kyber-1024) in use for KEM: to exchange WG PresharedKey
-	KemCipher_ClassicMcEliece348864 string `json:"kem_cipher2,omitempty"` // (Classic-McEliece-348864) in use for KEM: to exchange WG PresharedKey
+	KemCipher_Lattice               string `json:"kem_cipher1,omitempty"`    // (ML-KEM-1024, ML-KEM-768 or Kyber-1024) in use for KEM: to exchange WG PresharedKey
+	KemAlgorithm_Lattice            string `json:"kem_algorithm1,omitempty"` // algorithm used by the server for 'kem_cipher1' (empty for Kyber-1024; if it does not match the request - the client falls back to Kyber-1024)
+	KemCipher_ClassicMcEliece348864 string `json:"kem_cipher2,omitempty"`    // (Classic-McEliece-348864) in use for KEM: to exchange WG PresharedKey
 }
 
 // SessionNewResponse information about created session
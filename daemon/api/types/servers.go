@@ -25,6 +25,8 @@ package types
 import (
 	"fmt"
 	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/awg"
 )
 
 // -----------------------------------------------------------
@@ -76,6 +78,7 @@ type WireGuardServerHostInfo struct {
 	PublicKey string                      `json:"public_key"`
 	LocalIP   string                      `json:"local_ip"`
 	IPv6      WireGuardServerHostInfoIPv6 `json:"ipv6"`
+	AmneziaWG *awg.Params                 `json:"amneziawg,omitempty"` // AmneziaWG obfuscation parameters (nil - obfuscation not supported by the host)
 }
 
 // WireGuardServerInfo contains all info about WG server
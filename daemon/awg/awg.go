@@ -0,0 +1,183 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package awg
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AmneziaWGTransportType - AmneziaWG obfuscation transport (alternative to V2Ray transport for WireGuard connections)
+type AmneziaWGTransportType int
+
+const (
+	None AmneziaWGTransportType = iota
+	UDP  AmneziaWGTransportType = iota // junk packets and randomized headers over UDP
+)
+
+func (t AmneziaWGTransportType) ToString() string {
+	switch t {
+	case None:
+		return ""
+	case UDP:
+		return "UDP"
+	default:
+		return "unknown"
+	}
+}
+
+// Params - AmneziaWG obfuscation parameters.
+// AmneziaWG is a WireGuard protocol modification: the handshake packets are prefixed by junk packets
+// and padded by random data, and the message type headers are replaced by the custom values.
+// It makes WireGuard traffic not recognizable by DPI. The parameters must be the same as on the server side
+// (except junk packets parameters: Jc, Jmin, Jmax).
+// All zero values - means obfuscation is not in use (plain WireGuard).
+type Params struct {
+	Jc   int `json:"jc"`   // count of junk packets to send before the handshake [0-128]
+	Jmin int `json:"jmin"` // min size of junk packets
+	Jmax int `json:"jmax"` // max size of junk packets [Jmin-1280]
+	S1   int `json:"s1"`   // size of random data to prepend to the handshake initiation packet [0-1132]
+	S2   int `json:"s2"`   // size of random data to prepend to the handshake response packet [0-1188]
+	// custom message type headers (instead of WireGuard defaults: 1, 2, 3, 4)
+	H1 uint32 `json:"h1"` // handshake initiation
+	H2 uint32 `json:"h2"` // handshake response
+	H3 uint32 `json:"h3"` // cookie reply
+	H4 uint32 `json:"h4"` // transport data
+}
+
+const (
+	maxJc   = 128
+	maxJmax = 1280
+	maxS1   = 1132 // 1280 - size of handshake initiation packet (148)
+	maxS2   = 1188 // 1280 - size of handshake response packet (92)
+)
+
+// IsDefined returns 'true' when obfuscation parameters are defined
+func (p Params) IsDefined() bool {
+	return p != Params{}
+}
+
+// Validate checks the parameters are acceptable by AmneziaWG implementation
+func (p Params) Validate() error {
+	if !p.IsDefined() {
+		return fmt.Errorf("AmneziaWG parameters not defined")
+	}
+	if p.Jc < 0 || p.Jc > maxJc {
+		return fmt.Errorf("bad AmneziaWG parameter Jc=%d (acceptable interval is: [0 - %d])", p.Jc, maxJc)
+	}
+	if p.Jmin < 0 || p.Jmin > p.Jmax || p.Jmax > maxJmax {
+		return fmt.Errorf("bad AmneziaWG parameters Jmin=%d Jmax=%d (expected: 0 <= Jmin <= Jmax <= %d)", p.Jmin, p.Jmax, maxJmax)
+	}
+	if p.S1 < 0 || p.S1 > maxS1 {
+		return fmt.Errorf("bad AmneziaWG parameter S1=%d (acceptable interval is: [0 - %d])", p.S1, maxS1)
+	}
+	if p.S2 < 0 || p.S2 > maxS2 {
+		return fmt.Errorf("bad AmneziaWG parameter S2=%d (acceptable interval is: [0 - %d])", p.S2, maxS2)
+	}
+	// the handshake packets must not have the same size (otherwise they can not be distinguished)
+	if (p.S1 > 0 || p.S2 > 0) && p.S1+56 == p.S2 {
+		return fmt.Errorf("bad AmneziaWG parameters S1=%d S2=%d (S1 + 56 must not be equal to S2)", p.S1, p.S2)
+	}
+	headers := []uint32{p.H1, p.H2, p.H3, p.H4}
+	if headers[0] != 0 || headers[1] != 0 || headers[2] != 0 || headers[3] != 0 {
+		for i, h := range headers {
+			if h == 0 {
+				return fmt.Errorf("bad AmneziaWG parameter H%d (all headers H1-H4 must be defined)", i+1)
+			}
+			for j := i + 1; j < len(headers); j++ {
+				if h == headers[j] {
+					return fmt.Errorf("bad AmneziaWG parameters H%d and H%d (headers must be unique)", i+1, j+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the text representation of the parameters (for logging)
+func (p Params) String() string {
+	return fmt.Sprintf("Jc=%d Jmin=%d Jmax=%d S1=%d S2=%d H1=%d H2=%d H3=%d H4=%d", p.Jc, p.Jmin, p.Jmax, p.S1, p.S2, p.H1, p.H2, p.H3, p.H4)
+}
+
+// uapiLines returns the parameters in the format of userspace WireGuard configuration protocol ("key=value")
+func (p Params) uapiLines() []string {
+	ret := []string{
+		"jc=" + strconv.Itoa(p.Jc),
+		"jmin=" + strconv.Itoa(p.Jmin),
+		"jmax=" + strconv.Itoa(p.Jmax),
+		"s1=" + strconv.Itoa(p.S1),
+		"s2=" + strconv.Itoa(p.S2),
+	}
+	if p.H1 != 0 { // not defined: the default WireGuard message types are in use
+		ret = append(ret,
+			"h1="+strconv.FormatUint(uint64(p.H1), 10),
+			"h2="+strconv.FormatUint(uint64(p.H2), 10),
+			"h3="+strconv.FormatUint(uint64(p.H3), 10),
+			"h4="+strconv.FormatUint(uint64(p.H4), 10))
+	}
+	return ret
+}
+
+// UapiSet applies the parameters to the running userspace AmneziaWG device (e.g. 'amneziawg-go')
+// using the cross-platform userspace configuration protocol (https://www.wireguard.com/xplatform/)
+// 'socketPath' - path to the UAPI socket of the device (e.g. "/var/run/amneziawg/wg0.sock")
+func UapiSet(socketPath string, p Params) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second*5)
+	if err != nil {
+		return fmt.Errorf("failed to connect to AmneziaWG device: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 5))
+
+	request := "set=1\n" + strings.Join(p.uapiLines(), "\n") + "\n\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return fmt.Errorf("failed to configure AmneziaWG device: %w", err)
+	}
+
+	// response: "errno=0\n\n"
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if errno, ok := strings.CutPrefix(line, "errno="); ok {
+			if errno != "0" {
+				return fmt.Errorf("failed to configure AmneziaWG device (errno=%s)", errno)
+			}
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to configure AmneziaWG device: %w", err)
+	}
+	return fmt.Errorf("failed to configure AmneziaWG device (unexpected response)")
+}
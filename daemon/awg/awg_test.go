@@ -0,0 +1,92 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package awg
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testParams = Params{Jc: 4, Jmin: 40, Jmax: 70, S1: 15, S2: 68, H1: 1106457265, H2: 249455488, H3: 1209847463, H4: 1646644382}
+
+func TestValidate(t *testing.T) {
+	if err := testParams.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	bad := []func(p *Params){
+		func(p *Params) { *p = Params{} },
+		func(p *Params) { p.Jc = 129 },
+		func(p *Params) { p.Jmin = 80 },
+		func(p *Params) { p.Jmax = 1281 },
+		func(p *Params) { p.S1 = 1133 },
+		func(p *Params) { p.S2 = p.S1 + 56 },
+		func(p *Params) { p.H4 = p.H1 },
+		func(p *Params) { p.H3 = 0 },
+	}
+	for i, modify := range bad {
+		p := testParams
+		modify(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("case %d: expected error for %s", i, p)
+		}
+	}
+}
+
+func TestUapiSet(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skip("unix sockets not supported: ", err)
+	}
+	defer listener.Close()
+
+	// local test peer: userspace WireGuard device emulation
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() && scanner.Text() != "" {
+			lines = append(lines, scanner.Text())
+		}
+		conn.Write([]byte("errno=0\n\n"))
+		received <- lines
+	}()
+
+	if err := UapiSet(socketPath, testParams); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "set=1,jc=4,jmin=40,jmax=70,s1=15,s2=68,h1=1106457265,h2=249455488,h3=1209847463,h4=1646644382"
+	if got := strings.Join(<-received, ","); got != expected {
+		t.Errorf("unexpected request:\n got: %s\nwant: %s", got, expected)
+	}
+}
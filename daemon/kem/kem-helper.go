@@ -30,14 +30,26 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"os"
 	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
 )
 
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("kem")
+}
+
 type Kem_Algo_Name string
 
 const (
 	AlgName_Kyber1024             Kem_Algo_Name = "Kyber1024"
 	AlgName_ClassicMcEliece348864 Kem_Algo_Name = "Classic-McEliece-348864"
+	// Native algorithms (no 'kem-helper' binary required)
+	AlgName_MLKEM768  Kem_Algo_Name = "ML-KEM-768"
+	AlgName_MLKEM1024 Kem_Algo_Name = "ML-KEM-1024"
 )
 
 type KemHelper struct {
@@ -51,18 +63,42 @@ type KemHelper struct {
 }
 
 func GetDefaultKemAlgorithms() []Kem_Algo_Name {
-	return []Kem_Algo_Name{AlgName_Kyber1024, AlgName_ClassicMcEliece348864}
+	return DefaultPolicy().Algorithms()
+}
+
+// CreateHelperForPolicy initialize KEM helper for algorithms defined by the policy and generate Key pairs.
+// If the policy requires 'kem-helper' binary, but it is not available:
+//   - when the lattice algorithm is native (ML-KEM) - Classic-McEliece is not in use;
+//   - otherwise (Kyber1024) - an error is returned. The lattice algorithm is never replaced silently,
+//     because the server would encapsulate the secret for another algorithm.
+//
+// Use KemHelper.Policy() to get the effective policy.
+func CreateHelperForPolicy(kemHelperBinaryPath string, policy Policy) (*KemHelper, error) {
+	if policy.IsHelperRequired() {
+		if _, err := os.Stat(kemHelperBinaryPath); len(kemHelperBinaryPath) == 0 || err != nil {
+			if !isNativeAlgorithm(policy.Lattice) {
+				return nil, fmt.Errorf("kem-helper binary not available (required for KEM policy '%s')", policy)
+			}
+			downgraded := Policy{Lattice: policy.Lattice}
+			log.Warning(fmt.Sprintf("kem-helper binary not available; using KEM policy '%s' instead of '%s'", downgraded, policy))
+			policy = downgraded
+		}
+	}
+	return CreateHelper(kemHelperBinaryPath, policy.Algorithms())
 }
 
 // Initialize KEM helper and generate Key pairs
 // IMPORTANT! The algorithms order in argument 'kemAlgorithms' is important! It in use for PresharedKey calculation!
+// The 'kemHelperBinaryPath' is required only for algorithms which are not implemented natively (Kyber1024, Classic-McEliece).
 func CreateHelper(kemHelperBinaryPath string, kemAlgorithms []Kem_Algo_Name) (*KemHelper, error) {
-	if len(kemHelperBinaryPath) == 0 {
-		return nil, fmt.Errorf("kem-helper error: bad argument (kem helper binary path not defined)")
-	}
 	if len(kemAlgorithms) == 0 {
 		return nil, fmt.Errorf("kem-helper error: bad argument (kemAlgorithms not defined)")
 	}
+	for _, alg := range kemAlgorithms {
+		if !isNativeAlgorithm(alg) && len(kemHelperBinaryPath) == 0 {
+			return nil, fmt.Errorf("kem-helper error: bad argument (kem helper binary path not defined)")
+		}
+	}
 	helper := &KemHelper{
 		kemHelperPath: kemHelperBinaryPath,
 		ciphers:       make([]string, len(kemAlgorithms)),
@@ -87,6 +123,83 @@ func (k KemHelper) GetPublicKeyLiboqsVersion() string {
 	return k.liboqsVersion
 }
 
+// GetLibraryVersion returns the description of KEM implementation in use (value for 'kem_library_version' API field).
+// When only 'kem-helper' algorithms are in use - it is the liboqs version (as before).
+// Otherwise, it is a list of implementations separated by ';'. Example: "go-mlkem:ML-KEM-1024;liboqs:0.10.0"
+func (k KemHelper) GetLibraryVersion() string {
+	if !k.Policy().hasNativeAlgorithm() {
+		return k.liboqsVersion
+	}
+	var ret []string
+	for _, alg := range k.algorithms {
+		if isNativeAlgorithm(alg) {
+			ret = append(ret, nativeLibraryName+":"+string(alg))
+		}
+	}
+	if len(k.liboqsVersion) > 0 {
+		ret = append(ret, "liboqs:"+k.liboqsVersion)
+	}
+	return strings.Join(ret, ";")
+}
+
+// Policy returns the effective KEM policy of the helper
+func (k KemHelper) Policy() Policy {
+	p := Policy{}
+	for _, alg := range k.algorithms {
+		if alg == AlgName_ClassicMcEliece348864 {
+			p.McEliece = true
+		} else {
+			p.Lattice = alg
+		}
+	}
+	return p
+}
+
+// GetPublicKeys returns public keys in the order of API request fields:
+// 'lattice' - for 'kem_public_key1'; 'mceliece' - for 'kem_public_key2' (empty if not in use)
+func (k KemHelper) GetPublicKeys() (lattice, mceliece string, err error) {
+	p := k.Policy()
+	if lattice, err = k.GetPublicKey(p.Lattice); err != nil {
+		return "", "", err
+	}
+	if p.McEliece {
+		if mceliece, err = k.GetPublicKey(AlgName_ClassicMcEliece348864); err != nil {
+			return "", "", err
+		}
+	}
+	return lattice, mceliece, nil
+}
+
+// GetLatticeAlgorithm returns the value for 'kem_algorithm1' API request field.
+// It is empty for Kyber1024 (the algorithm which the server expects when the field is not defined).
+func (k KemHelper) GetLatticeAlgorithm() string {
+	if alg := k.Policy().Lattice; alg != AlgName_Kyber1024 {
+		return string(alg)
+	}
+	return ""
+}
+
+// SetCiphers sets ciphers received from API response ('kem_cipher1', 'kem_algorithm1', 'kem_cipher2').
+// Returns error (and ciphers are not set) when the server did not confirm the lattice algorithm of the public key:
+// the decapsulation would not fail in this case, but the PresharedKey would be different from the server's one.
+func (k KemHelper) SetCiphers(lattice, latticeAlgorithm, mceliece string) error {
+	p := k.Policy()
+	if latticeAlgorithm != k.GetLatticeAlgorithm() {
+		serverAlg := latticeAlgorithm
+		if serverAlg == "" {
+			serverAlg = string(AlgName_Kyber1024)
+		}
+		return fmt.Errorf("the server does not support '%s' KEM algorithm (server uses '%s')", p.Lattice, serverAlg)
+	}
+	if err := k.SetCipher(p.Lattice, lattice); err != nil {
+		return err
+	}
+	if p.McEliece {
+		return k.SetCipher(AlgName_ClassicMcEliece348864, mceliece)
+	}
+	return nil
+}
+
 func (k KemHelper) SetCipher(kemAlgoName Kem_Algo_Name, cipher string) error {
 	idx, err := k.getAlgoIndex(kemAlgoName)
 	if err != nil {
@@ -133,19 +246,56 @@ func (k KemHelper) getAlgoIndex(alg Kem_Algo_Name) (index int, err error) {
 }
 
 func (k *KemHelper) generateKeys() (retErr error) {
-	k.privateKeys, k.publicKeys, k.liboqsVersion, retErr = GenerateKeysMulti(k.kemHelperPath, k.algorithms)
-	return retErr
+	privateKeys := make([]string, 0, len(k.algorithms))
+	publicKeys := make([]string, 0, len(k.algorithms))
+	liboqsVersion := ""
+	for _, alg := range k.algorithms {
+		var priv, pub string
+		if isNativeAlgorithm(alg) {
+			priv, pub, retErr = generateKeysNative(alg)
+		} else {
+			var libVer string
+			priv, pub, libVer, retErr = GenerateKeys(k.kemHelperPath, alg)
+			if retErr == nil {
+				if liboqsVersion != "" && liboqsVersion != libVer {
+					retErr = fmt.Errorf("kem-helper internal error: different liboqs versions")
+				}
+				liboqsVersion = libVer
+			}
+		}
+		if retErr != nil {
+			return retErr
+		}
+		privateKeys = append(privateKeys, priv)
+		publicKeys = append(publicKeys, pub)
+	}
+	k.privateKeys, k.publicKeys, k.liboqsVersion = privateKeys, publicKeys, liboqsVersion
+	return nil
 }
 
 func (k *KemHelper) decodeCiphers(cipherBase64 []string) (retErr error) {
 	if len(k.algorithms) != len(cipherBase64) {
 		return fmt.Errorf("KemHelper error: unexpected count of ciphers to decode")
 	}
-	k.secrets, retErr = DecodeCipherMulti(k.kemHelperPath, k.algorithms, k.privateKeys, cipherBase64)
-	if retErr != nil {
-		k.secrets = []string{}
+	if len(k.algorithms) != len(k.privateKeys) {
+		return fmt.Errorf("KemHelper error: private keys not defined")
 	}
-	return retErr
+	secrets := make([]string, 0, len(k.algorithms))
+	for i, alg := range k.algorithms {
+		var secret string
+		if isNativeAlgorithm(alg) {
+			secret, retErr = decodeCipherNative(alg, k.privateKeys[i], cipherBase64[i])
+		} else {
+			secret, retErr = DecodeCipher(k.kemHelperPath, alg, k.privateKeys[i], cipherBase64[i])
+		}
+		if retErr != nil {
+			k.secrets = []string{}
+			return retErr
+		}
+		secrets = append(secrets, secret)
+	}
+	k.secrets = secrets
+	return nil
 }
 
 func (k *KemHelper) checkCiphers() error {
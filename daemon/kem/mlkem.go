@@ -0,0 +1,96 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package kem
+
+import (
+	"crypto/mlkem"
+	"encoding/base64"
+	"fmt"
+)
+
+// Native (in-process) implementation of the lattice-based KEM algorithms, using Go standard library 'crypto/mlkem'.
+// It does not require 'kem-helper' binary.
+//
+// Private key: base64-encoded 64-byte seed ("d || z" form, as defined in FIPS 203)
+// Public key:  base64-encoded encapsulation key
+
+const nativeLibraryName = "go-mlkem"
+
+func isNativeAlgorithm(alg Kem_Algo_Name) bool {
+	return alg == AlgName_MLKEM768 || alg == AlgName_MLKEM1024
+}
+
+func generateKeysNative(alg Kem_Algo_Name) (privateKeyBase64, publicKeyBase64 string, retErr error) {
+	var seed, pub []byte
+	switch alg {
+	case AlgName_MLKEM768:
+		dk, err := mlkem.GenerateKey768()
+		if err != nil {
+			return "", "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+		seed, pub = dk.Bytes(), dk.EncapsulationKey().Bytes()
+	case AlgName_MLKEM1024:
+		dk, err := mlkem.GenerateKey1024()
+		if err != nil {
+			return "", "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+		seed, pub = dk.Bytes(), dk.EncapsulationKey().Bytes()
+	default:
+		return "", "", fmt.Errorf("KEM error: algorithm '%s' is not supported natively", alg)
+	}
+	return base64.StdEncoding.EncodeToString(seed), base64.StdEncoding.EncodeToString(pub), nil
+}
+
+func decodeCipherNative(alg Kem_Algo_Name, privateKeyBase64 string, cipherBase64 string) (secretBase64 string, retErr error) {
+	seed, err := base64.StdEncoding.DecodeString(privateKeyBase64)
+	if err != nil {
+		return "", fmt.Errorf("KEM error (kem:%s): failed to decode private key: %w", alg, err)
+	}
+	cipher, err := base64.StdEncoding.DecodeString(cipherBase64)
+	if err != nil {
+		return "", fmt.Errorf("KEM error (kem:%s): failed to decode cipher: %w", alg, err)
+	}
+
+	var secret []byte
+	switch alg {
+	case AlgName_MLKEM768:
+		dk, err := mlkem.NewDecapsulationKey768(seed)
+		if err != nil {
+			return "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+		if secret, err = dk.Decapsulate(cipher); err != nil {
+			return "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+	case AlgName_MLKEM1024:
+		dk, err := mlkem.NewDecapsulationKey1024(seed)
+		if err != nil {
+			return "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+		if secret, err = dk.Decapsulate(cipher); err != nil {
+			return "", fmt.Errorf("KEM error (kem:%s): %w", alg, err)
+		}
+	default:
+		return "", fmt.Errorf("KEM error: algorithm '%s' is not supported natively", alg)
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
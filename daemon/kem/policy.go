@@ -0,0 +1,127 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package kem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy defines the set of KEM algorithms in use for WireGuard PresharedKey exchange.
+//
+// The lattice-based algorithm is always in use (its public key and cipher are transferred in the first slot of API request/response).
+// Classic-McEliece is optional (it is transferred in the second slot).
+type Policy struct {
+	Lattice  Kem_Algo_Name
+	McEliece bool
+}
+
+// DefaultPolicy returns the policy in use when the user did not configure it.
+// Kyber1024 stays the default: ML-KEM is used only when the user selects it explicitly
+// (and the server confirms it supports the algorithm, see KemHelper.SetCiphers()).
+func DefaultPolicy() Policy {
+	return Policy{Lattice: AlgName_Kyber1024, McEliece: true}
+}
+
+// ParsePolicy parses policy from string.
+// Supported format: "<lattice>[+mceliece]", where <lattice> is one of: "mlkem768", "mlkem1024", "kyber1024".
+// Examples: "mlkem1024", "mlkem768+mceliece".
+// Empty string (or "default") means default policy.
+func ParsePolicy(s string) (Policy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "default" {
+		return DefaultPolicy(), nil
+	}
+
+	var p Policy
+	for i, part := range strings.Split(s, "+") {
+		part = strings.NewReplacer("-", "", "_", "").Replace(strings.TrimSpace(part))
+		if i == 0 {
+			switch part {
+			case "mlkem768":
+				p.Lattice = AlgName_MLKEM768
+			case "mlkem1024":
+				p.Lattice = AlgName_MLKEM1024
+			case "kyber1024":
+				p.Lattice = AlgName_Kyber1024
+			default:
+				return Policy{}, fmt.Errorf("unsupported KEM policy '%s' (unknown lattice algorithm '%s')", s, part)
+			}
+			continue
+		}
+
+		if part != "mceliece" || p.McEliece {
+			return Policy{}, fmt.Errorf("unsupported KEM policy '%s'", s)
+		}
+		p.McEliece = true
+	}
+	return p, nil
+}
+
+// String returns policy in the format accepted by ParsePolicy()
+func (p Policy) String() string {
+	ret := strings.ToLower(strings.ReplaceAll(string(p.Lattice), "-", ""))
+	if p.McEliece {
+		ret += "+mceliece"
+	}
+	return ret
+}
+
+// Algorithms returns the list of KEM algorithms of the policy.
+// IMPORTANT! The order is important! It in use for PresharedKey calculation!
+func (p Policy) Algorithms() []Kem_Algo_Name {
+	ret := []Kem_Algo_Name{p.Lattice}
+	if p.McEliece {
+		ret = append(ret, AlgName_ClassicMcEliece348864)
+	}
+	return ret
+}
+
+// KyberFallback returns the policy to use when the server does not support the lattice algorithm of the policy:
+// Kyber1024 (the algorithm supported by all servers) with the same Classic-McEliece setting.
+// Returns false when the policy already uses Kyber1024.
+func (p Policy) KyberFallback() (Policy, bool) {
+	if p.Lattice == AlgName_Kyber1024 {
+		return p, false
+	}
+	return Policy{Lattice: AlgName_Kyber1024, McEliece: p.McEliece}, true
+}
+
+// IsHelperRequired returns true when external 'kem-helper' binary is required to apply the policy
+func (p Policy) IsHelperRequired() bool {
+	for _, alg := range p.Algorithms() {
+		if !isNativeAlgorithm(alg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) hasNativeAlgorithm() bool {
+	for _, alg := range p.Algorithms() {
+		if isNativeAlgorithm(alg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package kem
+
+import (
+	"crypto/mlkem"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Policy
+		wantErr bool
+	}{
+		{"", DefaultPolicy(), false},
+		{"default", DefaultPolicy(), false},
+		{"mlkem1024", Policy{Lattice: AlgName_MLKEM1024}, false},
+		{"ML-KEM-768+McEliece", Policy{Lattice: AlgName_MLKEM768, McEliece: true}, false},
+		{"kyber1024+mceliece", Policy{Lattice: AlgName_Kyber1024, McEliece: true}, false},
+		{"mceliece", Policy{}, true},
+		{"mlkem512", Policy{}, true},
+		{"mlkem768+mceliece+mceliece", Policy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %+v, want %+v", tt.in, got, tt.want)
+			continue
+		}
+		if !tt.wantErr {
+			if p, err := ParsePolicy(got.String()); err != nil || p != got {
+				t.Errorf("ParsePolicy(%q.String()) = %+v, %v", tt.in, p, err)
+			}
+		}
+	}
+}
+
+func TestNativeHelper(t *testing.T) {
+	for _, alg := range []Kem_Algo_Name{AlgName_MLKEM768, AlgName_MLKEM1024} {
+		helper, err := CreateHelperForPolicy("", Policy{Lattice: alg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubBase64, mceliece, err := helper.GetPublicKeys()
+		if err != nil || len(mceliece) > 0 {
+			t.Fatalf("%s: GetPublicKeys() = %q, %v", alg, mceliece, err)
+		}
+		pub, err := base64.StdEncoding.DecodeString(pubBase64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// encapsulate (server side)
+		var secret, cipher []byte
+		if alg == AlgName_MLKEM768 {
+			ek, err := mlkem.NewEncapsulationKey768(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, cipher = ek.Encapsulate()
+		} else {
+			ek, err := mlkem.NewEncapsulationKey1024(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, cipher = ek.Encapsulate()
+		}
+
+		if err := helper.SetCiphers(base64.StdEncoding.EncodeToString(cipher), "", ""); err == nil {
+			t.Fatalf("%s: expected error when the server did not confirm the algorithm", alg)
+		}
+		if err := helper.SetCiphers(base64.StdEncoding.EncodeToString(cipher), string(alg), ""); err != nil {
+			t.Fatal(err)
+		}
+		psk, err := helper.CalculatePresharedKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := sha256.Sum256(secret)
+		if psk != base64.StdEncoding.EncodeToString(expected[:]) {
+			t.Errorf("%s: unexpected PresharedKey", alg)
+		}
+		if v := helper.GetLibraryVersion(); v != nativeLibraryName+":"+string(alg) {
+			t.Errorf("%s: unexpected library version %q", alg, v)
+		}
+	}
+}
+
+func TestKyberFallback(t *testing.T) {
+	if p, ok := (Policy{Lattice: AlgName_MLKEM768, McEliece: true}).KyberFallback(); !ok || p != DefaultPolicy() {
+		t.Errorf("KyberFallback() = %+v, %v", p, ok)
+	}
+	if p, ok := (Policy{Lattice: AlgName_MLKEM1024}).KyberFallback(); !ok || p != (Policy{Lattice: AlgName_Kyber1024}) {
+		t.Errorf("KyberFallback() = %+v, %v", p, ok)
+	}
+	if _, ok := DefaultPolicy().KyberFallback(); ok {
+		t.Errorf("KyberFallback() for Kyber1024 policy must return false")
+	}
+}
@@ -0,0 +1,173 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package netinfo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	pmtuProbeAttempts = 2
+	sizeofICMPHeader  = 8
+)
+
+// ProbePathMTU detects the path MTU to the host.
+// It sends ICMP Echo requests with 'Don't Fragment' flag (binary search in the range [minMTU, maxMTU])
+// and returns the size of the biggest IP packet which reached the host.
+// Note: the host must respond to ICMP Echo requests.
+func ProbePathMTU(host net.IP, minMTU, maxMTU int, timeout time.Duration) (int, error) {
+	return ProbePathMTUWithMark(host, minMTU, maxMTU, timeout, 0)
+}
+
+// ProbePathMTUWithMark is the same as ProbePathMTU() but the probes are marked by the firewall mark ('fwmark'; Linux only).
+// It is in use to route the probes outside the VPN tunnel (policy-based routing) while the VPN is connected.
+// 'fwmark' = 0 - the probes are not marked.
+func ProbePathMTUWithMark(host net.IP, minMTU, maxMTU int, timeout time.Duration, fwmark uint32) (int, error) {
+	if host == nil || minMTU <= 0 || minMTU > maxMTU {
+		return 0, fmt.Errorf("bad arguments")
+	}
+
+	isIPv6 := host.To4() == nil
+	network, protocol, ipHeaderSize := "ip4:icmp", 1, 20
+	var echoType, echoReplyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if isIPv6 {
+		network, protocol, ipHeaderSize = "ip6:ipv6-icmp", 58, 40
+		echoType, echoReplyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ICMP socket: %w", err)
+	}
+	defer conn.Close()
+
+	ipConn, ok := conn.(*net.IPConn)
+	if !ok {
+		return 0, fmt.Errorf("unexpected ICMP socket type")
+	}
+	rawConn, err := ipConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) { sockoptErr = setDontFragment(fd, isIPv6) }); err != nil {
+		return 0, err
+	}
+	if sockoptErr != nil {
+		return 0, fmt.Errorf("failed to set 'Don't Fragment' flag: %w", sockoptErr)
+	}
+	if fwmark != 0 {
+		if err := rawConn.Control(func(fd uintptr) { sockoptErr = setFwmark(fd, fwmark) }); err != nil {
+			return 0, err
+		}
+		if sockoptErr != nil {
+			return 0, fmt.Errorf("failed to set firewall mark: %w", sockoptErr)
+		}
+	}
+
+	id := os.Getpid() & 0xffff
+	seq := 0
+	buf := make([]byte, 65536)
+
+	// probe returns true when the ICMP Echo reply received for the IP packet of defined size
+	probe := func(size int) bool {
+		for attempt := 0; attempt < pmtuProbeAttempts; attempt++ {
+			seq = (seq + 1) & 0xffff
+			msg := icmp.Message{Type: echoType, Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, size-ipHeaderSize-sizeofICMPHeader)}}
+			data, err := msg.Marshal(nil) // (IPv6) the checksum is calculated by the OS
+			if err != nil {
+				return false
+			}
+			if _, err := conn.WriteTo(data, &net.IPAddr{IP: host}); err != nil {
+				if errors.Is(err, syscall.EMSGSIZE) {
+					return false // the packet is bigger than MTU of the local interface (or known path MTU)
+				}
+				continue
+			}
+
+			conn.SetReadDeadline(time.Now().Add(timeout))
+			for {
+				n, peer, err := conn.ReadFrom(buf)
+				if err != nil {
+					break // timeout
+				}
+				if peerIP, ok := peer.(*net.IPAddr); !ok || !peerIP.IP.Equal(host) {
+					continue
+				}
+				reply, err := icmp.ParseMessage(protocol, buf[:n])
+				if err != nil || reply.Type != echoReplyType {
+					continue
+				}
+				if echo, ok := reply.Body.(*icmp.Echo); ok && echo.ID == id && echo.Seq == seq {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if !probe(minMTU) {
+		return 0, fmt.Errorf("no response from %s", host)
+	}
+	if probe(maxMTU) {
+		return maxMTU, nil // the most common case: no additional probes required
+	}
+	lo, hi := minMTU, maxMTU-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if probe(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// GetOutboundInterface returns the network interface (and its local network) which is in use to communicate with the host
+func GetOutboundInterface(host net.IP) (*net.Interface, *net.IPNet, error) {
+	localIP, err := GetOutboundIPEx(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	iface, err := InterfaceByIPAddr(localIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	addrs, _ := iface.Addrs()
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(localIP) {
+			return iface, &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}, nil
+		}
+	}
+	return iface, nil, nil
+}
@@ -0,0 +1,45 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package netinfo
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	_IP_DONTFRAGMENT = 14 // ws2ipdef.h
+	_IPV6_DONTFRAG   = 14 // ws2ipdef.h
+)
+
+func setDontFragment(fd uintptr, isIPv6 bool) error {
+	if isIPv6 {
+		return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, _IPV6_DONTFRAG, 1)
+	}
+	return windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, _IP_DONTFRAGMENT, 1)
+}
+
+func setFwmark(fd uintptr, fwmark uint32) error {
+	return fmt.Errorf("firewall mark is not supported on this platform")
+}
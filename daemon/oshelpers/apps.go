@@ -52,6 +52,9 @@ type AppInfo struct {
 	// Windows: absolute path to application binary
 	// Linux: program to execute, possibly with arguments.
 	AppBinaryPath string
+	// (optional; Linux) Runtime identity of the sandboxed application: 'flatpak:<application ID>' or 'snap:<snap name>'
+	// It can be used to add the application to Split Tunnel configuration
+	AppSandbox string
 }
 
 // GetInstalledApps returns a list of installed applications on the system
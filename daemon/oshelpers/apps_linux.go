@@ -68,6 +68,9 @@ func implGetInstalledApps(extraArgsJSON string) ([]AppInfo, error) {
 		}
 	}
 
+	// Flatpak and Snap applications export desktop entries and icons into their own directories
+	XDG_DATA_DIRS = applist.WithSandboxDataDirs(XDG_DATA_DIRS, HOME)
+
 	// read info about all installed apps
 	excludeApps := make(map[string]struct{}, 0)
 	excludeApps["/usr/bin/gnome-terminal"] = struct{}{} // Terminal is not possible to run in ST
@@ -95,7 +98,7 @@ func implGetInstalledApps(extraArgsJSON string) ([]AppInfo, error) {
 				}
 			}
 		}
-		app := AppInfo{AppName: e.Name, AppBinaryPath: e.Exec, AppIcon: base64Img}
+		app := AppInfo{AppName: e.Name, AppBinaryPath: e.Exec, AppIcon: base64Img, AppSandbox: e.Sandbox.String()}
 		retValues = append(retValues, app)
 	}
 
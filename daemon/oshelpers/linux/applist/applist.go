@@ -39,6 +39,8 @@ type DesktopEntry struct {
 	Name string
 	Icon string
 	Exec string
+	// Runtime identity of the sandboxed application (Flatpak or Snap); empty for non-sandboxed applications
+	Sandbox Sandbox
 }
 
 // GetAppsList returns list of DesktopEntry with an information about installed apps in the system
@@ -158,6 +160,65 @@ func readAppsDirectory(dirpath string, parsedEntries map[string]DesktopEntry, XD
 	return nil
 }
 
+// GetDesktopEntryBinary returns the absolute path to the binary which is executed by the desktop entry ('.desktop' file)
+func GetDesktopEntryBinary(desktopFile string) (string, error) {
+	keys, err := readDesktopEntryKeys(desktopFile)
+	if err != nil {
+		return "", err
+	}
+
+	execLine, ok := keys["Exec"]
+	if !ok {
+		return "", fmt.Errorf("'Exec' key not found in the desktop entry '%s'", desktopFile)
+	}
+	regexpBinaryArgs := regexp.MustCompile("(\".*\"|\\S*)(.*)")
+	binCols := regexpBinaryArgs.FindStringSubmatch(execLine)
+	if len(binCols) != 3 || len(binCols[1]) == 0 {
+		return "", fmt.Errorf("'Exec' key is empty in the desktop entry '%s'", desktopFile)
+	}
+	return exec.LookPath(strings.Trim(binCols[1], "\""))
+}
+
+// readDesktopEntryKeys returns all keys of "Desktop Entry" group of the '.desktop' file
+func readDesktopEntryKeys(desktopFile string) (map[string]string, error) {
+	file, err := os.Open(desktopFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keyValueRegexp := regexp.MustCompile("^([A-Za-z0-9-]*) *= *(.*)$")
+
+	ret := make(map[string]string)
+	vIsDesktopEntry := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 0 || line[0:1] == "#" {
+			continue
+		}
+		if line[0:1] == "[" {
+			if vIsDesktopEntry {
+				break // end of Desktop Entry
+			}
+			vIsDesktopEntry = strings.EqualFold(line, "[Desktop Entry]")
+			continue
+		}
+		if !vIsDesktopEntry {
+			continue
+		}
+
+		cols := keyValueRegexp.FindStringSubmatch(line)
+		if len(cols) != 3 || len(cols[1]) == 0 {
+			continue
+		}
+		if _, ok := ret[cols[1]]; !ok {
+			ret[cols[1]] = cols[2]
+		}
+	}
+	return ret, nil
+}
+
 func parseDesktopFile(filepath string, XDG_CURRENT_DESKTOP map[string]struct{}) (DesktopEntry, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -170,6 +231,7 @@ func parseDesktopFile(filepath string, XDG_CURRENT_DESKTOP map[string]struct{})
 	vIsDesktopEntry := false
 
 	var ret DesktopEntry
+	var xFlatpak, xSnapInstanceName string
 	// read file line-by-line (only "Desktop Entry")
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
@@ -253,7 +315,12 @@ func parseDesktopFile(filepath string, XDG_CURRENT_DESKTOP map[string]struct{})
 			ret.Icon = val
 		case "Exec":
 			ret.Exec = val
+		case "X-Flatpak":
+			xFlatpak = val
+		case "X-SnapInstanceName":
+			xSnapInstanceName = val
 		}
 	}
+	ret.Sandbox = desktopEntrySandbox(xFlatpak, xSnapInstanceName, ret.Exec)
 	return ret, nil
 }
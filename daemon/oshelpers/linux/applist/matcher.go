@@ -0,0 +1,118 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package applist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher checks if the running processes belong to the configured applications.
+// The application can be defined by:
+//   - the path to the binary;
+//   - the path to the desktop entry ('.desktop' file);
+//   - the sandbox identity (e.g. 'flatpak:org.mozilla.firefox', 'snap:firefox').
+//
+// Sandboxed applications (Flatpak, Snap) are started through wrappers, so their processes are matched
+// by the sandbox identity instead of the binary path.
+type Matcher struct {
+	// binaries of the configured applications (map[<absolute path to binary>]<configured application>)
+	binaries map[string]string
+	// sandboxed applications (map[<sandbox identity ('flatpak:<ID>', 'snap:<name>')>]<configured application>)
+	sandboxes map[string]string
+}
+
+// NewMatcher creates matcher for the configured applications.
+// The applications which can not be resolved are skipped (the errors are returned in 'skipped').
+func NewMatcher(apps []string) (m *Matcher, skipped []error) {
+	m = &Matcher{binaries: make(map[string]string, len(apps)), sandboxes: make(map[string]string)}
+	for _, app := range apps {
+		app = strings.TrimSpace(app)
+		if len(app) == 0 {
+			continue
+		}
+		if sb, ok := ParseSandbox(app); ok {
+			m.sandboxes[sb.String()] = app
+			continue
+		}
+
+		binary := app
+		if strings.HasSuffix(app, ".desktop") {
+			sb, err := GetDesktopEntrySandbox(app)
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("application '%s' skipped: %w", app, err))
+				continue
+			}
+			if !sb.IsEmpty() {
+				m.sandboxes[sb.String()] = app
+				continue
+			}
+			b, err := GetDesktopEntryBinary(app)
+			if err != nil {
+				skipped = append(skipped, fmt.Errorf("application '%s' skipped: %w", app, err))
+				continue
+			}
+			binary = b
+		}
+		if sb := GetBinarySandbox(binary); !sb.IsEmpty() {
+			m.sandboxes[sb.String()] = app
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(binary)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("application '%s' skipped: %w", app, err))
+			continue
+		}
+		m.binaries[resolved] = app
+	}
+	return m, skipped
+}
+
+// IsEmpty returns true when there are no applications to match
+func (m *Matcher) IsEmpty() bool {
+	return m == nil || (len(m.binaries) == 0 && len(m.sandboxes) == 0)
+}
+
+// Match returns the configured application of the process
+// (ok=false - the process does not belong to any configured application or it is already finished)
+func (m *Matcher) Match(pid int) (app string, ok bool) {
+	if m.IsEmpty() {
+		return "", false
+	}
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", false // process is already finished or it is a kernel thread
+	}
+	// Note: the binary of the sandboxed process is not resolvable from the host (it is in the mount namespace of the sandbox)
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		if app, ok = m.binaries[resolved]; ok {
+			return app, true
+		}
+	}
+	if len(m.sandboxes) > 0 {
+		app, ok = m.sandboxes[GetProcessSandbox(pid).String()]
+	}
+	return app, ok
+}
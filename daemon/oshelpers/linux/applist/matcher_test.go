@@ -0,0 +1,60 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package applist
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, skipped := NewMatcher([]string{exe, "/not/existing/binary", "flatpak:org.example.App", " "})
+	if len(skipped) != 1 {
+		t.Fatalf("expected 1 skipped application, got: %v", skipped)
+	}
+	if m.IsEmpty() {
+		t.Fatal("matcher must not be empty")
+	}
+	if app, ok := m.Match(os.Getpid()); !ok || app != exe {
+		t.Errorf("Match(self) = %q, %v", app, ok)
+	}
+	if _, ok := m.Match(os.Getppid()); ok {
+		t.Errorf("Match(parent) must not match")
+	}
+
+	var empty *Matcher
+	if !empty.IsEmpty() {
+		t.Error("nil matcher must be empty")
+	}
+	if _, ok := empty.Match(os.Getpid()); ok {
+		t.Error("nil matcher must not match")
+	}
+}
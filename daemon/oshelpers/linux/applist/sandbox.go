@@ -0,0 +1,243 @@
+//go:build linux
+// +build linux
+
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package applist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox types
+const (
+	SandboxFlatpak = "flatpak"
+	SandboxSnap    = "snap"
+)
+
+// Sandbox is the runtime identity of the sandboxed application (Flatpak or Snap).
+// Sandboxed applications are started through wrappers ('flatpak run ...', '/snap/bin/...'),
+// so the binary of the running process does not match the binary defined in the desktop entry.
+type Sandbox struct {
+	Type string // SandboxFlatpak or SandboxSnap
+	ID   string // Flatpak application ID (e.g. 'org.mozilla.firefox') or snap name (e.g. 'firefox')
+}
+
+func (s Sandbox) IsEmpty() bool {
+	return len(s.Type) == 0 || len(s.ID) == 0
+}
+
+// String returns the sandbox identity in format '<type>:<ID>' (e.g. 'flatpak:org.mozilla.firefox', 'snap:firefox')
+func (s Sandbox) String() string {
+	if s.IsEmpty() {
+		return ""
+	}
+	return s.Type + ":" + s.ID
+}
+
+// ParseSandbox parses the sandbox identity in format '<type>:<ID>' (e.g. 'flatpak:org.mozilla.firefox', 'snap:firefox')
+func ParseSandbox(str string) (Sandbox, bool) {
+	cols := strings.SplitN(strings.TrimSpace(str), ":", 2)
+	if len(cols) != 2 || len(cols[1]) == 0 || strings.ContainsAny(cols[1], "/ ") {
+		return Sandbox{}, false
+	}
+	if cols[0] != SandboxFlatpak && cols[0] != SandboxSnap {
+		return Sandbox{}, false
+	}
+	return Sandbox{Type: cols[0], ID: cols[1]}, true
+}
+
+// WithSandboxDataDirs returns $XDG_DATA_DIRS value extended by the directories where Flatpak and Snap
+// export the desktop entries and icons of installed applications (if they are not defined yet).
+// Arguments:
+//
+//	vXDG_DATA_DIRS	- environment variable $XDG_DATA_DIRS (e.g. '/usr/share/ubuntu:/usr/local/share/:/usr/share/')
+//	vHOME			- environment variable $HOME (e.g. '/home/user')
+func WithSandboxDataDirs(evXDG_DATA_DIRS string, evHOME string) string {
+	var dirs []string
+	if len(evXDG_DATA_DIRS) > 0 {
+		dirs = strings.Split(evXDG_DATA_DIRS, ":")
+	} else {
+		dirs = []string{"/usr/local/share/", "/usr/share/"}
+	}
+
+	var sandboxDirs []string
+	if len(evHOME) > 0 {
+		sandboxDirs = append(sandboxDirs, path.Join(evHOME, ".local", "share", "flatpak", "exports", "share"))
+	}
+	sandboxDirs = append(sandboxDirs, "/var/lib/flatpak/exports/share", "/var/lib/snapd/desktop")
+
+	for _, sd := range sandboxDirs {
+		isExists := false
+		for _, d := range dirs {
+			if path.Clean(d) == sd {
+				isExists = true
+				break
+			}
+		}
+		if !isExists {
+			dirs = append(dirs, sd)
+		}
+	}
+	return strings.Join(dirs, ":")
+}
+
+// GetBinarySandbox returns the sandbox identity of the wrapper binary:
+// '/snap/bin/<snap>[.<app>]' or '<flatpak installation>/exports/bin/<application ID>'.
+// Returns empty Sandbox if the binary is not a wrapper of sandboxed application.
+func GetBinarySandbox(binary string) Sandbox {
+	dir, name := filepath.Split(filepath.Clean(binary))
+	if len(name) == 0 {
+		return Sandbox{}
+	}
+	if dir == "/snap/bin/" {
+		return Sandbox{Type: SandboxSnap, ID: strings.SplitN(name, ".", 2)[0]}
+	}
+	if strings.HasSuffix(dir, "/flatpak/exports/bin/") {
+		return Sandbox{Type: SandboxFlatpak, ID: name}
+	}
+	return Sandbox{}
+}
+
+// GetDesktopEntrySandbox returns the sandbox identity of the application defined by desktop entry ('.desktop' file).
+// Returns empty Sandbox if the application is not sandboxed.
+func GetDesktopEntrySandbox(desktopFile string) (Sandbox, error) {
+	keys, err := readDesktopEntryKeys(desktopFile)
+	if err != nil {
+		return Sandbox{}, err
+	}
+	return desktopEntrySandbox(keys["X-Flatpak"], keys["X-SnapInstanceName"], keys["Exec"]), nil
+}
+
+// desktopEntrySandbox detects sandboxed application by the keys of desktop entry.
+// Flatpak and snapd add the keys 'X-Flatpak' and 'X-SnapInstanceName' to the exported desktop entries,
+// otherwise the command line ('Exec' key) is checked.
+func desktopEntrySandbox(xFlatpak, xSnapInstanceName, execLine string) Sandbox {
+	if len(xFlatpak) > 0 {
+		return Sandbox{Type: SandboxFlatpak, ID: xFlatpak}
+	}
+	if len(xSnapInstanceName) > 0 {
+		return Sandbox{Type: SandboxSnap, ID: xSnapInstanceName}
+	}
+
+	fields := strings.Fields(execLine)
+	// skip environment variables definition (e.g. 'env BAMF_DESKTOP_FILE_HINT=/var/lib/snapd/desktop/applications/firefox_firefox.desktop /snap/bin/firefox %u')
+	if len(fields) > 0 && path.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		for len(fields) > 0 && strings.Contains(fields[0], "=") {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return Sandbox{}
+	}
+
+	// e.g. '/usr/bin/flatpak run --branch=stable --arch=x86_64 --command=firefox --file-forwarding org.mozilla.firefox @@u %u @@'
+	if path.Base(strings.Trim(fields[0], "\"")) == "flatpak" {
+		if len(fields) < 2 || fields[1] != "run" {
+			return Sandbox{}
+		}
+		for _, f := range fields[2:] {
+			if !strings.HasPrefix(f, "-") {
+				return Sandbox{Type: SandboxFlatpak, ID: f}
+			}
+		}
+		return Sandbox{}
+	}
+
+	return GetBinarySandbox(strings.Trim(fields[0], "\""))
+}
+
+// GetProcessSandbox returns the sandbox identity of the running process.
+// Returns empty Sandbox if the process is not sandboxed (or it is already finished).
+func GetProcessSandbox(pid int) Sandbox {
+	// Flatpak: the file '/.flatpak-info' is available in the root of the sandbox
+	//	[Application]
+	//	name=org.mozilla.firefox
+	if id := readFlatpakInfoAppName(fmt.Sprintf("/proc/%d/root/.flatpak-info", pid)); len(id) > 0 {
+		return Sandbox{Type: SandboxFlatpak, ID: id}
+	}
+
+	// Snap (strict confinement): AppArmor label of the process 'snap.<snap>.<app> (enforce)'
+	for _, f := range []string{fmt.Sprintf("/proc/%d/attr/apparmor/current", pid), fmt.Sprintf("/proc/%d/attr/current", pid)} {
+		if data, err := os.ReadFile(f); err == nil {
+			if id := snapNameFromUnit(strings.Fields(string(data))); len(id) > 0 {
+				return Sandbox{Type: SandboxSnap, ID: id}
+			}
+			break
+		}
+	}
+
+	// Snap (systems without AppArmor or classic confinement): systemd scope of the process 'snap.<snap>.<app>-<UUID>.scope'
+	if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid)); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if id := snapNameFromUnit([]string{path.Base(line)}); len(id) > 0 {
+				return Sandbox{Type: SandboxSnap, ID: id}
+			}
+		}
+	}
+
+	return Sandbox{}
+}
+
+// snapNameFromUnit returns the snap name from the AppArmor label or systemd unit name ('snap.<snap>.<app>...')
+func snapNameFromUnit(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	cols := strings.SplitN(fields[0], ".", 3)
+	if len(cols) != 3 || cols[0] != "snap" {
+		return ""
+	}
+	return cols[1]
+}
+
+func readFlatpakInfoAppName(flatpakInfoFile string) string {
+	file, err := os.Open(flatpakInfoFile)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	isApplicationSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			isApplicationSection = line == "[Application]"
+			continue
+		}
+		if !isApplicationSection {
+			continue
+		}
+		if cols := strings.SplitN(line, "=", 2); len(cols) == 2 && strings.TrimSpace(cols[0]) == "name" {
+			return strings.TrimSpace(cols[1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package applist
+
+import "testing"
+
+func TestDesktopEntrySandbox(t *testing.T) {
+	tests := []struct {
+		name              string
+		xFlatpak          string
+		xSnapInstanceName string
+		exec              string
+		expected          string
+	}{
+		{name: "flatpak key", xFlatpak: "org.mozilla.firefox", exec: "/usr/bin/flatpak run org.mozilla.firefox", expected: "flatpak:org.mozilla.firefox"},
+		{name: "snap key", xSnapInstanceName: "firefox", exec: "/snap/bin/firefox %u", expected: "snap:firefox"},
+		{name: "flatpak run", exec: "/usr/bin/flatpak run --branch=stable --arch=x86_64 --command=firefox --file-forwarding org.mozilla.firefox @@u %u @@", expected: "flatpak:org.mozilla.firefox"},
+		{name: "flatpak exports bin", exec: "/var/lib/flatpak/exports/bin/org.gimp.GIMP %U", expected: "flatpak:org.gimp.GIMP"},
+		{name: "snap env", exec: "env BAMF_DESKTOP_FILE_HINT=/var/lib/snapd/desktop/applications/firefox_firefox.desktop /snap/bin/firefox %u", expected: "snap:firefox"},
+		{name: "snap app", exec: "/snap/bin/libreoffice.writer %U", expected: "snap:libreoffice"},
+		{name: "not sandboxed", exec: "/usr/bin/firefox %u", expected: ""},
+		{name: "flatpak not run", exec: "flatpak update", expected: ""},
+		{name: "empty", exec: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := desktopEntrySandbox(tt.xFlatpak, tt.xSnapInstanceName, tt.exec)
+			if sb.String() != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, sb.String())
+			}
+		})
+	}
+}
+
+func TestParseSandbox(t *testing.T) {
+	for str, expected := range map[string]bool{
+		"flatpak:org.mozilla.firefox": true,
+		"snap:firefox":                true,
+		"snap:":                       false,
+		"appimage:firefox":            false,
+		"/usr/bin/firefox":            false,
+		"flatpak:../../etc":           false,
+	} {
+		if _, ok := ParseSandbox(str); ok != expected {
+			t.Errorf("ParseSandbox('%s'): expected %v, got %v", str, expected, ok)
+		}
+	}
+}
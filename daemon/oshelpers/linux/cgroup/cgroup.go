@@ -0,0 +1,103 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package cgroup contains helpers to manage processes in cgroup v2 (unified hierarchy)
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UnifiedRoot is the default mount point of cgroup v2 unified hierarchy
+const UnifiedRoot = "/sys/fs/cgroup"
+
+const procsFile = "cgroup.procs"
+
+// IsCgroup2Mount returns true if the path is a mount point of cgroup v2 filesystem
+// The file format (/proc/self/mounts): "<device> <mount point> <fs type> <options> 0 0"
+func IsCgroup2Mount(path string) bool {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) >= 3 && cols[1] == path && cols[2] == "cgroup2" {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessCgroup returns the cgroup v2 path of the process (e.g. "/user.slice/user-1000.slice/session-2.scope")
+// Returns empty string if the process does not exist.
+// The file format (/proc/<PID>/cgroup): "0::<cgroup-path>"
+func ProcessCgroup(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(l, "0::") {
+			return strings.TrimSpace(strings.TrimPrefix(l, "0::"))
+		}
+	}
+	return ""
+}
+
+// MoveProcess moves the process to the cgroup ('cgroupPath' - absolute path to the cgroup directory)
+func MoveProcess(pid int, cgroupPath string) error {
+	f, err := os.OpenFile(filepath.Join(cgroupPath, procsFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(strconv.Itoa(pid))
+	return err
+}
+
+// Pids returns PIDs of the processes in the cgroup ('cgroupPath' - absolute path to the cgroup directory)
+// Returns empty list (and no error) if the cgroup does not exist.
+func Pids(cgroupPath string) ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(cgroupPath, procsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pids []int
+	for _, l := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(l); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,359 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("dbus")
+}
+
+const (
+	defaultSystemBusAddress = "unix:path=/var/run/dbus/system_bus_socket"
+	defaultCallTimeout      = time.Second * 10
+
+	busName      = "org.freedesktop.DBus"
+	busPath      = "/org/freedesktop/DBus"
+	busInterface = "org.freedesktop.DBus"
+	// PropertiesInterface - the standard interface to access object properties
+	PropertiesInterface = "org.freedesktop.DBus.Properties"
+)
+
+// Conn - connection to the D-Bus message bus (minimal client implementation: method calls, properties and signals)
+//
+// Usage example:
+//
+//	c, err := dbus.SystemBus()
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	ret, err := c.Call("org.freedesktop.resolve1", "/org/freedesktop/resolve1", "org.freedesktop.resolve1.Manager", "GetLink", "i", int32(2))
+type Conn struct {
+	conn net.Conn
+
+	writeMutex sync.Mutex
+	serial     uint32
+
+	mutex   sync.Mutex
+	calls   map[uint32]chan *Message
+	signals []chan<- *Message
+	closed  bool
+	err     error // the reason of the connection close
+}
+
+// SystemBus connects to the system message bus
+func SystemBus() (*Conn, error) {
+	addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+	if len(addr) == 0 {
+		addr = defaultSystemBusAddress
+	}
+	return Dial(addr)
+}
+
+// Dial connects to the message bus (only 'unix:path=' addresses are supported)
+func Dial(address string) (*Conn, error) {
+	var path string
+	for _, a := range strings.Split(address, ";") {
+		if p, ok := strings.CutPrefix(a, "unix:path="); ok {
+			path = strings.Split(p, ",")[0]
+			break
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("unsupported D-Bus address '%s'", address)
+	}
+
+	nc, err := net.DialTimeout("unix", path, defaultCallTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect D-Bus: %w", err)
+	}
+
+	c := &Conn{conn: nc, calls: make(map[uint32]chan *Message)}
+	if err := c.auth(); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("D-Bus authentication failed: %w", err)
+	}
+
+	go c.readLoop()
+
+	if _, err := c.Call(busName, busPath, busInterface, "Hello", ""); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("D-Bus 'Hello' failed: %w", err)
+	}
+	return c, nil
+}
+
+// auth performs the EXTERNAL authentication (credentials of the process are passed by the unix socket)
+func (c *Conn) auth() error {
+	c.conn.SetDeadline(time.Now().Add(defaultCallTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+	// read the reply byte-by-byte (the buffered reader is not used to not consume data of the messages)
+	var line []byte
+	for b := make([]byte, 1); len(line) == 0 || line[len(line)-1] != '\n'; {
+		if _, err := c.conn.Read(b); err != nil {
+			return err
+		}
+		if line = append(line, b[0]); len(line) > 1024 {
+			return fmt.Errorf("reply too long")
+		}
+	}
+	if !strings.HasPrefix(string(line), "OK ") {
+		return fmt.Errorf("unexpected reply '%s'", strings.TrimSpace(string(line)))
+	}
+	_, err := c.conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mutex.Unlock()
+	return c.conn.Close()
+}
+
+// IsClosed returns true if the connection is closed (e.g. the message bus was restarted)
+func (c *Conn) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// Call invokes the method and waits for reply. Returns the values of the reply body
+func (c *Conn) Call(dest string, path ObjectPath, iface, method, signature string, args ...interface{}) ([]interface{}, error) {
+	m := &Message{
+		Type:        TypeMethodCall,
+		Path:        path,
+		Interface:   iface,
+		Member:      method,
+		Destination: dest,
+		Signature:   signature,
+		Body:        args,
+	}
+
+	replyChan := make(chan *Message, 1)
+	serial, err := c.send(m, replyChan)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, serial)
+		c.mutex.Unlock()
+	}()
+
+	select {
+	case reply, ok := <-replyChan:
+		if !ok {
+			return nil, c.closeReason()
+		}
+		if reply.Type == TypeError {
+			return nil, reply.toError()
+		}
+		return reply.Body, nil
+	case <-time.After(defaultCallTimeout):
+		return nil, fmt.Errorf("D-Bus call %s.%s timeout", iface, method)
+	}
+}
+
+// GetProperty returns the value of the object property
+func (c *Conn) GetProperty(dest string, path ObjectPath, iface, name string) (interface{}, error) {
+	ret, err := c.Call(dest, path, PropertiesInterface, "Get", "ss", iface, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) != 1 {
+		return nil, fmt.Errorf("unexpected reply for property '%s'", name)
+	}
+	v, ok := ret[0].(Variant)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply for property '%s'", name)
+	}
+	return v.Value, nil
+}
+
+// SetProperty sets the value of the object property
+func (c *Conn) SetProperty(dest string, path ObjectPath, iface, name string, value Variant) error {
+	_, err := c.Call(dest, path, PropertiesInterface, "Set", "ssv", iface, name, value)
+	return err
+}
+
+// NameHasOwner returns true if the bus name is owned by some connection (the service is running)
+func (c *Conn) NameHasOwner(name string) (bool, error) {
+	ret, err := c.Call(busName, busPath, busInterface, "NameHasOwner", "s", name)
+	if err != nil {
+		return false, err
+	}
+	if len(ret) != 1 {
+		return false, fmt.Errorf("unexpected reply")
+	}
+	has, _ := ret[0].(bool)
+	return has, nil
+}
+
+// AddMatch subscribes for the signals defined by the match rule
+// (e.g. "type='signal',sender='org.freedesktop.resolve1',interface='org.freedesktop.DBus.Properties'")
+func (c *Conn) AddMatch(rule string) error {
+	_, err := c.Call(busName, busPath, busInterface, "AddMatch", "s", rule)
+	return err
+}
+
+// Signals registers the channel to receive signals (subscribe to the signals using AddMatch).
+// The signals are dropped when the channel is full. The channel is closed when the connection is closed.
+func (c *Conn) Signals(ch chan<- *Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		close(ch)
+		return
+	}
+	c.signals = append(c.signals, ch)
+}
+
+func (c *Conn) send(m *Message, replyChan chan *Message) (uint32, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.serial++
+	if c.serial == 0 {
+		c.serial++
+	}
+	m.Serial = c.serial
+
+	data, err := m.marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return 0, c.closeReason()
+	}
+	if replyChan != nil {
+		c.calls[m.Serial] = replyChan
+	}
+	c.mutex.Unlock()
+
+	if _, err := c.conn.Write(data); err != nil {
+		return 0, fmt.Errorf("D-Bus write error: %w", err)
+	}
+	return m.Serial, nil
+}
+
+func (c *Conn) closeReason() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return fmt.Errorf("D-Bus connection closed")
+}
+
+func (c *Conn) readLoop() {
+	var err error
+	defer func() {
+		c.mutex.Lock()
+		c.closed = true
+		if c.err == nil {
+			c.err = fmt.Errorf("D-Bus connection closed: %v", err)
+		}
+		for serial, ch := range c.calls {
+			close(ch)
+			delete(c.calls, serial)
+		}
+		for _, ch := range c.signals {
+			close(ch)
+		}
+		c.signals = nil
+		c.mutex.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	for {
+		var m *Message
+		if m, err = readMessage(r); err != nil {
+			return
+		}
+
+		switch m.Type {
+		case TypeMethodReturn, TypeError:
+			c.mutex.Lock()
+			if ch, ok := c.calls[m.ReplySerial]; ok {
+				ch <- m
+				delete(c.calls, m.ReplySerial)
+			}
+			c.mutex.Unlock()
+		case TypeSignal:
+			c.mutex.Lock()
+			for _, ch := range c.signals {
+				select {
+				case ch <- m:
+				default:
+					log.Debug("signal dropped (receiver is busy): ", m.Interface, ".", m.Member)
+				}
+			}
+			c.mutex.Unlock()
+		case TypeMethodCall:
+			// we are not exporting any objects
+			if m.Flags&flagNoReplyExpected == 0 {
+				go c.send(&Message{
+					Type:        TypeError,
+					ErrorName:   "org.freedesktop.DBus.Error.UnknownMethod",
+					ReplySerial: m.Serial,
+					Destination: m.Sender,
+					Signature:   "s",
+					Body:        []interface{}{"not supported"},
+				}, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,524 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// ObjectPath - D-Bus object path (signature 'o')
+type ObjectPath string
+
+// Signature - D-Bus type signature (signature 'g')
+type Signature string
+
+// Variant - D-Bus variant value (signature 'v')
+type Variant struct {
+	Signature string
+	Value     interface{}
+}
+
+// MakeVariant creates new Variant object
+func MakeVariant(signature string, value interface{}) Variant {
+	return Variant{Signature: signature, Value: value}
+}
+
+// Marshaling of the values (see https://dbus.freedesktop.org/doc/dbus-specification.html#message-protocol-marshaling)
+//
+// The Go types used to encode/decode D-Bus types:
+//
+//	y - byte; b - bool; n - int16; q - uint16; i - int32; u - uint32; x - int64; t - uint64; d - float64
+//	s - string; o - ObjectPath; g - Signature; v - Variant
+//	ay - []byte
+//	a{..} - map (decoded as map[interface{}]interface{})
+//	a.. - slice (decoded as []interface{})
+//	(..) - []interface{}
+//
+// On encoding, any integer type can be used for the integer D-Bus types; any slice type can be used for arrays
+// and any map type can be used for dictionaries.
+
+// encoder - encodes the values using little-endian byte order
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) putUint16(v uint16) {
+	e.align(2)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+}
+
+func (e *encoder) putUint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) putUint64(v uint64) {
+	e.align(8)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+// encodeAll encodes the values according to signature (the number of values must correspond to the signature)
+func (e *encoder) encodeAll(signature string, values ...interface{}) error {
+	for i := 0; len(signature) > 0; i++ {
+		sig, rest, err := nextType(signature)
+		if err != nil {
+			return err
+		}
+		if i >= len(values) {
+			return fmt.Errorf("not enough values for signature '%s'", signature)
+		}
+		if err := e.encode(sig, values[i]); err != nil {
+			return err
+		}
+		signature = rest
+		if len(signature) == 0 && i+1 != len(values) {
+			return fmt.Errorf("too many values for the signature")
+		}
+	}
+	return nil
+}
+
+// encode encodes the value of the single complete type
+func (e *encoder) encode(sig string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+
+	switch sig[0] {
+	case 'y':
+		u, err := toUint64(rv)
+		if err != nil {
+			return err
+		}
+		e.buf = append(e.buf, byte(u))
+	case 'b':
+		if rv.Kind() != reflect.Bool {
+			return fmt.Errorf("expected bool value, got %T", v)
+		}
+		var u uint32
+		if rv.Bool() {
+			u = 1
+		}
+		e.putUint32(u)
+	case 'n', 'q':
+		u, err := toUint64(rv)
+		if err != nil {
+			return err
+		}
+		e.putUint16(uint16(u))
+	case 'i', 'u':
+		u, err := toUint64(rv)
+		if err != nil {
+			return err
+		}
+		e.putUint32(uint32(u))
+	case 'x', 't':
+		u, err := toUint64(rv)
+		if err != nil {
+			return err
+		}
+		e.putUint64(u)
+	case 'd':
+		if rv.Kind() != reflect.Float64 && rv.Kind() != reflect.Float32 {
+			return fmt.Errorf("expected float value, got %T", v)
+		}
+		e.putUint64(math.Float64bits(rv.Float()))
+	case 's', 'o':
+		if rv.Kind() != reflect.String {
+			return fmt.Errorf("expected string value, got %T", v)
+		}
+		s := rv.String()
+		e.putUint32(uint32(len(s)))
+		e.buf = append(e.buf, s...)
+		e.buf = append(e.buf, 0)
+	case 'g':
+		if rv.Kind() != reflect.String {
+			return fmt.Errorf("expected signature value, got %T", v)
+		}
+		s := rv.String()
+		if len(s) > 255 {
+			return fmt.Errorf("signature too long")
+		}
+		e.buf = append(e.buf, byte(len(s)))
+		e.buf = append(e.buf, s...)
+		e.buf = append(e.buf, 0)
+	case 'v':
+		variant, ok := v.(Variant)
+		if !ok {
+			return fmt.Errorf("expected Variant value, got %T", v)
+		}
+		if err := e.encode("g", variant.Signature); err != nil {
+			return err
+		}
+		if _, rest, err := nextType(variant.Signature); err != nil || len(rest) > 0 {
+			return fmt.Errorf("bad variant signature '%s'", variant.Signature)
+		}
+		return e.encode(variant.Signature, variant.Value)
+	case 'a':
+		return e.encodeArray(sig[1:], rv)
+	case '(':
+		fields, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected []interface{} value for struct, got %T", v)
+		}
+		e.align(8)
+		return e.encodeAll(sig[1:len(sig)-1], fields...)
+	default:
+		return fmt.Errorf("unsupported type '%s'", sig)
+	}
+	return nil
+}
+
+func (e *encoder) encodeArray(elemSig string, rv reflect.Value) error {
+	e.putUint32(0) // length placeholder
+	lenPos := len(e.buf) - 4
+	e.align(alignment(elemSig[0]))
+	start := len(e.buf)
+
+	if elemSig[0] == '{' {
+		keySig, valSig, err := dictEntryTypes(elemSig)
+		if err != nil {
+			return err
+		}
+		if rv.Kind() != reflect.Map {
+			return fmt.Errorf("expected map value for dictionary, got %s", rv.Kind())
+		}
+		keys := rv.MapKeys()
+		// stable order of the entries
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface()) })
+		for _, k := range keys {
+			e.align(8)
+			if err := e.encode(keySig, k.Interface()); err != nil {
+				return err
+			}
+			if err := e.encode(valSig, rv.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+	} else {
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			if !rv.IsValid() { // nil value - empty array
+				binary.LittleEndian.PutUint32(e.buf[lenPos:], 0)
+				return nil
+			}
+			return fmt.Errorf("expected slice value for array, got %s", rv.Kind())
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.encode(elemSig, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+
+	binary.LittleEndian.PutUint32(e.buf[lenPos:], uint32(len(e.buf)-start))
+	return nil
+}
+
+func toUint64(rv reflect.Value) (uint64, error) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	default:
+		return 0, fmt.Errorf("expected integer value, got %s", rv.Kind())
+	}
+}
+
+type decoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return fmt.Errorf("unexpected end of message")
+	}
+	return nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) || n < 0 {
+		return nil, fmt.Errorf("unexpected end of message")
+	}
+	ret := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return ret, nil
+}
+
+func (d *decoder) readUint(size int) (uint64, error) {
+	if err := d.align(size); err != nil {
+		return 0, err
+	}
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(d.order.Uint16(b)), nil
+	case 4:
+		return uint64(d.order.Uint32(b)), nil
+	default:
+		return d.order.Uint64(b), nil
+	}
+}
+
+func (d *decoder) readString(lenSize int) (string, error) {
+	l, err := d.readUint(lenSize)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.read(int(l) + 1) // including trailing zero
+	if err != nil {
+		return "", err
+	}
+	return string(b[:l]), nil
+}
+
+// decodeAll decodes all values defined by signature
+func (d *decoder) decodeAll(signature string) ([]interface{}, error) {
+	var ret []interface{}
+	for len(signature) > 0 {
+		sig, rest, err := nextType(signature)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(sig, 0)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+		signature = rest
+	}
+	return ret, nil
+}
+
+// decode decodes the value of the single complete type
+func (d *decoder) decode(sig string, depth int) (interface{}, error) {
+	if depth > 64 {
+		return nil, fmt.Errorf("message nesting too deep")
+	}
+
+	switch sig[0] {
+	case 'y':
+		v, err := d.readUint(1)
+		return byte(v), err
+	case 'b':
+		v, err := d.readUint(4)
+		return v != 0, err
+	case 'n':
+		v, err := d.readUint(2)
+		return int16(v), err
+	case 'q':
+		v, err := d.readUint(2)
+		return uint16(v), err
+	case 'i':
+		v, err := d.readUint(4)
+		return int32(v), err
+	case 'u':
+		v, err := d.readUint(4)
+		return uint32(v), err
+	case 'x':
+		v, err := d.readUint(8)
+		return int64(v), err
+	case 't':
+		return d.readUint(8)
+	case 'd':
+		v, err := d.readUint(8)
+		return math.Float64frombits(v), err
+	case 's':
+		return d.readString(4)
+	case 'o':
+		s, err := d.readString(4)
+		return ObjectPath(s), err
+	case 'g':
+		s, err := d.readString(1)
+		return Signature(s), err
+	case 'v':
+		s, err := d.readString(1)
+		if err != nil {
+			return nil, err
+		}
+		if _, rest, err := nextType(s); err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("bad variant signature '%s'", s)
+		}
+		v, err := d.decode(s, depth+1)
+		return Variant{Signature: s, Value: v}, err
+	case 'a':
+		return d.decodeArray(sig[1:], depth)
+	case '(':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		var ret []interface{}
+		fields := sig[1 : len(sig)-1]
+		for len(fields) > 0 {
+			fSig, rest, err := nextType(fields)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(fSig, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+			fields = rest
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("unsupported type '%s'", sig)
+	}
+}
+
+func (d *decoder) decodeArray(elemSig string, depth int) (interface{}, error) {
+	l, err := d.readUint(4)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.align(alignment(elemSig[0])); err != nil {
+		return nil, err
+	}
+	end := d.pos + int(l)
+	if end > len(d.buf) {
+		return nil, fmt.Errorf("unexpected end of message")
+	}
+
+	if elemSig == "y" {
+		b, err := d.read(int(l))
+		return append([]byte(nil), b...), err
+	}
+
+	if elemSig[0] == '{' {
+		keySig, valSig, err := dictEntryTypes(elemSig)
+		if err != nil {
+			return nil, err
+		}
+		ret := make(map[interface{}]interface{})
+		for d.pos < end {
+			if err := d.align(8); err != nil {
+				return nil, err
+			}
+			k, err := d.decode(keySig, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.decode(valSig, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = v
+		}
+		return ret, nil
+	}
+
+	ret := []interface{}{}
+	for d.pos < end {
+		v, err := d.decode(elemSig, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// nextType returns the first complete type of the signature and the rest of the signature
+func nextType(sig string) (string, string, error) {
+	if len(sig) == 0 {
+		return "", "", fmt.Errorf("empty signature")
+	}
+	switch sig[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	case 'a':
+		elem, rest, err := nextType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + elem, rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != closing {
+			_, rest, err := nextType(sig[i:])
+			if err != nil {
+				return "", "", err
+			}
+			i = len(sig) - len(rest)
+		}
+		if i >= len(sig) || i == 1 {
+			return "", "", fmt.Errorf("bad signature '%s'", sig)
+		}
+		return sig[:i+1], sig[i+1:], nil
+	default:
+		return "", "", fmt.Errorf("bad signature '%s'", sig)
+	}
+}
+
+// dictEntryTypes returns key and value types of the dictionary entry signature ('{..}')
+func dictEntryTypes(sig string) (keySig, valSig string, err error) {
+	inner := sig[1 : len(sig)-1]
+	keySig, rest, err := nextType(inner)
+	if err != nil {
+		return "", "", err
+	}
+	valSig, rest, err = nextType(rest)
+	if err != nil || len(rest) > 0 {
+		return "", "", fmt.Errorf("bad dictionary signature '%s'", sig)
+	}
+	return keySig, valSig, nil
+}
+
+func alignment(t byte) int {
+	switch t {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a', 'h':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	default: // 'y', 'g', 'v'
+		return 1
+	}
+}
@@ -0,0 +1,211 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Message types
+const (
+	TypeMethodCall   byte = 1
+	TypeMethodReturn byte = 2
+	TypeError        byte = 3
+	TypeSignal       byte = 4
+)
+
+const (
+	flagNoReplyExpected = 0x1
+	protocolVersion     = 1
+	maxMessageSize      = 128 * 1024 * 1024
+)
+
+// Header field codes
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// Message - D-Bus message
+type Message struct {
+	Type        byte
+	Flags       byte
+	Serial      uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   string
+	Body        []interface{}
+}
+
+// Error - the error received from D-Bus peer
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e Error) Error() string {
+	if len(e.Message) > 0 {
+		return fmt.Sprintf("%s: %s", e.Name, e.Message)
+	}
+	return e.Name
+}
+
+// marshal returns the binary representation of the message
+func (m *Message) marshal() ([]byte, error) {
+	body := &encoder{}
+	if err := body.encodeAll(m.Signature, m.Body...); err != nil {
+		return nil, fmt.Errorf("failed to encode message body: %w", err)
+	}
+
+	var fields []interface{}
+	addField := func(code byte, sig string, v interface{}) {
+		fields = append(fields, []interface{}{code, MakeVariant(sig, v)})
+	}
+	if len(m.Path) > 0 {
+		addField(fieldPath, "o", m.Path)
+	}
+	if len(m.Interface) > 0 {
+		addField(fieldInterface, "s", m.Interface)
+	}
+	if len(m.Member) > 0 {
+		addField(fieldMember, "s", m.Member)
+	}
+	if len(m.ErrorName) > 0 {
+		addField(fieldErrorName, "s", m.ErrorName)
+	}
+	if m.ReplySerial != 0 {
+		addField(fieldReplySerial, "u", m.ReplySerial)
+	}
+	if len(m.Destination) > 0 {
+		addField(fieldDestination, "s", m.Destination)
+	}
+	if len(m.Signature) > 0 {
+		addField(fieldSignature, "g", Signature(m.Signature))
+	}
+
+	hdr := &encoder{}
+	hdr.buf = append(hdr.buf, 'l', m.Type, m.Flags, protocolVersion)
+	hdr.putUint32(uint32(len(body.buf)))
+	hdr.putUint32(m.Serial)
+	if err := hdr.encode("a(yv)", fields); err != nil {
+		return nil, fmt.Errorf("failed to encode message header: %w", err)
+	}
+	hdr.align(8)
+
+	return append(hdr.buf, body.buf...), nil
+}
+
+// readMessage reads and parses the message from the stream
+func readMessage(r io.Reader) (*Message, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("bad message endianness")
+	}
+	bodyLen := int(order.Uint32(fixed[4:8]))
+	fieldsLen := int(order.Uint32(fixed[12:16]))
+	hdrLen := 16 + fieldsLen
+	if hdrLen%8 != 0 {
+		hdrLen += 8 - hdrLen%8
+	}
+	if bodyLen < 0 || fieldsLen < 0 || hdrLen+bodyLen > maxMessageSize {
+		return nil, fmt.Errorf("message too large")
+	}
+
+	buf := make([]byte, hdrLen+bodyLen)
+	copy(buf, fixed)
+	if _, err := io.ReadFull(r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	m := &Message{Type: fixed[1], Flags: fixed[2], Serial: order.Uint32(fixed[8:12])}
+
+	d := &decoder{buf: buf[:16+fieldsLen], pos: 12, order: order}
+	fields, err := d.decode("a(yv)", 0)
+	if err != nil {
+		return nil, fmt.Errorf("bad message header: %w", err)
+	}
+	for _, f := range fields.([]interface{}) {
+		st := f.([]interface{})
+		code, v := st[0].(byte), st[1].(Variant).Value
+		switch code {
+		case fieldPath:
+			m.Path, _ = v.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = v.(string)
+		case fieldMember:
+			m.Member, _ = v.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = v.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = v.(uint32)
+		case fieldDestination:
+			m.Destination, _ = v.(string)
+		case fieldSender:
+			m.Sender, _ = v.(string)
+		case fieldSignature:
+			sig, _ := v.(Signature)
+			m.Signature = string(sig)
+		}
+	}
+
+	body := &decoder{buf: buf[hdrLen:], order: order}
+	if m.Body, err = body.decodeAll(m.Signature); err != nil {
+		return nil, fmt.Errorf("bad message body: %w", err)
+	}
+	return m, nil
+}
+
+// toError converts the error message to Error object
+func (m *Message) toError() error {
+	e := Error{Name: m.ErrorName}
+	if len(m.Body) > 0 {
+		e.Message, _ = m.Body[0].(string)
+	}
+	return e
+}
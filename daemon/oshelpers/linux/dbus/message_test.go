@@ -0,0 +1,108 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMessageMarshal(t *testing.T) {
+	m := &Message{
+		Type:        TypeMethodCall,
+		Serial:      7,
+		Path:        "/org/freedesktop/resolve1",
+		Interface:   "org.freedesktop.resolve1.Manager",
+		Member:      "SetLinkDNS",
+		Destination: "org.freedesktop.resolve1",
+		Signature:   "ia(iay)a{sv}b",
+		Body: []interface{}{
+			int32(3),
+			[]interface{}{
+				[]interface{}{int32(2), []byte{10, 0, 0, 1}},
+				[]interface{}{int32(10), make([]byte, 16)},
+			},
+			map[string]Variant{"servers": MakeVariant("as", []string{"1.1.1.1"}), "n": MakeVariant("t", uint64(5))},
+			true,
+		},
+	}
+
+	data, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := readMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret.Type != m.Type || ret.Serial != m.Serial || ret.Path != m.Path || ret.Interface != m.Interface ||
+		ret.Member != m.Member || ret.Destination != m.Destination || ret.Signature != m.Signature {
+		t.Fatalf("header mismatch: %+v", ret)
+	}
+
+	expected := []interface{}{
+		int32(3),
+		[]interface{}{
+			[]interface{}{int32(2), []byte{10, 0, 0, 1}},
+			[]interface{}{int32(10), make([]byte, 16)},
+		},
+		map[interface{}]interface{}{
+			"servers": MakeVariant("as", []interface{}{"1.1.1.1"}),
+			"n":       MakeVariant("t", uint64(5)),
+		},
+		true,
+	}
+	if !reflect.DeepEqual(ret.Body, expected) {
+		t.Errorf("body mismatch:\n got: %#v\nwant: %#v", ret.Body, expected)
+	}
+
+	// decoded values must be encodable again
+	if _, err := ret.marshal(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNextType(t *testing.T) {
+	tests := []struct{ sig, first, rest string }{
+		{"ia(iay)", "i", "a(iay)"},
+		{"a(iay)b", "a(iay)", "b"},
+		{"a{sa{sv}}t", "a{sa{sv}}", "t"},
+		{"(i(ss))", "(i(ss))", ""},
+	}
+	for _, tc := range tests {
+		first, rest, err := nextType(tc.sig)
+		if err != nil || first != tc.first || rest != tc.rest {
+			t.Errorf("%s: got (%s, %s, %v)", tc.sig, first, rest, err)
+		}
+	}
+	for _, bad := range []string{"a", "(i", "()", "z"} {
+		if _, _, err := nextType(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
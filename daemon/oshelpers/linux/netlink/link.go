@@ -0,0 +1,142 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Constants from linux/if_link.h and linux/if_addr.h
+const (
+	_IFLA_INFO_KIND = 1
+
+	_IFA_F_NODAD = 0x02
+
+	_sizeofIfInfoMsg = 16 // struct ifinfomsg
+	_sizeofIfAddrMsg = 8  // struct ifaddrmsg
+)
+
+// LinkAdd creates the network interface of the specified kind (e.g. 'wireguard')
+func LinkAdd(name string, kind string) error {
+	msg := newIfInfoMsg(0, 0, 0)
+	msg = append(msg, newAttr(syscall.IFLA_IFNAME, zeroTerminated(name))...)
+	msg = append(msg, newAttr(syscall.IFLA_LINKINFO, newAttr(_IFLA_INFO_KIND, []byte(kind)))...)
+
+	if err := rtnlAck(syscall.RTM_NEWLINK, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, msg); err != nil {
+		return fmt.Errorf("failed to create interface '%s' (%s): %w", name, kind, err)
+	}
+	return nil
+}
+
+// LinkDel removes the network interface (if exists)
+func LinkDel(name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil // interface does not exist
+	}
+	if err := rtnlAck(syscall.RTM_DELLINK, 0, newIfInfoMsg(iface.Index, 0, 0)); err != nil && err != syscall.ENODEV {
+		return fmt.Errorf("failed to remove interface '%s': %w", name, err)
+	}
+	return nil
+}
+
+// LinkSetUp sets the network interface up (and changes its MTU, when 'mtu' > 0)
+func LinkSetUp(name string, mtu int) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	msg := newIfInfoMsg(iface.Index, syscall.IFF_UP, syscall.IFF_UP)
+	if mtu > 0 {
+		msg = append(msg, attrUint32(syscall.IFLA_MTU, uint32(mtu))...)
+	}
+	if err := rtnlAck(syscall.RTM_NEWLINK, 0, msg); err != nil {
+		return fmt.Errorf("failed to set interface '%s' up: %w", name, err)
+	}
+	return nil
+}
+
+// LinkSetMTU changes MTU of the network interface
+func LinkSetMTU(name string, mtu int) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	msg := newIfInfoMsg(iface.Index, 0, 0)
+	msg = append(msg, attrUint32(syscall.IFLA_MTU, uint32(mtu))...)
+	if err := rtnlAck(syscall.RTM_NEWLINK, 0, msg); err != nil {
+		return fmt.Errorf("failed to set MTU of interface '%s': %w", name, err)
+	}
+	return nil
+}
+
+// AddrAdd assigns the IP address to the network interface
+// (the duplicate address detection is disabled for IPv6 addresses)
+func AddrAdd(name string, addr net.IPNet) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+
+	isIPv6 := addr.IP.To4() == nil
+	ip := addr.IP.To4()
+	if isIPv6 {
+		ip = addr.IP.To16()
+	}
+	prefixLen, _ := addr.Mask.Size()
+
+	msg := make([]byte, _sizeofIfAddrMsg)
+	msg[0] = familyOf(isIPv6)
+	msg[1] = byte(prefixLen)
+	if isIPv6 {
+		msg[2] = _IFA_F_NODAD
+	}
+	msg[3] = syscall.RT_SCOPE_UNIVERSE
+	binary.LittleEndian.PutUint32(msg[4:8], uint32(iface.Index))
+	msg = append(msg, newAttr(syscall.IFA_LOCAL, ip)...)
+	msg = append(msg, newAttr(syscall.IFA_ADDRESS, ip)...)
+
+	if err := rtnlAck(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_REPLACE, msg); err != nil {
+		return fmt.Errorf("failed to add address %s to '%s': %w", addr.String(), name, err)
+	}
+	return nil
+}
+
+func newIfInfoMsg(index int, flags uint32, change uint32) []byte {
+	msg := make([]byte, _sizeofIfInfoMsg)
+	msg[0] = syscall.AF_UNSPEC
+	binary.LittleEndian.PutUint32(msg[4:8], uint32(index))
+	binary.LittleEndian.PutUint32(msg[8:12], flags)
+	binary.LittleEndian.PutUint32(msg[12:16], change)
+	return msg
+}
+
+func zeroTerminated(s string) []byte {
+	return append([]byte(s), 0)
+}
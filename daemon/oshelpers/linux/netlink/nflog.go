@@ -0,0 +1,318 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// ErrNflogListenerClosed is returned by NflogListener.ReadPackets() when the listener is closed
+var ErrNflogListenerClosed = errors.New("NflogListener closed")
+
+// Constants from linux/netfilter/nfnetlink.h and linux/netfilter/nfnetlink_log.h
+const (
+	_NETLINK_NETFILTER = 12
+	_NFNL_SUBSYS_ULOG  = 4
+	_NFNETLINK_V0      = 0
+
+	_NFULNL_MSG_PACKET = 0
+	_NFULNL_MSG_CONFIG = 1
+
+	_NFULA_CFG_CMD   = 1
+	_NFULA_CFG_MODE  = 2
+	_NFULA_CFG_FLAGS = 6
+
+	_NFULNL_CFG_CMD_BIND   = 1
+	_NFULNL_CFG_CMD_UNBIND = 2
+
+	_NFULNL_COPY_PACKET = 0x02
+	_NFULNL_CFG_F_UID   = 0x0001
+
+	_NFULA_IFINDEX_INDEV  = 4
+	_NFULA_IFINDEX_OUTDEV = 5
+	_NFULA_PAYLOAD        = 9
+	_NFULA_PREFIX         = 10
+	_NFULA_UID            = 11
+
+	_NLA_TYPE_MASK = 0x3fff
+)
+
+// NflogPacket is a packet received from the kernel NFLOG target
+type NflogPacket struct {
+	Prefix   string // value of '--nflog-prefix' of the rule which logged the packet
+	InIface  uint32 // index of input interface (0 - if not defined)
+	OutIface uint32 // index of output interface (0 - if not defined)
+	Uid      int    // UID of the socket owner (-1 - if not defined; available only for locally generated packets)
+	Payload  []byte // network layer packet (starting from IP header)
+}
+
+// NflogListener provides possibility to receive packets logged by NFLOG iptables/nftables target
+//
+// Usage example:
+//
+//	l, err := CreateNflogListener(100)
+//	if err != nil {
+//		fmt.Printf("Failed to initialize NFLOG listener: %s\n", err)
+//		return
+//	}
+//	for {
+//		packets, err := l.ReadPackets()
+//		if err == ErrNflogListenerClosed {
+//			return
+//		}
+//		if err != nil {
+//			fmt.Printf("Could not read NFLOG packets: %s\n", err)
+//		}
+//		for _, p := range packets {
+//			fmt.Println(p.Prefix, len(p.Payload))
+//		}
+//	}
+type NflogListener struct {
+	fd    int
+	group uint16
+	seq   uint32
+
+	isClosed  atomic.Bool
+	readMutex sync.Mutex // locked while reading from the socket (Close() waits until the reading finished)
+}
+
+// CreateNflogListener creates new NflogListener object bound to the NFLOG group
+func CreateNflogListener(group uint16) (*NflogListener, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, _NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %s", err)
+	}
+
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err = syscall.Bind(s, addr); err != nil {
+		syscall.Close(s)
+		return nil, fmt.Errorf("socket binding error: %s", err)
+	}
+
+	// limit the time of blocking read (it gives possibility to stop reading without closing the socket)
+	if err := syscall.SetsockoptTimeval(s, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &syscall.Timeval{Sec: 1}); err != nil {
+		syscall.Close(s)
+		return nil, fmt.Errorf("failed to set socket options: %s", err)
+	}
+
+	l := &NflogListener{fd: s, group: group}
+
+	// bind to the NFLOG group
+	if err := l.sendConfig(syscall.AF_UNSPEC, attrCmd(_NFULNL_CFG_CMD_BIND)); err != nil {
+		syscall.Close(s)
+		return nil, fmt.Errorf("failed to bind NFLOG group %d: %w", group, err)
+	}
+	// request to copy packet payload (only headers are required: 128 bytes is enough for IPv6 + TCP/UDP headers)
+	if err := l.sendConfig(syscall.AF_UNSPEC, attrCopyMode(_NFULNL_COPY_PACKET, 128)); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set NFLOG copy mode: %w", err)
+	}
+	// request UID of the socket owner (not fatal if not supported by kernel)
+	if err := l.sendConfig(syscall.AF_UNSPEC, attrFlags(_NFULNL_CFG_F_UID)); err != nil {
+		log.Warning(fmt.Errorf("failed to set NFLOG flags (UID info will not be available): %w", err))
+	}
+
+	return l, nil
+}
+
+// ReadPackets returns received packets
+// (blocking call; empty result when no packets received during 1 second; ErrNflogListenerClosed - when the listener is closed)
+func (l *NflogListener) ReadPackets() (ret []NflogPacket, retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("NflogListener read panic: %v", r)
+		}
+	}()
+
+	l.readMutex.Lock()
+	defer l.readMutex.Unlock()
+	if l.isClosed.Load() {
+		return nil, ErrNflogListenerClosed
+	}
+
+	buf := make([]byte, 65536)
+	n, err := syscall.Read(l.fd, buf)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NflogListener read error: %s", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("NflogListener parse error: %s", err)
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != (_NFNL_SUBSYS_ULOG<<8)|_NFULNL_MSG_PACKET {
+			continue
+		}
+		if len(m.Data) < 4 { // nfgenmsg
+			continue
+		}
+		p := NflogPacket{Uid: -1}
+		forEachAttr(m.Data[4:], func(attrType uint16, val []byte) {
+			switch attrType {
+			case _NFULA_PREFIX:
+				p.Prefix = strings.TrimRight(string(val), "\x00")
+			case _NFULA_IFINDEX_INDEV:
+				if len(val) >= 4 {
+					p.InIface = binary.BigEndian.Uint32(val)
+				}
+			case _NFULA_IFINDEX_OUTDEV:
+				if len(val) >= 4 {
+					p.OutIface = binary.BigEndian.Uint32(val)
+				}
+			case _NFULA_UID:
+				if len(val) >= 4 {
+					p.Uid = int(binary.BigEndian.Uint32(val))
+				}
+			case _NFULA_PAYLOAD:
+				p.Payload = append([]byte(nil), val...)
+			}
+		})
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// Close unbinds the NFLOG group, closes the listener and releases resources.
+// If ReadPackets() is in progress - waits until it finished (up to 1 second).
+func (l *NflogListener) Close() error {
+	if l.isClosed.Swap(true) {
+		return nil
+	}
+
+	// ensure no one is reading from the socket (the ACK for UNBIND must not be consumed by the reader)
+	l.readMutex.Lock()
+	defer l.readMutex.Unlock()
+
+	if err := l.sendConfig(syscall.AF_UNSPEC, attrCmd(_NFULNL_CFG_CMD_UNBIND)); err != nil {
+		log.Warning(fmt.Errorf("failed to unbind NFLOG group %d: %w", l.group, err))
+	}
+	return syscall.Close(l.fd)
+}
+
+func (l *NflogListener) sendConfig(family uint8, attr []byte) error {
+	l.seq++
+
+	const hdrLen = syscall.NLMSG_HDRLEN
+	msg := make([]byte, hdrLen+4+len(attr))
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:6], (_NFNL_SUBSYS_ULOG<<8)|_NFULNL_MSG_CONFIG)
+	binary.LittleEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.LittleEndian.PutUint32(msg[8:12], l.seq)
+	// nfgenmsg
+	msg[hdrLen] = family
+	msg[hdrLen+1] = _NFNETLINK_V0
+	binary.BigEndian.PutUint16(msg[hdrLen+2:hdrLen+4], l.group)
+	copy(msg[hdrLen+4:], attr)
+
+	if err := syscall.Sendto(l.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	// wait for ACK (the socket has receive timeout: give up when no data received during the timeout)
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(l.fd, buf)
+		if err == syscall.EAGAIN {
+			return fmt.Errorf("netlink ACK not received")
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != l.seq || m.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return fmt.Errorf("unexpected netlink ACK size")
+			}
+			if errno := int32(binary.LittleEndian.Uint32(m.Data[0:4])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+func newAttr(attrType uint16, val []byte) []byte {
+	l := syscall.SizeofRtAttr + len(val)
+	ret := make([]byte, (l+syscall.NLMSG_ALIGNTO-1) & ^(syscall.NLMSG_ALIGNTO-1))
+	binary.LittleEndian.PutUint16(ret[0:2], uint16(l))
+	binary.LittleEndian.PutUint16(ret[2:4], attrType)
+	copy(ret[4:], val)
+	return ret
+}
+
+func attrCmd(cmd uint8) []byte {
+	return newAttr(_NFULA_CFG_CMD, []byte{cmd})
+}
+
+func attrCopyMode(mode uint8, copyRange uint32) []byte {
+	val := make([]byte, 6) // struct nfulnl_msg_config_mode
+	binary.BigEndian.PutUint32(val[0:4], copyRange)
+	val[4] = mode
+	return newAttr(_NFULA_CFG_MODE, val)
+}
+
+func attrFlags(flags uint16) []byte {
+	val := make([]byte, 2)
+	binary.BigEndian.PutUint16(val, flags)
+	return newAttr(_NFULA_CFG_FLAGS, val)
+}
+
+func forEachAttr(data []byte, f func(attrType uint16, val []byte)) {
+	for len(data) >= syscall.SizeofRtAttr {
+		l := int(binary.LittleEndian.Uint16(data[0:2]))
+		t := binary.LittleEndian.Uint16(data[2:4]) & _NLA_TYPE_MASK
+		if l < syscall.SizeofRtAttr || l > len(data) {
+			return
+		}
+		f(t, data[syscall.SizeofRtAttr:l])
+
+		aligned := (l + syscall.NLMSG_ALIGNTO - 1) & ^(syscall.NLMSG_ALIGNTO - 1)
+		if aligned > len(data) {
+			return
+		}
+		data = data[aligned:]
+	}
+}
@@ -0,0 +1,170 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+)
+
+// Constants from linux/connector.h and linux/cn_proc.h
+const (
+	_NETLINK_CONNECTOR = 11
+	_CN_IDX_PROC       = 1
+	_CN_VAL_PROC       = 1
+
+	_PROC_CN_MCAST_LISTEN = 1
+	_PROC_CN_MCAST_IGNORE = 2
+
+	_PROC_EVENT_EXEC = 0x00000002
+
+	_sizeofCnMsg = 20 // struct cn_msg (without data)
+)
+
+// ProcEventsListener provides possibility to receive notifications about processes
+// which executed new binary (the kernel process events connector)
+//
+// Usage example:
+//
+//	l, err := CreateProcEventsListener()
+//	if err != nil {
+//		fmt.Printf("Failed to initialize process events listener: %s\n", err)
+//		return
+//	}
+//	for {
+//		pids, err := l.ReadExecEvents()
+//		if err != nil {
+//			fmt.Printf("Could not read process events: %s\n", err)
+//		}
+//		for _, pid := range pids {
+//			fmt.Println("New binary executed by process", pid)
+//		}
+//	}
+type ProcEventsListener struct {
+	fd  int
+	seq uint32
+}
+
+// CreateProcEventsListener creates new ProcEventsListener object (requires CAP_NET_ADMIN)
+func CreateProcEventsListener() (*ProcEventsListener, error) {
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, _NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %s", err)
+	}
+
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: _CN_IDX_PROC}
+	if err = syscall.Bind(s, addr); err != nil {
+		syscall.Close(s)
+		return nil, fmt.Errorf("socket binding error: %s", err)
+	}
+
+	// limit the time of blocking read (it gives possibility to stop reading events without closing the socket)
+	if err := syscall.SetsockoptTimeval(s, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &syscall.Timeval{Sec: 1}); err != nil {
+		syscall.Close(s)
+		return nil, fmt.Errorf("failed to set socket options: %s", err)
+	}
+
+	l := &ProcEventsListener{fd: s}
+	if err := l.sendOp(_PROC_CN_MCAST_LISTEN); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to subscribe to process events: %w", err)
+	}
+	return l, nil
+}
+
+// ReadExecEvents returns PIDs of the processes which executed new binary
+// (blocking call; empty result when no events received during 1 second)
+func (l *ProcEventsListener) ReadExecEvents() (pids []int, retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("ProcEventsListener read panic: %v", r)
+		}
+	}()
+
+	buf := make([]byte, 65536)
+	n, err := syscall.Read(l.fd, buf)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ProcEventsListener read error: %s", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("ProcEventsListener parse error: %s", err)
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != syscall.NLMSG_DONE || len(m.Data) < _sizeofCnMsg {
+			continue
+		}
+		if binary.LittleEndian.Uint32(m.Data[0:4]) != _CN_IDX_PROC || binary.LittleEndian.Uint32(m.Data[4:8]) != _CN_VAL_PROC {
+			continue
+		}
+		// struct proc_event: what(4), cpu(4), timestamp_ns(8), event_data
+		ev := m.Data[_sizeofCnMsg:]
+		if len(ev) < 24 || binary.LittleEndian.Uint32(ev[0:4]) != _PROC_EVENT_EXEC {
+			continue
+		}
+		// struct exec_proc_event: process_pid(4), process_tgid(4)
+		tgid := int(int32(binary.LittleEndian.Uint32(ev[20:24])))
+		pids = append(pids, tgid)
+	}
+	return pids, nil
+}
+
+// Close unsubscribes from process events, closes the listener and releases resources
+func (l *ProcEventsListener) Close() error {
+	if l.fd != 0 {
+		l.sendOp(_PROC_CN_MCAST_IGNORE)
+		err := syscall.Close(l.fd)
+		l.fd = 0
+		return err
+	}
+	return nil
+}
+
+func (l *ProcEventsListener) sendOp(op uint32) error {
+	l.seq++
+
+	const hdrLen = syscall.NLMSG_HDRLEN
+	msg := make([]byte, hdrLen+_sizeofCnMsg+4)
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:6], syscall.NLMSG_DONE)
+	binary.LittleEndian.PutUint32(msg[8:12], l.seq)
+	binary.LittleEndian.PutUint32(msg[12:16], uint32(syscall.Getpid()))
+	// struct cn_msg
+	cn := msg[hdrLen:]
+	binary.LittleEndian.PutUint32(cn[0:4], _CN_IDX_PROC)
+	binary.LittleEndian.PutUint32(cn[4:8], _CN_VAL_PROC)
+	binary.LittleEndian.PutUint32(cn[8:12], l.seq)
+	binary.LittleEndian.PutUint16(cn[16:18], 4) // data length
+	binary.LittleEndian.PutUint32(cn[_sizeofCnMsg:], op)
+
+	return syscall.Sendto(l.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
@@ -0,0 +1,392 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+)
+
+// Constants from linux/fib_rules.h and linux/rtnetlink.h
+const (
+	_FRA_PRIORITY           = 6
+	_FRA_FWMARK             = 10
+	_FRA_SUPPRESS_PREFIXLEN = 14
+	_FRA_TABLE              = 15
+	_FRA_FWMASK             = 16
+
+	_FR_ACT_TO_TBL   = 1
+	_FIB_RULE_INVERT = 0x2
+
+	_sizeofRtMsg      = 12 // struct rtmsg
+	_sizeofFibRuleHdr = 12 // struct fib_rule_hdr
+)
+
+var rtnlSeq uint32
+
+// Route - the routing table entry (only the parameters required by the daemon)
+type Route struct {
+	IsIPv6   bool
+	Table    int
+	Dst      *net.IPNet // nil - default route
+	Gateway  net.IP
+	OutIface int // index of output interface
+	Metric   int
+}
+
+// Rule - the routing policy rule which forwards packets to the routing table
+// (only the parameters required by the daemon)
+type Rule struct {
+	IsIPv6   bool
+	Priority int // 0 - not defined (the kernel assigns the priority: the rule is added on the top)
+	Table    int
+	Mark     uint32 // 0 - not defined
+	Invert   bool   // 'not' rule (e.g. 'not from all fwmark 0xca6c lookup 51820')
+	// 'suppress_prefixlength' value (-1 - not defined)
+	// Note: the zero value is meaningful ('suppress_prefixlength 0' - ignore default routes of the table)
+	SuppressPrefixLen int
+}
+
+// GetRoutes returns all routes from the routing table
+func GetRoutes(isIPv6 bool, table int) ([]Route, error) {
+	msgs, err := rtnlRequest(syscall.RTM_GETROUTE, syscall.NLM_F_DUMP, newRtMsg(isIPv6, 0, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get routes: %w", err)
+	}
+
+	var ret []Route
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < _sizeofRtMsg {
+			continue
+		}
+		r := Route{IsIPv6: isIPv6, Table: int(m.Data[4])}
+		if m.Data[7] != syscall.RTN_UNICAST {
+			continue
+		}
+		dstLen := int(m.Data[1])
+		var dst net.IP
+		forEachAttr(m.Data[_sizeofRtMsg:], func(attrType uint16, val []byte) {
+			switch attrType {
+			case syscall.RTA_DST:
+				dst = net.IP(append([]byte(nil), val...))
+			case syscall.RTA_GATEWAY:
+				r.Gateway = net.IP(append([]byte(nil), val...))
+			case syscall.RTA_OIF:
+				if len(val) >= 4 {
+					r.OutIface = int(binary.LittleEndian.Uint32(val))
+				}
+			case syscall.RTA_PRIORITY:
+				if len(val) >= 4 {
+					r.Metric = int(binary.LittleEndian.Uint32(val))
+				}
+			case syscall.RTA_TABLE:
+				if len(val) >= 4 {
+					r.Table = int(binary.LittleEndian.Uint32(val))
+				}
+			}
+		})
+		if r.Table != table {
+			continue
+		}
+		if dst != nil {
+			r.Dst = &net.IPNet{IP: dst, Mask: net.CIDRMask(dstLen, len(dst)*8)}
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+// GetDefaultRoute returns the default route of the main routing table (the route with the lowest metric)
+func GetDefaultRoute(isIPv6 bool) (gateway net.IP, ifaceIndex int, err error) {
+	routes, err := GetRoutes(isIPv6, syscall.RT_TABLE_MAIN)
+	if err != nil {
+		return nil, 0, err
+	}
+	var def *Route
+	for i, r := range routes {
+		if r.Dst != nil || r.OutIface <= 0 {
+			continue
+		}
+		if def == nil || r.Metric < def.Metric {
+			def = &routes[i]
+		}
+	}
+	if def == nil {
+		return nil, 0, fmt.Errorf("default route not found")
+	}
+	return def.Gateway, def.OutIface, nil
+}
+
+// RouteReplace adds the route to the routing table (the existing route to the same destination is replaced)
+func RouteReplace(r Route) error {
+	if err := rtnlAck(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_REPLACE, routeMsg(r)); err != nil {
+		return fmt.Errorf("failed to add route: %w", err)
+	}
+	return nil
+}
+
+// RouteDel removes the route from the routing table (if exists)
+func RouteDel(r Route) error {
+	if err := rtnlAck(syscall.RTM_DELROUTE, 0, routeMsg(r)); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to remove route: %w", err)
+	}
+	return nil
+}
+
+// RouteFlushTable removes all routes from the routing table
+func RouteFlushTable(isIPv6 bool, table int) error {
+	routes, err := GetRoutes(isIPv6, table)
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		if err := rtnlAck(syscall.RTM_DELROUTE, 0, routeMsg(r)); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to remove route: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetRules returns the routing policy rules which forward packets to the routing tables
+func GetRules(isIPv6 bool) ([]Rule, error) {
+	msgs, err := rtnlRequest(syscall.RTM_GETRULE, syscall.NLM_F_DUMP, newFibRuleHdr(isIPv6, 0, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+
+	var ret []Rule
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWRULE || len(m.Data) < _sizeofFibRuleHdr {
+			continue
+		}
+		if m.Data[7] != _FR_ACT_TO_TBL {
+			continue
+		}
+		r := Rule{
+			IsIPv6:            isIPv6,
+			Table:             int(m.Data[4]),
+			Invert:            binary.LittleEndian.Uint32(m.Data[8:12])&_FIB_RULE_INVERT != 0,
+			SuppressPrefixLen: -1,
+		}
+		forEachAttr(m.Data[_sizeofFibRuleHdr:], func(attrType uint16, val []byte) {
+			if len(val) < 4 {
+				return
+			}
+			v := binary.LittleEndian.Uint32(val)
+			switch attrType {
+			case _FRA_PRIORITY:
+				r.Priority = int(v)
+			case _FRA_FWMARK:
+				r.Mark = v
+			case _FRA_TABLE:
+				r.Table = int(v)
+			case _FRA_SUPPRESS_PREFIXLEN:
+				if int32(v) >= 0 {
+					r.SuppressPrefixLen = int(int32(v))
+				}
+			}
+		})
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+// RuleAdd adds the routing policy rule
+func RuleAdd(r Rule) error {
+	if err := rtnlAck(syscall.RTM_NEWRULE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, ruleMsg(r)); err != nil {
+		return fmt.Errorf("failed to add rule: %w", err)
+	}
+	return nil
+}
+
+// RuleDel removes the routing policy rule (if exists)
+func RuleDel(r Rule) error {
+	if err := rtnlAck(syscall.RTM_DELRULE, 0, ruleMsg(r)); err != nil && err != syscall.ENOENT {
+		return fmt.Errorf("failed to remove rule: %w", err)
+	}
+	return nil
+}
+
+func familyOf(isIPv6 bool) byte {
+	if isIPv6 {
+		return syscall.AF_INET6
+	}
+	return syscall.AF_INET
+}
+
+func newRtMsg(isIPv6 bool, dstLen int, table int) []byte {
+	msg := make([]byte, _sizeofRtMsg)
+	msg[0] = familyOf(isIPv6)
+	msg[1] = byte(dstLen)
+	if table < 256 {
+		msg[4] = byte(table)
+	} else {
+		msg[4] = syscall.RT_TABLE_UNSPEC
+	}
+	return msg
+}
+
+func newFibRuleHdr(isIPv6 bool, table int, invert bool) []byte {
+	msg := make([]byte, _sizeofFibRuleHdr)
+	msg[0] = familyOf(isIPv6)
+	if table < 256 {
+		msg[4] = byte(table)
+	}
+	msg[7] = _FR_ACT_TO_TBL
+	if invert {
+		binary.LittleEndian.PutUint32(msg[8:12], _FIB_RULE_INVERT)
+	}
+	return msg
+}
+
+func attrUint32(attrType uint16, v uint32) []byte {
+	val := make([]byte, 4)
+	binary.LittleEndian.PutUint32(val, v)
+	return newAttr(attrType, val)
+}
+
+func routeMsg(r Route) []byte {
+	dstLen := 0
+	if r.Dst != nil {
+		dstLen, _ = r.Dst.Mask.Size()
+	}
+	msg := newRtMsg(r.IsIPv6, dstLen, r.Table)
+	msg[5] = syscall.RTPROT_BOOT
+	msg[6] = syscall.RT_SCOPE_UNIVERSE
+	msg[7] = syscall.RTN_UNICAST
+
+	ipBytes := func(ip net.IP) []byte {
+		if r.IsIPv6 {
+			return ip.To16()
+		}
+		return ip.To4()
+	}
+	if r.Dst != nil {
+		msg = append(msg, newAttr(syscall.RTA_DST, ipBytes(r.Dst.IP))...)
+	}
+	if r.Gateway != nil {
+		msg = append(msg, newAttr(syscall.RTA_GATEWAY, ipBytes(r.Gateway))...)
+	}
+	if r.OutIface > 0 {
+		msg = append(msg, attrUint32(syscall.RTA_OIF, uint32(r.OutIface))...)
+	}
+	if r.Metric > 0 {
+		msg = append(msg, attrUint32(syscall.RTA_PRIORITY, uint32(r.Metric))...)
+	}
+	msg = append(msg, attrUint32(syscall.RTA_TABLE, uint32(r.Table))...)
+	return msg
+}
+
+func ruleMsg(r Rule) []byte {
+	msg := newFibRuleHdr(r.IsIPv6, r.Table, r.Invert)
+	if r.Priority > 0 {
+		msg = append(msg, attrUint32(_FRA_PRIORITY, uint32(r.Priority))...)
+	}
+	if r.Mark != 0 {
+		msg = append(msg, attrUint32(_FRA_FWMARK, r.Mark)...)
+		msg = append(msg, attrUint32(_FRA_FWMASK, 0xffffffff)...)
+	}
+	if r.SuppressPrefixLen >= 0 {
+		msg = append(msg, attrUint32(_FRA_SUPPRESS_PREFIXLEN, uint32(r.SuppressPrefixLen))...)
+	}
+	msg = append(msg, attrUint32(_FRA_TABLE, uint32(r.Table))...)
+	return msg
+}
+
+// rtnlAck sends the request to the kernel and waits for acknowledgement
+func rtnlAck(msgType uint16, flags uint16, payload []byte) error {
+	_, err := rtnlRequest(msgType, flags|syscall.NLM_F_ACK, payload)
+	return err
+}
+
+// rtnlRequest sends the request to the kernel (NETLINK_ROUTE) and returns the response messages
+func rtnlRequest(msgType uint16, flags uint16, payload []byte) (ret []syscall.NetlinkMessage, retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("netlink request panic: %v", r)
+		}
+	}()
+
+	s, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("socket initialization error: %w", err)
+	}
+	defer syscall.Close(s)
+
+	if err := syscall.Bind(s, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("socket binding error: %w", err)
+	}
+
+	seq := atomic.AddUint32(&rtnlSeq, 1)
+	msg := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:6], msgType)
+	binary.LittleEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|flags)
+	binary.LittleEndian.PutUint32(msg[8:12], seq)
+	copy(msg[syscall.NLMSG_HDRLEN:], payload)
+
+	if err := syscall.Sendto(s, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	isDump := flags&syscall.NLM_F_DUMP == syscall.NLM_F_DUMP
+	buf := make([]byte, 65536)
+	for {
+		n, err := syscall.Read(s, buf)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return ret, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("unexpected netlink error message size")
+				}
+				if errno := int32(binary.LittleEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return ret, nil // ACK
+			default:
+				ret = append(ret, m)
+			}
+		}
+		if !isDump && len(ret) > 0 {
+			return ret, nil
+		}
+	}
+}
@@ -43,6 +43,7 @@ func (p *Protocol) createSettingsResponse() *types.SettingsResp {
 		WiFi:                        prefs.WiFiControl,
 		IsLogging:                   prefs.IsLogging,
 		AntiTracker:                 p._service.GetAntiTrackerStatus(),
+		WgKemPolicy:                 p._service.WgKemPolicy().String(),
 		// TODO: implement the rest of daemon settings
 	}
 }
@@ -92,20 +93,22 @@ func (p *Protocol) createConnectedResponse(state vpn.StateInfo) *types.Connected
 	manualDns := dns.GetLastManualDNS()
 
 	ret := &types.ConnectedResp{
-		TimeSecFrom1970: state.Time,
-		ClientIP:        state.ClientIP.String(),
-		ClientIPv6:      ipv6,
-		ServerIP:        state.ServerIP.String(),
-		ServerPort:      state.ServerPort,
-		VpnType:         state.VpnType,
-		ExitHostname:    state.ExitHostname,
-		Dns:             types.DnsStatus{Dns: manualDns, AntiTrackerStatus: p._service.GetAntiTrackerStatus()},
-		IsTCP:           state.IsTCP,
-		Mtu:             state.Mtu,
-		V2RayProxy:      state.V2RayProxy,
-		Obfsproxy:       state.Obfsproxy,
-		IsPaused:        p._service.IsPaused(),
-		PausedTill:      pausedTillStr,
+		TimeSecFrom1970:    state.Time,
+		ClientIP:           state.ClientIP.String(),
+		ClientIPv6:         ipv6,
+		ServerIP:           state.ServerIP.String(),
+		ServerPort:         state.ServerPort,
+		VpnType:            state.VpnType,
+		ExitHostname:       state.ExitHostname,
+		Dns:                types.DnsStatus{Dns: manualDns, AntiTrackerStatus: p._service.GetAntiTrackerStatus()},
+		IsTCP:              state.IsTCP,
+		Mtu:                state.Mtu,
+		V2RayProxy:         state.V2RayProxy,
+		Obfsproxy:          state.Obfsproxy,
+		IsAmneziaWG:        state.IsAmneziaWG,
+		PluggableTransport: state.PluggableTransport,
+		IsPaused:           p._service.IsPaused(),
+		PausedTill:         pausedTillStr,
 	}
 
 	return ret
@@ -36,6 +36,7 @@ import (
 	"time"
 
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/kem"
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/oshelpers"
 	"github.com/ivpn/desktop-app/daemon/protocol/eaa"
@@ -78,14 +79,26 @@ type Service interface {
 
 	APIRequest(apiAlias string, ipTypeRequired types.RequiredIPProtocol) (responseData []byte, err error)
 	DetectAccessiblePorts(portsToTest []api_types.PortInfo) (retPorts []api_types.PortInfo, err error)
+	LeakTest(probeDnsServers []net.IP, probeHosts []string, iface string, dnsQueryName string) (service_types.LeakTestReport, error)
+
+	DnsLocalFilter() (service_types.DnsLocalFilter, []service_types.DnsBlocklistStatus)
+	SetDnsLocalFilter(cfg service_types.DnsLocalFilter) error
+	UpdateDnsLocalBlocklists() error
+
+	DnsStats(topBlockedCount int) dns.DnsStats
+	DnsResolversHealth() []dns.DnsResolverHealth
+	SetDnsStatsCollection(enable bool) error
 
 	KillSwitchState() (status service_types.KillSwitchStatus, err error)
+	KillSwitchBlockedTraffic() ([]service_types.BlockedTrafficInfo, error)
 	SetKillSwitchState(bool) error
 	SetKillSwitchIsPersistent(isPersistant bool) error
 	SetKillSwitchAllowLANMulticast(isAllowLanMulticast bool) error
 	SetKillSwitchAllowLAN(isAllowLan bool) error
 	SetKillSwitchAllowAPIServers(isAllowAPIServers bool) error
 	SetKillSwitchUserExceptions(exceptions string, ignoreParsingErrors bool) error
+	SetKillSwitchAppsRules(blockedApps, allowedApps []string) error
+	SetKillSwitchLocalInterfaces(ifaces []service_types.LocalInterface) error
 
 	GetConnectionParams() service_types.ConnectionParams
 	SetConnectionParams(params service_types.ConnectionParams) error
@@ -96,11 +109,15 @@ type Service interface {
 	SplitTunnelling_AddApp(exec string) (cmdToExecute string, isAlreadyRunning bool, err error)
 	SplitTunnelling_RemoveApp(pid int, exec string) (err error)
 	SplitTunnelling_AddedPidInfo(pid int, exec string, cmdToExecute string) error
+	SplitTunnelling_SetDestinations(destinations []string) error
+	SplitTunnelling_AddAppPath(path string) error
+	SplitTunnelling_SetUsers(users, groups []string) error
 
 	GetInstalledApps(extraArgsJSON string) ([]oshelpers.AppInfo, error)
 	GetBinaryIcon(binaryPath string) (string, error)
 
 	Preferences() preferences.Preferences
+	WgKemPolicy() kem.Policy
 	SetPreference(key types.ServicePreference, val string) (isChanged bool, err error)
 	SetUserPreferences(userPrefs preferences.UserPreferences) (err error)
 	ResetPreferences() error
@@ -612,6 +629,33 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		}
 		p.sendResponse(conn, &types.CheckAccessiblePortsResponse{Ports: accessiblePorts}, req.Idx)
 
+	case "LeakTest":
+		var req types.LeakTest
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		var dnsServers []net.IP
+		var parseErr error
+		for _, s := range req.ProbeDnsServers {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				parseErr = fmt.Errorf("bad DNS server IP address '%s'", s)
+				break
+			}
+			dnsServers = append(dnsServers, ip)
+		}
+		if parseErr != nil {
+			p.sendErrorResponse(conn, reqCmd, parseErr)
+			break
+		}
+		report, err := p._service.LeakTest(dnsServers, req.ProbeHosts, req.Interface, req.DnsQueryName)
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.LeakTestResp{Report: report}, req.Idx)
+
 	case "KillSwitchGetStatus":
 		if status, err := p._service.KillSwitchState(); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
@@ -620,6 +664,14 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 				&types.KillSwitchStatusResp{KillSwitchStatus: status}, reqCmd.Idx)
 		}
 
+	case "KillSwitchGetBlockedTraffic":
+		if blockedTraffic, err := p._service.KillSwitchBlockedTraffic(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn,
+				&types.KillSwitchBlockedTrafficResp{BlockedTraffic: blockedTraffic}, reqCmd.Idx)
+		}
+
 	case "KillSwitchSetEnabled":
 		var req types.KillSwitchSetEnabled
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -658,6 +710,34 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
 		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
 
+	case "KillSwitchSetAppsRules":
+		var req types.KillSwitchSetAppsRules
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
+		if err := p._service.SetKillSwitchAppsRules(req.BlockedApps, req.AllowedApps); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		}
+
+	case "KillSwitchSetLocalInterfaces":
+		var req types.KillSwitchSetLocalInterfaces
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		// all clients will be notified in case of successful change by OnKillSwitchStateChanged() handler
+		if err := p._service.SetKillSwitchLocalInterfaces(req.LocalInterfaces); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		}
+
 	case "KillSwitchSetUserExceptions":
 		var req types.KillSwitchSetUserExceptions
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -765,10 +845,41 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
 		}
+		if !req.Reset && (req.Users != nil || req.Groups != nil) {
+			status, err := p._service.SplitTunnelling_GetStatus()
+			if err != nil {
+				p.sendErrorResponse(conn, reqCmd, err)
+				break
+			}
+			users, groups := status.Users, status.Groups
+			if req.Users != nil {
+				users = *req.Users
+			}
+			if req.Groups != nil {
+				groups = *req.Groups
+			}
+			if err := p._service.SplitTunnelling_SetUsers(users, groups); err != nil {
+				p.sendErrorResponse(conn, reqCmd, err)
+				break
+			}
+		}
 		// notify 'success'
 		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
 		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
 
+	case "SplitTunnelSetDestinations":
+		var req types.SplitTunnelSetDestinations
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_SetDestinations(req.Destinations); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
 	case "SplitTunnelAddApp":
 		var req types.SplitTunnelAddApp
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -807,6 +918,19 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			reqCmd.Idx)
 		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
 
+	case "SplitTunnelAddAppPath":
+		var req types.SplitTunnelAddAppPath
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SplitTunnelling_AddAppPath(req.Path); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// all clients will be notified about configuration change by service in OnSplitTunnelStatusChanged() handler
+
 	case "SplitTunnelRemoveApp":
 		var req types.SplitTunnelRemoveApp
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -864,6 +988,57 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			// notify current DNS status
 			p.notifyClients(&types.SetAlternateDNSResp{Dns: types.DnsStatus{Dns: p._service.GetManualDNSStatus(), AntiTrackerStatus: p._service.GetAntiTrackerStatus()}})
 		}
+	case "DnsLocalFilterGet":
+		filter, blocklists := p._service.DnsLocalFilter()
+		p.sendResponse(conn, &types.DnsLocalFilterResp{Filter: filter, Blocklists: blocklists}, reqCmd.Idx)
+
+	case "DnsLocalFilterSet":
+		var req types.DnsLocalFilterSet
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SetDnsLocalFilter(req.Filter); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		filter, blocklists := p._service.DnsLocalFilter()
+		p.sendResponse(conn, &types.DnsLocalFilterResp{Filter: filter, Blocklists: blocklists}, req.Idx)
+
+	case "DnsLocalFilterUpdate":
+		if err := p._service.UpdateDnsLocalBlocklists(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		filter, blocklists := p._service.DnsLocalFilter()
+		p.sendResponse(conn, &types.DnsLocalFilterResp{Filter: filter, Blocklists: blocklists}, reqCmd.Idx)
+
+	case "DnsStatsGet":
+		var req types.DnsStatsGet
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if req.TopBlockedCount <= 0 {
+			req.TopBlockedCount = 10
+		}
+		p.sendResponse(conn, &types.DnsStatsResp{Stats: p._service.DnsStats(req.TopBlockedCount)}, req.Idx)
+
+	case "DnsStatsSetCollection":
+		var req types.DnsStatsSetCollection
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.SetDnsStatsCollection(req.Enable); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.DnsStatsResp{Stats: p._service.DnsStats(10)}, req.Idx)
+
+	case "DnsResolversHealthGet":
+		p.sendResponse(conn, &types.DnsResolversHealthResp{Resolvers: p._service.DnsResolversHealth()}, reqCmd.Idx)
+
 	case "GetDnsPredefinedConfigs":
 		cfgs, err := dns.GetPredefinedDnsConfigurations()
 		if err != nil {
@@ -25,6 +25,7 @@ package protocol
 import (
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
 	"github.com/ivpn/desktop-app/daemon/protocol/types"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	"github.com/ivpn/desktop-app/daemon/wifiNotifier"
 )
@@ -63,6 +64,24 @@ func (p *Protocol) OnKillSwitchStateChanged() {
 	}
 }
 
+// OnKillSwitchBlockedTraffic - handler of new packets dropped by the firewall. Notifying clients.
+func (p *Protocol) OnKillSwitchBlockedTraffic() {
+	if p._service == nil {
+		return
+	}
+
+	if blockedTraffic, err := p._service.KillSwitchBlockedTraffic(); err != nil {
+		log.Error(err)
+	} else {
+		p.notifyClients(&types.KillSwitchBlockedTrafficResp{BlockedTraffic: blockedTraffic})
+	}
+}
+
+// OnDnsFailover - handler of the DNS server change by the local DNS forwarder. Notifying clients.
+func (p *Protocol) OnDnsFailover(info dns.DnsFailoverInfo) {
+	p.notifyClients(&types.DnsFailoverResp{Failover: info})
+}
+
 // OnWiFiChanged - handler of WiFi status change. Notifying clients.
 func (p *Protocol) OnWiFiChanged(info wifiNotifier.WifiInfo, err error) {
 	msg := &types.WiFiCurrentNetworkResp{
@@ -114,6 +114,21 @@ type KillSwitchSetUserExceptions struct {
 	FailOnParsingError bool
 }
 
+// KillSwitchSetAppsRules set the lists of applications (paths to binaries) which outgoing communication
+// must be blocked or allowed by the firewall
+type KillSwitchSetAppsRules struct {
+	RequestBase
+	BlockedApps []string // applications which are not allowed to communicate (even when VPN is connected)
+	AllowedApps []string // applications which are allowed to communicate (even when VPN is disconnected)
+}
+
+// KillSwitchSetLocalInterfaces set the configuration for trusted local interfaces
+// (e.g. bridge interfaces of containers and VMs: docker0, virbr0, lxcbr0 ...)
+type KillSwitchSetLocalInterfaces struct {
+	RequestBase
+	LocalInterfaces []service_types.LocalInterface
+}
+
 type KillSwitchSetAllowApiServers struct {
 	RequestBase
 	IsAllowApiServers bool
@@ -130,6 +145,11 @@ type KillSwitchGetStatus struct {
 	RequestBase
 }
 
+// KillSwitchGetBlockedTraffic get info about recent outgoing packets dropped by the kill-switch
+type KillSwitchGetBlockedTraffic struct {
+	RequestBase
+}
+
 // KillSwitchSetIsPersistent request to mark kill-switch persistant
 type KillSwitchSetIsPersistent struct {
 	RequestBase
@@ -154,7 +174,41 @@ type SetUserPreferences struct {
 type SetAlternateDns struct {
 	RequestBase
 	AntiTracker service_types.AntiTrackerMetadata
-	Dns         dns.DnsSettings // If 'AntiTracker' is enabled - his parameter will be ignored
+	Dns         dns.DnsSettings // If 'AntiTracker' is enabled - his parameter will be ignored (except 'DomainRules')
+}
+
+// DnsLocalFilterGet request to get the local DNS filter configuration (block lists, allowlist, static hosts)
+type DnsLocalFilterGet struct {
+	RequestBase
+}
+
+// DnsLocalFilterSet request to set the local DNS filter configuration (block lists, allowlist, static hosts)
+type DnsLocalFilterSet struct {
+	RequestBase
+	Filter service_types.DnsLocalFilter
+}
+
+// DnsLocalFilterUpdate request to download the remote DNS block lists (even if the cached copies are not outdated)
+type DnsLocalFilterUpdate struct {
+	RequestBase
+}
+
+// DnsStatsGet request to get DNS query statistics of the current connection session
+type DnsStatsGet struct {
+	RequestBase
+	TopBlockedCount int // max number of the most blocked domains to return (0 - default value)
+}
+
+// DnsStatsSetCollection request to enable/disable forced collection of DNS statistics
+// (when enabled - all DNS queries are processed by the local DNS forwarder)
+type DnsStatsSetCollection struct {
+	RequestBase
+	Enable bool
+}
+
+// DnsResolversHealthGet request to get the health status of DNS servers used by the local DNS forwarder
+type DnsResolversHealthGet struct {
+	RequestBase
 }
 
 // GetDnsPredefinedConfigs request to get list of predefined DoH/DoT configurations (if exists)
@@ -287,3 +341,13 @@ type CheckAccessiblePorts struct {
 	RequestBase
 	PortsToTest []api_types.PortInfo // in case of empty - will be tested all known ports
 }
+
+// LeakTest request to run diagnostic checks of the current VPN connection
+// (all fields are optional; empty values mean 'use defaults')
+type LeakTest struct {
+	RequestBase
+	ProbeDnsServers []string // DNS resolvers IP addresses
+	ProbeHosts      []string // TCP hosts in format "IP:port"
+	Interface       string   // non-tunnel interface name
+	DnsQueryName    string   // the name to resolve in DNS probes
+}
@@ -88,6 +88,12 @@ type DisabledFunctionalityLinux struct {
 	//	- there is no 'resolvectl' binary on target system
 	//	- 'resolvectl' initialisation try was failed
 	DnsMgmtNewResolvectlError string
+
+	// If not empty - it is not possible to manage DNS using systemd-resolved D-Bus API (org.freedesktop.resolve1)
+	DnsMgmtResolvedDbusError string
+
+	// If not empty - it is not possible to manage DNS using NetworkManager D-Bus API (org.freedesktop.NetworkManager)
+	DnsMgmtNetworkManagerError string
 }
 
 type DisabledFunctionalityForPlatform struct {
@@ -107,6 +113,7 @@ type DisabledFunctionality struct {
 	OpenVPNError            string // OpenVPN is not supported on this platform
 	ObfsproxyError          string // Obfsproxy is not supported on this platform
 	V2RayError              string // V2Ray is not supported on this platform
+	AmneziaWGError          string // AmneziaWG obfuscation is not supported on this platform
 	SplitTunnelError        string // SplitTunneling is not supported on this platform
 	SplitTunnelInverseError string // Inversed SplitTunneling is not supported on this platform
 
@@ -133,6 +140,7 @@ type SettingsResp struct {
 	WiFi                        preferences.WiFiParams
 	IsLogging                   bool
 	AntiTracker                 service_types.AntiTrackerMetadata
+	WgKemPolicy                 string // KEM algorithms policy for WireGuard PresharedKey exchange (effective value)
 
 	// TODO: implement the rest of daemon settings
 	// IsFwPersistant        bool
@@ -173,6 +181,7 @@ type SessionResp struct {
 	WgKeyGenerated     int64 // Unix time
 	WgKeysRegenInerval int64 // seconds
 	WgUsePresharedKey  bool
+	WgKemError         string // the reason why the configured KEM policy is not applied to the current WG keys (empty - applied)
 }
 
 // CreateSessionResp create new session info object to send to client
@@ -185,7 +194,8 @@ func CreateSessionResp(s preferences.SessionStatus) SessionResp {
 		WgLocalIP:          s.WGLocalIP,
 		WgKeyGenerated:     s.WGKeyGenerated.Unix(),
 		WgKeysRegenInerval: int64(s.WGKeysRegenInerval.Seconds()),
-		WgUsePresharedKey:  len(s.WGPresharedKey) > 0}
+		WgUsePresharedKey:  len(s.WGPresharedKey) > 0,
+		WgKemError:         s.WGKemError}
 }
 
 // SessionNewResp - information about created session (or error info)
@@ -213,6 +223,13 @@ type KillSwitchStatusResp struct {
 	service_types.KillSwitchStatus
 }
 
+// KillSwitchBlockedTrafficResp returns info about recent outgoing packets dropped by the kill-switch
+// (also, it is sent to all clients as a notification when new dropped packets detected)
+type KillSwitchBlockedTrafficResp struct {
+	CommandBase
+	BlockedTraffic []service_types.BlockedTrafficInfo
+}
+
 // KillSwitchGetIsPestistentResp returns kill-switch persistance status
 type KillSwitchGetIsPestistentResp struct {
 	CommandBase
@@ -259,8 +276,11 @@ type ConnectedResp struct {
 	Mtu             int                    // (for WireGuard connections)
 	V2RayProxy      v2r.V2RayTransportType // applicable only for 'CONNECTED' state
 	Obfsproxy       obfsproxy.Config       // applicable only for 'CONNECTED' state (OpenVPN)
-	IsPaused        bool                   // When "true" - the actual connection may be "disconnected" (depending on the platform and VPN protocol), but the daemon responds "connected"
-	PausedTill      string                 // pausedTill.Format(time.RFC3339)
+	IsAmneziaWG     bool                   // applicable only for 'CONNECTED' state (WireGuard with AmneziaWG obfuscation)
+	// name of the pluggable transport; applicable only for 'CONNECTED' state (OpenVPN)
+	PluggableTransport string
+	IsPaused           bool   // When "true" - the actual connection may be "disconnected" (depending on the platform and VPN protocol), but the daemon responds "connected"
+	PausedTill         string // pausedTill.Format(time.RFC3339)
 }
 
 // DisconnectionReason - disconnection reason
@@ -370,3 +390,36 @@ type CheckAccessiblePortsResponse struct {
 	RequestBase
 	Ports []api_types.PortInfo
 }
+
+// LeakTestResp contains the leak test results
+type LeakTestResp struct {
+	CommandBase
+	Report service_types.LeakTestReport
+}
+
+// DnsLocalFilterResp contains the local DNS filter configuration and the status of the block lists
+type DnsLocalFilterResp struct {
+	CommandBase
+	Filter     service_types.DnsLocalFilter
+	Blocklists []service_types.DnsBlocklistStatus
+}
+
+// DnsStatsResp contains DNS query statistics of the current connection session
+type DnsStatsResp struct {
+	CommandBase
+	Stats dns.DnsStats
+}
+
+// DnsResolversHealthResp contains the health status of DNS servers used by the local DNS forwarder
+// (empty - if the local DNS forwarder is not in use)
+type DnsResolversHealthResp struct {
+	CommandBase
+	Resolvers []dns.DnsResolverHealth
+}
+
+// DnsFailoverResp is a notification sent to all clients when the local DNS forwarder switched to another DNS server
+// (failover to the next/fallback server or recovery of the preferred one)
+type DnsFailoverResp struct {
+	CommandBase
+	Failover dns.DnsFailoverInfo
+}
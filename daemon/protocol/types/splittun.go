@@ -24,6 +24,7 @@ package types
 
 import (
 	"github.com/ivpn/desktop-app/daemon/oshelpers"
+	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/splittun"
 )
 
@@ -71,6 +72,17 @@ type SplitTunnelSetConfig struct {
 	IsAnyDns         bool // (only for Inverse Split Tunnel) When false: Allow only DNS servers specified by the IVPN application
 	IsAllowWhenNoVpn bool // (only for Inverse Split Tunnel) Allow connectivity for Split Tunnel apps when VPN is disabled
 	Reset            bool // disable ST and erase all ST config (if enabled - all the rest paremeters are ignored)
+	// (optional; applicable for Linux) users and groups (names or numeric IDs) which traffic is 'splitted'
+	// the same way as traffic of the applications in Split Tunnel environment (nil - do not change)
+	Users  *[]string
+	Groups *[]string
+}
+
+// SplitTunnelSetDestinations (request) sets the Split Tunnel destination rules
+// (CIDRs or domain names which always bypass the VPN tunnel; in Inverse Split Tunnel mode: which always use the VPN tunnel)
+type SplitTunnelSetDestinations struct {
+	RequestBase
+	Destinations []string
 }
 
 // GetSplitTunnelStatus (request) requests the Split-Tunnelling configuration
@@ -92,11 +104,17 @@ type SplitTunnelStatus struct {
 	// (true - if commands GetAppIcon/AppIconResp  applicable for this platform)
 	IsCanGetAppIconForBinary bool
 	// Information about applications added to ST configuration
-	// (applicable for Windows)
+	// (Windows: paths to binaries; Linux: paths to binaries or '.desktop' files which are added to ST automatically on start)
 	SplitTunnelApps []string
 	// Information about active applications running in Split-Tunnel environment
 	// (applicable for Linux)
 	RunningApps []splittun.RunningApp
+	// Destination rules: CIDRs or domain names which always bypass the VPN tunnel
+	// (in Inverse Split Tunnel mode: which always use the VPN tunnel)
+	Destinations []service_types.SplitTunnelDestinationStatus
+	// (applicable for Linux) Users and groups which traffic is 'splitted'
+	Users  []string
+	Groups []string
 }
 
 // SplitTunnelAddApp (request) add application to SplitTunneling
@@ -122,6 +140,16 @@ type SplitTunnelAddApp struct {
 	Exec string
 }
 
+// SplitTunnelAddAppPath (request) adds the application to the Split Tunnel configuration
+// Linux: path to the binary or '.desktop' file (the application is added to Split Tunnel environment automatically each time it starts)
+// or the sandbox identity of Flatpak/Snap application: 'flatpak:<application ID>', 'snap:<snap name>' (see oshelpers.AppInfo.AppSandbox)
+// Windows: full path to the app binary (the same as SplitTunnelAddApp)
+// Expected response: types.EmptyResp (success)
+type SplitTunnelAddAppPath struct {
+	RequestBase
+	Path string
+}
+
 // SplitTunnelAddAppCmdResp (response) contains shell command which have to be executed in user space environment
 // (not in use for Windows platform)
 type SplitTunnelAddAppCmdResp struct {
@@ -152,6 +180,7 @@ type SplitTunnelRemoveApp struct {
 	RequestBase
 	// (applicable for Linux) PID of the running process in ST environment
 	Pid int
-	// (applicable for Windows) full path to the app binary to be excluded from ST
+	// Windows: full path to the app binary to be excluded from ST
+	// Linux (when Pid is not defined): the application path to be removed from ST configuration (see SplitTunnelAddAppPath)
 	Exec string
 }
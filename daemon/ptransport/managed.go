@@ -0,0 +1,277 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package ptransport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+const (
+	startTimeout = time.Second * 10
+	stopTimeout  = time.Second * 2
+)
+
+// ManagedProxy - PT client binary running as a managed proxy (Tor PT protocol v1).
+// Implements Transport interface.
+type ManagedProxy struct {
+	binaryPath    string
+	transportName string
+	stateDir      string
+
+	mutex   sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stopped chan struct{}
+	exitErr error // valid only after 'stopped' is closed
+}
+
+// NewManagedProxy creates new managed proxy object
+//
+//	binaryPath - path to PT client binary
+//	transportName - name of the transport to request from the binary (e.g. "obfs4")
+//	stateDir - directory where the PT binary is allowed to keep its state (removed when the proxy stopped)
+func NewManagedProxy(binaryPath, transportName, stateDir string) *ManagedProxy {
+	return &ManagedProxy{binaryPath: binaryPath, transportName: transportName, stateDir: stateDir}
+}
+
+// Start - asynchronously start the PT binary and wait until it reports the local SOCKS5 proxy port
+func (p *ManagedProxy) Start() (port int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.cmd != nil {
+		return 0, fmt.Errorf("pluggable transport '%s' already started", p.transportName)
+	}
+
+	log.Info(fmt.Sprintf("Starting pluggable transport '%s' (%s)", p.transportName, p.binaryPath))
+
+	cmd := exec.Command(p.binaryPath)
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "TOR_PT_MANAGED_TRANSPORT_VER=1")
+	cmd.Env = append(cmd.Env, "TOR_PT_CLIENT_TRANSPORTS="+p.transportName)
+	cmd.Env = append(cmd.Env, "TOR_PT_STATE_LOCATION="+p.stateDir)
+	// the PT binary must exit when its stdin is closed (e.g. when the daemon crashed)
+	cmd.Env = append(cmd.Env, "TOR_PT_EXIT_ON_STDIN_CLOSE=1")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to init pluggable transport: %w", err)
+	}
+
+	parser := newCmethodParser(p.transportName)
+	if err := shell.StartConsoleReaders(cmd, parser.processOutput); err != nil {
+		return 0, fmt.Errorf("failed to init pluggable transport: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start pluggable transport: %w", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			log.Info(fmt.Sprintf("Pluggable transport '%s' stopped: %s", p.transportName, err))
+		} else {
+			log.Info(fmt.Sprintf("Pluggable transport '%s' stopped", p.transportName))
+		}
+		p.exitErr = err
+		close(stopped)
+
+		// remove PT state directory
+		if len(p.stateDir) > 0 {
+			os.RemoveAll(p.stateDir)
+		}
+	}()
+
+	p.cmd = cmd
+	p.stdin = stdin
+	p.stopped = stopped
+
+	select {
+	case <-parser.done:
+		err = parser.err
+	case <-stopped:
+		err = fmt.Errorf("pluggable transport '%s' process exited unexpectedly", p.transportName)
+	case <-time.After(startTimeout):
+		err = fmt.Errorf("pluggable transport '%s' start timeout", p.transportName)
+	}
+
+	if err != nil {
+		log.Error(err)
+		p.stop()
+		return 0, err
+	}
+
+	log.Info(fmt.Sprintf("Pluggable transport '%s' started (SOCKS5 port %d)", p.transportName, parser.port))
+	return parser.port, nil
+}
+
+// Wait - wait until the PT binary stopped
+func (p *ManagedProxy) Wait() error {
+	p.mutex.Lock()
+	stopped := p.stopped
+	p.mutex.Unlock()
+
+	if stopped == nil {
+		return nil
+	}
+	<-stopped
+	return p.exitErr
+}
+
+// Stop - stop the PT binary
+func (p *ManagedProxy) Stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.stop()
+}
+
+func (p *ManagedProxy) stop() {
+	if p.cmd == nil || p.stopped == nil {
+		return
+	}
+
+	select {
+	case <-p.stopped:
+		return // already stopped
+	default:
+	}
+
+	log.Info(fmt.Sprintf("Stopping pluggable transport '%s'...", p.transportName))
+
+	// graceful stop: closing stdin (TOR_PT_EXIT_ON_STDIN_CLOSE)
+	p.stdin.Close()
+	select {
+	case <-p.stopped:
+		return
+	case <-time.After(stopTimeout):
+	}
+
+	if err := shell.Kill(p.cmd); err != nil {
+		log.Error(err)
+	}
+}
+
+// cmethodParser - parser of the PT binary output (client side of the managed proxy protocol)
+//
+// Output example:
+//
+//	VERSION 1
+//	CMETHOD obfs4 socks5 127.0.0.1:53914
+//	CMETHODS DONE
+type cmethodParser struct {
+	transportName string
+	done          chan struct{}
+	once          sync.Once
+	// fields are valid only after 'done' is closed
+	port int
+	err  error
+}
+
+func newCmethodParser(transportName string) *cmethodParser {
+	return &cmethodParser{transportName: transportName, done: make(chan struct{})}
+}
+
+func (c *cmethodParser) finish(err error) {
+	c.once.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+func (c *cmethodParser) isFinished() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *cmethodParser) processOutput(text string, isError bool) {
+	if isError {
+		log.Info("[ERR] ", text)
+		return
+	}
+	log.Info("[OUT] ", text)
+
+	if c.isFinished() {
+		return // only LOG/STATUS messages expected after initialization
+	}
+
+	keyword, args, _ := strings.Cut(text, " ")
+	switch keyword {
+	case "VERSION":
+		if args != "1" {
+			c.finish(fmt.Errorf("pluggable transport: unsupported protocol version '%s'", args))
+		}
+	case "VERSION-ERROR":
+		c.finish(fmt.Errorf("pluggable transport: no supported protocol version (%s)", args))
+	case "ENV-ERROR":
+		c.finish(fmt.Errorf("pluggable transport: environment error (%s)", args))
+	case "CMETHOD-ERROR":
+		c.finish(fmt.Errorf("pluggable transport: %s", args))
+	case "CMETHOD":
+		// CMETHOD <transport> <'socks4','socks5'> <address:port> [<ARGS>] ...
+		fields := strings.Fields(args)
+		if len(fields) < 3 || fields[0] != c.transportName {
+			return
+		}
+		if fields[1] != "socks5" {
+			c.finish(fmt.Errorf("pluggable transport: unsupported proxy type '%s' (expected 'socks5')", fields[1]))
+			return
+		}
+		_, portStr, err := net.SplitHostPort(fields[2])
+		if err != nil {
+			c.finish(fmt.Errorf("pluggable transport: bad proxy address '%s': %w", fields[2], err))
+			return
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			c.finish(fmt.Errorf("pluggable transport: bad proxy port '%s'", portStr))
+			return
+		}
+		c.port = port
+	case "CMETHODS":
+		if args != "DONE" {
+			return
+		}
+		if c.port <= 0 {
+			c.finish(errors.New("pluggable transport: transport '" + c.transportName + "' not provided by the binary"))
+			return
+		}
+		c.finish(nil)
+	}
+}
@@ -0,0 +1,321 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package ptransport implements the client side of pluggable transports
+// following the Tor Pluggable Transport managed proxy protocol (v1):
+// https://spec.torproject.org/pt-spec/
+//
+// Any compliant PT client binary can be used (e.g. 'lyrebird'/'obfs4proxy' for obfs4).
+// The PT binary runs a local SOCKS5 proxy; the traffic sent through this proxy is obfuscated by the transport.
+//
+// Some transports connect to other hosts than the VPN server (e.g. 'webtunnel' or 'snowflake').
+// These hosts are defined by the transport arguments (see EndpointHosts()); their IP addresses are resolved
+// before the connection (see ResolveEndpoints()), allowed by the firewall and routed outside the VPN tunnel.
+// Note: the transport must not connect to the hosts which are not known before the connection
+// (e.g. the WebRTC peers of 'snowflake' are reachable only until the VPN tunnel is established).
+// The deprecated and insecure transports ('obfs2', 'scramblesuit') are not supported.
+package ptransport
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("ptrans")
+}
+
+// Transport - client side of the pluggable transport (local SOCKS5 proxy)
+type Transport interface {
+	// Start - asynchronously start the transport. Returns the port of local SOCKS5 proxy (127.0.0.1)
+	Start() (port int, err error)
+	// Wait - wait until the transport stopped
+	Wait() error
+	// Stop - stop the transport
+	Stop()
+}
+
+// Config - configuration of the generic pluggable transport
+type Config struct {
+	Name       string // transport name (e.g. "obfs4", "webtunnel")
+	BinaryPath string // absolute path to the PT client binary (e.g. "/usr/bin/lyrebird")
+	// Per-connection arguments of the transport in format "key=value;key=value"
+	// (the same as in Tor bridge line, e.g. "cert=ssH+9rP8d...;iat-mode=0").
+	// Characters '=', ';' and '\' in keys and values must be escaped by '\'.
+	Args string
+	// Port of the server-side transport (0 - use the port of VPN connection)
+	RemotePort int
+}
+
+// insecureTransports - names of the deprecated transports which are not allowed to use
+var insecureTransports = []string{"obfs2", "scramblesuit"}
+
+// endpointArgs - names of the transport arguments which define the hosts the transport connects to
+// (e.g. 'webtunnel': "url"; 'snowflake': "url", "front", "fronts", "ice").
+// The value can be a list of elements separated by ','.
+var endpointArgs = []string{"url", "front", "fronts", "ice"}
+
+// transport names are C identifiers (see pt-spec)
+var transportNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// IsDefined returns 'true' when transport is configured
+func (c Config) IsDefined() bool {
+	return len(c.Name) > 0 || len(c.BinaryPath) > 0
+}
+
+// Validate checks the configuration
+func (c Config) Validate() error {
+	if !transportNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("bad pluggable transport name '%s'", c.Name)
+	}
+	for _, t := range insecureTransports {
+		if t == c.Name {
+			return fmt.Errorf("pluggable transport '%s' is not supported (deprecated and insecure)", c.Name)
+		}
+	}
+	if !filepath.IsAbs(c.BinaryPath) {
+		return fmt.Errorf("pluggable transport binary path must be absolute ('%s')", c.BinaryPath)
+	}
+	if c.RemotePort < 0 || c.RemotePort > 65535 {
+		return fmt.Errorf("bad pluggable transport remote port: %d", c.RemotePort)
+	}
+	if _, err := c.EndpointHosts(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EndpointHosts returns the hosts (names or IP addresses) which the transport connects to
+// in addition to the VPN server (see endpointArgs)
+func (c Config) EndpointHosts() ([]string, error) {
+	args, err := ParseArgs(c.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	known := make(map[string]struct{})
+	for _, a := range args {
+		if !isEndpointArg(a.Key) {
+			continue
+		}
+		for _, element := range strings.Split(a.Value, ",") {
+			element = strings.TrimSpace(element)
+			if len(element) == 0 {
+				continue
+			}
+			host, err := endpointHost(element)
+			if err != nil {
+				return nil, fmt.Errorf("bad pluggable transport argument '%s': %w", a.Key, err)
+			}
+			if _, ok := known[host]; !ok {
+				known[host] = struct{}{}
+				ret = append(ret, host)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// ResolveEndpoints returns IPv4 addresses of the hosts which the transport connects to (see EndpointHosts())
+func (c Config) ResolveEndpoints() ([]net.IP, error) {
+	hosts, err := c.EndpointHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []net.IP
+	for _, h := range hosts {
+		ips := []net.IP{net.ParseIP(h)}
+		if ips[0] == nil {
+			if ips, err = net.LookupIP(h); err != nil {
+				return nil, fmt.Errorf("failed to resolve the host of pluggable transport '%s': %w", h, err)
+			}
+		}
+		isResolved := false
+		for _, ip := range ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				ret = append(ret, ip4)
+				isResolved = true
+			}
+		}
+		if !isResolved {
+			return nil, fmt.Errorf("failed to resolve the host of pluggable transport '%s' (no IPv4 address)", h)
+		}
+	}
+	return ret, nil
+}
+
+func isEndpointArg(key string) bool {
+	for _, k := range endpointArgs {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointHost returns the host of the endpoint defined as URL ("https://host/path"),
+// STUN server ("stun:host:port") or host name ("host", "host:port")
+func endpointHost(endpoint string) (string, error) {
+	host := endpoint
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", err
+		}
+		host = u.Hostname()
+	} else {
+		if scheme, opaque, ok := strings.Cut(endpoint, ":"); ok && (scheme == "stun" || scheme == "stuns") {
+			host = opaque
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	if len(host) == 0 || strings.ContainsAny(host, "/@ ") {
+		return "", fmt.Errorf("bad host '%s'", endpoint)
+	}
+	return host, nil
+}
+
+// ToString returns short description of the transport (for logging and status info)
+func (c Config) ToString() string {
+	if !c.IsDefined() {
+		return "disabled"
+	}
+	return c.Name
+}
+
+func (c Config) Equals(b Config) bool {
+	return c == b
+}
+
+// SocksAuth returns the SOCKS5 credentials to pass the arguments of the transport
+// (see ParseArgs(), SocksAuthFromArgs())
+func (c Config) SocksAuth() (username, password string, err error) {
+	args, err := ParseArgs(c.Args)
+	if err != nil {
+		return "", "", err
+	}
+	return SocksAuthFromArgs(args)
+}
+
+// Arg - argument of the pluggable transport
+type Arg struct {
+	Key   string
+	Value string
+}
+
+// ParseArgs parses the arguments in format "key=value;key=value"
+// ('=', ';' and '\' can be escaped by '\')
+func ParseArgs(s string) ([]Arg, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return nil, fmt.Errorf("bad pluggable transport arguments (unexpected characters)")
+	}
+
+	var ret []Arg
+	var cur strings.Builder
+	var arg Arg
+	isKey := true
+	isEscaped := false
+	flush := func() error {
+		if isKey {
+			if cur.Len() == 0 {
+				return nil // empty element (e.g. trailing ';')
+			}
+			return fmt.Errorf("bad pluggable transport argument '%s' (expected format: key=value)", cur.String())
+		}
+		arg.Value = cur.String()
+		if len(arg.Key) == 0 {
+			return fmt.Errorf("bad pluggable transport argument (empty key)")
+		}
+		ret = append(ret, arg)
+		cur.Reset()
+		arg = Arg{}
+		isKey = true
+		return nil
+	}
+
+	for _, r := range s {
+		switch {
+		case isEscaped:
+			cur.WriteRune(r)
+			isEscaped = false
+		case r == '\\':
+			isEscaped = true
+		case r == '=' && isKey:
+			arg.Key = cur.String()
+			cur.Reset()
+			isKey = false
+		case r == ';':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if isEscaped {
+		return nil, fmt.Errorf("bad pluggable transport arguments (unterminated escape sequence)")
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// EncodeArgs returns the arguments in format "key=value;key=value" (with escaped special characters)
+func EncodeArgs(args []Arg) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`)
+	elements := make([]string, 0, len(args))
+	for _, a := range args {
+		elements = append(elements, escaper.Replace(a.Key)+"="+escaper.Replace(a.Value))
+	}
+	return strings.Join(elements, ";")
+}
+
+// SocksAuthFromArgs returns the SOCKS5 credentials to pass the per-connection arguments to the transport.
+// According to pt-spec, the encoded arguments are split between the username and the password fields
+// (the transport concatenates them back). Each field is limited to 255 bytes.
+// Both fields are not empty (OpenVPN requires both lines in the proxy authentication file).
+func SocksAuthFromArgs(args []Arg) (username, password string, err error) {
+	encoded := EncodeArgs(args)
+	if len(encoded) == 0 {
+		return "", "", nil
+	}
+	if len(encoded) > 255*2 {
+		return "", "", fmt.Errorf("pluggable transport arguments are too long (%d bytes; max 510)", len(encoded))
+	}
+	if len(encoded) > 255 {
+		return encoded[:255], encoded[255:], nil
+	}
+	return encoded[:len(encoded)-1], encoded[len(encoded)-1:], nil
+}
@@ -0,0 +1,156 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package ptransport
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	args, err := ParseArgs(`cert=ab\=c\;d\\;iat-mode=0;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Arg{{Key: "cert", Value: `ab=c;d\`}, {Key: "iat-mode", Value: "0"}}
+	if len(args) != len(expected) || args[0] != expected[0] || args[1] != expected[1] {
+		t.Fatalf("unexpected result: %v", args)
+	}
+	if enc := EncodeArgs(args); enc != `cert=ab\=c\;d\\;iat-mode=0` {
+		t.Errorf("unexpected encoded value: %s", enc)
+	}
+
+	for _, bad := range []string{"cert", "=value", `a=b\`, "a=b\nc=d"} {
+		if _, err := ParseArgs(bad); err == nil {
+			t.Errorf("expected error for '%s'", bad)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := (Config{Name: "obfs4", BinaryPath: "/usr/bin/lyrebird"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Config{Name: "webtunnel", BinaryPath: "/usr/bin/lyrebird", Args: "url=https://example.com/path"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// deprecated and insecure transports
+	for _, name := range []string{"obfs2", "scramblesuit"} {
+		if err := (Config{Name: name, BinaryPath: "/usr/bin/lyrebird"}).Validate(); err == nil {
+			t.Errorf("expected error for '%s'", name)
+		}
+	}
+	if err := (Config{Name: "webtunnel", BinaryPath: "/usr/bin/lyrebird", Args: "url=https:///path"}).Validate(); err == nil {
+		t.Error("expected error for bad endpoint")
+	}
+}
+
+func TestEndpointHosts(t *testing.T) {
+	c := Config{Name: "snowflake", Args: `url=https://broker.example.com/;fronts=cdn.example.com,cdn2.example.com;ice=stun:stun.example.net:3478,stun:192.0.2.1:3478,stun:[2001:db8::1]:3478;fingerprint=ABCD`}
+	hosts, err := c.EndpointHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"broker.example.com", "cdn.example.com", "cdn2.example.com", "stun.example.net", "192.0.2.1", "2001:db8::1"}
+	if strings.Join(hosts, " ") != strings.Join(expected, " ") {
+		t.Errorf("unexpected result: %v", hosts)
+	}
+
+	if hosts, err = (Config{Name: "obfs4", Args: "cert=abc;iat-mode=0"}).EndpointHosts(); err != nil || len(hosts) != 0 {
+		t.Errorf("unexpected result: %v (%v)", hosts, err)
+	}
+
+	ips, err := (Config{Name: "webtunnel", Args: "url=https://192.0.2.1:8443/path;front=[2001:db8::1]:443"}).ResolveEndpoints()
+	if err == nil || len(ips) != 0 {
+		t.Errorf("expected error for the host without IPv4 address (%v)", ips)
+	}
+	ips, err = (Config{Name: "webtunnel", Args: "url=https://192.0.2.1:8443/path"}).ResolveEndpoints()
+	if err != nil || len(ips) != 1 || ips[0].String() != "192.0.2.1" {
+		t.Errorf("unexpected result: %v (%v)", ips, err)
+	}
+}
+
+func TestSocksAuthFromArgs(t *testing.T) {
+	user, pass, err := SocksAuthFromArgs([]Arg{{Key: "iat-mode", Value: "0"}})
+	if err != nil || user != "iat-mode=" || pass != "0" {
+		t.Errorf("unexpected result: '%s' '%s' (%v)", user, pass, err)
+	}
+
+	long := strings.Repeat("x", 300)
+	user, pass, err = SocksAuthFromArgs([]Arg{{Key: "url", Value: long}})
+	if err != nil || len(user) != 255 || user+pass != "url="+long {
+		t.Errorf("unexpected result: '%s' '%s' (%v)", user, pass, err)
+	}
+
+	if _, _, err = SocksAuthFromArgs([]Arg{{Key: "url", Value: long + long}}); err == nil {
+		t.Error("expected error for too long arguments")
+	}
+}
+
+func TestManagedProxy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not applicable on Windows")
+	}
+
+	// fake PT binary: it exits when stdin is closed (TOR_PT_EXIT_ON_STDIN_CLOSE)
+	script := `#!/bin/sh
+[ "$TOR_PT_MANAGED_TRANSPORT_VER" = "1" ] || { echo "VERSION-ERROR no-version"; exit 1; }
+echo "VERSION 1"
+for t in $(echo "$TOR_PT_CLIENT_TRANSPORTS" | tr ',' ' '); do
+	if [ "$t" = "faketransport" ]; then
+		echo "CMETHOD $t socks5 127.0.0.1:12345"
+	else
+		echo "CMETHOD-ERROR $t no such transport"
+	fi
+done
+echo "CMETHODS DONE"
+cat > /dev/null
+`
+	dir := t.TempDir()
+	binPath := filepath.Join(dir, "fake-pt")
+	if err := os.WriteFile(binPath, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewManagedProxy(binPath, "faketransport", filepath.Join(dir, "state"))
+	port, err := p.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 12345 {
+		t.Errorf("unexpected port: %d", port)
+	}
+	p.Stop()
+	if err := p.Wait(); err != nil {
+		t.Errorf("unexpected exit error: %v", err)
+	}
+
+	p = NewManagedProxy(binPath, "unknown", filepath.Join(dir, "state"))
+	if _, err := p.Start(); err == nil {
+		t.Error("expected error for unsupported transport")
+	}
+	p.Wait()
+}
@@ -0,0 +1,237 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package blocklist implements loading of the local DNS block lists.
+//
+// Supported formats (can be mixed in the same file):
+//
+//	0.0.0.0 ads.example.com     # hosts-format
+//	tracker.example.com         # domain list
+//	||metrics.example.com^      # adblock-style domain rule
+//
+// The block lists defined by URL are downloaded and cached on disk.
+package blocklist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("dnsbl")
+}
+
+const (
+	// the cached copy of the remote list is updated when it is older than this period
+	CacheMaxAge = time.Hour * 24
+
+	downloadTimeout = time.Second * 60
+	maxListSize     = 64 * 1024 * 1024
+)
+
+// Load returns domains from the block list.
+// 'src' - path to the local file or http(s) URL.
+// Remote lists are cached in 'cacheDir': the list is downloaded when the cached copy is missing,
+// outdated (see CacheMaxAge) or 'forceUpdate' is true. If the download failed - the cached copy is used (if exists).
+func Load(src, cacheDir string, forceUpdate bool) (domains []string, updated time.Time, err error) {
+	if !isUrl(src) {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		defer f.Close()
+		domains, err = Parse(f)
+		return domains, time.Now(), err
+	}
+
+	cacheFile := CacheFilePath(src, cacheDir)
+	cacheInfo, cacheErr := os.Stat(cacheFile)
+	if cacheErr != nil || forceUpdate || time.Since(cacheInfo.ModTime()) > CacheMaxAge {
+		if err := download(src, cacheFile); err != nil {
+			if cacheErr != nil {
+				return nil, time.Time{}, err
+			}
+			log.Warning(fmt.Sprintf("%s (using cached copy from %s)", err, cacheInfo.ModTime().Format(time.RFC3339)))
+		} else {
+			cacheInfo, cacheErr = os.Stat(cacheFile)
+		}
+	}
+	if cacheErr != nil {
+		return nil, time.Time{}, cacheErr
+	}
+
+	f, err := os.Open(cacheFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	domains, err = Parse(f)
+	return domains, cacheInfo.ModTime(), err
+}
+
+// CacheFilePath returns path to the cached copy of the remote block list
+func CacheFilePath(src, cacheDir string) string {
+	hash := sha256.Sum256([]byte(src))
+	return filepath.Join(cacheDir, hex.EncodeToString(hash[:8])+".txt")
+}
+
+// RemoveCache removes cached copies of the remote lists which are not in the 'keepSources' list
+func RemoveCache(cacheDir string, keepSources []string) {
+	keep := make(map[string]struct{}, len(keepSources))
+	for _, src := range keepSources {
+		keep[filepath.Base(CacheFilePath(src, cacheDir))] = struct{}{}
+	}
+	files, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if _, ok := keep[f.Name()]; ok || f.IsDir() {
+			continue
+		}
+		os.Remove(filepath.Join(cacheDir, f.Name()))
+	}
+}
+
+func download(url, dstFile string) error {
+	log.Info("Downloading DNS block list: ", url)
+
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download block list '%s': %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download block list '%s': %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstFile), 0700); err != nil {
+		return err
+	}
+	tmpFile := dstFile + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, maxListSize+1))
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil && n > maxListSize {
+		err = fmt.Errorf("the list is too big (max %d bytes)", maxListSize)
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to download block list '%s': %w", url, err)
+	}
+	return os.Rename(tmpFile, dstFile)
+}
+
+// Parse returns domains from the block list (hosts-format, domain list or adblock-style domain rules)
+func Parse(r io.Reader) ([]string, error) {
+	var ret []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '!' || line[0] == '[' {
+			continue
+		}
+
+		// adblock-style: "||example.com^"
+		if strings.HasPrefix(line, "||") {
+			line = strings.TrimPrefix(line, "||")
+			idx := strings.IndexByte(line, '^')
+			if idx < 0 || idx != len(line)-1 {
+				continue // only rules for the whole domain are supported
+			}
+			if d, ok := normalizeDomain(line[:idx]); ok {
+				ret = append(ret, d)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if net.ParseIP(fields[0]) != nil {
+			// hosts-format: "0.0.0.0 example.com [example2.com ...]"
+			for _, f := range fields[1:] {
+				if d, ok := normalizeDomain(f); ok && !isLocalHostName(d) {
+					ret = append(ret, d)
+				}
+			}
+			continue
+		}
+		if len(fields) == 1 {
+			if d, ok := normalizeDomain(fields[0]); ok {
+				ret = append(ret, d)
+			}
+		}
+	}
+	return ret, scanner.Err()
+}
+
+func normalizeDomain(d string) (string, bool) {
+	d = strings.Trim(strings.TrimPrefix(strings.ToLower(d), "*."), ".")
+	if len(d) == 0 || len(d) > 253 || !strings.Contains(d, ".") {
+		return "", false
+	}
+	for _, c := range d {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.') {
+			return "", false
+		}
+	}
+	if net.ParseIP(d) != nil {
+		return "", false
+	}
+	return d, true
+}
+
+func isLocalHostName(d string) bool {
+	switch d {
+	case "localhost.localdomain", "local.localdomain", "broadcasthost.localdomain":
+		return true
+	}
+	return strings.HasPrefix(d, "ip6-")
+}
+
+func isUrl(src string) bool {
+	s := strings.ToLower(src)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
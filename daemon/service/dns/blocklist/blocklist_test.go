@@ -0,0 +1,90 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package blocklist
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	list := `# comment
+0.0.0.0 ads.example.com
+127.0.0.1	localhost
+127.0.0.1 Tracker.Example.com metrics.example.com # inline comment
+::1 ip6-localhost
+plain.example.org
+||adblock.example.net^
+||path.example.net^$third-party
+! adblock comment
+[Adblock Plus 2.0]
+not a domain list line
+`
+	domains, err := Parse(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ads.example.com", "tracker.example.com", "metrics.example.com", "plain.example.org", "adblock.example.net"}
+	if !reflect.DeepEqual(domains, expected) {
+		t.Errorf("expected %v, got %v", expected, domains)
+	}
+}
+
+func TestLoadUrlCached(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, "0.0.0.0 ads.example.com")
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		domains, _, err := Load(srv.URL, cacheDir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(domains) != 1 || domains[0] != "ads.example.com" {
+			t.Fatalf("unexpected domains %v", domains)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 download (cached copy must be used), got %d", requests)
+	}
+
+	// the cached copy is used when the server is not available
+	srv.Close()
+	if domains, _, err := Load(srv.URL, cacheDir, true); err != nil || len(domains) != 1 {
+		t.Errorf("cached copy expected to be used: %v %v", domains, err)
+	}
+
+	RemoveCache(cacheDir, nil)
+	if _, err := os.Stat(CacheFilePath(srv.URL, cacheDir)); err == nil {
+		t.Error("cache file expected to be removed")
+	}
+}
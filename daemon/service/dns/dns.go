@@ -36,6 +36,25 @@ type DnsExtraSettings struct {
 	// If true - use old style DNS management mechanism
 	// by direct modifying file '/etc/resolv.conf'
 	Linux_IsDnsMgmtOldStyle bool
+	// Linux DNS management method (see LinuxDnsMgmt...). Ignored when Linux_IsDnsMgmtOldStyle is true.
+	Linux_DnsMgmtMethod string
+}
+
+// Linux DNS management methods
+const (
+	LinuxDnsMgmtAuto           = ""               // 'resolvectl' (if available), otherwise - direct modification of '/etc/resolv.conf'
+	LinuxDnsMgmtResolvectl     = "resolvectl"     // systemd-resolved configuration using 'resolvectl' binary
+	LinuxDnsMgmtResolvedDbus   = "resolved-dbus"  // systemd-resolved configuration using D-Bus API (org.freedesktop.resolve1)
+	LinuxDnsMgmtNetworkManager = "networkmanager" // NetworkManager global DNS configuration using D-Bus API (org.freedesktop.NetworkManager)
+)
+
+// IsLinuxDnsMgmtMethodValid returns true if the value is a known Linux DNS management method
+func IsLinuxDnsMgmtMethodValid(method string) bool {
+	switch method {
+	case LinuxDnsMgmtAuto, LinuxDnsMgmtResolvectl, LinuxDnsMgmtResolvedDbus, LinuxDnsMgmtNetworkManager:
+		return true
+	}
+	return false
 }
 
 var (
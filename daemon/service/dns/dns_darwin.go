@@ -59,7 +59,7 @@ func implResume(defaultDNS DnsSettings, localInterfaceIP net.IP) error {
 }
 
 func implGetDnsEncryptionAbilities() (dnsOverHttps, dnsOverTls bool, err error) {
-	return true, false, nil
+	return true, true, nil
 }
 
 // Set manual DNS.
@@ -72,13 +72,16 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 	}()
 
 	ResolversTeardown()
-	// start encrypted DNS configuration (if required)
-	if dnsCfg.UseEncryption() {
+	dnsInfoForFirewall = dnsCfg
+	// start local resolver for encrypted DNS, split DNS rules, local filtering rules or DNS statistics (if required)
+	if dnsCfg.UseEncryption() || len(dnsCfg.DomainRules) > 0 || isLocalResolverForced() {
 		confs, err := ResolversSetup(dnsCfg)
 		if err != nil {
 			return DnsSettings{}, err
 		}
-		// the local DNS must be configured to the dnscrypt-proxy (localhost)
+		// the firewall must allow plain DNS servers used by the local resolver
+		dnsInfoForFirewall = resolversFirewallInfo(confs, dnsCfg)
+		// the local DNS must be configured to the local resolver (localhost)
 		dnsCfg = DnsSettings{Servers: confs}
 	}
 
@@ -97,7 +100,7 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 		return DnsSettings{}, fmt.Errorf("set manual DNS: Failed to change DNS: %w", err)
 	}
 
-	return dnsCfg, nil
+	return dnsInfoForFirewall, nil
 }
 
 // DeleteManual - reset manual DNS configuration to default (DHCP)
@@ -0,0 +1,102 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/resolver"
+)
+
+var (
+	failoverNotifierMutex sync.Mutex
+	failoverNotifier      func(DnsFailoverInfo)
+)
+
+// DnsResolverHealth - the health status of the DNS server used by the local resolver
+type DnsResolverHealth struct {
+	Server     string
+	IsFallback bool   // true - the fallback DNS server (used only when all configured DNS servers are unreachable)
+	IsActive   bool   // true - DNS queries are currently sent to this server first
+	IsHealthy  bool   // false - the server failed several times in a row
+	LastError  string // the last failure reason
+	LatencyMs  float64
+}
+
+// DnsFailoverInfo - the information about the DNS server which became active
+type DnsFailoverInfo struct {
+	Server     string // the active DNS server
+	IsFallback bool   // true - all configured DNS servers are unreachable, the fallback DNS server is in use
+	IsPrimary  bool   // true - the most preferred DNS server is in use (e.g. recovered after failure)
+}
+
+// SetFailoverNotifier registers the function which is called when the local resolver switched to another DNS server
+// (failover to the next/fallback server or recovery of the preferred one)
+func SetFailoverNotifier(f func(DnsFailoverInfo)) {
+	failoverNotifierMutex.Lock()
+	defer failoverNotifierMutex.Unlock()
+	failoverNotifier = f
+}
+
+// GetResolversHealth returns the health status of DNS servers used by the local resolver
+// (empty - if the local resolver is not in use)
+func GetResolversHealth() []DnsResolverHealth {
+	localResolverMutex.Lock()
+	r := localResolver
+	localResolverMutex.Unlock()
+
+	if r == nil {
+		return nil
+	}
+
+	var ret []DnsResolverHealth
+	for _, h := range r.Health() {
+		ret = append(ret, DnsResolverHealth{
+			Server:     h.Upstream.String(),
+			IsFallback: h.IsFallback,
+			IsActive:   h.IsActive,
+			IsHealthy:  h.IsHealthy,
+			LastError:  h.LastError,
+			LatencyMs:  float64(h.Latency.Microseconds()) / 1000,
+		})
+	}
+	return ret
+}
+
+func onActiveUpstreamChanged(active resolver.Upstream, isFallback bool) {
+	failoverNotifierMutex.Lock()
+	f := failoverNotifier
+	failoverNotifierMutex.Unlock()
+
+	if f == nil {
+		return
+	}
+
+	info := DnsFailoverInfo{Server: active.String(), IsFallback: isFallback}
+	if !isFallback {
+		if health := GetResolversHealth(); len(health) > 0 {
+			info.IsPrimary = health[0].Server == info.Server
+		}
+	}
+	f(info)
+}
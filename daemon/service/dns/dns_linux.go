@@ -28,6 +28,7 @@ package dns
 import (
 	"fmt"
 	"net"
+	"os"
 
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 )
@@ -40,13 +41,18 @@ func isResolveCtlInUse() bool {
 	return len(platform.ResolvectlBinPath()) > 0
 }
 
+// DNS management method in use: one of LinuxDnsMgmt... constants or mgmtMethodResolvconf
+const mgmtMethodResolvconf = "resolvconf"
+
 var (
-	isOldMgmtStyleInUse bool
-	f_implInitialize    func() error
-	f_implPause         func(localInterfaceIP net.IP) error
-	f_implResume        func(localInterfaceIP net.IP) error
-	f_implSetManual     func(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error)
-	f_implDeleteManual  func(localInterfaceIP net.IP) error
+	mgmtMethodInUse    string
+	f_implInitialize   func() error
+	f_implPause        func(localInterfaceIP net.IP) error
+	f_implResume       func(localInterfaceIP net.IP) error
+	f_implSetManual    func(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error)
+	f_implDeleteManual func(localInterfaceIP net.IP) error
+	// returns true if split DNS rules can be applied by the OS (otherwise - the local resolver is in use)
+	f_implIsDomainRulesApplicable func(rules []DnsDomainRule, localInterfaceIP net.IP) bool
 )
 
 var (
@@ -61,45 +67,116 @@ func init() {
 	f_implResume = func(localInterfaceIP net.IP) error { return err }
 	f_implSetManual = func(dnsCfg DnsSettings, localInterfaceIP net.IP) (DnsSettings, error) { return DnsSettings{}, err }
 	f_implDeleteManual = func(localInterfaceIP net.IP) error { return err }
+	f_implIsDomainRulesApplicable = func(rules []DnsDomainRule, localInterfaceIP net.IP) bool { return false }
 }
 
 // implInitialize doing initialization stuff (called on application start)
 func implInitialize() error {
+	method := requiredMgmtMethod()
 
-	if !isNeedUseOldMgmtStyle() && isResolveCtlInUse() {
+	switch method {
+	case LinuxDnsMgmtResolvedDbus:
+		if err := rdbus_isAvailable(); err != nil {
+			log.Warning(fmt.Sprintf("Unable to use systemd-resolved D-Bus API for DNS management (%s). Using default method.", err))
+			method = LinuxDnsMgmtAuto
+		}
+	case LinuxDnsMgmtNetworkManager:
+		if err := nm_isAvailable(); err != nil {
+			log.Warning(fmt.Sprintf("Unable to use NetworkManager D-Bus API for DNS management (%s). Using default method.", err))
+			method = LinuxDnsMgmtAuto
+		}
+	}
+	if method == LinuxDnsMgmtAuto || (method == LinuxDnsMgmtResolvectl && !isResolveCtlInUse()) {
+		method = mgmtMethodResolvconf
+		if isResolveCtlInUse() {
+			method = LinuxDnsMgmtResolvectl
+		}
+	}
+
+	// restore the NetworkManager global DNS configuration if it was changed by the daemon before (e.g. the daemon was not stopped correctly)
+	if method != LinuxDnsMgmtNetworkManager {
+		if _, err := os.Stat(platform.NmGlobalDnsBackupFile()); err == nil {
+			if err := nm_restore(); err != nil {
+				log.Warning(fmt.Errorf("failed to restore NetworkManager global DNS configuration: %w", err))
+			}
+		}
+	}
+
+	switch method {
+	case LinuxDnsMgmtResolvedDbus:
+		// systemd-resolved D-Bus API
+		f_implInitialize = rdbus_implInitialize
+		f_implPause = rdbus_implPause
+		f_implResume = rdbus_implResume
+		f_implSetManual = rdbus_implSetManual
+		f_implDeleteManual = rdbus_implDeleteManual
+		f_implIsDomainRulesApplicable = rctl_isDomainRulesApplicable
+		log.Info("Initialized management: systemd-resolved D-Bus API in use")
+	case LinuxDnsMgmtNetworkManager:
+		// NetworkManager D-Bus API (split DNS rules are applied by the local resolver)
+		f_implInitialize = nm_implInitialize
+		f_implPause = nm_implPause
+		f_implResume = nm_implResume
+		f_implSetManual = nm_implSetManual
+		f_implDeleteManual = nm_implDeleteManual
+		f_implIsDomainRulesApplicable = func(rules []DnsDomainRule, localInterfaceIP net.IP) bool { return false }
+		log.Info("Initialized management: NetworkManager D-Bus API in use")
+	case LinuxDnsMgmtResolvectl:
 		// new management style: using 'resolvectl'
 		f_implInitialize = rctl_implInitialize
 		f_implPause = rctl_implPause
 		f_implResume = rctl_implResume
 		f_implSetManual = rctl_implSetManual
 		f_implDeleteManual = rctl_implDeleteManual
-		isOldMgmtStyleInUse = false
+		f_implIsDomainRulesApplicable = rctl_isDomainRulesApplicable
 		log.Info("Initialized management: resolvectl in use")
-	} else {
+	default:
 		// old management style: direct modifying '/etc/resolv.conf'
 		f_implInitialize = rconf_implInitialize
 		f_implPause = rconf_implPause
 		f_implResume = rconf_implResume
 		f_implSetManual = rconf_implSetManual
 		f_implDeleteManual = rconf_implDeleteManual
-		isOldMgmtStyleInUse = true
+		f_implIsDomainRulesApplicable = func(rules []DnsDomainRule, localInterfaceIP net.IP) bool { return false }
 		log.Info("Initialized management: direct modification the '/etc/resolv.conf' ")
 	}
+	mgmtMethodInUse = method
 
 	return f_implInitialize()
 }
 
-func isNeedUseOldMgmtStyle() bool {
-	if funcGetUserSettings != nil {
-		extraSettings := funcGetUserSettings()
-		return extraSettings.Linux_IsDnsMgmtOldStyle
+// requiredMgmtMethod returns DNS management method defined by user settings
+// (mgmtMethodResolvconf - for the old style management)
+func requiredMgmtMethod() string {
+	if funcGetUserSettings == nil {
+		return LinuxDnsMgmtAuto
+	}
+	extraSettings := funcGetUserSettings()
+	if extraSettings.Linux_IsDnsMgmtOldStyle {
+		return mgmtMethodResolvconf
 	}
-	return false
+	return extraSettings.Linux_DnsMgmtMethod
+}
+
+// LinuxDnsMgmtMethodInUse returns DNS management method in use ("resolvconf" - direct modification of '/etc/resolv.conf')
+func LinuxDnsMgmtMethodInUse() string {
+	return mgmtMethodInUse
+}
+
+// LinuxIsResolvedDbusAvailable returns error if systemd-resolved D-Bus API can not be used for DNS management
+func LinuxIsResolvedDbusAvailable() error {
+	return rdbus_isAvailable()
+}
+
+// LinuxIsNetworkManagerAvailable returns error if NetworkManager D-Bus API can not be used for DNS management
+func LinuxIsNetworkManagerAvailable() error {
+	return nm_isAvailable()
 }
 
 func implApplyUserSettings() error {
 	// checking if the required settings is already initialized
-	if isNeedUseOldMgmtStyle() == isOldMgmtStyleInUse {
+	required := requiredMgmtMethod()
+	if required == mgmtMethodInUse || (required == LinuxDnsMgmtAuto && (mgmtMethodInUse == LinuxDnsMgmtResolvectl || mgmtMethodInUse == mgmtMethodResolvconf)) {
 		return nil // expected configuration already applied
 	}
 	// if DNS changed to a custom value - we have to restore the original DNS settings before changing the DNS management style
@@ -110,7 +187,7 @@ func implApplyUserSettings() error {
 }
 
 func implGetDnsEncryptionAbilities() (dnsOverHttps, dnsOverTls bool, err error) {
-	return true, false, nil
+	return true, true, nil
 }
 func implGetPredefinedDnsConfigurations() ([]DnsSettings, error) {
 	return []DnsSettings{}, nil
@@ -158,15 +235,30 @@ func implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirew
 		return dnsCfg, nil
 	}
 
+	// Split DNS rules are applied using systemd-resolved routing domains (if possible);
+	// otherwise - by the local resolver.
+	// The local resolver is also required for encrypted DNS, local filtering rules (block lists, hosts ...) and DNS statistics
+	useLocalResolver := dnsCfg.UseEncryption() || isLocalResolverForced()
+	if len(dnsCfg.DomainRules) > 0 && !f_implIsDomainRulesApplicable(dnsCfg.DomainRules, localInterfaceIP) {
+		useLocalResolver = true
+	}
+
 	// start encrypted DNS configuration (if required)
-	if dnsCfg.UseEncryption() {
+	if useLocalResolver {
 		confs, err := ResolversSetup(dnsCfg)
 		if err != nil {
 			return DnsSettings{}, err
 		}
-		// the local DNS must be configured to the dnscrypt-proxy (localhost)
+		fwInfo := resolversFirewallInfo(confs, dnsCfg)
+		// the local DNS must be configured to the local resolver (localhost)
 		dnsCfg = DnsSettings{Servers: confs}
 		manualDNS = dnsCfg
+
+		if _, err := f_implSetManual(dnsCfg, localInterfaceIP); err != nil {
+			return DnsSettings{}, err
+		}
+		// the firewall must allow plain DNS servers used by the local resolver
+		return fwInfo, nil
 	}
 
 	return f_implSetManual(dnsCfg, localInterfaceIP)
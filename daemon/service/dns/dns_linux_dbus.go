@@ -0,0 +1,166 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dns
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/dbus"
+)
+
+var (
+	bus_connMutex sync.Mutex
+	bus_conn      *dbus.Conn
+)
+
+// bus_systemBus returns the connection to the D-Bus system bus (reconnects if the connection was closed)
+func bus_systemBus() (*dbus.Conn, error) {
+	bus_connMutex.Lock()
+	defer bus_connMutex.Unlock()
+
+	if bus_conn != nil && !bus_conn.IsClosed() {
+		return bus_conn, nil
+	}
+	c, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	bus_conn = c
+	return c, nil
+}
+
+// bus_isServiceRunning returns error if the D-Bus service is not running
+func bus_isServiceRunning(busName string) error {
+	c, err := bus_systemBus()
+	if err != nil {
+		return err
+	}
+	has, err := c.NameHasOwner(busName)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return fmt.Errorf("'%s' is not running", busName)
+	}
+	return nil
+}
+
+// bus_startSignalMonitor subscribes to D-Bus signals defined by 'matchRules' and calls 'onSignal' when they are received.
+// The signals received within a short period of time are processed once (only the last signal is passed to 'onSignal').
+// The monitor uses own D-Bus connection and stops when the 'done' channel is closed.
+func bus_startSignalMonitor(name string, matchRules []string, done <-chan struct{}, onSignal func(m *dbus.Message)) {
+	const (
+		reactionDelay  = time.Second * 2
+		reconnectDelay = time.Second * 5
+	)
+
+	go func() {
+		// Recover from panic (if any)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error(fmt.Sprintf("!!! PANIC !!! [recovered]: %v", r))
+			}
+		}()
+
+		log.Info(fmt.Sprintf("%s: DNS-change monitoring start", name))
+		defer log.Info(fmt.Sprintf("%s: DNS-change monitoring stopped", name))
+
+		for {
+			conn, signals, err := bus_subscribe(matchRules)
+			if err != nil {
+				log.Error(fmt.Errorf("%s: failed to start DNS-change monitoring: %w", name, err))
+			} else {
+				isDone := bus_processSignals(signals, done, reactionDelay, onSignal)
+				conn.Close()
+				if isDone {
+					return
+				}
+				log.Warning(fmt.Sprintf("%s: D-Bus connection closed; restarting DNS-change monitoring...", name))
+			}
+
+			select {
+			case <-time.After(reconnectDelay):
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func bus_subscribe(matchRules []string) (*dbus.Conn, <-chan *dbus.Message, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, nil, err
+	}
+	signals := make(chan *dbus.Message, 32)
+	conn.Signals(signals)
+	for _, rule := range matchRules {
+		if err := conn.AddMatch(rule); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to subscribe to D-Bus signals: %w", err)
+		}
+	}
+	return conn, signals, nil
+}
+
+// bus_processSignals processes signals until the 'done' channel is closed (returns true) or the connection is closed (returns false)
+func bus_processSignals(signals <-chan *dbus.Message, done <-chan struct{}, reactionDelay time.Duration, onSignal func(m *dbus.Message)) bool {
+	for {
+		var last *dbus.Message
+		select {
+		case m, ok := <-signals:
+			if !ok {
+				return false
+			}
+			last = m
+		case <-done:
+			return true
+		}
+
+		// wait for reaction (needed to avoid multiple reactions on the changes in short period of time)
+		timer := time.NewTimer(reactionDelay)
+	wait:
+		for {
+			select {
+			case m, ok := <-signals:
+				if !ok {
+					timer.Stop()
+					return false
+				}
+				last = m
+			case <-timer.C:
+				break wait
+			case <-done:
+				timer.Stop()
+				return true
+			}
+		}
+
+		onSignal(last)
+	}
+}
@@ -0,0 +1,367 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/dbus"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+)
+
+// DNS management using NetworkManager D-Bus API.
+// It is intended for systems where NetworkManager owns the DNS configuration ('/etc/resolv.conf' or systemd-resolved).
+// Instead of changing the configuration which NetworkManager overwrites (e.g. after WiFi reconnection),
+// the NetworkManager global DNS configuration is used: it has priority over DNS settings of all connections.
+//
+// Note: NetworkManager keeps the global DNS configuration persistent (NetworkManager-intern.conf),
+// so the original configuration is saved to a file and restored on disconnection or on the next daemon start.
+//
+// For reference: https://networkmanager.dev/docs/api/latest/gdbus-org.freedesktop.NetworkManager.html
+
+const (
+	nm_busName       = "org.freedesktop.NetworkManager"
+	nm_path          = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+	nm_iface         = "org.freedesktop.NetworkManager"
+	nm_propGlobalDns = "GlobalDnsConfiguration"
+)
+
+var (
+	nm_done       chan struct{}
+	nm_done_mutex sync.Mutex
+
+	nm_mutex   sync.Mutex
+	nm_applied *nm_globalDns // configuration applied by the daemon (nil - not applied)
+)
+
+// nm_globalDns - NetworkManager global DNS configuration
+type nm_globalDns struct {
+	Searches []string                      `json:",omitempty"`
+	Options  []string                      `json:",omitempty"`
+	Domains  map[string]nm_globalDnsDomain `json:",omitempty"` // "*" - default domain
+}
+
+type nm_globalDnsDomain struct {
+	Servers []string `json:",omitempty"`
+	Options []string `json:",omitempty"`
+}
+
+// nm_isAvailable returns error if NetworkManager D-Bus API is not accessible
+func nm_isAvailable() error {
+	return bus_isServiceRunning(nm_busName)
+}
+
+func nm_implInitialize() error {
+	if err := nm_isAvailable(); err != nil {
+		return err
+	}
+	// restore the original configuration (if the daemon was not stopped correctly while DNS was changed)
+	if _, err := os.Stat(platform.NmGlobalDnsBackupFile()); err == nil {
+		log.Info("Restoring original NetworkManager global DNS configuration...")
+		return nm_restore()
+	}
+	return nil
+}
+
+func nm_implPause(localInterfaceIP net.IP) error {
+	nm_stopDnsChangeMonitor()
+	return nm_restore()
+}
+
+func nm_implResume(localInterfaceIP net.IP) error {
+	return nil // nothing to resume: the configuration is re-applied by f_implSetManual()
+}
+
+// Set manual DNS.
+func nm_implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error) {
+	nm_stopDnsChangeMonitor() // stop monitoring
+	defer func() {
+		if retErr == nil {
+			nm_startDnsChangeMonitor() // if success - start monitoring
+		}
+	}()
+
+	if localInterfaceIP == nil || localInterfaceIP.IsUnspecified() {
+		log.Info("'Set DNS' call ignored due to no local address initialized")
+		return dnsCfg, nil
+	}
+
+	cfg := nm_globalDns{Domains: map[string]nm_globalDnsDomain{"*": {}}}
+	for _, svr := range dnsCfg.Servers {
+		if ip := svr.Ip(); ip != nil {
+			def := cfg.Domains["*"]
+			def.Servers = append(def.Servers, ip.String())
+			cfg.Domains["*"] = def
+		}
+	}
+	if len(cfg.Domains["*"].Servers) == 0 {
+		return DnsSettings{}, nm_error(fmt.Errorf("no DNS servers defined"))
+	}
+
+	if err := nm_apply(cfg); err != nil {
+		return DnsSettings{}, nm_error(err)
+	}
+	return dnsCfg, nil
+}
+
+// DeleteManual - reset manual DNS configuration to default
+func nm_implDeleteManual(localInterfaceIP net.IP) error {
+	nm_stopDnsChangeMonitor()
+	return nm_restore()
+}
+
+func nm_error(err error) error {
+	return fmt.Errorf("failed to change DNS configuration (NetworkManager D-Bus): %w", err)
+}
+
+// nm_apply sets NetworkManager global DNS configuration (the original configuration is saved before the first change)
+func nm_apply(cfg nm_globalDns) error {
+	nm_mutex.Lock()
+	defer nm_mutex.Unlock()
+
+	backupFile := platform.NmGlobalDnsBackupFile()
+	if _, err := os.Stat(backupFile); err != nil {
+		orig, err := nm_read()
+		if err != nil {
+			return fmt.Errorf("failed to read global DNS configuration: %w", err)
+		}
+		data, err := json.Marshal(orig)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(backupFile, data, 0600); err != nil {
+			return fmt.Errorf("failed to save original global DNS configuration: %w", err)
+		}
+	}
+
+	if err := nm_write(cfg); err != nil {
+		return err
+	}
+	nm_applied = &cfg
+	return nil
+}
+
+// nm_restore restores the original NetworkManager global DNS configuration
+func nm_restore() error {
+	nm_mutex.Lock()
+	defer nm_mutex.Unlock()
+
+	nm_applied = nil
+
+	backupFile := platform.NmGlobalDnsBackupFile()
+	data, err := os.ReadFile(backupFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // nothing to restore
+		}
+		return nm_error(err)
+	}
+
+	var orig nm_globalDns
+	if err := json.Unmarshal(data, &orig); err != nil {
+		log.Warning(fmt.Errorf("failed to parse the original NetworkManager global DNS configuration (resetting to empty): %w", err))
+		orig = nm_globalDns{}
+	}
+	if err := nm_write(orig); err != nil {
+		return nm_error(err)
+	}
+	os.Remove(backupFile)
+	return nil
+}
+
+// nm_read returns current NetworkManager global DNS configuration
+func nm_read() (nm_globalDns, error) {
+	var ret nm_globalDns
+
+	c, err := bus_systemBus()
+	if err != nil {
+		return ret, err
+	}
+	v, err := c.GetProperty(nm_busName, nm_path, nm_iface, nm_propGlobalDns)
+	if err != nil {
+		return ret, err
+	}
+	props, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return ret, fmt.Errorf("unexpected type of '%s' property", nm_propGlobalDns)
+	}
+
+	ret.Searches = nm_stringsValue(props["searches"])
+	ret.Options = nm_stringsValue(props["options"])
+	if domainsVar, ok := props["domains"].(dbus.Variant); ok {
+		if domains, ok := domainsVar.Value.(map[interface{}]interface{}); ok {
+			ret.Domains = make(map[string]nm_globalDnsDomain)
+			for k, v := range domains {
+				name, _ := k.(string)
+				dVar, _ := v.(dbus.Variant)
+				dProps, _ := dVar.Value.(map[interface{}]interface{})
+				ret.Domains[name] = nm_globalDnsDomain{
+					Servers: nm_stringsValue(dProps["servers"]),
+					Options: nm_stringsValue(dProps["options"]),
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+// nm_write sets NetworkManager global DNS configuration (empty configuration - the global DNS is not in use)
+func nm_write(cfg nm_globalDns) error {
+	c, err := bus_systemBus()
+	if err != nil {
+		return err
+	}
+
+	props := map[string]dbus.Variant{}
+	if len(cfg.Searches) > 0 {
+		props["searches"] = dbus.MakeVariant("as", cfg.Searches)
+	}
+	if len(cfg.Options) > 0 {
+		props["options"] = dbus.MakeVariant("as", cfg.Options)
+	}
+	if len(cfg.Domains) > 0 {
+		domains := map[string]dbus.Variant{}
+		for name, d := range cfg.Domains {
+			dProps := map[string]dbus.Variant{}
+			if len(d.Servers) > 0 {
+				dProps["servers"] = dbus.MakeVariant("as", d.Servers)
+			}
+			if len(d.Options) > 0 {
+				dProps["options"] = dbus.MakeVariant("as", d.Options)
+			}
+			domains[name] = dbus.MakeVariant("a{sv}", dProps)
+		}
+		props["domains"] = dbus.MakeVariant("a{sv}", domains)
+	}
+
+	return c.SetProperty(nm_busName, nm_path, nm_iface, nm_propGlobalDns, dbus.MakeVariant("a{sv}", props))
+}
+
+func nm_stringsValue(v interface{}) []string {
+	variant, ok := v.(dbus.Variant)
+	if !ok {
+		return nil
+	}
+	arr, ok := variant.Value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var ret []string
+	for _, s := range arr {
+		if str, ok := s.(string); ok {
+			ret = append(ret, str)
+		}
+	}
+	return ret
+}
+
+// nm_configOk - returns true if current NetworkManager global DNS configuration is the same as applied by the daemon
+func nm_configOk() (bool, error) {
+	nm_mutex.Lock()
+	applied := nm_applied
+	nm_mutex.Unlock()
+
+	if applied == nil {
+		return true, nil
+	}
+	current, err := nm_read()
+	if err != nil {
+		return false, err
+	}
+	return nm_isEqual(current, *applied), nil
+}
+
+func nm_isEqual(a, b nm_globalDns) bool {
+	normalize := func(c nm_globalDns) nm_globalDns {
+		ret := nm_globalDns{Searches: c.Searches, Options: c.Options, Domains: map[string]nm_globalDnsDomain{}}
+		for k, v := range c.Domains {
+			opts := append([]string{}, v.Options...)
+			sort.Strings(opts)
+			ret.Domains[k] = nm_globalDnsDomain{Servers: v.Servers, Options: opts}
+		}
+		return ret
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func nm_stopDnsChangeMonitor() {
+	nm_done_mutex.Lock()
+	defer nm_done_mutex.Unlock()
+
+	if nm_done == nil {
+		return // already stopped
+	}
+	close(nm_done)
+	nm_done = nil
+}
+
+func nm_startDnsChangeMonitor() {
+	nm_done_mutex.Lock()
+	defer nm_done_mutex.Unlock()
+	if nm_done != nil {
+		close(nm_done) // stop previous monitoring (if any)
+	}
+	nm_done = make(chan struct{})
+
+	matchRules := []string{
+		// NetworkManager properties changed (including 'GlobalDnsConfiguration')
+		fmt.Sprintf("type='signal',sender='%s',interface='%s',member='PropertiesChanged',path='%s'", nm_busName, dbus.PropertiesInterface, nm_path),
+		// NetworkManager restarted
+		fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='%s'", nm_busName),
+	}
+
+	bus_startSignalMonitor("NetworkManager", matchRules, nm_done, func(m *dbus.Message) {
+		if isPaused {
+			return
+		}
+		isOk, err := nm_configOk()
+		if err != nil {
+			log.Error(fmt.Errorf("DNS-change monitoring failed to check configuration: %w", err))
+			return
+		}
+		if isOk {
+			return
+		}
+
+		nm_mutex.Lock()
+		applied := nm_applied
+		nm_mutex.Unlock()
+		if applied == nil {
+			return
+		}
+
+		log.Info(fmt.Sprintf("DNS-change monitoring: NetworkManager global DNS was changed outside [%s.%s]. Restoring ...", m.Interface, m.Member))
+		if err := nm_apply(*applied); err != nil {
+			log.Error(nm_error(err))
+		}
+	})
+}
@@ -48,14 +48,27 @@ var (
 	rctl_done             chan struct{}
 	rctl_done_mutex       sync.Mutex
 	rctl_localInterfaceIp net.IP
+
+	// network links (non-VPN interfaces) modified to apply split DNS rules (key - link name)
+	rctl_splitDnsLinks      map[string]rctl_linkState
+	rctl_splitDnsLinksMutex sync.Mutex
 )
 
+// rctl_linkState - the systemd-resolved configuration of the network link
+type rctl_linkState struct {
+	origDns          []string // original DNS servers (restored when split DNS rules are removed)
+	origDomains      []string // original domains
+	origDefaultRoute string   // original 'default-route' value ("yes"/"no")
+	domains          []string // routing domains applied by split DNS rules
+}
+
 func rctl_implInitialize() error {
 	return nil
 }
 
 func rctl_implPause(localInterfaceIP net.IP) error {
 	rctl_stopDnsChangeMonitor()
+	rctl_restoreSplitDnsLinks()
 
 	inf, err := netinfo.InterfaceByIPAddr(localInterfaceIP)
 	if err != nil {
@@ -144,9 +157,191 @@ func rctl_applySetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoFo
 		return DnsSettings{}, rctl_error(err)
 	}
 
+	if err := rctl_applyDomainRules(dnsCfg.DomainRules, localInterfaceIP); err != nil {
+		return DnsSettings{}, rctl_error(err)
+	}
+
 	return dnsCfg, nil
 }
 
+// rctl_isDomainRulesApplicable returns true if split DNS rules can be applied using systemd-resolved routing domains.
+// It is possible only when the rule's servers are reachable through non-VPN interfaces
+// (the VPN link uses own DNS servers for all other domains).
+func rctl_isDomainRulesApplicable(rules []DnsDomainRule, localInterfaceIP net.IP) bool {
+	if _, err := rctl_domainRulesByLink(rules, localInterfaceIP); err != nil {
+		log.Info(fmt.Sprintf("Split DNS rules can not be applied using routing domains (%s)", err))
+		return false
+	}
+	return true
+}
+
+// rctl_domainRulesByLink returns split DNS rules grouped by the network link (interface name) through which the rule's servers are reachable
+func rctl_domainRulesByLink(rules []DnsDomainRule, localInterfaceIP net.IP) (map[string][]DnsDomainRule, error) {
+	vpnInterfaceName := ""
+	if inf, err := netinfo.InterfaceByIPAddr(localInterfaceIP); err == nil {
+		vpnInterfaceName = inf.Name
+	}
+
+	ret := make(map[string][]DnsDomainRule)
+	for _, rule := range rules {
+		linkName := ""
+		for _, ip := range rule.ServersIPs() {
+			outIp, err := netinfo.GetOutboundIPEx(ip)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine route to DNS server %s: %w", ip, err)
+			}
+			inf, err := netinfo.InterfaceByIPAddr(outIp)
+			if err != nil {
+				return nil, fmt.Errorf("unable to determine interface for DNS server %s: %w", ip, err)
+			}
+			if inf.Name == vpnInterfaceName {
+				return nil, fmt.Errorf("DNS server %s is reachable through the VPN interface", ip)
+			}
+			if linkName != "" && linkName != inf.Name {
+				return nil, fmt.Errorf("DNS servers for domain %s are reachable through different interfaces", rule.Domain)
+			}
+			linkName = inf.Name
+		}
+		if linkName == "" {
+			return nil, fmt.Errorf("no DNS servers defined for domain %s", rule.Domain)
+		}
+		// the link can have only one list of DNS servers
+		for _, r := range ret[linkName] {
+			if strings.Join(r.Servers, ",") != strings.Join(rule.Servers, ",") {
+				return nil, fmt.Errorf("domains %s and %s use different DNS servers on the same interface %s", r.Domain, rule.Domain, linkName)
+			}
+		}
+		ret[linkName] = append(ret[linkName], rule)
+	}
+	return ret, nil
+}
+
+// rctl_applyDomainRules configures split DNS rules as routing domains of non-VPN links:
+//
+//	resolvectl dns enp0s3 192.168.1.1
+//	resolvectl domain enp0s3 '~corp.example' '~lan'
+//	resolvectl default-route enp0s3 false
+//
+// The original configuration of the links is restored by rctl_restoreSplitDnsLinks()
+func rctl_applyDomainRules(rules []DnsDomainRule, localInterfaceIP net.IP) error {
+	rctl_restoreSplitDnsLinks()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	rulesByLink, err := rctl_domainRulesByLink(rules, localInterfaceIP)
+	if err != nil {
+		return err
+	}
+
+	rctl_splitDnsLinksMutex.Lock()
+	defer rctl_splitDnsLinksMutex.Unlock()
+
+	if rctl_splitDnsLinks == nil {
+		rctl_splitDnsLinks = make(map[string]rctl_linkState)
+	}
+
+	binPath := platform.ResolvectlBinPath()
+	for linkName, linkRules := range rulesByLink {
+		state := rctl_readLinkState(linkName)
+		for _, r := range linkRules {
+			state.domains = append(state.domains, "~"+r.Domain)
+		}
+		rctl_splitDnsLinks[linkName] = state
+
+		if err := shell.Exec(log, binPath, append([]string{"dns", linkName}, linkRules[0].Servers...)...); err != nil {
+			return err
+		}
+		if err := shell.Exec(log, binPath, append([]string{"domain", linkName}, state.domains...)...); err != nil {
+			return err
+		}
+		// the link must not be used for the domains not defined by the rules
+		if err := shell.Exec(log, binPath, "default-route", linkName, "false"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rctl_restoreSplitDnsLinks restores the original configuration of the links modified by split DNS rules
+func rctl_restoreSplitDnsLinks() {
+	rctl_splitDnsLinksMutex.Lock()
+	defer rctl_splitDnsLinksMutex.Unlock()
+
+	binPath := platform.ResolvectlBinPath()
+	for linkName, state := range rctl_splitDnsLinks {
+		dnsArgs := append([]string{"dns", linkName}, state.origDns...)
+		if len(state.origDns) == 0 {
+			dnsArgs = append(dnsArgs, "")
+		}
+		domainArgs := append([]string{"domain", linkName}, state.origDomains...)
+		if len(state.origDomains) == 0 {
+			domainArgs = append(domainArgs, "")
+		}
+		if err := shell.Exec(log, binPath, dnsArgs...); err != nil {
+			log.Warning(fmt.Errorf("failed to restore DNS servers for link %s: %w", linkName, err))
+		}
+		if err := shell.Exec(log, binPath, domainArgs...); err != nil {
+			log.Warning(fmt.Errorf("failed to restore DNS domains for link %s: %w", linkName, err))
+		}
+		if len(state.origDefaultRoute) > 0 {
+			if err := shell.Exec(log, binPath, "default-route", linkName, state.origDefaultRoute); err != nil {
+				log.Warning(fmt.Errorf("failed to restore DNS default-route for link %s: %w", linkName, err))
+			}
+		}
+	}
+	rctl_splitDnsLinks = nil
+}
+
+// rctl_readLinkState returns current systemd-resolved configuration of the link
+func rctl_readLinkState(linkName string) (ret rctl_linkState) {
+	ret.origDns = rctl_readLinkProperty("dns", linkName)
+	ret.origDomains = rctl_readLinkProperty("domain", linkName)
+	if v := rctl_readLinkProperty("default-route", linkName); len(v) > 0 {
+		ret.origDefaultRoute = v[0]
+	}
+	return ret
+}
+
+// rctl_readLinkProperty returns values of the link property. Example of 'resolvectl domain enp0s3' output:
+//
+//	Link 2 (enp0s3): lan ~corp.example
+func rctl_readLinkProperty(property, linkName string) []string {
+	outText, _, _, _, err := shell.ExecAndGetOutput(nil, 1024*5, "", platform.ResolvectlBinPath(), property, linkName)
+	if err != nil {
+		log.Warning(fmt.Errorf("failed to read DNS '%s' for link %s: %w", property, linkName, err))
+		return nil
+	}
+	idx := strings.Index(outText, "):")
+	if idx < 0 {
+		return nil
+	}
+	return strings.Fields(outText[idx+2:])
+}
+
+// rctl_splitDnsLinksOk - returns true if the links modified by split DNS rules still have the expected routing domains
+func rctl_splitDnsLinksOk() bool {
+	rctl_splitDnsLinksMutex.Lock()
+	defer rctl_splitDnsLinksMutex.Unlock()
+
+	for linkName, state := range rctl_splitDnsLinks {
+		current := rctl_readLinkProperty("domain", linkName)
+		for _, d := range state.domains {
+			found := false
+			for _, c := range current {
+				if c == d {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // DeleteManual - reset manual DNS configuration to default
 func rctl_implDeleteManual(localInterfaceIP net.IP) error {
 	rctl_stopDnsChangeMonitor()
@@ -314,5 +509,5 @@ func rctl_configOk() (bool, error) {
 		return false, err
 	}
 
-	return regExpCurDns.MatchString(outText) && regExpDnsDomain.MatchString(outText), nil
+	return regExpCurDns.MatchString(outText) && regExpDnsDomain.MatchString(outText) && rctl_splitDnsLinksOk(), nil
 }
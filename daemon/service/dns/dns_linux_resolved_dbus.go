@@ -0,0 +1,491 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package dns
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/dbus"
+)
+
+// DNS management using systemd-resolved D-Bus API.
+// The same configuration as applied by 'resolvectl' (see dns_linux_resolvectl.go), but without spawning processes.
+// Changes of the configuration are detected by D-Bus signals (instead of monitoring resolv.conf files).
+//
+// For reference: https://www.freedesktop.org/software/systemd/man/latest/org.freedesktop.resolve1.html
+
+const (
+	rdbus_busName      = "org.freedesktop.resolve1"
+	rdbus_path         = dbus.ObjectPath("/org/freedesktop/resolve1")
+	rdbus_managerIface = "org.freedesktop.resolve1.Manager"
+	rdbus_linkIface    = "org.freedesktop.resolve1.Link"
+)
+
+var (
+	rdbus_done             chan struct{}
+	rdbus_done_mutex       sync.Mutex
+	rdbus_localInterfaceIp net.IP
+
+	// network links (non-VPN interfaces) modified to apply split DNS rules (key - link name)
+	rdbus_splitDnsLinks      map[string]rdbus_linkState
+	rdbus_splitDnsLinksMutex sync.Mutex
+)
+
+// rdbus_linkState - the systemd-resolved configuration of the network link
+type rdbus_linkState struct {
+	index            int
+	origDns          []interface{} // original DNS servers: a(iay)
+	origDomains      []interface{} // original domains: a(sb)
+	origDefaultRoute bool          // original 'DefaultRoute' value
+	domains          []string      // routing domains applied by split DNS rules
+}
+
+// rdbus_isAvailable returns error if systemd-resolved D-Bus API is not accessible
+func rdbus_isAvailable() error {
+	return bus_isServiceRunning(rdbus_busName)
+}
+
+func rdbus_implInitialize() error {
+	return rdbus_isAvailable()
+}
+
+func rdbus_implPause(localInterfaceIP net.IP) error {
+	rdbus_stopDnsChangeMonitor()
+	rdbus_restoreSplitDnsLinks()
+
+	inf, err := netinfo.InterfaceByIPAddr(localInterfaceIP)
+	if err != nil {
+		return nil // seems the interface not created. Nothing to resume
+	}
+
+	if err := rdbus_setLinkDomains(inf.Index, nil); err != nil {
+		return rdbus_error(err)
+	}
+	if err := rdbus_call("SetLinkDefaultRoute", "ib", int32(inf.Index), false); err != nil {
+		return rdbus_error(err)
+	}
+	return nil
+}
+
+func rdbus_implResume(localInterfaceIP net.IP) error {
+	inf, err := netinfo.InterfaceByIPAddr(localInterfaceIP)
+	if err != nil {
+		return rdbus_error(err)
+	}
+
+	if err := rdbus_setLinkDomains(inf.Index, []string{"~."}); err != nil {
+		return rdbus_error(err)
+	}
+	if err := rdbus_call("SetLinkDefaultRoute", "ib", int32(inf.Index), true); err != nil {
+		return rdbus_error(err)
+	}
+
+	rdbus_startDnsChangeMonitor()
+	return nil
+}
+
+// Set manual DNS.
+func rdbus_implSetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error) {
+	rdbus_stopDnsChangeMonitor() // stop monitoring
+	defer func() {
+		if retErr == nil {
+			rdbus_startDnsChangeMonitor() // if success - start monitoring
+		}
+	}()
+	rdbus_localInterfaceIp = localInterfaceIP
+	return rdbus_applySetManual(dnsCfg, localInterfaceIP)
+}
+
+func rdbus_applySetManual(dnsCfg DnsSettings, localInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error) {
+	if localInterfaceIP == nil || localInterfaceIP.IsUnspecified() {
+		log.Info("'Set DNS' call ignored due to no local address initialized")
+		return dnsCfg, nil
+	}
+	inf, err := netinfo.InterfaceByIPAddr(localInterfaceIP)
+	if err != nil {
+		return DnsSettings{}, rdbus_error(err)
+	}
+
+	if err := rdbus_setLinkDomains(inf.Index, []string{"~."}); err != nil {
+		return DnsSettings{}, rdbus_error(err)
+	}
+	if err := rdbus_call("SetLinkDefaultRoute", "ib", int32(inf.Index), true); err != nil {
+		return DnsSettings{}, rdbus_error(err)
+	}
+	if err := rdbus_setLinkDns(inf.Index, dnsCfg.Servers); err != nil {
+		return DnsSettings{}, rdbus_error(err)
+	}
+
+	if err := rdbus_applyDomainRules(dnsCfg.DomainRules, localInterfaceIP); err != nil {
+		return DnsSettings{}, rdbus_error(err)
+	}
+
+	return dnsCfg, nil
+}
+
+// DeleteManual - reset manual DNS configuration to default
+func rdbus_implDeleteManual(localInterfaceIP net.IP) error {
+	rdbus_stopDnsChangeMonitor()
+	return rdbus_implPause(localInterfaceIP)
+}
+
+// rdbus_applyDomainRules configures split DNS rules as routing domains of non-VPN links
+// (the same logic as rctl_applyDomainRules())
+func rdbus_applyDomainRules(rules []DnsDomainRule, localInterfaceIP net.IP) error {
+	rdbus_restoreSplitDnsLinks()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	rulesByLink, err := rctl_domainRulesByLink(rules, localInterfaceIP)
+	if err != nil {
+		return err
+	}
+
+	rdbus_splitDnsLinksMutex.Lock()
+	defer rdbus_splitDnsLinksMutex.Unlock()
+
+	if rdbus_splitDnsLinks == nil {
+		rdbus_splitDnsLinks = make(map[string]rdbus_linkState)
+	}
+
+	for linkName, linkRules := range rulesByLink {
+		inf, err := net.InterfaceByName(linkName)
+		if err != nil {
+			return err
+		}
+		state, err := rdbus_readLinkState(inf.Index)
+		if err != nil {
+			return err
+		}
+		for _, r := range linkRules {
+			state.domains = append(state.domains, "~"+r.Domain)
+		}
+		rdbus_splitDnsLinks[linkName] = state
+
+		var servers []DnsServerConfig
+		for _, s := range linkRules[0].Servers {
+			servers = append(servers, DnsServerConfig{Address: s})
+		}
+		if err := rdbus_setLinkDns(inf.Index, servers); err != nil {
+			return err
+		}
+		if err := rdbus_setLinkDomains(inf.Index, state.domains); err != nil {
+			return err
+		}
+		// the link must not be used for the domains not defined by the rules
+		if err := rdbus_call("SetLinkDefaultRoute", "ib", int32(inf.Index), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rdbus_restoreSplitDnsLinks restores the original configuration of the links modified by split DNS rules
+func rdbus_restoreSplitDnsLinks() {
+	rdbus_splitDnsLinksMutex.Lock()
+	defer rdbus_splitDnsLinksMutex.Unlock()
+
+	for linkName, state := range rdbus_splitDnsLinks {
+		idx := int32(state.index)
+		if err := rdbus_call("SetLinkDNS", "ia(iay)", idx, state.origDns); err != nil {
+			log.Warning(fmt.Errorf("failed to restore DNS servers for link %s: %w", linkName, err))
+		}
+		if err := rdbus_call("SetLinkDomains", "ia(sb)", idx, state.origDomains); err != nil {
+			log.Warning(fmt.Errorf("failed to restore DNS domains for link %s: %w", linkName, err))
+		}
+		if err := rdbus_call("SetLinkDefaultRoute", "ib", idx, state.origDefaultRoute); err != nil {
+			log.Warning(fmt.Errorf("failed to restore DNS default-route for link %s: %w", linkName, err))
+		}
+	}
+	rdbus_splitDnsLinks = nil
+}
+
+// rdbus_readLinkState returns current systemd-resolved configuration of the link
+func rdbus_readLinkState(linkIndex int) (ret rdbus_linkState, err error) {
+	ret.index = linkIndex
+	if ret.origDns, err = rdbus_getLinkArrayProperty(linkIndex, "DNS"); err != nil {
+		return ret, err
+	}
+	if ret.origDomains, err = rdbus_getLinkArrayProperty(linkIndex, "Domains"); err != nil {
+		return ret, err
+	}
+	v, err := rdbus_getLinkProperty(linkIndex, "DefaultRoute")
+	if err != nil {
+		return ret, err
+	}
+	ret.origDefaultRoute, _ = v.(bool)
+	return ret, nil
+}
+
+// rdbus_configOk - returns true if systemd-resolved DNS configuration is expected for VPN interface
+func rdbus_configOk() (bool, error) {
+	if rdbus_localInterfaceIp == nil || rdbus_localInterfaceIp.IsUnspecified() || manualDNS.IsEmpty() {
+		return false, fmt.Errorf("unable to check/compare OS DNS settings for the VPN interface: expected DNS configuration is not defined")
+	}
+	inf, err := netinfo.InterfaceByIPAddr(rdbus_localInterfaceIp)
+	if err != nil {
+		return false, fmt.Errorf("unable to check/compare OS DNS settings for the VPN interface: %w", err)
+	}
+
+	servers, err := rdbus_getLinkArrayProperty(inf.Index, "DNS")
+	if err != nil {
+		return false, err
+	}
+	var expectedServers []net.IP
+	for _, svr := range manualDNS.Servers {
+		if ip := svr.Ip(); ip != nil {
+			expectedServers = append(expectedServers, ip)
+		}
+	}
+	if len(servers) != len(expectedServers) {
+		return false, nil
+	}
+	for i, s := range servers {
+		if ip := rdbus_parseAddress(s); ip == nil || !ip.Equal(expectedServers[i]) {
+			return false, nil
+		}
+	}
+
+	domains, err := rdbus_getLinkArrayProperty(inf.Index, "Domains")
+	if err != nil {
+		return false, err
+	}
+	if !rdbus_hasDomains(domains, []string{"~."}) {
+		return false, nil
+	}
+
+	defaultRoute, err := rdbus_getLinkProperty(inf.Index, "DefaultRoute")
+	if err != nil {
+		return false, err
+	}
+	if isDefRoute, _ := defaultRoute.(bool); !isDefRoute {
+		return false, nil
+	}
+
+	return rdbus_splitDnsLinksOk(), nil
+}
+
+// rdbus_splitDnsLinksOk - returns true if the links modified by split DNS rules still have the expected routing domains
+func rdbus_splitDnsLinksOk() bool {
+	rdbus_splitDnsLinksMutex.Lock()
+	defer rdbus_splitDnsLinksMutex.Unlock()
+
+	for _, state := range rdbus_splitDnsLinks {
+		domains, err := rdbus_getLinkArrayProperty(state.index, "Domains")
+		if err != nil || !rdbus_hasDomains(domains, state.domains) {
+			return false
+		}
+	}
+	return true
+}
+
+func rdbus_error(err error) error {
+	return fmt.Errorf("failed to change DNS configuration (systemd-resolved D-Bus): %w", err)
+}
+
+func rdbus_call(method, signature string, args ...interface{}) error {
+	c, err := bus_systemBus()
+	if err != nil {
+		return err
+	}
+	_, err = c.Call(rdbus_busName, rdbus_path, rdbus_managerIface, method, signature, args...)
+	return err
+}
+
+// rdbus_setLinkDns sets DNS servers of the link (SetLinkDNS)
+func rdbus_setLinkDns(linkIndex int, servers []DnsServerConfig) error {
+	addrs := []interface{}{}
+	for _, svr := range servers {
+		ip := svr.Ip()
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			addrs = append(addrs, []interface{}{int32(syscall.AF_INET), []byte(ip4)})
+		} else {
+			addrs = append(addrs, []interface{}{int32(syscall.AF_INET6), []byte(ip.To16())})
+		}
+	}
+	return rdbus_call("SetLinkDNS", "ia(iay)", int32(linkIndex), addrs)
+}
+
+// rdbus_setLinkDomains sets domains of the link (SetLinkDomains).
+// The routing-only domains are prefixed by '~' (the same format as used by 'resolvectl domain')
+func rdbus_setLinkDomains(linkIndex int, domains []string) error {
+	entries := []interface{}{}
+	for _, d := range domains {
+		isRouteOnly := len(d) > 0 && d[0] == '~'
+		if isRouteOnly {
+			d = d[1:]
+		}
+		if d == "" {
+			d = "."
+		}
+		entries = append(entries, []interface{}{d, isRouteOnly})
+	}
+	return rdbus_call("SetLinkDomains", "ia(sb)", int32(linkIndex), entries)
+}
+
+func rdbus_linkPath(linkIndex int) (dbus.ObjectPath, error) {
+	c, err := bus_systemBus()
+	if err != nil {
+		return "", err
+	}
+	ret, err := c.Call(rdbus_busName, rdbus_path, rdbus_managerIface, "GetLink", "i", int32(linkIndex))
+	if err != nil {
+		return "", err
+	}
+	if len(ret) != 1 {
+		return "", fmt.Errorf("unexpected 'GetLink' reply")
+	}
+	path, ok := ret[0].(dbus.ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("unexpected 'GetLink' reply")
+	}
+	return path, nil
+}
+
+func rdbus_getLinkProperty(linkIndex int, name string) (interface{}, error) {
+	path, err := rdbus_linkPath(linkIndex)
+	if err != nil {
+		return nil, err
+	}
+	c, err := bus_systemBus()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetProperty(rdbus_busName, path, rdbus_linkIface, name)
+}
+
+func rdbus_getLinkArrayProperty(linkIndex int, name string) ([]interface{}, error) {
+	v, err := rdbus_getLinkProperty(linkIndex, name)
+	if err != nil {
+		return nil, err
+	}
+	ret, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type of link property '%s'", name)
+	}
+	return ret, nil
+}
+
+// rdbus_parseAddress converts '(iay)' value to IP address
+func rdbus_parseAddress(v interface{}) net.IP {
+	st, ok := v.([]interface{})
+	if !ok || len(st) != 2 {
+		return nil
+	}
+	if b, ok := st[1].([]byte); ok && (len(b) == net.IPv4len || len(b) == net.IPv6len) {
+		return net.IP(b)
+	}
+	return nil
+}
+
+// rdbus_hasDomains returns true if all expected domains (in 'resolvectl' format: '~' prefix for routing-only domains)
+// are defined in 'a(sb)' value
+func rdbus_hasDomains(domains []interface{}, expected []string) bool {
+	for _, e := range expected {
+		isRouteOnly := len(e) > 0 && e[0] == '~'
+		if isRouteOnly {
+			e = e[1:]
+		}
+		if e == "" {
+			e = "."
+		}
+		found := false
+		for _, d := range domains {
+			st, ok := d.([]interface{})
+			if !ok || len(st) != 2 {
+				continue
+			}
+			name, _ := st[0].(string)
+			routeOnly, _ := st[1].(bool)
+			if name == e && routeOnly == isRouteOnly {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func rdbus_stopDnsChangeMonitor() {
+	rdbus_done_mutex.Lock()
+	defer rdbus_done_mutex.Unlock()
+
+	if rdbus_done == nil {
+		return // already stopped
+	}
+	close(rdbus_done)
+	rdbus_done = nil
+}
+
+func rdbus_startDnsChangeMonitor() {
+	rdbus_done_mutex.Lock()
+	defer rdbus_done_mutex.Unlock()
+	if rdbus_done != nil {
+		close(rdbus_done) // stop previous monitoring (if any)
+	}
+	rdbus_done = make(chan struct{})
+
+	if rdbus_localInterfaceIp == nil || rdbus_localInterfaceIp.IsUnspecified() || manualDNS.IsEmpty() {
+		log.Warning("unable to start DNS-change monitoring: dns configuration is not defined")
+		return
+	}
+
+	matchRules := []string{
+		// link/manager properties changed
+		fmt.Sprintf("type='signal',sender='%s',interface='%s',member='PropertiesChanged',path_namespace='%s'", rdbus_busName, dbus.PropertiesInterface, rdbus_path),
+		// systemd-resolved restarted
+		fmt.Sprintf("type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='%s'", rdbus_busName),
+	}
+
+	bus_startSignalMonitor("systemd-resolved", matchRules, rdbus_done, func(m *dbus.Message) {
+		if isPaused {
+			return
+		}
+		isOk, err := rdbus_configOk()
+		if err != nil {
+			log.Error(fmt.Errorf("DNS-change monitoring failed to check configuration: %w", err))
+			return
+		}
+		if isOk {
+			return
+		}
+		log.Info(fmt.Sprintf("DNS-change monitoring: DNS was changed outside [%s %s.%s]. Restoring ...", m.Path, m.Interface, m.Member))
+		if _, err := rdbus_applySetManual(manualDNS, rdbus_localInterfaceIp); err != nil {
+			log.Error(rdbus_error(err))
+		}
+	})
+}
@@ -26,7 +26,10 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/resolver"
 )
 
 type DnsEncryption int
@@ -35,13 +38,18 @@ const (
 	EncryptionNone         DnsEncryption = 0
 	EncryptionDnsOverTls   DnsEncryption = 1
 	EncryptionDnsOverHttps DnsEncryption = 2
+	// DNS stamp ("sdns://SERVER" or "sdns://RELAY/SERVER"): DNSCrypt (optionally, via Anonymized DNSCrypt relay)
+	// or Oblivious DoH (via ODoH relay). DoH stamps are converted to EncryptionDnsOverHttps.
+	EncryptionDnsStamp DnsEncryption = 3
 )
 
 // DnsServerConfig represents a single DNS server configuration
 type DnsServerConfig struct {
-	Address    string        // IP address of the DNS server
-	Encryption DnsEncryption // Encryption type (None, DoH, DoT)
-	Template   string        // DoH/DoT template
+	// IP address of the DNS server
+	// (DNS stamp: the address is defined by the stamp; for ODoH - it is the IP address of the target server)
+	Address    string
+	Encryption DnsEncryption // Encryption type (None, DoH, DoT, DNS stamp)
+	Template   string        // DoH/DoT template or DNS stamp
 }
 
 func (d DnsServerConfig) Equal(x DnsServerConfig) bool {
@@ -80,6 +88,13 @@ func (d *DnsServerConfig) ValidateAndNormalize() error {
 	d.Address = strings.TrimSpace(d.Address)
 	d.Template = strings.TrimSpace(d.Template)
 
+	// DNS stamp: the server address can be defined by the stamp
+	if d.Encryption == EncryptionDnsStamp {
+		if err := d.normalizeStamp(); err != nil {
+			return err
+		}
+	}
+
 	// Allow empty configs (they represent "no DNS server")
 	if d.IsEmpty() {
 		return nil
@@ -108,6 +123,8 @@ func (d *DnsServerConfig) ValidateAndNormalize() error {
 		if len(d.Template) == 0 { // TODO: any other validation for DoT template?
 			return fmt.Errorf("template is required for DoT")
 		}
+	case EncryptionDnsStamp:
+		// already validated by normalizeStamp()
 	default:
 		return fmt.Errorf("unsupported encryption type %d", d.Encryption)
 	}
@@ -115,6 +132,51 @@ func (d *DnsServerConfig) ValidateAndNormalize() error {
 	return nil
 }
 
+// normalizeStamp validates DNS stamp and initializes the server address defined by the stamp.
+// DoH stamp is converted to the DoH configuration.
+func (d *DnsServerConfig) normalizeStamp() error {
+	relay, stamp, err := resolver.ParseStamp(d.Template)
+	if err != nil {
+		return fmt.Errorf("invalid DNS stamp: %w", err)
+	}
+
+	if stamp.IP() != nil {
+		if len(d.Address) > 0 && !stamp.IP().Equal(net.ParseIP(d.Address)) {
+			return fmt.Errorf("IP address %s does not match the DNS stamp (%s)", d.Address, stamp.IP())
+		}
+		d.Address = stamp.IP().String()
+	}
+
+	switch stamp.Proto {
+	case resolver.StampProtoTypeDoH:
+		if len(d.Address) == 0 {
+			return fmt.Errorf("IP address of DoH server %s is not defined", stamp.ProviderName)
+		}
+		host := stamp.ProviderName
+		if _, _, err := net.SplitHostPort(host); err != nil && stamp.Port() != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(stamp.Port()))
+		}
+		d.Encryption = EncryptionDnsOverHttps
+		d.Template = "https://" + host + stamp.Path
+	case resolver.StampProtoTypeDNSCrypt:
+		if relay != nil && relay.IP().Equal(stamp.IP()) {
+			return fmt.Errorf("DNSCrypt relay and server must be different")
+		}
+	case resolver.StampProtoTypeODoHTarget:
+		if relay == nil {
+			return fmt.Errorf("ODoH relay is not defined (expected 'sdns://RELAY/TARGET')")
+		}
+		if relay.IP() == nil {
+			return fmt.Errorf("IP address of ODoH relay %s is not defined by the stamp", relay.ProviderName)
+		}
+		// The target host name is never resolved: its IP address is required to request the target configuration
+		if len(d.Address) == 0 {
+			return fmt.Errorf("IP address of ODoH target %s is not defined", stamp.ProviderName)
+		}
+	}
+	return nil
+}
+
 func (d DnsServerConfig) InfoString() string {
 	if d.IsEmpty() {
 		return "<none>"
@@ -127,6 +189,8 @@ func (d DnsServerConfig) InfoString() string {
 		return host + " (DoT " + template + ")"
 	case EncryptionDnsOverHttps:
 		return host + " (DoH " + template + ")"
+	case EncryptionDnsStamp:
+		return host + " (" + resolver.StampDescription(template) + ")"
 	case EncryptionNone:
 		return host
 	default:
@@ -134,23 +198,105 @@ func (d DnsServerConfig) InfoString() string {
 	}
 }
 
+// DnsDomainRule represents the split DNS rule (conditional forwarding):
+// the queries for the domain (and all its subdomains) are sent only to the rule's DNS servers
+type DnsDomainRule struct {
+	Domain  string   // domain name (e.g. "corp.example"; the "*.corp.example" form is also accepted)
+	Servers []string // IP addresses of plain DNS servers in order of preference
+}
+
+func (r DnsDomainRule) Equal(x DnsDomainRule) bool {
+	if r.Domain != x.Domain || len(r.Servers) != len(x.Servers) {
+		return false
+	}
+	for i := range r.Servers {
+		if r.Servers[i] != x.Servers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ServersIPs returns IP addresses of the rule's DNS servers (invalid addresses are skipped)
+func (r DnsDomainRule) ServersIPs() []net.IP {
+	ips := make([]net.IP, 0, len(r.Servers))
+	for _, svr := range r.Servers {
+		if ip := net.ParseIP(svr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func (r *DnsDomainRule) ValidateAndNormalize() error {
+	domain := strings.ToLower(strings.TrimSpace(r.Domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.Trim(domain, ".")
+	if len(domain) == 0 {
+		return fmt.Errorf("domain name is empty")
+	}
+	if len(domain) > 253 {
+		return fmt.Errorf("domain name is too long: %s", domain)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("invalid domain name: %s", r.Domain)
+		}
+		for _, c := range label {
+			if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_') {
+				return fmt.Errorf("invalid domain name: %s", r.Domain)
+			}
+		}
+	}
+	r.Domain = domain
+
+	if len(r.Servers) == 0 {
+		return fmt.Errorf("no DNS servers defined for domain %s", domain)
+	}
+	for i, svr := range r.Servers {
+		ip := net.ParseIP(strings.TrimSpace(svr))
+		if ip == nil || ip.IsUnspecified() {
+			return fmt.Errorf("invalid DNS server IP address for domain %s: %s", domain, svr)
+		}
+		r.Servers[i] = ip.String()
+	}
+	return nil
+}
+
+func (r DnsDomainRule) InfoString() string {
+	return r.Domain + " -> " + strings.Join(r.Servers, "/")
+}
+
 // DnsSettings represents the DNS configuration
 // It can include multiple DNS servers with different encryption methods
 type DnsSettings struct {
 	// List of DNS servers specified in order of preference
 	Servers []DnsServerConfig
+	// Split DNS rules: queries for specific domains are sent to specific DNS servers
+	// (all other queries are sent to 'Servers')
+	DomainRules []DnsDomainRule
 	// Internal metadata about the DNS configuration
 	metadata DnsMetadata
 }
 
 type DnsMetadata struct {
 	IsInternalDnsConfig bool // FALSE if DNS settings are custom (defined by user)
+	// Plain DNS server used by the local resolver when all 'Servers' are unreachable
+	// (e.g. the default DNS of the VPN connection; nil - no fallback)
+	FallbackDns net.IP
 }
 
 func (d DnsSettings) Metadata() DnsMetadata {
 	return d.metadata
 }
 
+// WithFallback returns a copy of DnsSettings with defined fallback DNS server
+// (it is in use only when DNS queries are processed by the local resolver)
+func (d DnsSettings) WithFallback(ip net.IP) DnsSettings {
+	d.metadata.FallbackDns = ip
+	return d
+}
+
 // Create DnsSettings object with no encryption single DNS server
 func DnsSettingsCreate(ip net.IP) DnsSettings {
 	if ip == nil {
@@ -171,6 +317,14 @@ func (d DnsSettings) Equal(x DnsSettings) bool {
 			return false
 		}
 	}
+	if len(d.DomainRules) != len(x.DomainRules) {
+		return false
+	}
+	for i := range d.DomainRules {
+		if !d.DomainRules[i].Equal(x.DomainRules[i]) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -186,7 +340,7 @@ func (d DnsSettings) IsEmpty() bool {
 	return true
 }
 
-// UseEncryption - returns TRUE if at least one DNS server uses encryption (DoH or DoT)
+// UseEncryption - returns TRUE if at least one DNS server uses encryption (DoH, DoT or DNS stamp)
 func (d DnsSettings) UseEncryption() bool {
 	for _, srv := range d.Servers {
 		if srv.Encryption != EncryptionNone && !srv.IsEmpty() {
@@ -210,29 +364,95 @@ func (d DnsSettings) GetUnencryptedServersAddresses() []net.IP {
 	return ips
 }
 
+// GetStampsServersAddresses - returns list of IP addresses of the hosts which are accessed directly for DNS servers defined by DNS stamps:
+// DNSCrypt servers and relays (Anonymized DNSCrypt relays, ODoH relays).
+// DNSCrypt servers and relays are often listening on DNS port (53), so they must be allowed by firewall.
+func (d DnsSettings) GetStampsServersAddresses() []net.IP {
+	var ips []net.IP
+	for _, srv := range d.Servers {
+		if srv.Encryption != EncryptionDnsStamp || srv.IsEmpty() {
+			continue
+		}
+		relay, stamp, err := resolver.ParseStamp(srv.Template)
+		if err != nil {
+			continue
+		}
+		if ip := srv.Ip(); ip != nil && stamp.Proto == resolver.StampProtoTypeDNSCrypt {
+			ips = append(ips, ip)
+		}
+		if relay != nil && relay.IP() != nil {
+			ips = append(ips, relay.IP())
+		}
+	}
+	return ips
+}
+
+// GetDomainRulesServersAddresses - returns list of IP addresses of DNS servers used by split DNS rules
+func (d DnsSettings) GetDomainRulesServersAddresses() []net.IP {
+	var ips []net.IP
+	for _, rule := range d.DomainRules {
+	nextIp:
+		for _, ip := range rule.ServersIPs() {
+			for _, existing := range ips {
+				if existing.Equal(ip) {
+					continue nextIp
+				}
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
 func (d DnsSettings) InfoString() string {
-	if d.IsEmpty() {
+	if d.IsEmpty() && len(d.DomainRules) == 0 {
 		return "<none>"
 	}
 	var sb strings.Builder
-	for i, srv := range d.Servers {
-		if i > 0 {
-			sb.WriteString(", ")
+	if d.IsEmpty() {
+		sb.WriteString("<default>") // only split DNS rules defined
+	} else {
+		for i, srv := range d.Servers {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(srv.InfoString())
 		}
-		sb.WriteString(srv.InfoString())
+	}
+	if len(d.DomainRules) > 0 {
+		sb.WriteString(" [split DNS: ")
+		for i, rule := range d.DomainRules {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(rule.InfoString())
+		}
+		sb.WriteString("]")
 	}
 	return sb.String()
 }
 
 func (d DnsSettings) ValidateAndNormalize() error {
-	if d.IsEmpty() {
-		return nil
+	if !d.IsEmpty() {
+		for i := range d.Servers {
+			if err := d.Servers[i].ValidateAndNormalize(); err != nil {
+				return fmt.Errorf("DNS server %d: %w", i+1, err)
+			}
+		}
 	}
 
-	for i, srv := range d.Servers {
-		if err := srv.ValidateAndNormalize(); err != nil {
-			return fmt.Errorf("DNS server %d: %w", i+1, err)
+	// Note: split DNS rules can be defined without DNS servers.
+	// In this case, all other queries are sent to the default DNS (or AntiTracker) of the VPN connection.
+
+	domains := make(map[string]struct{}, len(d.DomainRules))
+	for i := range d.DomainRules {
+		if err := d.DomainRules[i].ValidateAndNormalize(); err != nil {
+			return fmt.Errorf("split DNS rule %d: %w", i+1, err)
+		}
+		if _, ok := domains[d.DomainRules[i].Domain]; ok {
+			return fmt.Errorf("split DNS rule %d: duplicate domain %s", i+1, d.DomainRules[i].Domain)
 		}
+		domains[d.DomainRules[i].Domain] = struct{}{}
 	}
 	return nil
 }
@@ -0,0 +1,135 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dns
+
+import (
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns/resolver"
+)
+
+var (
+	// statistics are collected by the local resolver; the collector is shared by all resolver instances
+	// (the resolver is restarted on each DNS configuration change)
+	localStats = resolver.NewStatsCollector()
+
+	statsMutex  sync.Mutex
+	statsForced bool // true - the local resolver is always in use (to collect statistics for all DNS queries)
+)
+
+// DnsStatsDomain - the number of blocked queries for the domain
+type DnsStatsDomain struct {
+	Domain string
+	Count  uint64
+}
+
+// DnsStatsUpstream - statistics of the DNS server used by the local resolver
+type DnsStatsUpstream struct {
+	Server       string
+	Queries      uint64
+	Failures     uint64
+	AvgLatencyMs float64
+	MaxLatencyMs float64
+}
+
+// DnsStats - DNS query statistics of the current connection session
+type DnsStats struct {
+	// true - DNS queries are processed by the local resolver (the statistics are being collected)
+	IsCollecting bool
+	// true - the local resolver is forced to be in use for all DNS configurations (see SetStatsCollection)
+	IsForced bool
+	Since    int64 // unix time of the statistics start
+
+	Queries         uint64 // total number of queries
+	CacheHits       uint64 // answered from the local cache
+	BlockedLocal    uint64 // blocked by the local DNS filter (user-defined block lists)
+	BlockedUpstream uint64 // blocked by DNS server (e.g. AntiTracker): answered by 0.0.0.0 or ::
+	NxDomain        uint64 // NXDOMAIN answers received from DNS servers
+	Failed          uint64 // not resolved queries (all DNS servers failed)
+
+	TopBlocked []DnsStatsDomain
+	Upstreams  []DnsStatsUpstream
+}
+
+// SetStatsCollection enables/disables forced statistics collection.
+// When enabled - all DNS queries are processed by the local resolver (even if it is not required by the DNS configuration).
+// Otherwise, the statistics are collected only when the local resolver is in use (encrypted DNS, split DNS, local DNS filter ...).
+// Note: the DNS configuration must be re-applied to start/stop the local resolver.
+func SetStatsCollection(forced bool) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	statsForced = forced
+}
+
+// IsStatsCollectionForced returns true if the local resolver is forced to be in use to collect DNS statistics
+func IsStatsCollectionForced() bool {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	return statsForced
+}
+
+// StatsReset erases the collected DNS statistics (e.g. on new connection session)
+func StatsReset() {
+	localStats.Reset()
+}
+
+// GetStats returns the DNS statistics collected since the last StatsReset() call
+// 'topBlockedCount' - max number of the most blocked domains to return
+func GetStats(topBlockedCount int) DnsStats {
+	st := localStats.Get(topBlockedCount)
+
+	localResolverMutex.Lock()
+	isCollecting := localResolver != nil
+	localResolverMutex.Unlock()
+
+	ret := DnsStats{
+		IsCollecting:    isCollecting,
+		IsForced:        IsStatsCollectionForced(),
+		Since:           st.Since.Unix(),
+		Queries:         st.Queries,
+		CacheHits:       st.CacheHits,
+		BlockedLocal:    st.BlockedLocal,
+		BlockedUpstream: st.BlockedUpstream,
+		NxDomain:        st.NxDomain,
+		Failed:          st.Failed,
+	}
+	for _, d := range st.TopBlocked {
+		ret.TopBlocked = append(ret.TopBlocked, DnsStatsDomain{Domain: d.Domain, Count: d.Count})
+	}
+	for _, u := range st.Upstreams {
+		ret.Upstreams = append(ret.Upstreams, DnsStatsUpstream{
+			Server:       u.Upstream,
+			Queries:      u.Queries,
+			Failures:     u.Failures,
+			AvgLatencyMs: float64(u.AvgLatency.Microseconds()) / 1000,
+			MaxLatencyMs: float64(u.MaxLatency.Microseconds()) / 1000,
+		})
+	}
+	return ret
+}
+
+// isLocalResolverForced returns true if the local resolver must be used independently of the DNS configuration:
+// to apply the local filtering rules, to collect DNS statistics or to notify about DNS answers (see SetAnswersNotifier)
+func isLocalResolverForced() bool {
+	return IsFilterEnabled() || IsStatsCollectionForced() || isAnswersNotifierSet()
+}
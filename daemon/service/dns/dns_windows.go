@@ -87,6 +87,8 @@ func fSetDNSByLocalIP(interfaceLocalAddr net.IP, dnsCfg DnsServerConfig, op Oper
 	case EncryptionDnsOverHttps:
 		isDoH = 1
 		dohTemplateUrl = dnsCfg.Template
+	case EncryptionDnsStamp:
+		return fmt.Errorf("DNS stamps are not supported by Windows natively")
 	default:
 		isDoH = 0
 	}
@@ -183,7 +185,17 @@ func implResume(defaultDNS DnsSettings, localInterfaceIP net.IP) error {
 func implGetDnsEncryptionAbilities() (dnsOverHttps, dnsOverTls bool, err error) {
 	defer catchPanic(&err)
 
-	return true, false, err
+	return true, true, err
+}
+
+// isUseNonNativeEncryption returns true if DNS encryption is not supported by Windows natively (DoT, DNS stamp)
+func isUseNonNativeEncryption(dnsCfg DnsSettings) bool {
+	for _, srv := range dnsCfg.Servers {
+		if (srv.Encryption == EncryptionDnsOverTls || srv.Encryption == EncryptionDnsStamp) && !srv.IsEmpty() {
+			return true
+		}
+	}
+	return false
 }
 
 func implSetManual(dnsCfg DnsSettings, vpnInterfaceIP net.IP) (dnsInfoForFirewall DnsSettings, retErr error) {
@@ -191,13 +203,13 @@ func implSetManual(dnsCfg DnsSettings, vpnInterfaceIP net.IP) (dnsInfoForFirewal
 	defer func() {
 		if retErr != nil {
 			if err := ResolversTeardown(); err != nil {
-				log.Error("failed to stop dnscrypt-proxy: ", err)
+				log.Error("failed to stop local DNS resolver: ", err)
 			}
 		}
 	}()
 
 	if err := ResolversTeardown(); err != nil {
-		log.Error("failed to stop dnscrypt-proxy: ", err)
+		log.Error("failed to stop local DNS resolver: ", err)
 	}
 
 	// If there was defined DNS - remove it from non-VPN interfaces (if necessary)
@@ -212,13 +224,17 @@ func implSetManual(dnsCfg DnsSettings, vpnInterfaceIP net.IP) (dnsInfoForFirewal
 		return DnsSettings{}, fmt.Errorf("unable to change DNS (configuration is not defined)")
 	}
 
-	// If system does not support encrypted DNS natively - start dnscrypt-proxy for encrypted DNS
-	if dnsCfg.UseEncryption() && !fIsCanUseNativeDnsOverHttps() {
+	// If system does not support encrypted DNS natively - start local DNS resolver for encrypted DNS
+	// (native implementation supports only DoH; DoT, DNSCrypt and ODoH are processed by the local resolver).
+	// The local DNS resolver is also required for split DNS rules, local filtering rules (block lists, hosts ...) and DNS statistics.
+	if (dnsCfg.UseEncryption() && (!fIsCanUseNativeDnsOverHttps() || isUseNonNativeEncryption(dnsCfg))) || len(dnsCfg.DomainRules) > 0 || isLocalResolverForced() {
 		confs, err := ResolversSetup(dnsCfg)
 		if err != nil {
 			return DnsSettings{}, err
 		}
-		// the local DNS must be configured to the dnscrypt-proxy (localhost)
+		// the firewall must allow plain DNS servers used by the local resolver
+		dnsInfoForFirewall = resolversFirewallInfo(confs, dnsCfg)
+		// the local DNS must be configured to the local resolver (localhost)
 		dnsCfg = DnsSettings{Servers: confs}
 	}
 
@@ -281,14 +297,17 @@ func implSetManual(dnsCfg DnsSettings, vpnInterfaceIP net.IP) (dnsInfoForFirewal
 	// save last changed DNS address
 	_lastDNS = dnsCfg
 
-	return _lastDNS, retErr
+	if dnsInfoForFirewall.IsEmpty() {
+		dnsInfoForFirewall = _lastDNS
+	}
+	return dnsInfoForFirewall, retErr
 }
 
 func implDeleteManual(vpnInterfaceIP net.IP) (retErr error) {
 	defer catchPanic(&retErr)
 
 	if err := ResolversTeardown(); err != nil {
-		log.Error("failed to stop dnscrypt-proxy: ", err)
+		log.Error("failed to stop local DNS resolver: ", err)
 	}
 
 	if _lastDNS.IsEmpty() {
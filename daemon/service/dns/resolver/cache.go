@@ -0,0 +1,183 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	cacheMaxTTL      = 3600 // seconds
+	cacheNegativeTTL = 60   // seconds (used for negative answers without SOA record)
+)
+
+type cacheKey struct {
+	name  string
+	qtype dnsmessage.Type
+	class dnsmessage.Class
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	msg      dnsmessage.Message
+	storedAt time.Time
+	expireAt time.Time
+}
+
+// cache - LRU cache of DNS answers
+type cache struct {
+	mutex   sync.Mutex
+	maxSize int
+	entries map[cacheKey]*list.Element
+	lru     *list.List // front - the most recently used
+}
+
+func newCache(maxSize int) *cache {
+	return &cache{maxSize: maxSize, entries: make(map[cacheKey]*list.Element), lru: list.New()}
+}
+
+func newCacheKey(q dnsmessage.Question) cacheKey {
+	return cacheKey{name: strings.ToLower(q.Name.String()), qtype: q.Type, class: q.Class}
+}
+
+// get returns cached response (packed, with TTLs decreased by the time elapsed since caching) or nil
+func (c *cache) get(q dnsmessage.Question, id uint16) []byte {
+	key := newCacheKey(q)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	now := time.Now()
+	if !now.Before(e.expireAt) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+
+	elapsed := uint32(now.Sub(e.storedAt) / time.Second)
+	msg := e.msg
+	msg.ID = id
+	msg.Questions = []dnsmessage.Question{q} // keep the original case of the name
+	msg.Answers = decreaseTTL(msg.Answers, elapsed)
+	msg.Authorities = decreaseTTL(msg.Authorities, elapsed)
+	msg.Additionals = decreaseTTL(msg.Additionals, elapsed)
+
+	ret, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return ret
+}
+
+// put saves the response to the cache (if it is cacheable)
+func (c *cache) put(q dnsmessage.Question, resp []byte) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		return
+	}
+	if msg.Truncated || (msg.RCode != dnsmessage.RCodeSuccess && msg.RCode != dnsmessage.RCodeNameError) {
+		return
+	}
+
+	ttl := responseTTL(msg)
+	if ttl == 0 {
+		return
+	}
+
+	now := time.Now()
+	key := newCacheKey(q)
+	entry := &cacheEntry{key: key, msg: msg, storedAt: now, expireAt: now.Add(time.Duration(ttl) * time.Second)}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// responseTTL returns the time (seconds) the response can be cached:
+// the minimal TTL of answers, or SOA-based TTL for negative answers (RFC 2308)
+func responseTTL(msg dnsmessage.Message) uint32 {
+	var ttl uint32 = cacheMaxTTL
+
+	if len(msg.Answers) > 0 && msg.RCode == dnsmessage.RCodeSuccess {
+		for _, rr := range msg.Answers {
+			if rr.Header.TTL < ttl {
+				ttl = rr.Header.TTL
+			}
+		}
+		return ttl
+	}
+
+	// negative answer (NXDOMAIN or NODATA)
+	for _, rr := range msg.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			ttl = rr.Header.TTL
+			if soa.MinTTL < ttl {
+				ttl = soa.MinTTL
+			}
+			return min(ttl, cacheMaxTTL)
+		}
+	}
+	return cacheNegativeTTL
+}
+
+func decreaseTTL(records []dnsmessage.Resource, elapsed uint32) []dnsmessage.Resource {
+	if len(records) == 0 {
+		return records
+	}
+	ret := make([]dnsmessage.Resource, len(records))
+	copy(ret, records)
+	for i := range ret {
+		if ret[i].Header.Type == dnsmessage.TypeOPT {
+			continue // TTL field of OPT record contains extended RCODE and flags
+		}
+		if ret[i].Header.TTL > elapsed {
+			ret[i].Header.TTL -= elapsed
+		} else {
+			ret[i].Header.TTL = 0
+		}
+	}
+	return ret
+}
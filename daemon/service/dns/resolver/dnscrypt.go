@@ -0,0 +1,430 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/poly1305"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSCrypt v2 protocol client (https://dnscrypt.info/protocol)
+// with Anonymized DNSCrypt relays support (https://github.com/DNSCrypt/dnscrypt-protocol/blob/master/ANONYMIZED-DNSCRYPT.txt)
+
+const (
+	dnscryptCertMagic                  = "DNSC"
+	dnscryptResolverMagic              = "r6fnvWj8"
+	dnscryptEsVersionXSalsa20Poly1305  = 0x0001
+	dnscryptEsVersionXChaCha20Poly1305 = 0x0002
+	dnscryptCertMinSize                = 124
+	dnscryptClientNonceSize            = 12
+	dnscryptNonceSize                  = 24
+	dnscryptMinUdpQuerySize            = 256
+	dnscryptPaddingBlockSize           = 64
+	dnscryptCertRefreshInterval        = time.Hour
+)
+
+// header of the packet sent to Anonymized DNSCrypt relay (followed by the server IPv6 address and port)
+var dnscryptRelayMagic = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00}
+
+type dnscryptCert struct {
+	esVersion   uint16 // encryption system: XSalsa20-Poly1305 or XChaCha20-Poly1305
+	serial      uint32
+	clientMagic []byte
+	sharedKey   [32]byte
+	notAfter    time.Time
+}
+
+type dnscryptClient struct {
+	serverIP     net.IP
+	serverPort   int
+	providerName string
+	providerPk   ed25519.PublicKey
+	relayAddr    string // "IP:port" of Anonymized DNSCrypt relay ("" - if relay not in use)
+
+	publicKey *[32]byte
+	secretKey *[32]byte
+
+	mutex         sync.Mutex
+	cert          *dnscryptCert
+	certFetchedAt time.Time
+}
+
+func newDnscryptClient(ip net.IP, stamp ServerStamp, relay *ServerStamp) (*dnscryptClient, error) {
+	if stamp.Proto != StampProtoTypeDNSCrypt {
+		return nil, fmt.Errorf("not a DNSCrypt stamp (%s)", stamp.Proto.String())
+	}
+	pk, sk, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	c := &dnscryptClient{
+		serverIP:     ip,
+		serverPort:   stamp.Port(),
+		providerName: dnsmessageName(stamp.ProviderName),
+		providerPk:   ed25519.PublicKey(stamp.ServerPk),
+		publicKey:    pk,
+		secretKey:    sk,
+	}
+	if relay != nil {
+		if relay.Proto != StampProtoTypeDNSCryptRelay {
+			return nil, fmt.Errorf("%s is not applicable for DNSCrypt server", relay.Proto.String())
+		}
+		c.relayAddr = relay.ServerAddress()
+	}
+	return c, nil
+}
+
+func dnsmessageName(name string) string {
+	if len(name) > 0 && name[len(name)-1] != '.' {
+		return name + "."
+	}
+	return name
+}
+
+func (c *dnscryptClient) resetCert() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cert = nil
+}
+
+func (c *dnscryptClient) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	cert, err := c.getCert(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNSCrypt certificate: %w", err)
+	}
+
+	resp, err := c.exchangeEncrypted(ctx, "udp", cert, query)
+	if err == nil {
+		var p dnsmessage.Parser
+		if hdr, e := p.Start(resp); e == nil && hdr.Truncated {
+			resp, err = c.exchangeEncrypted(ctx, "tcp", cert, query)
+		}
+	}
+	if err != nil {
+		// the resolver key could be changed: request new certificate on next query
+		c.resetCert()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *dnscryptClient) exchangeEncrypted(ctx context.Context, network string, cert *dnscryptCert, query []byte) ([]byte, error) {
+	encrypted, clientNonce, err := c.encrypt(cert, query, network == "udp")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, network, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return c.decrypt(cert, clientNonce, resp)
+}
+
+// encrypt creates DNSCrypt query: <client-magic> <client-pk> <client-nonce> <encrypted-query>
+func (c *dnscryptClient) encrypt(cert *dnscryptCert, query []byte, isUdp bool) (encrypted []byte, clientNonce []byte, err error) {
+	// ISO/IEC 7816-4 padding to the multiple of 64 bytes (at least 256 bytes for UDP)
+	paddedLen := (len(query) + 1 + dnscryptPaddingBlockSize - 1) &^ (dnscryptPaddingBlockSize - 1)
+	if isUdp && paddedLen < dnscryptMinUdpQuerySize {
+		paddedLen = dnscryptMinUdpQuerySize
+	}
+	padded := make([]byte, paddedLen)
+	copy(padded, query)
+	padded[len(query)] = 0x80
+
+	var nonce [dnscryptNonceSize]byte
+	if _, err := rand.Read(nonce[:dnscryptClientNonceSize]); err != nil {
+		return nil, nil, err
+	}
+
+	encrypted = make([]byte, 0, len(cert.clientMagic)+32+dnscryptClientNonceSize+box.Overhead+paddedLen)
+	encrypted = append(encrypted, cert.clientMagic...)
+	encrypted = append(encrypted, c.publicKey[:]...)
+	encrypted = append(encrypted, nonce[:dnscryptClientNonceSize]...)
+	encrypted = cert.seal(encrypted, padded, &nonce)
+	return encrypted, nonce[:dnscryptClientNonceSize], nil
+}
+
+// decrypt decrypts DNSCrypt response: <resolver-magic> <nonce> <encrypted-response>
+func (c *dnscryptClient) decrypt(cert *dnscryptCert, clientNonce []byte, resp []byte) ([]byte, error) {
+	headerLen := len(dnscryptResolverMagic) + dnscryptNonceSize
+	if len(resp) < headerLen+box.Overhead || string(resp[:len(dnscryptResolverMagic)]) != dnscryptResolverMagic {
+		return nil, errors.New("bad DNSCrypt response")
+	}
+	var nonce [dnscryptNonceSize]byte
+	copy(nonce[:], resp[len(dnscryptResolverMagic):headerLen])
+	if !bytes.Equal(nonce[:dnscryptClientNonceSize], clientNonce) {
+		return nil, errors.New("bad DNSCrypt response (unexpected nonce)")
+	}
+
+	padded, ok := cert.open(nil, resp[headerLen:], &nonce)
+	if !ok {
+		return nil, errors.New("bad DNSCrypt response (decryption failed)")
+	}
+	idx := bytes.LastIndexByte(padded, 0x80)
+	if idx < 0 || len(bytes.Trim(padded[idx+1:], "\x00")) > 0 {
+		return nil, errors.New("bad DNSCrypt response (invalid padding)")
+	}
+	return padded[:idx], nil
+}
+
+// send sends the packet to the server (or via relay, if defined) and returns the response
+func (c *dnscryptClient) send(ctx context.Context, network string, packet []byte) ([]byte, error) {
+	addr := hostPort(c.serverIP, c.serverPort)
+	if len(c.relayAddr) > 0 {
+		// <anonymized-dnscrypt-magic> <server-ip (IPv6 or IPv4-mapped)> <server-port (big endian)> <packet>
+		hdr := make([]byte, 0, len(dnscryptRelayMagic)+18+len(packet))
+		hdr = append(hdr, dnscryptRelayMagic...)
+		hdr = append(hdr, c.serverIP.To16()...)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(c.serverPort))
+		packet = append(hdr, packet...)
+		addr = c.relayAddr
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setDeadline(ctx, conn)
+
+	if network == "tcp" {
+		if err := writeTcpMessage(conn, packet); err != nil {
+			return nil, err
+		}
+		return readTcpMessage(conn)
+	}
+
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// getCert returns the current resolver certificate (the certificate is requested from the server if necessary)
+func (c *dnscryptClient) getCert(ctx context.Context) (*dnscryptCert, error) {
+	c.mutex.Lock()
+	cert := c.cert
+	if cert != nil && (time.Since(c.certFetchedAt) > dnscryptCertRefreshInterval || time.Now().After(cert.notAfter)) {
+		cert = nil
+	}
+	c.mutex.Unlock()
+	if cert != nil {
+		return cert, nil
+	}
+
+	cert, err := c.fetchCert(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cert = cert
+	c.certFetchedAt = time.Now()
+	return cert, nil
+}
+
+// fetchCert requests the resolver certificates (TXT records of the provider name) and returns the valid one with the highest serial
+func (c *dnscryptClient) fetchCert(ctx context.Context) (*dnscryptCert, error) {
+	name, err := dnsmessage.NewName(c.providerName)
+	if err != nil {
+		return nil, fmt.Errorf("bad provider name '%s': %w", c.providerName, err)
+	}
+	var id [2]byte
+	rand.Read(id[:])
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, "udp", packet)
+	if err == nil {
+		var p dnsmessage.Parser
+		if hdr, e := p.Start(resp); e == nil && hdr.Truncated {
+			resp, err = c.send(ctx, "tcp", packet)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		return nil, fmt.Errorf("bad certificate response: %w", err)
+	}
+	if msg.ID != query.ID || !msg.Response {
+		return nil, errors.New("bad certificate response: unexpected message")
+	}
+
+	var best *dnscryptCert
+	var lastErr error = errors.New("no certificates received")
+	for _, a := range msg.Answers {
+		txt, ok := a.Body.(*dnsmessage.TXTResource)
+		if !ok {
+			continue
+		}
+		cert, err := c.parseCert([]byte(strings.Join(txt.TXT, "")))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == nil || cert.serial > best.serial {
+			best = cert
+		}
+	}
+	if best == nil {
+		return nil, lastErr
+	}
+	return best, nil
+}
+
+// parseCert verifies and parses the resolver certificate:
+// <cert-magic> <es-version> <protocol-minor-version> <signature> <resolver-pk> <client-magic> <serial> <ts-start> <ts-end> <extensions>
+func (c *dnscryptClient) parseCert(bin []byte) (*dnscryptCert, error) {
+	if len(bin) < dnscryptCertMinSize || string(bin[:4]) != dnscryptCertMagic {
+		return nil, errors.New("bad certificate")
+	}
+	esVersion := binary.BigEndian.Uint16(bin[4:6])
+	if esVersion != dnscryptEsVersionXSalsa20Poly1305 && esVersion != dnscryptEsVersionXChaCha20Poly1305 {
+		return nil, fmt.Errorf("unsupported certificate encryption system (0x%04x)", esVersion)
+	}
+	if !ed25519.Verify(c.providerPk, bin[72:], bin[8:72]) {
+		return nil, errors.New("bad certificate signature")
+	}
+
+	tsStart := time.Unix(int64(binary.BigEndian.Uint32(bin[116:120])), 0)
+	tsEnd := time.Unix(int64(binary.BigEndian.Uint32(bin[120:124])), 0)
+	if now := time.Now(); now.Before(tsStart) || now.After(tsEnd) {
+		return nil, fmt.Errorf("certificate is not valid at this time (valid from %s to %s)", tsStart, tsEnd)
+	}
+
+	cert := &dnscryptCert{
+		esVersion:   esVersion,
+		serial:      binary.BigEndian.Uint32(bin[112:116]),
+		clientMagic: append([]byte(nil), bin[104:112]...),
+		notAfter:    tsEnd,
+	}
+	var resolverPk [32]byte
+	copy(resolverPk[:], bin[72:104])
+	if esVersion == dnscryptEsVersionXChaCha20Poly1305 {
+		// HChaCha20(X25519(client-sk, resolver-pk)) - the same as libsodium 'crypto_box_curve25519xchacha20poly1305_beforenm'
+		dh, err := curve25519.X25519(c.secretKey[:], resolverPk[:])
+		if err != nil {
+			return nil, fmt.Errorf("bad resolver public key: %w", err)
+		}
+		key, err := chacha20.HChaCha20(dh, make([]byte, 16))
+		if err != nil {
+			return nil, err
+		}
+		copy(cert.sharedKey[:], key)
+	} else {
+		box.Precompute(&cert.sharedKey, &resolverPk, c.secretKey)
+	}
+	return cert, nil
+}
+
+// seal encrypts and authenticates the message according to the encryption system of the certificate
+func (cert *dnscryptCert) seal(out, message []byte, nonce *[dnscryptNonceSize]byte) []byte {
+	if cert.esVersion == dnscryptEsVersionXChaCha20Poly1305 {
+		return xchachaSecretboxSeal(out, message, nonce, &cert.sharedKey)
+	}
+	return box.SealAfterPrecomputation(out, message, nonce, &cert.sharedKey)
+}
+
+// open authenticates and decrypts the message according to the encryption system of the certificate
+func (cert *dnscryptCert) open(out, sealed []byte, nonce *[dnscryptNonceSize]byte) ([]byte, bool) {
+	if cert.esVersion == dnscryptEsVersionXChaCha20Poly1305 {
+		return xchachaSecretboxOpen(out, sealed, nonce, &cert.sharedKey)
+	}
+	return box.OpenAfterPrecomputation(out, sealed, nonce, &cert.sharedKey)
+}
+
+// xchachaSecretboxSeal is the NaCl 'secretbox' construction with XChaCha20 instead of XSalsa20
+// (libsodium 'crypto_secretbox_xchacha20poly1305'): <poly1305-tag> <encrypted-message>.
+// The first 32 bytes of the keystream are the Poly1305 key; the message is encrypted by the rest of the keystream.
+func xchachaSecretboxSeal(out, message []byte, nonce *[dnscryptNonceSize]byte, key *[32]byte) []byte {
+	cipher, polyKey := xchachaSecretboxInit(nonce, key)
+
+	ret := append(out, make([]byte, poly1305.TagSize+len(message))...)
+	sealed := ret[len(out):]
+	encrypted := sealed[poly1305.TagSize:]
+	cipher.XORKeyStream(encrypted, message)
+
+	var tag [poly1305.TagSize]byte
+	poly1305.Sum(&tag, encrypted, &polyKey)
+	copy(sealed, tag[:])
+	return ret
+}
+
+// xchachaSecretboxOpen opens the message sealed by xchachaSecretboxSeal()
+func xchachaSecretboxOpen(out, sealed []byte, nonce *[dnscryptNonceSize]byte, key *[32]byte) ([]byte, bool) {
+	if len(sealed) < poly1305.TagSize {
+		return nil, false
+	}
+	cipher, polyKey := xchachaSecretboxInit(nonce, key)
+
+	var tag [poly1305.TagSize]byte
+	copy(tag[:], sealed[:poly1305.TagSize])
+	encrypted := sealed[poly1305.TagSize:]
+	if !poly1305.Verify(&tag, encrypted, &polyKey) {
+		return nil, false
+	}
+
+	ret := append(out, make([]byte, len(encrypted))...)
+	cipher.XORKeyStream(ret[len(out):], encrypted)
+	return ret, true
+}
+
+// xchachaSecretboxInit returns the XChaCha20 cipher (the first 32 bytes of the keystream are already consumed) and the Poly1305 key
+func xchachaSecretboxInit(nonce *[dnscryptNonceSize]byte, key *[32]byte) (*chacha20.Cipher, [32]byte) {
+	// never fails: the key and nonce sizes are correct (24 bytes nonce - XChaCha20)
+	cipher, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	var polyKey [32]byte
+	cipher.XORKeyStream(polyKey[:], polyKey[:])
+	return cipher, polyKey
+}
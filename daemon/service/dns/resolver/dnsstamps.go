@@ -1,4 +1,4 @@
-package dnscryptproxy
+package resolver
 
 // Original source:
 // https://github.com/jedisct1/go-dnsstamps/blob/master/dnsstamps.go
@@ -583,4 +583,4 @@ func (stamp *ServerStamp) oDohRelayString() string {
 	str := base64.RawURLEncoding.EncodeToString(bin)
 
 	return "sdns://" + str
-}
\ No newline at end of file
+}
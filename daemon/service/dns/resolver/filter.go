@@ -0,0 +1,171 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TTL of the answers created by the filter (blocked domains and static hosts entries)
+const filterAnswerTTL = 60
+
+// Filter - local DNS filtering rules: block lists, allowlist and static hosts entries.
+// The filter is applied by the resolver before forwarding the query to upstream servers.
+//
+// Priority of the rules:
+//  1. static hosts entries (exact name match)
+//  2. allowlist (the domain and all its subdomains are never blocked)
+//  3. block list (the domain and all its subdomains are blocked: NXDOMAIN response)
+type Filter struct {
+	blocked map[string]struct{}
+	allowed map[string]struct{}
+	hosts   map[string][]net.IP
+}
+
+// NewFilter creates new filter object
+func NewFilter(blocked, allowed []string, hosts map[string][]net.IP) *Filter {
+	f := &Filter{
+		blocked: make(map[string]struct{}, len(blocked)),
+		allowed: make(map[string]struct{}, len(allowed)),
+		hosts:   make(map[string][]net.IP, len(hosts)),
+	}
+	for _, d := range blocked {
+		if d = normalizeDomain(d); len(d) > 0 {
+			f.blocked[d] = struct{}{}
+		}
+	}
+	for _, d := range allowed {
+		if d = normalizeDomain(d); len(d) > 0 {
+			f.allowed[d] = struct{}{}
+		}
+	}
+	for d, ips := range hosts {
+		if d = normalizeDomain(d); len(d) > 0 && len(ips) > 0 {
+			f.hosts[d] = append(f.hosts[d], ips...)
+		}
+	}
+	return f
+}
+
+// IsEmpty returns true if the filter has no rules
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.blocked) == 0 && len(f.allowed) == 0 && len(f.hosts) == 0)
+}
+
+// BlockedDomainsCount returns number of domains in the block list
+func (f *Filter) BlockedDomainsCount() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.blocked)
+}
+
+// IsBlocked returns true if the name is blocked by the filter
+func (f *Filter) IsBlocked(name string) bool {
+	name = normalizeDomain(name)
+	if _, ok := f.hosts[name]; ok {
+		return false
+	}
+	if matchDomainOrParent(f.allowed, name) {
+		return false
+	}
+	return matchDomainOrParent(f.blocked, name)
+}
+
+// response returns the response for the query if it is processed by the filter
+// (nil - the query must be forwarded to upstream servers)
+func (f *Filter) response(query []byte, q dnsmessage.Question) (resp []byte, isBlocked bool) {
+	name := normalizeDomain(q.Name.String())
+
+	if ips, ok := f.hosts[name]; ok {
+		return hostsResponse(query, q, ips), false
+	}
+	if matchDomainOrParent(f.allowed, name) {
+		return nil, false
+	}
+	if matchDomainOrParent(f.blocked, name) {
+		return errorResponse(query, dnsmessage.RCodeNameError), true
+	}
+	return nil, false
+}
+
+// matchDomainOrParent returns true if the name or one of its parent domains is in the list
+// (e.g. for "a.b.example.com": "a.b.example.com", "b.example.com", "example.com", "com")
+func matchDomainOrParent(list map[string]struct{}, name string) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for {
+		if _, ok := list[name]; ok {
+			return true
+		}
+		idx := strings.IndexByte(name, '.')
+		if idx < 0 {
+			return false
+		}
+		name = name[idx+1:]
+	}
+}
+
+// hostsResponse creates the response for the name defined by static hosts entries
+// (A/AAAA answers; empty answer for other query types)
+func hostsResponse(query []byte, q dnsmessage.Question, ips []net.IP) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 hdr.ID,
+			Response:           true,
+			Authoritative:      true,
+			OpCode:             hdr.OpCode,
+			RecursionDesired:   hdr.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: []dnsmessage.Question{q},
+	}
+	for _, ip := range ips {
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: filterAnswerTTL}
+		if ip4 := ip.To4(); ip4 != nil {
+			if q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL {
+				rh.Type = dnsmessage.TypeA
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AResource{A: [4]byte(ip4)}})
+			}
+		} else if ip16 := ip.To16(); ip16 != nil {
+			if q.Type == dnsmessage.TypeAAAA || q.Type == dnsmessage.TypeALL {
+				rh.Type = dnsmessage.TypeAAAA
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AAAAResource{AAAA: [16]byte(ip16)}})
+			}
+		}
+	}
+	ret, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return ret
+}
@@ -0,0 +1,216 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	healthCheckInterval          = time.Second * 30 // probing interval when all upstreams are healthy
+	healthCheckIntervalUnhealthy = time.Second * 10 // probing interval when some upstreams are unhealthy (to detect recovery)
+	healthCheckAttempts          = 2                // number of probe attempts before the upstream is considered unreachable
+	unhealthyFailuresThreshold   = 2                // number of consecutive failures to consider the upstream unhealthy
+)
+
+// UpstreamHealth - the health status of the upstream server
+type UpstreamHealth struct {
+	Upstream   Upstream
+	IsFallback bool          // the fallback upstream (used when all default upstreams are unreachable)
+	IsActive   bool          // the most preferred healthy upstream (the queries are sent to it first)
+	IsHealthy  bool          // false - if the upstream failed several times in a row
+	LastError  string        // the last failure reason (empty - if the last query succeeded)
+	Latency    time.Duration // latency of the last successful query
+}
+
+// Health returns the health status of default and fallback upstreams
+func (r *Resolver) Health() []UpstreamHealth {
+	r.activeMutex.Lock()
+	active := r.active
+	r.activeMutex.Unlock()
+
+	get := func(u *upstream, isFallback bool) UpstreamHealth {
+		u.mutex.Lock()
+		defer u.mutex.Unlock()
+		h := UpstreamHealth{
+			Upstream:   u.cfg,
+			IsFallback: isFallback,
+			IsActive:   u == active,
+			IsHealthy:  u.failures < unhealthyFailuresThreshold,
+			Latency:    u.latency,
+		}
+		if u.lastErr != nil {
+			h.LastError = u.lastErr.Error()
+		}
+		return h
+	}
+
+	ret := make([]UpstreamHealth, 0, len(r.upstreams)+len(r.fallback))
+	for _, u := range r.upstreams {
+		ret = append(ret, get(u, false))
+	}
+	for _, u := range r.fallback {
+		ret = append(ret, get(u, true))
+	}
+	return ret
+}
+
+// healthMonitor periodically probes default and fallback upstreams and updates the active upstream
+func (r *Resolver) healthMonitor() {
+	defer r.wg.Done()
+
+	for {
+		interval := healthCheckInterval
+		if r.hasUnhealthyUpstreams() {
+			interval = healthCheckIntervalUnhealthy
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		r.probeUpstreams()
+		if r.ctx.Err() != nil {
+			return
+		}
+		r.updateActiveUpstream()
+	}
+}
+
+func (r *Resolver) hasUnhealthyUpstreams() bool {
+	for _, u := range r.upstreams {
+		if !u.isHealthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// probeUpstreams sends the probe query to all default and fallback upstreams (in parallel)
+func (r *Resolver) probeUpstreams() {
+	var wg sync.WaitGroup
+	for _, u := range append(append([]*upstream{}, r.upstreams...), r.fallback...) {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+
+			var err error
+			for i := 0; i < healthCheckAttempts && r.ctx.Err() == nil; i++ {
+				var latency time.Duration
+				if latency, err = r.probe(u); err == nil {
+					u.setSucceeded(latency)
+					return
+				}
+			}
+			if r.ctx.Err() != nil {
+				return
+			}
+			if u.isHealthy() {
+				log.Warning(fmt.Sprintf("upstream %s is unreachable: %s", u.cfg, err))
+			}
+			// the probe failed several times: mark upstream unhealthy immediately
+			u.mutex.Lock()
+			u.failedAt = time.Now()
+			u.failures = max(u.failures+1, unhealthyFailuresThreshold)
+			u.lastErr = err
+			u.mutex.Unlock()
+		}(u)
+	}
+	wg.Wait()
+}
+
+// probe sends the query for the root name servers ('. IN NS') to the upstream
+func (r *Resolver) probe(u *upstream) (time.Duration, error) {
+	var id [2]byte
+	rand.Read(id[:])
+	queryID := binary.BigEndian.Uint16(id[:])
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: queryID, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("."), Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, upstreamTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := u.exchange(ctx, query)
+	if err == nil {
+		err = validateResponse(resp, queryID)
+	}
+	return time.Since(start), err
+}
+
+// updateActiveUpstream selects the most preferred healthy upstream: the first healthy default upstream
+// or the first healthy fallback upstream (if all default upstreams are unhealthy).
+// If nothing is healthy (e.g. no connectivity) - the active upstream is not changed.
+func (r *Resolver) updateActiveUpstream() {
+	var newActive *upstream
+	isFallback := false
+	for _, u := range r.upstreams {
+		if u.isHealthy() {
+			newActive = u
+			break
+		}
+	}
+	if newActive == nil {
+		for _, u := range r.fallback {
+			if u.isHealthy() {
+				newActive, isFallback = u, true
+				break
+			}
+		}
+	}
+	if newActive == nil {
+		return
+	}
+
+	r.activeMutex.Lock()
+	isChanged := r.active != newActive
+	r.active = newActive
+	r.activeMutex.Unlock()
+
+	if !isChanged {
+		return
+	}
+	if isFallback {
+		log.Warning(fmt.Sprintf("All upstreams are unreachable. Switched to fallback upstream %s", newActive.cfg))
+	} else {
+		log.Info(fmt.Sprintf("Active upstream: %s", newActive.cfg))
+	}
+	if r.onActiveChanged != nil {
+		// asynchronous call: the handler is allowed to reconfigure (stop) the resolver
+		go r.onActiveChanged(newActive.cfg, isFallback)
+	}
+}
@@ -23,7 +23,7 @@
 //  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
 //
 
-package dnscryptproxy
+package resolver
 
 import (
 	"fmt"
@@ -20,67 +20,16 @@
 //  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
 //
 
-package dnscryptproxy
+package resolver
 
 import (
 	"fmt"
 	"net"
-
-	"github.com/ivpn/desktop-app/daemon/logger"
 )
 
-var log *logger.Logger
-
-func init() {
-	log = logger.NewLogger("dnscrt")
-}
-
-type DnsCryptProxy struct {
-	binaryPath     string
-	configFilePath string
-	listenAddr     net.IP      // address where dnscrypt-proxy will listen
-	extra          extraParams // platform-specific extra params
-}
-
 var hookInitLoopbackIP func(loopbackIP net.IP) error
 
-// Start - create and start dnscrypt-proxy instance asynchronously
-func Start(binaryPath, configFile string, listenAddr net.IP) (obj *DnsCryptProxy, retErr error) {
-	p := &DnsCryptProxy{
-		binaryPath:     binaryPath,
-		configFilePath: configFile,
-		listenAddr:     listenAddr,
-	}
-
-	defer func() {
-		if retErr != nil {
-			retErr = fmt.Errorf("error starting dnscrypt-proxy: %w", retErr)
-			p.implStop()
-		}
-	}()
-
-	return p, p.implStart()
-}
-func (p *DnsCryptProxy) Stop() (err error) {
-	if p == nil {
-		return nil
-	}
-
-	log.Info("Stopping dnscrypt-proxy")
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("error stopping dnscrypt-proxy: %w", err)
-		}
-	}()
-
-	ret := p.implStop()
-	if ret == nil {
-		p = nil
-	}
-	return ret
-}
-
-// getFreeLocalAddressForDNS searches for a free TCP/UDP port pairs on loopback addresses
+// GetFreeLocalAddressForDNS searches for a free TCP/UDP port pairs on loopback addresses
 // in the 127.0.0.x range, starting from the specified address.
 // It returns the first found local IP address where both TCP:53 and UDP:53 ports are available.
 //
@@ -91,7 +40,6 @@ func (p *DnsCryptProxy) Stop() (err error) {
 //
 // Parameters:
 //   - startAddress: IPv4 address in 127.0.0.x range to start searching from (nil defaults to 127.0.0.1)
-//   - desiredPort: Port number to check for availability (typically 53 for DNS)
 //
 // Returns:
 //   - net.IP: Local IP address with the 53 port available on both TCP and UDP
@@ -119,7 +67,7 @@ func GetFreeLocalAddressForDNS(startAddress net.IP) (net.IP, error) {
 		}
 
 		// Check UDP port availability
-		udpAddr := &net.UDPAddr{IP: ip, Port: 53}
+		udpAddr := &net.UDPAddr{IP: ip, Port: defaultPort}
 		udpConn, err := net.ListenUDP("udp", udpAddr)
 		if err != nil {
 			continue // UDP port not available on this IP
@@ -127,7 +75,7 @@ func GetFreeLocalAddressForDNS(startAddress net.IP) (net.IP, error) {
 		udpConn.Close()
 
 		// Check TCP port availability
-		tcpAddr := &net.TCPAddr{IP: ip, Port: 5}
+		tcpAddr := &net.TCPAddr{IP: ip, Port: defaultPort}
 		tcpConn, err := net.ListenTCP("tcp", tcpAddr)
 		if err != nil {
 			continue // TCP port not available on this IP
@@ -0,0 +1,528 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Oblivious DNS over HTTPS (RFC 9230) client:
+// the queries are encrypted using the target public key (HPKE, RFC 9180) and sent to the target via ODoH relay (proxy).
+// So, the relay knows the client IP but can not read the queries, and the target can read the queries but does not know the client IP.
+
+const (
+	odohContentType           = "application/oblivious-dns-message"
+	odohConfigsPath           = "/.well-known/odohconfigs"
+	odohConfigVersion         = 0x0001
+	odohMessageTypeQuery      = 0x01
+	odohMessageTypeResponse   = 0x02
+	odohQueryPaddingBlockSize = 128
+	odohConfigRefreshInterval = time.Hour
+
+	// HPKE algorithms (RFC 9180)
+	hpkeKemX25519HkdfSha256 = 0x0020
+	hpkeKdfHkdfSha256       = 0x0001
+	hpkeAeadAes128Gcm       = 0x0001
+	hpkeAeadChaCha20Poly    = 0x0003
+)
+
+type odohConfig struct {
+	aeadID    uint16
+	publicKey *ecdh.PublicKey
+	keyID     []byte
+}
+
+type odohClient struct {
+	targetHost string // target host name (optionally, with port)
+	targetPath string
+	relayURL   string // relay URL with 'targethost' and 'targetpath' query parameters
+
+	relayHttpClient  *http.Client // connects to the relay
+	targetHttpClient *http.Client // connects to the target (used only to get target configuration)
+
+	mutex           sync.Mutex
+	config          *odohConfig
+	configFetchedAt time.Time
+}
+
+func newOdohClient(targetIP net.IP, stamp ServerStamp, relay *ServerStamp) (*odohClient, error) {
+	if stamp.Proto != StampProtoTypeODoHTarget {
+		return nil, fmt.Errorf("not an ODoH target stamp (%s)", stamp.Proto.String())
+	}
+	if relay == nil || relay.Proto != StampProtoTypeODoHRelay {
+		return nil, errors.New("ODoH relay not defined")
+	}
+	if relay.IP() == nil {
+		return nil, errors.New("IP address of ODoH relay not defined by the stamp")
+	}
+
+	relayURL, err := url.Parse(relay.URL())
+	if err != nil {
+		return nil, fmt.Errorf("bad ODoH relay URL: %w", err)
+	}
+	q := relayURL.Query()
+	q.Set("targethost", stamp.ProviderName)
+	q.Set("targetpath", stamp.Path)
+	relayURL.RawQuery = q.Encode()
+
+	targetPort := DefaultPort
+	if _, p, err := net.SplitHostPort(stamp.ProviderName); err == nil {
+		if targetPort, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("bad ODoH target port '%s'", p)
+		}
+	}
+
+	return &odohClient{
+		targetHost:       stamp.ProviderName,
+		targetPath:       stamp.Path,
+		relayURL:         relayURL.String(),
+		relayHttpClient:  newHttpClientForAddress(relay.ServerAddress(), relayURL.Hostname()),
+		targetHttpClient: newHttpClientForAddress(hostPort(targetIP, targetPort), hostNameOnly(stamp.ProviderName)),
+	}, nil
+}
+
+func hostNameOnly(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// newHttpClientForAddress returns HTTP client which always connects to the defined address (the host name is never resolved)
+func newHttpClientForAddress(addr string, serverName string) *http.Client {
+	dialer := &net.Dialer{Timeout: upstreamTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig:     &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12},
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        4,
+			IdleConnTimeout:     time.Second * 60,
+			TLSHandshakeTimeout: upstreamTimeout,
+		},
+	}
+}
+
+func (c *odohClient) close() {
+	c.relayHttpClient.CloseIdleConnections()
+	c.targetHttpClient.CloseIdleConnections()
+}
+
+func (c *odohClient) resetConfig() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.config = nil
+}
+
+func (c *odohClient) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	cfg, err := c.getConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ODoH target configuration: %w", err)
+	}
+
+	// RFC 8484: DNS ID should be 0; the original ID is restored in the response
+	q := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(q[0:2], 0)
+
+	// ephemeral key of HPKE sender (new for each query)
+	skE, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, hpkeCtx, queryPlain, err := odohEncryptQuery(cfg, skE, q)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.relayURL, bytes.NewReader(encrypted))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", odohContentType)
+	req.Header.Set("Accept", odohContentType)
+
+	resp, err := c.relayHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest {
+			// the target key could be changed: request new configuration on next query
+			c.resetConfig()
+		}
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := odohDecryptResponse(hpkeCtx, queryPlain, body)
+	if err != nil {
+		c.resetConfig()
+		return nil, err
+	}
+	if len(ret) < 12 {
+		return nil, fmt.Errorf("bad response size (%d bytes)", len(ret))
+	}
+	copy(ret[0:2], query[0:2])
+	return ret, nil
+}
+
+// getConfig returns the target configuration (the configuration is requested from the target if necessary)
+//
+// The configuration is requested directly from the target (not via the relay): the ODoH relays forward only
+// the encrypted DNS messages (POST requests with 'targethost' and 'targetpath'), there is no standard way
+// to request the target configuration through the relay.
+// The request contains no DNS data: the target gets only the client IP and the fact the client uses the target
+// (it can not link the client with the queries, since each query uses a new ephemeral key and comes from the relay).
+// The configuration is cached (see odohConfigRefreshInterval), so the target is contacted rarely;
+// when the VPN is connected, the request goes through the VPN tunnel and the client IP is not exposed.
+func (c *odohClient) getConfig(ctx context.Context) (*odohConfig, error) {
+	c.mutex.Lock()
+	cfg := c.config
+	if cfg != nil && time.Since(c.configFetchedAt) > odohConfigRefreshInterval {
+		cfg = nil
+	}
+	c.mutex.Unlock()
+	if cfg != nil {
+		return cfg, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+c.targetHost+odohConfigsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.targetHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	if cfg, err = odohParseConfigs(body); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.config = cfg
+	c.configFetchedAt = time.Now()
+	return cfg, nil
+}
+
+// odohParseConfigs returns the first supported configuration from ObliviousDoHConfigs:
+//
+//	ObliviousDoHConfigs: <length (2)> ObliviousDoHConfig...
+//	ObliviousDoHConfig: <version (2)> <length (2)> <contents>
+//	ObliviousDoHConfigContents: <kem_id (2)> <kdf_id (2)> <aead_id (2)> <public_key length (2)> <public_key>
+func odohParseConfigs(data []byte) (*odohConfig, error) {
+	if len(data) < 2 || int(binary.BigEndian.Uint16(data[0:2])) != len(data)-2 {
+		return nil, errors.New("bad ODoH configuration")
+	}
+	data = data[2:]
+	for len(data) >= 4 {
+		version := binary.BigEndian.Uint16(data[0:2])
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+l {
+			break
+		}
+		contents := data[4 : 4+l]
+		data = data[4+l:]
+
+		if version != odohConfigVersion || len(contents) < 8 {
+			continue
+		}
+		kemID := binary.BigEndian.Uint16(contents[0:2])
+		kdfID := binary.BigEndian.Uint16(contents[2:4])
+		aeadID := binary.BigEndian.Uint16(contents[4:6])
+		pkLen := int(binary.BigEndian.Uint16(contents[6:8]))
+		if kemID != hpkeKemX25519HkdfSha256 || kdfID != hpkeKdfHkdfSha256 || (aeadID != hpkeAeadAes128Gcm && aeadID != hpkeAeadChaCha20Poly) || len(contents) != 8+pkLen {
+			continue
+		}
+		pk, err := ecdh.X25519().NewPublicKey(contents[8:])
+		if err != nil {
+			continue
+		}
+		prk, err := hkdf.Extract(sha256.New, contents, nil)
+		if err != nil {
+			return nil, err
+		}
+		keyID, err := hkdf.Expand(sha256.New, prk, "odoh key id", sha256.Size)
+		if err != nil {
+			return nil, err
+		}
+		return &odohConfig{aeadID: aeadID, publicKey: pk, keyID: keyID}, nil
+	}
+	return nil, errors.New("no supported ODoH configuration found")
+}
+
+// odohEncryptQuery creates ObliviousDoHMessage with encrypted query:
+//
+//	<message_type (1)> <key_id length (2)> <key_id> <encrypted_message length (2)> <enc> <ct>
+func odohEncryptQuery(cfg *odohConfig, skE *ecdh.PrivateKey, query []byte) (msg []byte, ctx *hpkeContext, queryPlain []byte, err error) {
+	// ObliviousDoHMessagePlaintext: <dns_message length (2)> <dns_message> <padding length (2)> <padding>
+	paddingLen := (odohQueryPaddingBlockSize - (len(query)+4)%odohQueryPaddingBlockSize) % odohQueryPaddingBlockSize
+	queryPlain = make([]byte, 0, len(query)+4+paddingLen)
+	queryPlain = binary.BigEndian.AppendUint16(queryPlain, uint16(len(query)))
+	queryPlain = append(queryPlain, query...)
+	queryPlain = binary.BigEndian.AppendUint16(queryPlain, uint16(paddingLen))
+	queryPlain = append(queryPlain, make([]byte, paddingLen)...)
+
+	enc, ctx, err := hpkeSetupBaseS(skE, cfg.publicKey, cfg.aeadID, []byte("odoh query"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aad := odohAad(odohMessageTypeQuery, cfg.keyID)
+	ct := ctx.aead.Seal(nil, ctx.baseNonce, queryPlain, aad)
+
+	msg = append(aad, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(aad):], uint16(len(enc)+len(ct)))
+	msg = append(msg, enc...)
+	msg = append(msg, ct...)
+	return msg, ctx, queryPlain, nil
+}
+
+// odohDecryptResponse decrypts ObliviousDoHMessage with encrypted response:
+//
+//	<message_type (1)> <response_nonce length (2)> <response_nonce> <encrypted_message length (2)> <encrypted_message>
+func odohDecryptResponse(ctx *hpkeContext, queryPlain []byte, msg []byte) ([]byte, error) {
+	if len(msg) < 3 || msg[0] != odohMessageTypeResponse {
+		return nil, errors.New("bad ODoH response")
+	}
+	nonceLen := int(binary.BigEndian.Uint16(msg[1:3]))
+	if len(msg) < 5+nonceLen {
+		return nil, errors.New("bad ODoH response")
+	}
+	respNonce := msg[3 : 3+nonceLen]
+	ctLen := int(binary.BigEndian.Uint16(msg[3+nonceLen : 5+nonceLen]))
+	ct := msg[5+nonceLen:]
+	if len(ct) != ctLen {
+		return nil, errors.New("bad ODoH response")
+	}
+
+	aead, nonce, err := odohResponseAead(ctx, queryPlain, respNonce)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, ct, odohAad(odohMessageTypeResponse, respNonce))
+	if err != nil {
+		return nil, errors.New("bad ODoH response (decryption failed)")
+	}
+
+	// ObliviousDoHMessagePlaintext
+	if len(plain) < 2 {
+		return nil, errors.New("bad ODoH response")
+	}
+	l := int(binary.BigEndian.Uint16(plain[0:2]))
+	if len(plain) < 2+l {
+		return nil, errors.New("bad ODoH response")
+	}
+	return plain[2 : 2+l], nil
+}
+
+// odohResponseAead returns AEAD and nonce to encrypt/decrypt the response (RFC 9230, section 6.4):
+//
+//	secret = Export("odoh response", Nk)
+//	salt = Q_plain || len(resp_nonce) || resp_nonce
+//	prk = Extract(salt, secret)
+//	key = Expand(prk, "odoh key", Nk)
+//	nonce = Expand(prk, "odoh nonce", Nn)
+func odohResponseAead(ctx *hpkeContext, queryPlain []byte, respNonce []byte) (aead cipher.AEAD, nonce []byte, err error) {
+	secret, err := ctx.export([]byte("odoh response"), ctx.keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	salt := append([]byte(nil), queryPlain...)
+	salt = binary.BigEndian.AppendUint16(salt, uint16(len(respNonce)))
+	salt = append(salt, respNonce...)
+	prk, err := hkdf.Extract(sha256.New, secret, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := hkdf.Expand(sha256.New, prk, "odoh key", ctx.keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if aead, err = newHpkeAead(ctx.aeadID, key); err != nil {
+		return nil, nil, err
+	}
+	if nonce, err = hkdf.Expand(sha256.New, prk, "odoh nonce", aead.NonceSize()); err != nil {
+		return nil, nil, err
+	}
+	return aead, nonce, nil
+}
+
+func odohAad(messageType byte, keyID []byte) []byte {
+	aad := make([]byte, 0, 3+len(keyID))
+	aad = append(aad, messageType)
+	aad = binary.BigEndian.AppendUint16(aad, uint16(len(keyID)))
+	return append(aad, keyID...)
+}
+
+// hpkeContext - the HPKE encryption context (RFC 9180; base mode, single-shot)
+type hpkeContext struct {
+	aeadID         uint16
+	aead           cipher.AEAD
+	keySize        int
+	baseNonce      []byte
+	exporterSecret []byte
+	suiteID        []byte
+}
+
+// export returns the secret exported from the encryption context
+func (c *hpkeContext) export(exporterContext []byte, length int) ([]byte, error) {
+	return hpkeLabeledExpand(c.suiteID, c.exporterSecret, "sec", exporterContext, length)
+}
+
+// hpkeSetupBaseS - HPKE sender setup in base mode: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM or ChaCha20Poly1305
+// (skE - ephemeral key of the sender; it must be generated randomly for each setup)
+func hpkeSetupBaseS(skE *ecdh.PrivateKey, pkR *ecdh.PublicKey, aeadID uint16, info []byte) (enc []byte, ctx *hpkeContext, err error) {
+	// Encap
+	dh, err := skE.ECDH(pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc = skE.PublicKey().Bytes()
+	sharedSecret, err := hpkeSharedSecret(dh, enc, pkR.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx, err = hpkeKeySchedule(sharedSecret, aeadID, info); err != nil {
+		return nil, nil, err
+	}
+	return enc, ctx, nil
+}
+
+// hpkeSharedSecret - DHKEM ExtractAndExpand(dh, kem_context = enc || pkR)
+func hpkeSharedSecret(dh, enc, pkR []byte) ([]byte, error) {
+	kemSuiteID := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKemX25519HkdfSha256)
+	eaePrk, err := hpkeLabeledExtract(kemSuiteID, nil, "eae_prk", dh)
+	if err != nil {
+		return nil, err
+	}
+	return hpkeLabeledExpand(kemSuiteID, eaePrk, "shared_secret", append(append([]byte(nil), enc...), pkR...), sha256.Size)
+}
+
+// hpkeKeySchedule - HPKE KeySchedule in base mode (no PSK)
+func hpkeKeySchedule(sharedSecret []byte, aeadID uint16, info []byte) (*hpkeContext, error) {
+	keySize := 16
+	if aeadID == hpkeAeadChaCha20Poly {
+		keySize = chacha20poly1305.KeySize
+	}
+	suiteID := []byte("HPKE")
+	suiteID = binary.BigEndian.AppendUint16(suiteID, hpkeKemX25519HkdfSha256)
+	suiteID = binary.BigEndian.AppendUint16(suiteID, hpkeKdfHkdfSha256)
+	suiteID = binary.BigEndian.AppendUint16(suiteID, aeadID)
+
+	pskIDHash, err := hpkeLabeledExtract(suiteID, nil, "psk_id_hash", nil)
+	if err != nil {
+		return nil, err
+	}
+	infoHash, err := hpkeLabeledExtract(suiteID, nil, "info_hash", info)
+	if err != nil {
+		return nil, err
+	}
+	keyScheduleContext := append(append([]byte{0x00}, pskIDHash...), infoHash...) // mode_base = 0x00
+	secret, err := hpkeLabeledExtract(suiteID, sharedSecret, "secret", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &hpkeContext{aeadID: aeadID, keySize: keySize, suiteID: suiteID}
+	key, err := hpkeLabeledExpand(suiteID, secret, "key", keyScheduleContext, keySize)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.aead, err = newHpkeAead(aeadID, key); err != nil {
+		return nil, err
+	}
+	if ctx.baseNonce, err = hpkeLabeledExpand(suiteID, secret, "base_nonce", keyScheduleContext, ctx.aead.NonceSize()); err != nil {
+		return nil, err
+	}
+	if ctx.exporterSecret, err = hpkeLabeledExpand(suiteID, secret, "exp", keyScheduleContext, sha256.Size); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+func newHpkeAead(aeadID uint16, key []byte) (cipher.AEAD, error) {
+	switch aeadID {
+	case hpkeAeadAes128Gcm:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case hpkeAeadChaCha20Poly:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unsupported AEAD (0x%04x)", aeadID)
+	}
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) ([]byte, error) {
+	labeledIkm := append([]byte("HPKE-v1"), suiteID...)
+	labeledIkm = append(labeledIkm, label...)
+	labeledIkm = append(labeledIkm, ikm...)
+	return hkdf.Extract(sha256.New, labeledIkm, salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) ([]byte, error) {
+	labeledInfo := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	return hkdf.Expand(sha256.New, prk, string(labeledInfo), length)
+}
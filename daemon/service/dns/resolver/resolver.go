@@ -0,0 +1,661 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package resolver implements the in-process DNS stub resolver.
+//
+// The resolver listens on a loopback address (UDP and TCP) and forwards the queries to the upstream servers
+// using plain DNS, DNS-over-TLS (DoT), DNS-over-HTTPS (DoH), DNSCrypt (optionally, via Anonymized DNSCrypt relay)
+// or Oblivious DoH (ODoH; via ODoH relay).
+// The answers are cached. If an upstream server fails - the query is forwarded to the next one (failover).
+// The upstream servers are periodically probed; when all of them are unreachable - the fallback servers are used
+// until one of the upstream servers recovers.
+// Queries for the domains defined by domain rules (split DNS) are forwarded only to the rule's upstream servers.
+// The local filter (block lists, allowlist, static hosts entries) is applied before forwarding.
+// The query statistics are collected by StatsCollector (if defined).
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("dnsres")
+}
+
+const (
+	defaultPort        = 53
+	defaultCacheSize   = 1024
+	upstreamTimeout    = time.Second * 3
+	upstreamRetryDelay = time.Second * 30 // failed upstream has lower priority during this period
+	tcpIdleTimeout     = time.Second * 10
+	maxMessageSize     = 65535
+	maxUdpInFlight     = 256 // max number of UDP queries processed simultaneously
+)
+
+// UpstreamType - the protocol used to communicate with upstream DNS server
+type UpstreamType int
+
+const (
+	UpstreamPlain    UpstreamType = iota // plain DNS (UDP, TCP fallback for truncated responses)
+	UpstreamDoT                          // DNS-over-TLS
+	UpstreamDoH                          // DNS-over-HTTPS
+	UpstreamDNSCrypt                     // DNSCrypt v2 (optionally, via Anonymized DNSCrypt relay)
+	UpstreamODoH                         // Oblivious DNS-over-HTTPS (via ODoH relay)
+)
+
+func (t UpstreamType) String() string {
+	switch t {
+	case UpstreamPlain:
+		return "plain"
+	case UpstreamDoT:
+		return "DoT"
+	case UpstreamDoH:
+		return "DoH"
+	case UpstreamDNSCrypt:
+		return "DNSCrypt"
+	case UpstreamODoH:
+		return "ODoH"
+	default:
+		return "unknown"
+	}
+}
+
+// Upstream - the upstream DNS server configuration
+type Upstream struct {
+	Type UpstreamType
+	// IP address of the server (the server host name is never resolved)
+	// ODoH: IP address of the target (in use only to request the target configuration; the queries are sent via relay)
+	Address net.IP
+	Port    int // plain DNS: server port (default: 53); DoH/DoT/DNSCrypt/ODoH: the port is defined by template
+	// DoH: URL template (e.g. "https://dns.example.com/dns-query")
+	// DoT: server name (e.g. "dns.example.com" or "tls://dns.example.com:853")
+	// DNSCrypt/ODoH: DNS stamp of the server or relay and server ("sdns://SERVER" or "sdns://RELAY/SERVER")
+	Template string
+}
+
+func (u Upstream) String() string {
+	if u.Type == UpstreamPlain {
+		if u.Port > 0 && u.Port != defaultPort {
+			return hostPort(u.Address, u.Port)
+		}
+		return u.Address.String()
+	}
+	if u.Type == UpstreamDNSCrypt || u.Type == UpstreamODoH {
+		return fmt.Sprintf("%s (%s)", u.Address, StampDescription(u.Template))
+	}
+	return fmt.Sprintf("%s (%s %s)", u.Address, u.Type, u.Template)
+}
+
+// DomainRule - the conditional forwarding rule:
+// queries for the domain (and all its subdomains) are forwarded only to the rule's upstream servers
+type DomainRule struct {
+	Domain    string     // domain name (e.g. "corp.example")
+	Upstreams []Upstream // upstream servers in order of preference
+}
+
+// Config - the resolver configuration
+type Config struct {
+	ListenAddress net.IP          // local address to listen (e.g. 127.0.0.1)
+	Port          int             // local port to listen (default: 53; negative value - any free port)
+	Upstreams     []Upstream      // upstream servers in order of preference
+	DomainRules   []DomainRule    // conditional forwarding rules (the longest matching domain wins)
+	Filter        *Filter         // local filtering rules: block lists, allowlist, static hosts (nil - no filtering)
+	CacheSize     int             // max number of cached answers (default: 1024; negative value disables cache)
+	Stats         *StatsCollector // query statistics collector (nil - statistics are not collected)
+	// Fallback upstream servers: used when all 'Upstreams' are unreachable (not applicable for domain rules)
+	Fallback []Upstream
+	// OnActiveUpstreamChanged is called when the active upstream server changed (e.g. failover to the next server
+	// or recovery of the preferred one). Optional.
+	OnActiveUpstreamChanged func(active Upstream, isFallback bool)
+	// OnAnswer is called for each response which contains IP addresses (A/AAAA records) of the queried name,
+	// before the response is sent to the client. 'ttl' - the minimal TTL of the addresses. Optional.
+	OnAnswer func(name string, ips []net.IP, ttl time.Duration)
+}
+
+type domainRule struct {
+	domain    string // lowercase, without leading/trailing dots
+	upstreams []*upstream
+}
+
+// match returns true if the name is the rule domain or its subdomain
+func (d *domainRule) match(name string) bool {
+	return name == d.domain || strings.HasSuffix(name, "."+d.domain)
+}
+
+// Resolver - the DNS stub resolver
+type Resolver struct {
+	listenAddr *net.UDPAddr
+	udpConn    *net.UDPConn
+	tcpListen  *net.TCPListener
+	upstreams  []*upstream
+	fallback   []*upstream
+	rules      []*domainRule // sorted by domain length (the longest first)
+	cache      *cache
+	filter     atomic.Pointer[Filter]
+	stats      *StatsCollector
+	udpSlots   chan struct{} // semaphore: limits the number of UDP queries processed simultaneously
+
+	onActiveChanged func(active Upstream, isFallback bool)
+	onAnswer        func(name string, ips []net.IP, ttl time.Duration)
+	activeMutex     sync.Mutex
+	active          *upstream // the most preferred healthy upstream (updated by health monitor)
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// Start creates new resolver and starts listening for DNS queries
+func Start(cfg Config) (*Resolver, error) {
+	if cfg.ListenAddress == nil {
+		return nil, fmt.Errorf("listen address not defined")
+	}
+	if len(cfg.Upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream DNS servers defined")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	} else if cfg.Port < 0 {
+		cfg.Port = 0
+	}
+	if cfg.CacheSize == 0 {
+		cfg.CacheSize = defaultCacheSize
+	}
+
+	r := &Resolver{udpSlots: make(chan struct{}, maxUdpInFlight)}
+	for _, u := range cfg.Upstreams {
+		upstr, err := newUpstream(u)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %s: %w", u, err)
+		}
+		r.upstreams = append(r.upstreams, upstr)
+	}
+	for _, u := range cfg.Fallback {
+		upstr, err := newUpstream(u)
+		if err != nil {
+			r.closeUpstreams()
+			return nil, fmt.Errorf("fallback upstream %s: %w", u, err)
+		}
+		r.fallback = append(r.fallback, upstr)
+	}
+	r.active = r.upstreams[0]
+	r.onActiveChanged = cfg.OnActiveUpstreamChanged
+	r.onAnswer = cfg.OnAnswer
+	for _, dr := range cfg.DomainRules {
+		rule := &domainRule{domain: normalizeDomain(dr.Domain)}
+		if len(rule.domain) == 0 {
+			r.closeUpstreams()
+			return nil, fmt.Errorf("domain rule: empty domain name")
+		}
+		if len(dr.Upstreams) == 0 {
+			r.closeUpstreams()
+			return nil, fmt.Errorf("domain rule '%s': no upstream DNS servers defined", rule.domain)
+		}
+		for _, u := range dr.Upstreams {
+			upstr, err := newUpstream(u)
+			if err != nil {
+				r.closeUpstreams()
+				return nil, fmt.Errorf("domain rule '%s': upstream %s: %w", rule.domain, u, err)
+			}
+			rule.upstreams = append(rule.upstreams, upstr)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	sort.SliceStable(r.rules, func(i, j int) bool { return len(r.rules[i].domain) > len(r.rules[j].domain) })
+
+	if cfg.CacheSize > 0 {
+		r.cache = newCache(cfg.CacheSize)
+	}
+	r.filter.Store(cfg.Filter)
+	r.stats = cfg.Stats
+
+	var err error
+	if r.udpConn, err = net.ListenUDP("udp", &net.UDPAddr{IP: cfg.ListenAddress, Port: cfg.Port}); err != nil {
+		return nil, fmt.Errorf("failed to listen UDP: %w", err)
+	}
+	r.listenAddr = r.udpConn.LocalAddr().(*net.UDPAddr)
+	if r.tcpListen, err = net.ListenTCP("tcp", &net.TCPAddr{IP: r.listenAddr.IP, Port: r.listenAddr.Port}); err != nil {
+		r.udpConn.Close()
+		return nil, fmt.Errorf("failed to listen TCP: %w", err)
+	}
+
+	r.ctx, r.ctxCancel = context.WithCancel(context.Background())
+
+	r.wg.Add(3)
+	go r.serveUDP()
+	go r.serveTCP()
+	go r.healthMonitor()
+
+	log.Info(fmt.Sprintf("Started on %s (upstreams: %v)", r.listenAddr, cfg.Upstreams))
+	if len(cfg.Fallback) > 0 {
+		log.Info(fmt.Sprintf("Fallback upstreams: %v", cfg.Fallback))
+	}
+	for _, dr := range cfg.DomainRules {
+		log.Info(fmt.Sprintf("Domain rule: '%s' -> %v", dr.Domain, dr.Upstreams))
+	}
+	return r, nil
+}
+
+// Stop stops the resolver
+func (r *Resolver) Stop() error {
+	if r == nil {
+		return nil
+	}
+	r.ctxCancel()
+	errUdp := r.udpConn.Close()
+	errTcp := r.tcpListen.Close()
+	r.wg.Wait()
+	r.closeUpstreams()
+	log.Info(fmt.Sprintf("Stopped (%s)", r.listenAddr))
+
+	if errUdp != nil {
+		return errUdp
+	}
+	return errTcp
+}
+
+func (r *Resolver) closeUpstreams() {
+	for _, u := range r.upstreams {
+		u.close()
+	}
+	for _, u := range r.fallback {
+		u.close()
+	}
+	for _, rule := range r.rules {
+		for _, u := range rule.upstreams {
+			u.close()
+		}
+	}
+}
+
+// SetFilter updates the local filtering rules of the running resolver (nil - no filtering)
+func (r *Resolver) SetFilter(f *Filter) {
+	r.filter.Store(f)
+}
+
+// ListenAddress returns the local address the resolver is listening on
+func (r *Resolver) ListenAddress() net.IP {
+	return r.listenAddr.IP
+}
+
+// ListenPort returns the local port the resolver is listening on
+func (r *Resolver) ListenPort() int {
+	return r.listenAddr.Port
+}
+
+func (r *Resolver) serveUDP() {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := r.udpConn.ReadFromUDP(buf)
+		if err != nil {
+			if r.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Error(fmt.Errorf("UDP read error: %w", err))
+			}
+			return
+		}
+
+		// Drop the query when too many queries are in progress (e.g. all upstreams are timing out).
+		// The client will retry it; otherwise the number of goroutines is unbounded.
+		select {
+		case r.udpSlots <- struct{}{}:
+		default:
+			log.Debug(fmt.Sprintf("Too many UDP queries in progress; query from %s dropped", addr))
+			continue
+		}
+		query := append([]byte(nil), buf[:n]...)
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-r.udpSlots }()
+			resp := r.handleQuery(query)
+			if resp == nil {
+				return
+			}
+			resp = truncateForUDP(query, resp)
+			r.udpConn.WriteToUDP(resp, addr)
+		}()
+	}
+}
+
+func (r *Resolver) serveTCP() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.tcpListen.AcceptTCP()
+		if err != nil {
+			if r.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Error(fmt.Errorf("TCP accept error: %w", err))
+			}
+			return
+		}
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer conn.Close()
+
+			// close connection when resolver is stopping
+			stop := context.AfterFunc(r.ctx, func() { conn.Close() })
+			defer stop()
+
+			for {
+				conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+				query, err := readTcpMessage(conn)
+				if err != nil {
+					return
+				}
+				resp := r.handleQuery(query)
+				if resp == nil {
+					return
+				}
+				if err := writeTcpMessage(conn, resp); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// handleQuery returns the response for the query (nil - if query is malformed and must be ignored)
+func (r *Resolver) handleQuery(query []byte) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return errorResponse(query, dnsmessage.RCodeFormatError)
+	}
+
+	r.stats.onQuery()
+
+	if f := r.filter.Load(); f != nil {
+		if resp, isBlocked := f.response(query, q); resp != nil {
+			if isBlocked {
+				r.stats.onBlocked(q.Name.String(), true)
+			} else {
+				r.notifyAnswer(q, resp)
+			}
+			return resp
+		}
+	}
+
+	if r.cache != nil {
+		if resp := r.cache.get(q, hdr.ID); resp != nil {
+			r.stats.onCacheHit()
+			r.stats.onResponse(q, resp)
+			r.notifyAnswer(q, resp)
+			return resp
+		}
+	}
+
+	upstreams := r.upstreamsForName(q.Name.String())
+
+	ctx, cancel := context.WithTimeout(r.ctx, upstreamTimeout*time.Duration(len(upstreams)))
+	defer cancel()
+
+	resp, err := r.exchange(ctx, query, upstreams)
+	if err != nil {
+		log.Warning(fmt.Sprintf("failed to resolve '%s' %s: %s", q.Name, q.Type, err))
+		r.stats.onFailed()
+		return errorResponse(query, dnsmessage.RCodeServerFailure)
+	}
+	r.stats.onResponse(q, resp)
+
+	if r.cache != nil {
+		r.cache.put(q, resp)
+	}
+	r.notifyAnswer(q, resp)
+	return resp
+}
+
+// notifyAnswer passes the IP addresses from the response to the OnAnswer handler (if defined)
+func (r *Resolver) notifyAnswer(q dnsmessage.Question, resp []byte) {
+	if r.onAnswer == nil || (q.Type != dnsmessage.TypeA && q.Type != dnsmessage.TypeAAAA) {
+		return
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil || msg.RCode != dnsmessage.RCodeSuccess {
+		return
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for _, rr := range msg.Answers {
+		var ip net.IP
+		switch b := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(b.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(b.AAAA[:])
+		default:
+			continue // e.g. CNAME
+		}
+		if len(ips) == 0 || rr.Header.TTL < ttl {
+			ttl = rr.Header.TTL
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		return
+	}
+	r.onAnswer(normalizeDomain(q.Name.String()), ips, time.Duration(ttl)*time.Second)
+}
+
+// upstreamsForName returns upstreams which must be used to resolve the name:
+// upstreams of the most specific matching domain rule or default upstreams (if no rule matches)
+func (r *Resolver) upstreamsForName(name string) []*upstream {
+	name = normalizeDomain(name)
+	for _, rule := range r.rules {
+		if rule.match(name) {
+			return rule.upstreams
+		}
+	}
+	if len(r.fallback) > 0 {
+		// The fallback upstreams are used when default upstreams failed.
+		// If all default upstreams are unhealthy - the fallback upstreams are used first.
+		ret := upstreamsByPriority(r.upstreams)
+		if !ret[0].isHealthy() {
+			return append(upstreamsByPriority(r.fallback), ret...)
+		}
+		return append(ret, r.fallback...)
+	}
+	return r.upstreams
+}
+
+// exchange forwards the query to upstream servers (in order of priority) until the valid answer received
+func (r *Resolver) exchange(ctx context.Context, query []byte, upstreams []*upstream) ([]byte, error) {
+	queryID := binary.BigEndian.Uint16(query[0:2])
+
+	var lastErr error
+	for _, u := range upstreamsByPriority(upstreams) {
+		if ctx.Err() != nil {
+			break
+		}
+		uCtx, cancel := context.WithTimeout(ctx, upstreamTimeout)
+		start := time.Now()
+		resp, err := u.exchange(uCtx, query)
+		latency := time.Since(start)
+		cancel()
+		if err == nil {
+			err = validateResponse(resp, queryID)
+		}
+		r.stats.onUpstreamResult(u.cfg, latency, err != nil)
+		if err != nil {
+			u.setFailed(err)
+			lastErr = fmt.Errorf("%s: %w", u.cfg, err)
+			continue
+		}
+		u.setSucceeded(latency)
+		return resp, nil
+	}
+
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return nil, lastErr
+}
+
+// upstreamsByPriority returns upstreams in order of preference;
+// upstreams which recently failed (or unhealthy) are moved to the end of the list
+func upstreamsByPriority(upstreams []*upstream) []*upstream {
+	ret := make([]*upstream, 0, len(upstreams))
+	var failed []*upstream
+	for _, u := range upstreams {
+		if u.isRecentlyFailed() || !u.isHealthy() {
+			failed = append(failed, u)
+		} else {
+			ret = append(ret, u)
+		}
+	}
+	return append(ret, failed...)
+}
+
+// normalizeDomain returns lowercase domain name without leading/trailing dots
+func normalizeDomain(name string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+func validateResponse(resp []byte, queryID uint16) error {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return fmt.Errorf("bad response: %w", err)
+	}
+	if !hdr.Response || hdr.ID != queryID {
+		return fmt.Errorf("bad response: unexpected message")
+	}
+	if hdr.RCode == dnsmessage.RCodeServerFailure || hdr.RCode == dnsmessage.RCodeRefused {
+		return fmt.Errorf("response code %s", hdr.RCode)
+	}
+	return nil
+}
+
+// errorResponse creates the response with an error code (the question section is copied from the query)
+func errorResponse(query []byte, rcode dnsmessage.RCode) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	msg := dnsmessage.Message{Header: dnsmessage.Header{
+		ID:               hdr.ID,
+		Response:         true,
+		OpCode:           hdr.OpCode,
+		RecursionDesired: hdr.RecursionDesired,
+		RCode:            rcode,
+	}}
+	if q, err := p.Question(); err == nil {
+		msg.Questions = []dnsmessage.Question{q}
+	}
+	ret, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return ret
+}
+
+// truncateForUDP sets TC flag and removes records if the response does not fit into the UDP message size
+// supported by the client (512 bytes or the size defined by EDNS0 OPT record of the query)
+func truncateForUDP(query, resp []byte) []byte {
+	maxSize := 512
+	var p dnsmessage.Parser
+	if _, err := p.Start(query); err == nil {
+		p.SkipAllQuestions()
+		p.SkipAllAnswers()
+		p.SkipAllAuthorities()
+		for {
+			h, err := p.AdditionalHeader()
+			if err != nil {
+				break
+			}
+			if h.Type == dnsmessage.TypeOPT {
+				if size := int(h.Class); size > maxSize {
+					maxSize = size
+				}
+				break
+			}
+			p.SkipAdditional()
+		}
+	}
+	if len(resp) <= maxSize {
+		return resp
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		return resp
+	}
+	msg.Truncated = true
+	msg.Answers, msg.Authorities, msg.Additionals = nil, nil, nil
+	if ret, err := msg.Pack(); err == nil {
+		return ret
+	}
+	return resp
+}
+
+func readTcpMessage(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTcpMessage(w io.Writer, msg []byte) error {
+	if len(msg) > maxMessageSize {
+		return fmt.Errorf("message too large (%d bytes)", len(msg))
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf[0:2], uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+func hostPort(ip net.IP, port int) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
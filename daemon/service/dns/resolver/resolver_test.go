@@ -0,0 +1,976 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testUpstream is a plain DNS server which answers A queries with the defined IP address
+type testUpstream struct {
+	conn    *net.UDPConn
+	answer  net.IP
+	queries atomic.Int32
+	down    atomic.Bool // true - respond with SERVFAIL
+}
+
+func startTestUpstream(t *testing.T, answer net.IP) *testUpstream {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &testUpstream{conn: conn, answer: answer}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			u.queries.Add(1)
+			if u.down.Load() {
+				if resp := testServerFailure(buf[:n]); resp != nil {
+					conn.WriteToUDP(resp, addr)
+				}
+				continue
+			}
+			if resp := testAnswer(buf[:n], answer, 300); resp != nil {
+				conn.WriteToUDP(resp, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return u
+}
+
+func (u *testUpstream) port() int {
+	return u.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func testAnswer(query []byte, answer net.IP, ttl uint32) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil || len(msg.Questions) == 0 {
+		return nil
+	}
+	msg.Response = true
+	msg.Answers = []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.AResource{A: [4]byte(answer.To4())},
+	}}
+	ret, _ := msg.Pack()
+	return ret
+}
+
+func testServerFailure(query []byte) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil
+	}
+	msg.Response = true
+	msg.RCode = dnsmessage.RCodeServerFailure
+	ret, _ := msg.Pack()
+	return ret
+}
+
+func testQuery(t *testing.T, r *Resolver, name string) (net.IP, uint32) {
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 0x1234, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packet, _ := q.Pack()
+
+	conn, err := net.Dial("udp", hostPort(r.ListenAddress(), r.ListenPort()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 10))
+	if _, err := conn.Write(packet); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 0x1234 {
+		t.Fatalf("unexpected response ID %x", resp.ID)
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess || len(resp.Answers) == 0 {
+		return nil, 0
+	}
+	a := resp.Answers[0].Body.(*dnsmessage.AResource).A
+	return net.IP(a[:]), resp.Answers[0].Header.TTL
+}
+
+func TestResolverCacheAndFailover(t *testing.T) {
+	// the first upstream is not listening (queries fail)
+	dead, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPort := dead.LocalAddr().(*net.UDPAddr).Port
+	dead.Close()
+
+	good := startTestUpstream(t, net.IPv4(10, 1, 2, 3))
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams: []Upstream{
+			{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: deadPort},
+			{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: good.port()},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	ip, ttl := testQuery(t, r, "example.com.")
+	if !ip.Equal(net.IPv4(10, 1, 2, 3)) || ttl == 0 || ttl > 300 {
+		t.Fatalf("unexpected answer %v (TTL %d)", ip, ttl)
+	}
+
+	// cached answer (case-insensitive)
+	ip, _ = testQuery(t, r, "EXAMPLE.com.")
+	if !ip.Equal(net.IPv4(10, 1, 2, 3)) {
+		t.Fatalf("unexpected cached answer %v", ip)
+	}
+	if good.queries.Load() != 1 {
+		t.Errorf("expected 1 upstream query, got %d", good.queries.Load())
+	}
+
+	// the failed upstream has lower priority now
+	if p := upstreamsByPriority(r.upstreams); p[0].cfg.Port != good.port() {
+		t.Error("failed upstream must be moved to the end of the list")
+	}
+}
+
+func TestResolverOnAnswer(t *testing.T) {
+	upstr := startTestUpstream(t, net.IPv4(10, 1, 2, 3))
+
+	type answer struct {
+		name string
+		ips  []net.IP
+		ttl  time.Duration
+	}
+	answers := make(chan answer, 2)
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams:     []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: upstr.port()}},
+		OnAnswer: func(name string, ips []net.IP, ttl time.Duration) {
+			answers <- answer{name, ips, ttl}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// the handler is called for upstream and cached answers
+	for i := 0; i < 2; i++ {
+		testQuery(t, r, "Example.com.")
+		select {
+		case a := <-answers:
+			if a.name != "example.com" || len(a.ips) != 1 || !a.ips[0].Equal(net.IPv4(10, 1, 2, 3)) || a.ttl <= 0 || a.ttl > 300*time.Second {
+				t.Fatalf("unexpected answer notification: %+v", a)
+			}
+		default:
+			t.Fatal("answer notification not received")
+		}
+	}
+}
+
+func TestResolverHealthAndFallback(t *testing.T) {
+	primary := startTestUpstream(t, net.IPv4(10, 0, 0, 1))
+	fallback := startTestUpstream(t, net.IPv4(10, 0, 0, 2))
+
+	type activeEvt struct {
+		port       int
+		isFallback bool
+	}
+	events := make(chan activeEvt, 4)
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams:     []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: primary.port()}},
+		Fallback:      []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: fallback.port()}},
+		CacheSize:     -1,
+		OnActiveUpstreamChanged: func(active Upstream, isFallback bool) {
+			events <- activeEvt{port: active.Port, isFallback: isFallback}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	waitEvent := func(expected activeEvt) {
+		t.Helper()
+		select {
+		case e := <-events:
+			if e != expected {
+				t.Fatalf("unexpected active upstream event %+v (expected %+v)", e, expected)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("active upstream change was not notified")
+		}
+	}
+	checkHealth := func(primaryHealthy, fallbackActive bool) {
+		t.Helper()
+		h := r.Health()
+		if len(h) != 2 || h[0].IsFallback || !h[1].IsFallback {
+			t.Fatalf("unexpected health info %+v", h)
+		}
+		if h[0].IsHealthy != primaryHealthy || h[1].IsActive != fallbackActive || h[0].IsActive == fallbackActive {
+			t.Fatalf("unexpected health info %+v", h)
+		}
+	}
+
+	if ip, _ := testQuery(t, r, "example.com."); !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatalf("unexpected answer %v", ip)
+	}
+	if fallback.queries.Load() != 0 {
+		t.Error("fallback upstream must not be used when primary is healthy")
+	}
+
+	// primary is down: the query is answered by fallback; the probe switches to fallback
+	primary.down.Store(true)
+	if ip, _ := testQuery(t, r, "example.com."); !ip.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatalf("unexpected answer %v", ip)
+	}
+	r.probeUpstreams()
+	r.updateActiveUpstream()
+	waitEvent(activeEvt{port: fallback.port(), isFallback: true})
+	checkHealth(false, true)
+
+	// unhealthy primary is not queried first
+	cnt := primary.queries.Load()
+	if ip, _ := testQuery(t, r, "example.org."); !ip.Equal(net.IPv4(10, 0, 0, 2)) {
+		t.Fatalf("unexpected answer %v", ip)
+	}
+	if primary.queries.Load() != cnt {
+		t.Error("unhealthy primary upstream must not be queried before fallback")
+	}
+
+	// primary recovered
+	primary.down.Store(false)
+	r.probeUpstreams()
+	r.updateActiveUpstream()
+	waitEvent(activeEvt{port: primary.port(), isFallback: false})
+	checkHealth(true, false)
+	if ip, _ := testQuery(t, r, "example.net."); !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatalf("unexpected answer %v", ip)
+	}
+}
+
+func TestResolverDomainRules(t *testing.T) {
+	def := startTestUpstream(t, net.IPv4(10, 0, 0, 1))
+	corp := startTestUpstream(t, net.IPv4(10, 0, 0, 2))
+	corpDev := startTestUpstream(t, net.IPv4(10, 0, 0, 3))
+
+	plain := func(u *testUpstream) []Upstream {
+		return []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: u.port()}}
+	}
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams:     plain(def),
+		DomainRules: []DomainRule{
+			{Domain: "Corp.Example.", Upstreams: plain(corp)},
+			{Domain: "dev.corp.example", Upstreams: plain(corpDev)},
+		},
+		CacheSize: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	tests := []struct {
+		name     string
+		expected net.IP
+	}{
+		{"corp.example.", net.IPv4(10, 0, 0, 2)},
+		{"host.CORP.example.", net.IPv4(10, 0, 0, 2)},
+		{"host.dev.corp.example.", net.IPv4(10, 0, 0, 3)},
+		{"notcorp.example.", net.IPv4(10, 0, 0, 1)},
+		{"example.com.", net.IPv4(10, 0, 0, 1)},
+	}
+	for _, tc := range tests {
+		if ip, _ := testQuery(t, r, tc.name); !ip.Equal(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, ip)
+		}
+	}
+}
+
+func TestResolverFilter(t *testing.T) {
+	upstr := startTestUpstream(t, net.IPv4(10, 0, 0, 1))
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams:     []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: upstr.port()}},
+		Filter: NewFilter(
+			[]string{"tracker.example", "ads.example.com"},
+			[]string{"good.tracker.example"},
+			map[string][]net.IP{"router.lan": {net.IPv4(192, 168, 1, 1)}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	tests := []struct {
+		name     string
+		expected net.IP // nil - blocked
+	}{
+		{"tracker.example.", nil},
+		{"a.b.Tracker.example.", nil},
+		{"ads.example.com.", nil},
+		{"good.tracker.example.", net.IPv4(10, 0, 0, 1)},
+		{"x.good.tracker.example.", net.IPv4(10, 0, 0, 1)},
+		{"example.com.", net.IPv4(10, 0, 0, 1)},
+		{"router.lan.", net.IPv4(192, 168, 1, 1)},
+	}
+	for _, tc := range tests {
+		ip, _ := testQuery(t, r, tc.name)
+		if (tc.expected == nil && ip != nil) || (tc.expected != nil && !ip.Equal(tc.expected)) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, ip)
+		}
+	}
+
+	// filter update
+	r.SetFilter(nil)
+	if ip, _ := testQuery(t, r, "tracker.example."); !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("tracker.example: expected not blocked after filter removed, got %v", ip)
+	}
+}
+
+func TestResolverStats(t *testing.T) {
+	upstr := startTestUpstream(t, net.IPv4(10, 0, 0, 1))
+	sinkhole := startTestUpstream(t, net.IPv4zero)
+	stats := NewStatsCollector()
+
+	r, err := Start(Config{
+		ListenAddress: net.IPv4(127, 0, 0, 1),
+		Port:          -1,
+		Upstreams:     []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: upstr.port()}},
+		DomainRules:   []DomainRule{{Domain: "tracker.example", Upstreams: []Upstream{{Type: UpstreamPlain, Address: net.IPv4(127, 0, 0, 1), Port: sinkhole.port()}}}},
+		Filter:        NewFilter([]string{"ads.example"}, nil, nil),
+		Stats:         stats,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	for _, name := range []string{"example.com.", "example.com.", "ads.example.", "ads.example.", "a.tracker.example."} {
+		testQuery(t, r, name)
+	}
+
+	st := stats.Get(10)
+	if st.Queries != 5 || st.CacheHits != 1 || st.BlockedLocal != 2 || st.BlockedUpstream != 1 || st.Failed != 0 {
+		t.Errorf("unexpected counters: %+v", st)
+	}
+	if len(st.TopBlocked) != 2 || st.TopBlocked[0] != (DomainCount{Domain: "ads.example", Count: 2}) {
+		t.Errorf("unexpected top blocked domains: %v", st.TopBlocked)
+	}
+	if len(st.Upstreams) != 2 || st.Upstreams[0].Queries != 1 || st.Upstreams[0].Failures != 0 {
+		t.Errorf("unexpected upstream stats: %+v", st.Upstreams)
+	}
+
+	stats.Reset()
+	if st := stats.Get(10); st.Queries != 0 || len(st.TopBlocked) != 0 || len(st.Upstreams) != 0 {
+		t.Errorf("stats not reset: %+v", st)
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != dohContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", dohContentType)
+		w.Write(testAnswer(query, net.IPv4(10, 9, 8, 7), 60))
+	}))
+	defer srv.Close()
+
+	srvURL, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(srvURL.Port())
+
+	u, err := newUpstream(Upstream{Type: UpstreamDoH, Address: net.IPv4(127, 0, 0, 1), Template: "https://dns.example.com:" + strconv.Itoa(port) + "/dns-query{?dns}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trust the test server certificate
+	u.httpClient.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	defer u.close()
+
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 0x4321},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packet, _ := q.Pack()
+
+	resp, err := u.exchange(context.Background(), packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateResponse(resp, 0x4321); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseDoTTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		host     string
+		port     int
+	}{
+		{"dns.example.com", "dns.example.com", 853},
+		{"tls://dns.example.com", "dns.example.com", 853},
+		{"tls://dns.example.com:8853", "dns.example.com", 8853},
+		{"dns.example.com:8853", "dns.example.com", 8853},
+	}
+	for _, tc := range tests {
+		host, port, err := parseDoTTemplate(tc.template)
+		if err != nil || host != tc.host || port != tc.port {
+			t.Errorf("%s: unexpected result %s %d %v", tc.template, host, port, err)
+		}
+	}
+}
+
+// testStampField returns length-prefixed stamp field
+func testStampField(v []byte) []byte {
+	return append([]byte{byte(len(v))}, v...)
+}
+
+func testStamp(proto StampProtoType, fields ...[]byte) string {
+	bin := []byte{byte(proto)}
+	if proto != StampProtoTypeDNSCryptRelay {
+		bin = append(bin, make([]byte, 8)...) // props
+	}
+	for _, f := range fields {
+		bin = append(bin, f...)
+	}
+	return "sdns://" + base64.RawURLEncoding.EncodeToString(bin)
+}
+
+func TestParseStamp(t *testing.T) {
+	pk := make([]byte, 32)
+	dnscrypt := testStamp(StampProtoTypeDNSCrypt, testStampField([]byte("1.2.3.4:8443")), testStampField(pk), testStampField([]byte("2.dnscrypt-cert.example.com")))
+	dnscryptRelay := testStamp(StampProtoTypeDNSCryptRelay, testStampField([]byte("[2001:db8::1]")))
+	doh := testStamp(StampProtoTypeDoH, testStampField([]byte("5.6.7.8")), testStampField(nil), testStampField([]byte("dns.example.com")), testStampField([]byte("/dns-query")))
+	odohTarget := testStamp(StampProtoTypeODoHTarget, testStampField([]byte("odoh.example.com")), testStampField([]byte("/dns-query")))
+	odohRelay := testStamp(StampProtoTypeODoHRelay, testStampField([]byte("9.9.9.9")), testStampField(nil), testStampField([]byte("relay.example.com")), testStampField([]byte("/proxy")))
+
+	relay, server, err := ParseStamp(dnscrypt)
+	if err != nil || relay != nil || server.Proto != StampProtoTypeDNSCrypt || server.ServerAddress() != "1.2.3.4:8443" || server.ProviderName != "2.dnscrypt-cert.example.com" {
+		t.Errorf("DNSCrypt: unexpected result %+v %v", server, err)
+	}
+
+	relay, server, err = ParseStamp(dnscryptRelay + "/" + strings.TrimPrefix(dnscrypt, "sdns://"))
+	if err != nil || relay == nil || relay.ServerAddress() != "[2001:db8::1]:443" || server.Proto != StampProtoTypeDNSCrypt {
+		t.Errorf("DNSCrypt via relay: unexpected result %+v %+v %v", relay, server, err)
+	}
+
+	_, server, err = ParseStamp(doh)
+	if err != nil || server.Proto != StampProtoTypeDoH || server.URL() != "https://dns.example.com/dns-query" || !server.IP().Equal(net.IPv4(5, 6, 7, 8)) {
+		t.Errorf("DoH: unexpected result %+v %v", server, err)
+	}
+
+	relay, server, err = ParseStamp(odohRelay + "/" + strings.TrimPrefix(odohTarget, "sdns://"))
+	if err != nil || relay == nil || relay.URL() != "https://relay.example.com/proxy" || server.URL() != "https://odoh.example.com/dns-query" {
+		t.Errorf("ODoH: unexpected result %+v %+v %v", relay, server, err)
+	}
+
+	// wrong combinations
+	for _, s := range []string{
+		"https://dns.example.com",
+		dnscryptRelay,
+		odohRelay + "/" + strings.TrimPrefix(dnscrypt, "sdns://"),
+		dnscryptRelay + "/" + strings.TrimPrefix(odohTarget, "sdns://"),
+		dnscrypt + "x",
+	} {
+		if _, _, err := ParseStamp(s); err == nil {
+			t.Errorf("%s: error expected", s)
+		}
+	}
+}
+
+// testDnscryptServer is a DNSCrypt server which answers A queries with the defined IP address
+type testDnscryptServer struct {
+	conn        *net.UDPConn
+	providerPk  ed25519.PublicKey
+	resolverPk  *[32]byte
+	resolverSk  *[32]byte
+	clientMagic []byte
+	cert        []byte
+}
+
+func startTestDnscryptServer(t *testing.T, answer net.IP) *testDnscryptServer {
+	providerPk, providerSk, _ := ed25519.GenerateKey(rand.Reader)
+	resolverPk, resolverSk, _ := box.GenerateKey(rand.Reader)
+	s := &testDnscryptServer{providerPk: providerPk, resolverPk: resolverPk, resolverSk: resolverSk, clientMagic: []byte("12345678")}
+
+	signed := append(append([]byte(nil), resolverPk[:]...), s.clientMagic...)
+	signed = binary.BigEndian.AppendUint32(signed, 1) // serial
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(-time.Hour).Unix()))
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(time.Hour).Unix()))
+	s.cert = append([]byte(dnscryptCertMagic), 0x00, 0x01, 0x00, 0x00)
+	s.cert = append(s.cert, ed25519.Sign(providerSk, signed)...)
+	s.cert = append(s.cert, signed...)
+
+	var err error
+	if s.conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := s.conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if resp := s.handle(buf[:n], answer); resp != nil {
+				s.conn.WriteToUDP(resp, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() { s.conn.Close() })
+	return s
+}
+
+func (s *testDnscryptServer) handle(packet []byte, answer net.IP) []byte {
+	if !bytes.HasPrefix(packet, s.clientMagic) {
+		// plain DNS query for the certificate
+		var msg dnsmessage.Message
+		if err := msg.Unpack(packet); err != nil || len(msg.Questions) == 0 || msg.Questions[0].Type != dnsmessage.TypeTXT {
+			return nil
+		}
+		msg.Response = true
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.TXTResource{TXT: []string{string(s.cert)}},
+		}}
+		resp, _ := msg.Pack()
+		return resp
+	}
+
+	if len(packet) < 52 {
+		return nil
+	}
+	var clientPk [32]byte
+	copy(clientPk[:], packet[8:40])
+	var nonce [24]byte
+	copy(nonce[:], packet[40:52])
+	padded, ok := box.Open(nil, packet[52:], &nonce, &clientPk, s.resolverSk)
+	if !ok || len(padded) < dnscryptMinUdpQuerySize {
+		return nil
+	}
+	query := padded[:bytes.LastIndexByte(padded, 0x80)]
+
+	resp := append(testAnswer(query, answer, 60), 0x80)
+	resp = append(resp, make([]byte, 64-len(resp)%64)...)
+	rand.Read(nonce[12:])
+	ret := append([]byte(dnscryptResolverMagic), nonce[:]...)
+	return box.Seal(ret, resp, &nonce, &clientPk, s.resolverSk)
+}
+
+func (s *testDnscryptServer) stamp() string {
+	return testStamp(StampProtoTypeDNSCrypt, testStampField([]byte(s.conn.LocalAddr().String())), testStampField(s.providerPk), testStampField([]byte("2.dnscrypt-cert.example.com")))
+}
+
+// startTestDnscryptRelay starts Anonymized DNSCrypt relay
+func startTestDnscryptRelay(t *testing.T) (addr string, relayed *atomic.Int32) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed = &atomic.Int32{}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n < 28 || !bytes.Equal(buf[:10], dnscryptRelayMagic) {
+				continue
+			}
+			relayed.Add(1)
+			srv := &net.UDPAddr{IP: net.IP(append([]byte(nil), buf[10:26]...)), Port: int(binary.BigEndian.Uint16(buf[26:28]))}
+			c, err := net.DialUDP("udp", nil, srv)
+			if err != nil {
+				continue
+			}
+			c.SetDeadline(time.Now().Add(time.Second))
+			c.Write(buf[28:n])
+			resp := make([]byte, 1500)
+			if rn, err := c.Read(resp); err == nil {
+				conn.WriteToUDP(resp[:rn], addr)
+			}
+			c.Close()
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), relayed
+}
+
+func TestResolverDNSCrypt(t *testing.T) {
+	srv := startTestDnscryptServer(t, net.IPv4(10, 1, 2, 3))
+	relayAddr, relayed := startTestDnscryptRelay(t)
+
+	stamps := []string{
+		srv.stamp(),
+		testStamp(StampProtoTypeDNSCryptRelay, testStampField([]byte(relayAddr))) + "/" + strings.TrimPrefix(srv.stamp(), "sdns://"),
+	}
+	for i, stamp := range stamps {
+		r, err := Start(Config{ListenAddress: net.IPv4(127, 0, 0, 1), Port: -1, CacheSize: -1,
+			Upstreams: []Upstream{{Type: UpstreamDNSCrypt, Address: net.IPv4(127, 0, 0, 1), Template: stamp}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip, _ := testQuery(t, r, "example.com.")
+		r.Stop()
+		if !ip.Equal(net.IPv4(10, 1, 2, 3)) {
+			t.Errorf("stamp %d: unexpected answer %v", i, ip)
+		}
+	}
+	if relayed.Load() != 2 { // certificate request + query
+		t.Errorf("unexpected number of relayed packets: %d", relayed.Load())
+	}
+}
+
+// TestDnscryptXChaCha20 checks XChaCha20-Poly1305 encryption system against the values produced by libsodium
+// ('crypto_box_curve25519xchacha20poly1305_beforenm' and 'crypto_box_curve25519xchacha20poly1305_easy_afternm')
+func TestDnscryptXChaCha20(t *testing.T) {
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	var clientSk [32]byte
+	copy(clientSk[:], mustHex("0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"))
+	resolverPk := mustHex("5869aff450549732cbaaed5e5df9b30a6da31cb0e5742bad5ad4a1a768f1a67b")
+	expectedSharedKey := mustHex("477667c7653c9e341690ab1d6c6bbbd9c6178db822a19ef699d3a0239264384d")
+	expectedSealed := mustHex("984ab50d439c4b85a6b8a4d5171e4923af3a87f95e156c2aa84557669758b5cc7d4eaf6be2a862e8b70982878c193a0eab9a7b856bc1522f70fe1f8170003a3b5e1bf5f578780a48fdad647f7aa4fa3144e0b51c3245e2504be676eabbccb2dc71079e62fc")
+	message := []byte("The quick brown fox jumps over the lazy dog; DNSCrypt XChaCha20-Poly1305 test message")
+	var nonce [dnscryptNonceSize]byte
+	for i := range nonce {
+		nonce[i] = byte(100 + i)
+	}
+
+	providerPk, providerSk, _ := ed25519.GenerateKey(rand.Reader)
+	signed := append(append([]byte(nil), resolverPk...), []byte("12345678")...)
+	signed = binary.BigEndian.AppendUint32(signed, 1) // serial
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(-time.Hour).Unix()))
+	signed = binary.BigEndian.AppendUint32(signed, uint32(time.Now().Add(time.Hour).Unix()))
+	certBin := append([]byte(dnscryptCertMagic), 0x00, 0x02, 0x00, 0x00)
+	certBin = append(certBin, ed25519.Sign(providerSk, signed)...)
+	certBin = append(certBin, signed...)
+
+	c := &dnscryptClient{providerPk: providerPk, secretKey: &clientSk}
+	cert, err := c.parseCert(certBin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert.sharedKey[:], expectedSharedKey) {
+		t.Fatalf("unexpected shared key: %x", cert.sharedKey)
+	}
+	sealed := cert.seal(nil, message, &nonce)
+	if !bytes.Equal(sealed, expectedSealed) {
+		t.Fatalf("unexpected sealed message: %x", sealed)
+	}
+	if opened, ok := cert.open(nil, sealed, &nonce); !ok || !bytes.Equal(opened, message) {
+		t.Errorf("failed to open sealed message")
+	}
+	sealed[len(sealed)-1] ^= 0xff
+	if _, ok := cert.open(nil, sealed, &nonce); ok {
+		t.Errorf("modified message must not be opened")
+	}
+}
+
+// testOdohTarget returns ODoH target handler which answers A queries with the defined IP address
+func testOdohTarget(t *testing.T, answer net.IP) http.Handler {
+	skR, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	contents := binary.BigEndian.AppendUint16(nil, hpkeKemX25519HkdfSha256)
+	contents = binary.BigEndian.AppendUint16(contents, hpkeKdfHkdfSha256)
+	contents = binary.BigEndian.AppendUint16(contents, hpkeAeadAes128Gcm)
+	contents = binary.BigEndian.AppendUint16(contents, uint16(len(skR.PublicKey().Bytes())))
+	contents = append(contents, skR.PublicKey().Bytes()...)
+	configs := binary.BigEndian.AppendUint16(nil, odohConfigVersion)
+	configs = binary.BigEndian.AppendUint16(configs, uint16(len(contents)))
+	configs = append(binary.BigEndian.AppendUint16(nil, uint16(len(configs)+len(contents))), append(configs, contents...)...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == odohConfigsPath {
+			w.Write(configs)
+			return
+		}
+		msg, _ := io.ReadAll(req.Body)
+		// <type> <key_id len> <key_id> <encrypted len> <enc (32)> <ct>
+		keyIDLen := int(binary.BigEndian.Uint16(msg[1:3]))
+		enc := msg[5+keyIDLen : 5+keyIDLen+32]
+		pkE, _ := ecdh.X25519().NewPublicKey(enc)
+		dh, _ := skR.ECDH(pkE)
+		sharedSecret, _ := hpkeSharedSecret(dh, enc, skR.PublicKey().Bytes())
+		ctx, _ := hpkeKeySchedule(sharedSecret, hpkeAeadAes128Gcm, []byte("odoh query"))
+		queryPlain, err := ctx.aead.Open(nil, ctx.baseNonce, msg[5+keyIDLen+32:], msg[:3+keyIDLen])
+		if err != nil {
+			t.Errorf("ODoH target: failed to decrypt query: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(queryPlain)%odohQueryPaddingBlockSize != 0 {
+			t.Errorf("ODoH target: query not padded (%d bytes)", len(queryPlain))
+		}
+
+		respPlain := testAnswer(queryPlain[2:2+binary.BigEndian.Uint16(queryPlain[0:2])], answer, 60)
+		respPlain = append(binary.BigEndian.AppendUint16(nil, uint16(len(respPlain))), append(respPlain, 0, 0)...)
+		respNonce := make([]byte, 16)
+		rand.Read(respNonce)
+		aead, nonce, _ := odohResponseAead(ctx, queryPlain, respNonce)
+		aad := odohAad(odohMessageTypeResponse, respNonce)
+		ct := aead.Seal(nil, nonce, respPlain, aad)
+
+		w.Header().Set("Content-Type", odohContentType)
+		w.Write(append(binary.BigEndian.AppendUint16(aad, uint16(len(ct))), ct...))
+	})
+}
+
+func TestResolverODoH(t *testing.T) {
+	target := testOdohTarget(t, net.IPv4(10, 3, 2, 1))
+	targetSrv := httptest.NewTLSServer(target)
+	defer targetSrv.Close()
+
+	var relayed atomic.Int32
+	relaySrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/proxy" || req.URL.Query().Get("targetpath") != "/dns-query" || req.Header.Get("Content-Type") != odohContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		relayed.Add(1)
+		target.ServeHTTP(w, req)
+	}))
+	defer relaySrv.Close()
+
+	targetURL, _ := url.Parse(targetSrv.URL)
+	relayURL, _ := url.Parse(relaySrv.URL)
+	stamp := testStamp(StampProtoTypeODoHRelay, testStampField([]byte(relayURL.Host)), testStampField(nil), testStampField([]byte("relay.example.com:"+relayURL.Port())), testStampField([]byte("/proxy"))) + "/" +
+		strings.TrimPrefix(testStamp(StampProtoTypeODoHTarget, testStampField([]byte("odoh.example.com:"+targetURL.Port())), testStampField([]byte("/dns-query"))), "sdns://")
+
+	u, err := newUpstream(Upstream{Type: UpstreamODoH, Address: net.IPv4(127, 0, 0, 1), Template: stamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trust the test servers certificates
+	u.odoh.relayHttpClient.Transport.(*http.Transport).TLSClientConfig = relaySrv.Client().Transport.(*http.Transport).TLSClientConfig
+	u.odoh.targetHttpClient.Transport.(*http.Transport).TLSClientConfig = targetSrv.Client().Transport.(*http.Transport).TLSClientConfig
+	defer u.close()
+
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 0x1234},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packet, _ := q.Pack()
+
+	resp, err := u.exchange(context.Background(), packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateResponse(resp, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil || len(msg.Answers) != 1 || msg.Answers[0].Body.(*dnsmessage.AResource).A != [4]byte{10, 3, 2, 1} {
+		t.Errorf("unexpected response: %+v %v", msg, err)
+	}
+	if relayed.Load() != 1 {
+		t.Errorf("query was not sent via relay")
+	}
+}
+
+// TestHpkeRFC9180 checks HPKE implementation against the test vectors of RFC 9180
+// (Appendix A.1.1 and A.2.1: base mode, DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM / ChaCha20Poly1305)
+func TestHpkeRFC9180(t *testing.T) {
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	tests := []struct {
+		aeadID                                                        uint16
+		skEm, pkRm, enc, sharedSecret, key, baseNonce, exporterSecret string
+		ct, exported                                                  string
+	}{
+		{ // A.1.1
+			aeadID:         hpkeAeadAes128Gcm,
+			skEm:           "52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736",
+			pkRm:           "3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d",
+			enc:            "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+			sharedSecret:   "fe0e18c9f024ce43799ae393c7e8fe8fce9d218875e8227b0187c04e7d2ea1fc",
+			key:            "4531685d41d65f03dc48f6b8302c05b0",
+			baseNonce:      "56d890e5accaaf011cff4b7d",
+			exporterSecret: "45ff1c2e220db587171952c0592d5f5ebe103f1561a2614e38f2ffd47e99e3f8",
+			ct:             "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+			exported:       "e9e43065102c3836401bed8c3c3c75ae46be1639869391d62c61f1ec7af54931",
+		},
+		{ // A.2.1
+			aeadID:         hpkeAeadChaCha20Poly,
+			skEm:           "f4ec9b33b792c372c1d2c2063507b684ef925b8c75a42dbcbf57d63ccd381600",
+			pkRm:           "4310ee97d88cc1f088a5576c77ab0cf5c3ac797f3d95139c6c84b5429c59662a",
+			enc:            "1afa08d3dec047a643885163f1180476fa7ddb54c6a8029ea33f95796bf2ac4a",
+			sharedSecret:   "0bbe78490412b4bbea4812666f7916932b828bba79942424abb65244930d69a7",
+			key:            "ad2744de8e17f4ebba575b3f5f5a8fa1f69c2a07f6e7500bc60ca6e3e3ec1c91",
+			baseNonce:      "5c4d98150661b848853b547f",
+			exporterSecret: "a3b010d4994890e2c6968a36f64470d3c824c8f5029942feb11e7a74b2921922",
+			ct:             "1c5250d8034ec2b784ba2cfd69dbdb8af406cfe3ff938e131f0def8c8b60b4db21993c62ce81883d2dd1b51a28",
+			exported:       "5acb09211139c43b3090489a9da433e8a30ee7188ba8b0a9a1ccf0c229283e53",
+		},
+	}
+	info := mustHex("4f6465206f6e2061204772656369616e2055726e") // "Ode on a Grecian Urn"
+	pt := mustHex("4265617574792069732074727574682c20747275746820626561757479")
+	aad := mustHex("436f756e742d30") // "Count-0"
+
+	for _, test := range tests {
+		skE, err := ecdh.X25519().NewPrivateKey(mustHex(test.skEm))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkR, err := ecdh.X25519().NewPublicKey(mustHex(test.pkRm))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, ctx, err := hpkeSetupBaseS(skE, pkR, test.aeadID, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, mustHex(test.enc)) {
+			t.Errorf("AEAD 0x%04x: unexpected enc: %x", test.aeadID, enc)
+		}
+		dh, _ := skE.ECDH(pkR)
+		if sharedSecret, err := hpkeSharedSecret(dh, enc, pkR.Bytes()); err != nil || !bytes.Equal(sharedSecret, mustHex(test.sharedSecret)) {
+			t.Errorf("AEAD 0x%04x: unexpected shared secret: %x (%v)", test.aeadID, sharedSecret, err)
+		}
+		if !bytes.Equal(ctx.baseNonce, mustHex(test.baseNonce)) || !bytes.Equal(ctx.exporterSecret, mustHex(test.exporterSecret)) || ctx.keySize != len(mustHex(test.key)) {
+			t.Errorf("AEAD 0x%04x: unexpected context: base_nonce=%x exporter_secret=%x", test.aeadID, ctx.baseNonce, ctx.exporterSecret)
+		}
+		// sequence number 0: the nonce is equal to base_nonce
+		if ct := ctx.aead.Seal(nil, ctx.baseNonce, pt, aad); !bytes.Equal(ct, mustHex(test.ct)) {
+			t.Errorf("AEAD 0x%04x: unexpected ciphertext: %x", test.aeadID, ct)
+		}
+		if exported, err := ctx.export([]byte("TestContext"), 32); err != nil || !bytes.Equal(exported, mustHex(test.exported)) {
+			t.Errorf("AEAD 0x%04x: unexpected exported value: %x (%v)", test.aeadID, exported, err)
+		}
+	}
+}
+
+// TestOdohKnownAnswer checks ODoH (RFC 9230) messages against the values produced by the independent implementation
+// (Python: libsodium X25519, hashlib HKDF-SHA256, OpenSSL AES-128-GCM) which reproduces RFC 9180 test vectors.
+// The keys are from RFC 9180 Appendix A.1.1 (skEm - ephemeral key of the client; skRm - key of the target).
+func TestOdohKnownAnswer(t *testing.T) {
+	mustHex := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	configs := mustHex("002c0001002800200001000100203948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d")
+	expectedKeyID := mustHex("9e8dcd70b0b660258285b685197740e491cbdd8101b1783affdfeba52e09bc79")
+	query := mustHex("000001000001000000000000076578616d706c6503636f6d0000010001") // example.com A
+	expectedQueryMsg := mustHex("0100209e8dcd70b0b660258285b685197740e491cbdd8101b1783affdfeba52e09bc7900b037fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431ad7537701ae754a322204196c2fbaeff944b3cc630515f8f31435a0d7cb7c82abbf593fad2a6fa2fa65c05c17346361aecdff9f51cb993e48581301d368272bf2003491d600290bd35f5218d6320d05fd2d465e93204b6d0e58cbf25b789d1d4bff279f4e57071f4a49541c6d511ac78d0998b139b52dadb85783ff56c6d260d45d7d9777d9a261a774f1fda83543c10")
+	// response_nonce: 000102...0f
+	responseMsg := mustHex("020010000102030405060708090a0b0c0d0e0f0041ea62f4cd38a62d3e6cc91ee0312bda48e8df28d479783412097a8673b52926b894cfc30e5716f2b0a9153f4bd30f14979ba74454221417b9da36457ab279ffbd5e")
+	expectedResponse := mustHex("000081800001000100000000076578616d706c6503636f6d0000010001c00c000100010000003c00045db8d822")
+
+	cfg, err := odohParseConfigs(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.aeadID != hpkeAeadAes128Gcm || !bytes.Equal(cfg.keyID, expectedKeyID) {
+		t.Fatalf("unexpected configuration: aead=0x%04x key_id=%x", cfg.aeadID, cfg.keyID)
+	}
+
+	skE, err := ecdh.X25519().NewPrivateKey(mustHex("52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ctx, queryPlain, err := odohEncryptQuery(cfg, skE, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg, expectedQueryMsg) {
+		t.Fatalf("unexpected query message: %x", msg)
+	}
+
+	resp, err := odohDecryptResponse(ctx, queryPlain, responseMsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, expectedResponse) {
+		t.Errorf("unexpected response: %x", resp)
+	}
+	responseMsg[len(responseMsg)-1] ^= 0xff
+	if _, err := odohDecryptResponse(ctx, queryPlain, responseMsg); err == nil {
+		t.Errorf("modified response must not be decrypted")
+	}
+}
@@ -0,0 +1,129 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseStamp parses DNS stamp of the server ("sdns://SERVER") or relay and server ("sdns://RELAY/SERVER").
+// Supported server protocols: DNSCrypt, DoH, ODoH target.
+// Relays: DNSCrypt relay (anonymized DNSCrypt), ODoH relay (ODoH proxy).
+// The stamps are decoded by the parser from 'dnsstamps.go'.
+//
+// Returns relay stamp (nil - if relay not defined) and server stamp.
+func ParseStamp(stamp string) (relay *ServerStamp, server ServerStamp, err error) {
+	stamp = strings.TrimSpace(stamp)
+	if strings.Contains(strings.TrimPrefix(stamp, "sdns://"), "/") {
+		r, s, err := NewRelayAndServerStampFromString(stamp)
+		if err != nil {
+			return nil, s, err
+		}
+		switch {
+		case r.Proto == StampProtoTypeDNSCryptRelay && s.Proto == StampProtoTypeDNSCrypt:
+		case r.Proto == StampProtoTypeODoHRelay && s.Proto == StampProtoTypeODoHTarget:
+		default:
+			return nil, s, fmt.Errorf("%s is not applicable for %s server", r.Proto.String(), s.Proto.String())
+		}
+		relay, server = &r, s
+	} else {
+		if server, err = NewServerStampFromString(stamp); err != nil {
+			return nil, server, err
+		}
+		if server.isRelay() {
+			return nil, server, errors.New("relay stamp is defined instead of the server stamp")
+		}
+	}
+
+	if server.Proto == StampProtoTypeDNSCrypt && len(server.ServerPk) != 32 {
+		return nil, server, errors.New("invalid DNS stamp (bad public key size)")
+	}
+	return relay, server, nil
+}
+
+// StampDescription returns short human-readable description of the DNS stamp
+// (e.g. "DNSCrypt 2.dnscrypt-cert.example.com via 1.2.3.4:443")
+func StampDescription(stamp string) string {
+	relay, server, err := ParseStamp(stamp)
+	if err != nil {
+		return "invalid stamp"
+	}
+	var ret string
+	switch server.Proto {
+	case StampProtoTypeDNSCrypt:
+		ret = fmt.Sprintf("%s %s", server.Proto.String(), server.ProviderName)
+	default:
+		ret = fmt.Sprintf("%s %s", server.Proto.String(), server.URL())
+	}
+	if relay != nil {
+		if relay.Proto == StampProtoTypeODoHRelay {
+			ret += " via " + relay.URL()
+		} else {
+			ret += " via " + relay.ServerAddress()
+		}
+	}
+	return ret
+}
+
+// IP returns IP address of the server (nil - if not defined by stamp, e.g. DoH, ODoH)
+func (s ServerStamp) IP() net.IP {
+	host, _, err := net.SplitHostPort(s.ServerAddrStr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Port returns port of the server (DefaultPort - if not defined by stamp)
+func (s ServerStamp) Port() int {
+	_, p, err := net.SplitHostPort(s.ServerAddrStr)
+	if err != nil {
+		return DefaultPort
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return DefaultPort
+	}
+	return port
+}
+
+// ServerAddress returns "IP:port" of the server ("" - if IP address not defined by stamp)
+func (s ServerStamp) ServerAddress() string {
+	if s.IP() == nil {
+		return ""
+	}
+	return hostPort(s.IP(), s.Port())
+}
+
+// URL returns URL of DoH or ODoH server (e.g. "https://dns.example.com/dns-query")
+func (s ServerStamp) URL() string {
+	return "https://" + s.ProviderName + s.Path
+}
+
+func (s ServerStamp) isRelay() bool {
+	return s.Proto == StampProtoTypeDNSCryptRelay || s.Proto == StampProtoTypeODoHRelay
+}
@@ -0,0 +1,263 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// max number of distinct blocked domains to keep counters for (new domains are not counted when the limit is reached)
+const statsMaxBlockedDomains = 4096
+
+// DomainCount - the number of queries for the domain
+type DomainCount struct {
+	Domain string
+	Count  uint64
+}
+
+// UpstreamStats - the statistics of the upstream DNS server
+type UpstreamStats struct {
+	Upstream   string        // upstream description (see Upstream.String())
+	Queries    uint64        // number of queries forwarded to the upstream
+	Failures   uint64        // number of failed queries (timeout, bad response ...)
+	AvgLatency time.Duration // average latency of successful queries
+	MaxLatency time.Duration // max latency of successful queries
+}
+
+// Stats - the snapshot of DNS query statistics
+type Stats struct {
+	Since           time.Time // start of the statistics collection
+	Queries         uint64    // total number of processed queries
+	CacheHits       uint64    // number of queries answered from cache
+	BlockedLocal    uint64    // number of queries blocked by the local filter (see Filter)
+	BlockedUpstream uint64    // number of queries blocked by the upstream server (answer 0.0.0.0 or ::)
+	NxDomain        uint64    // number of NXDOMAIN answers received from upstream servers
+	Failed          uint64    // number of queries not resolved (all upstreams failed)
+	TopBlocked      []DomainCount
+	Upstreams       []UpstreamStats
+}
+
+type upstreamCounters struct {
+	queries      uint64
+	failures     uint64
+	latencyTotal time.Duration
+	latencyMax   time.Duration
+}
+
+// StatsCollector collects DNS query statistics.
+// The collector can be shared by multiple resolver instances (e.g. when resolver restarted on DNS configuration change).
+type StatsCollector struct {
+	mutex     sync.Mutex
+	stats     Stats
+	blocked   map[string]uint64
+	upstreams map[string]*upstreamCounters
+	order     []string // upstreams in order of the first use
+}
+
+// NewStatsCollector creates new statistics collector
+func NewStatsCollector() *StatsCollector {
+	c := &StatsCollector{}
+	c.Reset()
+	return c
+}
+
+// Reset erases all collected statistics
+func (c *StatsCollector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats = Stats{Since: time.Now()}
+	c.blocked = make(map[string]uint64)
+	c.upstreams = make(map[string]*upstreamCounters)
+	c.order = nil
+}
+
+// Get returns the snapshot of collected statistics
+// 'topBlockedCount' - max number of the most blocked domains to return
+func (c *StatsCollector) Get(topBlockedCount int) Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := c.stats
+
+	ret.TopBlocked = make([]DomainCount, 0, len(c.blocked))
+	for d, cnt := range c.blocked {
+		ret.TopBlocked = append(ret.TopBlocked, DomainCount{Domain: d, Count: cnt})
+	}
+	sort.Slice(ret.TopBlocked, func(i, j int) bool {
+		if ret.TopBlocked[i].Count == ret.TopBlocked[j].Count {
+			return ret.TopBlocked[i].Domain < ret.TopBlocked[j].Domain
+		}
+		return ret.TopBlocked[i].Count > ret.TopBlocked[j].Count
+	})
+	if topBlockedCount >= 0 && len(ret.TopBlocked) > topBlockedCount {
+		ret.TopBlocked = ret.TopBlocked[:topBlockedCount]
+	}
+
+	for _, name := range c.order {
+		u := c.upstreams[name]
+		us := UpstreamStats{Upstream: name, Queries: u.queries, Failures: u.failures, MaxLatency: u.latencyMax}
+		if succeeded := u.queries - u.failures; succeeded > 0 {
+			us.AvgLatency = u.latencyTotal / time.Duration(succeeded)
+		}
+		ret.Upstreams = append(ret.Upstreams, us)
+	}
+	return ret
+}
+
+func (c *StatsCollector) onQuery() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Queries++
+}
+
+func (c *StatsCollector) onCacheHit() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.CacheHits++
+}
+
+func (c *StatsCollector) onFailed() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Failed++
+}
+
+func (c *StatsCollector) onBlocked(name string, isLocal bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if isLocal {
+		c.stats.BlockedLocal++
+	} else {
+		c.stats.BlockedUpstream++
+	}
+	name = normalizeDomain(name)
+	if _, ok := c.blocked[name]; ok || len(c.blocked) < statsMaxBlockedDomains {
+		c.blocked[name]++
+	}
+}
+
+func (c *StatsCollector) onNxDomain() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.NxDomain++
+}
+
+func (c *StatsCollector) onUpstreamResult(u Upstream, latency time.Duration, failed bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := u.String()
+	cnt, ok := c.upstreams[name]
+	if !ok {
+		cnt = &upstreamCounters{}
+		c.upstreams[name] = cnt
+		c.order = append(c.order, name)
+	}
+	cnt.queries++
+	if failed {
+		cnt.failures++
+		return
+	}
+	cnt.latencyTotal += latency
+	if latency > cnt.latencyMax {
+		cnt.latencyMax = latency
+	}
+}
+
+// onResponse analyzes the upstream response: detects NXDOMAIN and blocked (sinkholed) answers
+func (c *StatsCollector) onResponse(q dnsmessage.Question, resp []byte) {
+	if c == nil {
+		return
+	}
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return
+	}
+	if hdr.RCode == dnsmessage.RCodeNameError {
+		c.onNxDomain()
+		return
+	}
+	if isSinkholeAnswer(&p) {
+		c.onBlocked(q.Name.String(), false)
+	}
+}
+
+// isSinkholeAnswer returns true if the response contains A/AAAA answers and all of them are
+// unspecified addresses (0.0.0.0 or ::). This is the way the DNS-based blockers (e.g. AntiTracker) respond to blocked names.
+func isSinkholeAnswer(p *dnsmessage.Parser) bool {
+	if err := p.SkipAllQuestions(); err != nil {
+		return false
+	}
+	hasAddresses := false
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			break
+		}
+		switch h.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil || !net.IP(r.A[:]).IsUnspecified() {
+				return false
+			}
+			hasAddresses = true
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil || !net.IP(r.AAAA[:]).IsUnspecified() {
+				return false
+			}
+			hasAddresses = true
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return false
+			}
+		}
+	}
+	return hasAddresses
+}
@@ -0,0 +1,351 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	dotDefaultPort  = 853
+	dohDefaultPort  = 443
+	dotMaxIdleConns = 4
+	dohContentType  = "application/dns-message"
+)
+
+type upstream struct {
+	cfg Upstream
+
+	mutex    sync.Mutex
+	failedAt time.Time // time of the last failure (zero - if the last query succeeded)
+	failures int       // number of consecutive failures (see isHealthy())
+	lastErr  error     // the last failure reason
+	latency  time.Duration
+
+	// DoT
+	dotAddr   string
+	tlsConfig *tls.Config
+	dotConns  chan *tls.Conn // idle connections
+
+	// DoH
+	dohURL     string
+	httpClient *http.Client
+
+	// DNSCrypt
+	dnscrypt *dnscryptClient
+
+	// ODoH
+	odoh *odohClient
+}
+
+func newUpstream(cfg Upstream) (*upstream, error) {
+	if cfg.Address == nil {
+		return nil, fmt.Errorf("server address not defined")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	u := &upstream{cfg: cfg}
+
+	switch cfg.Type {
+	case UpstreamPlain:
+	case UpstreamDoT:
+		host, port, err := parseDoTTemplate(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		if len(host) == 0 {
+			host = cfg.Address.String()
+		}
+		u.dotAddr = hostPort(cfg.Address, port)
+		u.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12, ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+		u.dotConns = make(chan *tls.Conn, dotMaxIdleConns)
+	case UpstreamDoH:
+		dohURL, err := parseDoHTemplate(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		port := dohDefaultPort
+		if p := dohURL.Port(); len(p) > 0 {
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("bad DoH port '%s'", p)
+			}
+		}
+		u.dohURL = dohURL.String()
+
+		// Never resolve the DoH server host name: always connect to the defined IP address
+		u.httpClient = newHttpClientForAddress(hostPort(cfg.Address, port), dohURL.Hostname())
+	case UpstreamDNSCrypt, UpstreamODoH:
+		relay, stamp, err := ParseStamp(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Type == UpstreamDNSCrypt {
+			u.dnscrypt, err = newDnscryptClient(cfg.Address, stamp, relay)
+		} else {
+			u.odoh, err = newOdohClient(cfg.Address, stamp, relay)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported upstream type %d", cfg.Type)
+	}
+	return u, nil
+}
+
+// parseDoTTemplate parses DoT server name in formats: "host", "host:port", "tls://host[:port]"
+func parseDoTTemplate(template string) (host string, port int, err error) {
+	template = strings.TrimPrefix(strings.TrimSpace(template), "tls://")
+	template = strings.TrimSuffix(template, "/")
+	port = dotDefaultPort
+	if h, p, err := net.SplitHostPort(template); err == nil {
+		if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+			return "", 0, fmt.Errorf("bad DoT port '%s'", p)
+		}
+		template = h
+	}
+	return template, port, nil
+}
+
+// parseDoHTemplate parses DoH URL template (RFC 8484); the "{?dns}" variable is removed (POST requests are in use)
+func parseDoHTemplate(template string) (*url.URL, error) {
+	template = strings.TrimSuffix(strings.TrimSpace(template), "{?dns}")
+	u, err := url.Parse(template)
+	if err != nil {
+		return nil, fmt.Errorf("bad DoH template: %w", err)
+	}
+	if u.Scheme != "https" || len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("bad DoH template '%s' (expected 'https://host/path')", template)
+	}
+	return u, nil
+}
+
+func (u *upstream) setFailed(err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.failedAt = time.Now()
+	u.failures++
+	u.lastErr = err
+}
+
+func (u *upstream) setSucceeded(latency time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.failedAt = time.Time{}
+	u.failures = 0
+	u.lastErr = nil
+	u.latency = latency
+}
+
+// isHealthy returns false if the upstream failed several times in a row (it is healthy again after the first success)
+func (u *upstream) isHealthy() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.failures < unhealthyFailuresThreshold
+}
+
+func (u *upstream) isRecentlyFailed() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return !u.failedAt.IsZero() && time.Since(u.failedAt) < upstreamRetryDelay
+}
+
+func (u *upstream) close() {
+	if u.dotConns != nil {
+		for {
+			select {
+			case c := <-u.dotConns:
+				c.Close()
+			default:
+				return
+			}
+		}
+	}
+	if u.httpClient != nil {
+		u.httpClient.CloseIdleConnections()
+	}
+	if u.odoh != nil {
+		u.odoh.close()
+	}
+}
+
+func (u *upstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	switch u.cfg.Type {
+	case UpstreamDoT:
+		return u.exchangeDoT(ctx, query)
+	case UpstreamDoH:
+		return u.exchangeDoH(ctx, query)
+	case UpstreamDNSCrypt:
+		return u.dnscrypt.exchange(ctx, query)
+	case UpstreamODoH:
+		return u.odoh.exchange(ctx, query)
+	default:
+		return u.exchangePlain(ctx, query)
+	}
+}
+
+// exchangePlain sends the query over UDP; TCP is in use when the response is truncated
+func (u *upstream) exchangePlain(ctx context.Context, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", hostPort(u.cfg.Address, u.cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setDeadline(ctx, conn)
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	queryID := binary.BigEndian.Uint16(query[0:2])
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var p dnsmessage.Parser
+		hdr, err := p.Start(buf[:n])
+		if err != nil || hdr.ID != queryID || !hdr.Response {
+			continue // not our response
+		}
+		if hdr.Truncated {
+			return u.exchangeTCP(ctx, query)
+		}
+		return append([]byte(nil), buf[:n]...), nil
+	}
+}
+
+func (u *upstream) exchangeTCP(ctx context.Context, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", hostPort(u.cfg.Address, u.cfg.Port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	setDeadline(ctx, conn)
+
+	if err := writeTcpMessage(conn, query); err != nil {
+		return nil, err
+	}
+	return readTcpMessage(conn)
+}
+
+func (u *upstream) exchangeDoT(ctx context.Context, query []byte) ([]byte, error) {
+	// try to use idle connection first (it can be already closed by server: in this case retry with new connection)
+	select {
+	case conn := <-u.dotConns:
+		if resp, err := dotExchangeConn(ctx, conn, query); err == nil {
+			u.dotPutIdle(conn)
+			return resp, nil
+		}
+		conn.Close()
+	default:
+	}
+
+	d := tls.Dialer{Config: u.tlsConfig}
+	c, err := d.DialContext(ctx, "tcp", u.dotAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn := c.(*tls.Conn)
+	resp, err := dotExchangeConn(ctx, conn, query)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	u.dotPutIdle(conn)
+	return resp, nil
+}
+
+func (u *upstream) dotPutIdle(conn *tls.Conn) {
+	conn.SetDeadline(time.Time{})
+	select {
+	case u.dotConns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func dotExchangeConn(ctx context.Context, conn *tls.Conn, query []byte) ([]byte, error) {
+	setDeadline(ctx, conn)
+	if err := writeTcpMessage(conn, query); err != nil {
+		return nil, err
+	}
+	return readTcpMessage(conn)
+}
+
+// exchangeDoH sends the query using POST request (RFC 8484)
+func (u *upstream) exchangeDoH(ctx context.Context, query []byte) ([]byte, error) {
+	// RFC 8484: DNS ID should be 0 (cache friendly); the original ID is restored in the response
+	q := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(q[0:2], 0)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.dohURL, bytes.NewReader(q))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 12 {
+		return nil, fmt.Errorf("bad response size (%d bytes)", len(body))
+	}
+	copy(body[0:2], query[0:2])
+	return body, nil
+}
+
+func setDeadline(ctx context.Context, conn net.Conn) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+}
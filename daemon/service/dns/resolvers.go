@@ -3,149 +3,235 @@ package dns
 import (
 	"fmt"
 	"net"
-	"path"
 	"sync"
+	"time"
 
-	"github.com/ivpn/desktop-app/daemon/service/dns/dnscryptproxy"
-	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/dns/resolver"
 )
 
 var (
-	dnsCryptProxyMutex     sync.Mutex
-	dnsCryptProxyInstances []*dnscryptproxy.DnsCryptProxy
+	localResolverMutex sync.Mutex
+	localResolver      *resolver.Resolver
+	localFilter        *resolver.Filter // local filtering rules (block lists, allowlist, static hosts)
+
+	answersNotifierMutex sync.Mutex
+	answersNotifier      func(name string, ips []net.IP, ttl time.Duration)
 )
 
+// SetAnswersNotifier registers the function which is called for each answer of the local resolver
+// which contains IP addresses of the queried name (the function is called before the answer is sent to the client).
+// While the function is registered (not nil) - the local resolver is in use for all DNS configurations.
+// Note: the DNS configuration must be re-applied to start/stop the local resolver.
+func SetAnswersNotifier(f func(name string, ips []net.IP, ttl time.Duration)) {
+	answersNotifierMutex.Lock()
+	defer answersNotifierMutex.Unlock()
+	answersNotifier = f
+}
+
+// isAnswersNotifierSet returns true if the answers notifier is registered (see SetAnswersNotifier)
+func isAnswersNotifierSet() bool {
+	answersNotifierMutex.Lock()
+	defer answersNotifierMutex.Unlock()
+	return answersNotifier != nil
+}
+
+func onAnswer(name string, ips []net.IP, ttl time.Duration) {
+	answersNotifierMutex.Lock()
+	f := answersNotifier
+	answersNotifierMutex.Unlock()
+
+	if f != nil {
+		f(name, ips, ttl)
+	}
+}
+
+// SetFilter sets the local DNS filtering rules:
+//   - blocked - blocked domains (including subdomains)
+//   - allowed - domains which are never blocked (including subdomains)
+//   - hosts - static hosts entries
+//
+// The rules are enforced by the local resolver: if it is running - the rules are updated immediately,
+// otherwise the DNS configuration must be re-applied to start the local resolver.
+func SetFilter(blocked, allowed []string, hosts map[string][]net.IP) {
+	f := resolver.NewFilter(blocked, allowed, hosts)
+	if f.IsEmpty() {
+		f = nil
+	}
+
+	localResolverMutex.Lock()
+	defer localResolverMutex.Unlock()
+
+	localFilter = f
+	if localResolver != nil {
+		localResolver.SetFilter(f)
+	}
+}
+
+// IsFilterEnabled returns true if the local DNS filtering rules are defined
+// (the local resolver is required to enforce them)
+func IsFilterEnabled() bool {
+	localResolverMutex.Lock()
+	defer localResolverMutex.Unlock()
+	return localFilter != nil
+}
+
 // ResolversSetup initializes DNS configuration for both plain and encrypted DNS servers.
-// For encrypted DNS (DoH), it starts local dnscrypt-proxy instances as local resolvers.
-// For plain DNS servers, it returns them unchanged.
+// It starts the in-process DNS resolver (listening on a loopback address) which forwards the queries
+// to the configured servers (plain DNS, DoH, DoT, DNSCrypt or ODoH) with caching and failover in the order of preference.
+// Queries for the domains defined by split DNS rules (dnsCfg.DomainRules) are forwarded to the rule's servers.
+// The local filtering rules (see SetFilter) are applied before forwarding. The query statistics are collected (see GetStats).
+// The servers are periodically probed: when all of them are unreachable - the fallback DNS server (see DnsSettings.WithFallback)
+// is used until one of the servers recovers (see GetResolversHealth and SetFailoverNotifier).
 //
 // Parameters:
 //   - dnsCfg: DNS configuration containing servers with various encryption types
 //
 // Returns:
 //   - []DnsServerConfig: List of DNS server configurations ready to apply as system resolvers.
-//     This includes plain DNS servers and local dnscrypt-proxy listeners (127.0.0.x) for encrypted DNS.
-//   - error: Error if configuration creation or dnscrypt-proxy startup fails
+//     It contains the single local resolver address (127.0.0.x).
+//   - error: Error if configuration is wrong or the local resolver failed to start
 func ResolversSetup(dnsCfg DnsSettings) ([]DnsServerConfig, error) {
-	// return slice of DNS server configs
-	ret := make([]DnsServerConfig, 0, len(dnsCfg.Servers))
-	dnsCryptConfigs := make([]*dnscryptproxy.Config, 0, len(dnsCfg.Servers))
-
-	nextResolverIP := net.IPv4(127, 0, 0, 1)
-
+	upstreams := make([]resolver.Upstream, 0, len(dnsCfg.Servers))
 	for _, svr := range dnsCfg.Servers {
-
-		if svr.Encryption == EncryptionNone {
-			// plain DNS server - return as is (only one server must be in this group)
-			ret = append(ret, svr)
+		if svr.IsEmpty() {
 			continue
 		}
-		if svr.Encryption != EncryptionDnsOverHttps {
-			return nil, fmt.Errorf("unsupported DNS encryption type %d", svr.Encryption)
-		}
-		// DoH servers - start dnscrypt-proxy instance for this group
-
-		// Get free local address for dnscrypt-proxy listener
-		localIp, err := dnscryptproxy.GetFreeLocalAddressForDNS(nextResolverIP)
+		u, err := toResolverUpstream(svr)
 		if err != nil {
-			log.Warning("Failed to get free local address for dnscrypt-proxy: ", err)
-			// Failed to get free local address for dnscrypt-proxy.
-			// Anyway, try to use nextResolverIP
-			localIp = nextResolverIP
+			return nil, err
 		}
-		// increment last byte for next resolver
-		nextResolverIP = net.IPv4(127, 0, 0, localIp.To4()[3]+1)
+		upstreams = append(upstreams, u)
+	}
 
-		// add local dnscrypt-proxy listener as a system DNS server
-		ret = append(ret, DnsServerConfig{Address: localIp.String()})
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no usable DNS servers configured")
+	}
 
-		cfg, err := createDnscryptProxyConfig(localIp, []DnsServerConfig{svr})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create dnscrypt-proxy config: %w", err)
+	rules := make([]resolver.DomainRule, 0, len(dnsCfg.DomainRules))
+	for _, r := range dnsCfg.DomainRules {
+		rule := resolver.DomainRule{Domain: r.Domain}
+		for _, ip := range r.ServersIPs() {
+			rule.Upstreams = append(rule.Upstreams, resolver.Upstream{Type: resolver.UpstreamPlain, Address: ip})
+		}
+		if len(rule.Upstreams) == 0 {
+			return nil, fmt.Errorf("no usable DNS servers configured for domain %s", r.Domain)
 		}
-		dnsCryptConfigs = append(dnsCryptConfigs, cfg)
+		rules = append(rules, rule)
+	}
+
+	ResolversTeardown()
 
+	// Get free local address for the resolver
+	localIp, err := resolver.GetFreeLocalAddressForDNS(nil)
+	if err != nil {
+		log.Warning("Failed to get free local address for DNS resolver: ", err)
+		// Anyway, try to use default address
+		localIp = net.IPv4(127, 0, 0, 1)
 	}
 
-	if len(ret) == 0 {
-		return nil, fmt.Errorf("no usable DNS servers configured")
+	var fallback []resolver.Upstream
+	if fb := fallbackDns(dnsCfg); fb != nil {
+		fallback = append(fallback, resolver.Upstream{Type: resolver.UpstreamPlain, Address: fb})
 	}
 
-	if err := startDnscryptProxyInstances(dnsCryptConfigs); err != nil {
-		return nil, fmt.Errorf("failed to start dnscrypt-proxy instances: %w", err)
+	localResolverMutex.Lock()
+	filter := localFilter
+	localResolverMutex.Unlock()
+
+	r, err := resolver.Start(resolver.Config{
+		ListenAddress:           localIp,
+		Upstreams:               upstreams,
+		DomainRules:             rules,
+		Filter:                  filter,
+		Stats:                   localStats,
+		Fallback:                fallback,
+		OnActiveUpstreamChanged: onActiveUpstreamChanged,
+		OnAnswer:                onAnswer,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start local DNS resolver: %w", err)
 	}
 
-	return ret, nil
-}
+	localResolverMutex.Lock()
+	localResolver = r
+	localResolverMutex.Unlock()
 
-// ResolversTeardown stops any running dnscrypt-proxy instances
-func ResolversTeardown() error {
-	return stopDnscryptProxyInstances()
+	return []DnsServerConfig{{Address: r.ListenAddress().String()}}, nil
 }
 
-func createDnscryptProxyConfig(listenAddress net.IP, servers []DnsServerConfig) (*dnscryptproxy.Config, error) {
-	resolvers := make([]dnscryptproxy.Resolver, 0, len(servers))
-	for _, svr := range servers {
-		resolver, err := dnscryptproxy.NewResolver(svr.Ip(), dnscryptproxy.StampProtoTypeDoH, svr.Template)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create resolver for %s: %w", svr.InfoString(), err)
+// resolversFirewallInfo returns DNS configuration to be used for the firewall rules when the local resolver is in use:
+// the local resolver address, plain DNS servers used by the resolver as upstreams (including fallback) and split DNS rules
+// (encrypted DNS servers are not using DNS port, so they are not included)
+func resolversFirewallInfo(localCfg []DnsServerConfig, dnsCfg DnsSettings) DnsSettings {
+	servers := append([]DnsServerConfig{}, localCfg...)
+	for _, svr := range dnsCfg.Servers {
+		if svr.Encryption == EncryptionNone && !svr.IsEmpty() {
+			servers = append(servers, svr)
 		}
-		resolvers = append(resolvers, resolver)
 	}
-	if len(resolvers) == 0 {
-		return nil, fmt.Errorf("no usable DNS servers configured")
+	if fb := fallbackDns(dnsCfg); fb != nil {
+		servers = append(servers, DnsServerConfig{Address: fb.String()})
 	}
-
-	return dnscryptproxy.NewConfig(listenAddress, resolvers)
+	return DnsSettings{Servers: servers, DomainRules: dnsCfg.DomainRules, metadata: dnsCfg.metadata}
 }
 
-func stopDnscryptProxyInstances() error {
-	dnsCryptProxyMutex.Lock()
-	defer dnsCryptProxyMutex.Unlock()
+// ResolversTeardown stops the local DNS resolver (if running)
+func ResolversTeardown() error {
+	localResolverMutex.Lock()
+	defer localResolverMutex.Unlock()
 
-	for _, proxy := range dnsCryptProxyInstances {
-		if err := proxy.Stop(); err != nil {
-			log.Warning("failed to stop dnscrypt-proxy: ", err)
-		}
+	if localResolver == nil {
+		return nil
+	}
+	err := localResolver.Stop()
+	localResolver = nil
+	if err != nil {
+		log.Warning("failed to stop local DNS resolver: ", err)
 	}
-	dnsCryptProxyInstances = nil
 	return nil
 }
 
-func startDnscryptProxyInstances(configs []*dnscryptproxy.Config) (retErr error) {
-	defer func() {
-		if retErr != nil {
-			retErr = fmt.Errorf("error starting dnscrypt-proxy instances: %w", retErr)
-			stopDnscryptProxyInstances()
+// fallbackDns returns the fallback DNS server for the local resolver
+// (nil - if not defined or it is already one of the plain DNS servers of the configuration)
+func fallbackDns(dnsCfg DnsSettings) net.IP {
+	fb := dnsCfg.metadata.FallbackDns
+	if fb == nil || fb.IsUnspecified() {
+		return nil
+	}
+	for _, svr := range dnsCfg.Servers {
+		if svr.Encryption == EncryptionNone && fb.Equal(svr.Ip()) {
+			return nil
 		}
-	}()
-
-	stopDnscryptProxyInstances()
-
-	dnsCryptProxyMutex.Lock()
-	defer dnsCryptProxyMutex.Unlock()
-
-	dnsCryptProxyInstances = make([]*dnscryptproxy.DnsCryptProxy, 0, len(configs))
-
-	binPath, templateFile, configDir := platform.DnsCryptProxyInfo()
-	if len(binPath) == 0 || len(templateFile) == 0 || len(configDir) == 0 {
-		return fmt.Errorf("dnscrypt-proxy configuration not defined")
 	}
+	return fb
+}
 
-	for _, cfg := range configs {
-		uniqNamePostfix := cfg.ID()
-		configFileOut := path.Join(configDir, fmt.Sprintf("dnscrypt-proxy-%s.toml", uniqNamePostfix))
-
-		// save dnscrypt-proxy configuration file
-		if err := cfg.Save(templateFile, configFileOut, ""); err != nil {
-			return fmt.Errorf("failed to save dnscrypt-proxy config file: %w", err)
+func toResolverUpstream(svr DnsServerConfig) (resolver.Upstream, error) {
+	if svr.Encryption == EncryptionDnsStamp {
+		// initialize the server address defined by stamp (DoH stamp is converted to DoH configuration)
+		if err := svr.normalizeStamp(); err != nil {
+			return resolver.Upstream{}, err
 		}
+	}
 
-		proxy, err := dnscryptproxy.Start(binPath, configFileOut, cfg.ListenAddress())
-		if err != nil {
-			return fmt.Errorf("failed to start dnscrypt-proxy: %w", err)
+	u := resolver.Upstream{Address: svr.Ip(), Template: svr.Template}
+	if u.Address == nil {
+		return u, fmt.Errorf("invalid IP address of DNS server %s", svr.InfoString())
+	}
+	switch svr.Encryption {
+	case EncryptionNone:
+		u.Type = resolver.UpstreamPlain
+	case EncryptionDnsOverHttps:
+		u.Type = resolver.UpstreamDoH
+	case EncryptionDnsOverTls:
+		u.Type = resolver.UpstreamDoT
+	case EncryptionDnsStamp:
+		u.Type = resolver.UpstreamDNSCrypt
+		if _, stamp, _ := resolver.ParseStamp(svr.Template); stamp.Proto == resolver.StampProtoTypeODoHTarget {
+			u.Type = resolver.UpstreamODoH
 		}
-		dnsCryptProxyInstances = append(dnsCryptProxyInstances, proxy)
+	default:
+		return u, fmt.Errorf("unsupported DNS encryption type %d", svr.Encryption)
 	}
-	return nil
+	return u, nil
 }
@@ -0,0 +1,235 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+const (
+	// max number of aggregated entries to keep in memory (the oldest entries are removed first)
+	blockedTrafficMaxEntries = 256
+	// clients are notified about new dropped packets not more often than once per this period
+	blockedTrafficNotifyDelay = time.Second * 5
+)
+
+// GetBlockedTraffic returns the information about recent outgoing packets dropped by the firewall
+// (sorted by the time of the last dropped packet; the most recent first)
+func GetBlockedTraffic() ([]types.BlockedTrafficInfo, error) {
+	if err := implIsBlockedTrafficSupported(); err != nil {
+		return nil, err
+	}
+	return blockedTraffic.get(), nil
+}
+
+// SetBlockedTrafficNotifier registers the function which is called when new dropped packets were detected
+func SetBlockedTrafficNotifier(f func()) {
+	blockedTraffic.mutex.Lock()
+	defer blockedTraffic.mutex.Unlock()
+	blockedTraffic.notifyFunc = f
+}
+
+// packetInfo - the basic info parsed from the network layer packet
+type packetInfo struct {
+	protocol string
+	isIPv6   bool
+	srcIP    net.IP
+	dstIP    net.IP
+	srcPort  int
+	dstPort  int
+}
+
+type blockedTrafficKey struct {
+	protocol    string
+	dstIP       string
+	dstPort     int
+	processPath string
+}
+
+type blockedTrafficCollector struct {
+	mutex       sync.Mutex
+	entries     map[blockedTrafficKey]*types.BlockedTrafficInfo
+	notifyFunc  func()
+	notifyTimer *time.Timer
+}
+
+var blockedTraffic blockedTrafficCollector
+
+// add registers dropped packet
+func (c *blockedTrafficCollector) add(p packetInfo, pid int, processPath string, uid int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[blockedTrafficKey]*types.BlockedTrafficInfo)
+	}
+
+	now := time.Now().Unix()
+	key := blockedTrafficKey{protocol: p.protocol, dstIP: p.dstIP.String(), dstPort: p.dstPort, processPath: processPath}
+	if e, ok := c.entries[key]; ok {
+		e.Count++
+		e.LastSeen = now
+		if pid > 0 {
+			e.Pid = pid
+		}
+	} else {
+		if len(c.entries) >= blockedTrafficMaxEntries {
+			c.removeOldest()
+		}
+		c.entries[key] = &types.BlockedTrafficInfo{
+			Protocol:    key.protocol,
+			DstIP:       key.dstIP,
+			DstPort:     key.dstPort,
+			Pid:         pid,
+			ProcessPath: processPath,
+			Uid:         uid,
+			Count:       1,
+			FirstSeen:   now,
+			LastSeen:    now,
+		}
+	}
+
+	// notify (not more often than once per 'blockedTrafficNotifyDelay')
+	if c.notifyFunc != nil && c.notifyTimer == nil {
+		c.notifyTimer = time.AfterFunc(blockedTrafficNotifyDelay, func() {
+			c.mutex.Lock()
+			f := c.notifyFunc
+			c.notifyTimer = nil
+			c.mutex.Unlock()
+			if f != nil {
+				f()
+			}
+		})
+	}
+}
+
+func (c *blockedTrafficCollector) removeOldest() {
+	var oldestKey *blockedTrafficKey
+	var oldestTime int64
+	for k, v := range c.entries {
+		if oldestKey == nil || v.LastSeen < oldestTime {
+			key := k
+			oldestKey = &key
+			oldestTime = v.LastSeen
+		}
+	}
+	if oldestKey != nil {
+		delete(c.entries, *oldestKey)
+	}
+}
+
+func (c *blockedTrafficCollector) get() []types.BlockedTrafficInfo {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := make([]types.BlockedTrafficInfo, 0, len(c.entries))
+	for _, v := range c.entries {
+		ret = append(ret, *v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].LastSeen == ret[j].LastSeen {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].LastSeen > ret[j].LastSeen
+	})
+	return ret
+}
+
+// parsePacket parses IPv4/IPv6 packet header (and TCP/UDP ports, if applicable)
+func parsePacket(data []byte) (ret packetInfo, ok bool) {
+	if len(data) < 1 {
+		return ret, false
+	}
+
+	var proto byte
+	var l4 []byte
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return ret, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return ret, false
+		}
+		proto = data[9]
+		ret.srcIP = net.IP(append([]byte(nil), data[12:16]...))
+		ret.dstIP = net.IP(append([]byte(nil), data[16:20]...))
+		// ports are available only in the first fragment
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff == 0 {
+			l4 = data[ihl:]
+		}
+	case 6:
+		if len(data) < 40 {
+			return ret, false
+		}
+		ret.isIPv6 = true
+		proto = data[6]
+		ret.srcIP = net.IP(append([]byte(nil), data[8:24]...))
+		ret.dstIP = net.IP(append([]byte(nil), data[24:40]...))
+		l4 = data[40:]
+		// skip IPv6 extension headers
+		for proto == 0 || proto == 43 || proto == 60 { // Hop-by-Hop, Routing, Destination Options
+			if len(l4) < 8 {
+				l4 = nil
+				break
+			}
+			proto = l4[0]
+			extLen := (int(l4[1]) + 1) * 8
+			if len(l4) < extLen {
+				l4 = nil
+				break
+			}
+			l4 = l4[extLen:]
+		}
+	default:
+		return ret, false
+	}
+
+	switch proto {
+	case 1:
+		ret.protocol = "icmp"
+	case 58:
+		ret.protocol = "icmpv6"
+	case 6:
+		ret.protocol = "tcp"
+	case 17:
+		ret.protocol = "udp"
+	default:
+		ret.protocol = "ip:" + strconv.Itoa(int(proto))
+	}
+
+	if (proto == 6 || proto == 17) && len(l4) >= 4 {
+		ret.srcPort = int(binary.BigEndian.Uint16(l4[0:2]))
+		ret.dstPort = int(binary.BigEndian.Uint16(l4[2:4]))
+	}
+
+	return ret, true
+}
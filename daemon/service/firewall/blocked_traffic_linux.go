@@ -0,0 +1,202 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+)
+
+// The NFLOG group used by firewall rules to log dropped packets
+// (must be the same as '_drops_nflog_group' in firewall.sh)
+const blockedTrafficNflogGroup = 4956
+
+// Prefix of NFLOG rule for outgoing packets
+// (must be the same as '_drops_nflog_prefix_out' in firewall.sh)
+const blockedTrafficNflogPrefixOut = "IVPN-DROP-OUT"
+
+var (
+	blockedTrafficListener      *netlink.NflogListener
+	blockedTrafficListenerMutex sync.Mutex
+)
+
+func implIsBlockedTrafficSupported() error {
+	return nil
+}
+
+// startBlockedTrafficMonitor starts receiving info about packets dropped by the firewall
+// (the firewall rules are sending info about dropped packets to the NFLOG group)
+func startBlockedTrafficMonitor() {
+	blockedTrafficListenerMutex.Lock()
+	defer blockedTrafficListenerMutex.Unlock()
+
+	if blockedTrafficListener != nil {
+		return
+	}
+
+	listener, err := netlink.CreateNflogListener(blockedTrafficNflogGroup)
+	if err != nil {
+		log.Warning(fmt.Errorf("blocked traffic monitor not started: %w", err))
+		return
+	}
+	blockedTrafficListener = listener
+
+	go func() {
+		log.Info("Blocked traffic monitor started")
+		defer log.Info("Blocked traffic monitor stopped")
+
+		for {
+			packets, err := listener.ReadPackets()
+			if err == netlink.ErrNflogListenerClosed {
+				return
+			}
+			if err != nil {
+				blockedTrafficListenerMutex.Lock()
+				isStopped := blockedTrafficListener != listener
+				blockedTrafficListenerMutex.Unlock()
+				if !isStopped {
+					log.Error(fmt.Errorf("blocked traffic monitor: %w", err))
+					stopBlockedTrafficMonitor()
+				}
+				return
+			}
+
+			for _, p := range packets {
+				if p.Prefix != blockedTrafficNflogPrefixOut {
+					continue
+				}
+				pInfo, ok := parsePacket(p.Payload)
+				if !ok {
+					continue
+				}
+
+				pid, processPath := 0, ""
+				if pInfo.srcPort > 0 {
+					pid, processPath = findProcessBySocket(pInfo.protocol, pInfo.isIPv6, pInfo.srcPort)
+				}
+				blockedTraffic.add(pInfo, pid, processPath, p.Uid)
+			}
+		}
+	}()
+}
+
+func stopBlockedTrafficMonitor() {
+	blockedTrafficListenerMutex.Lock()
+	defer blockedTrafficListenerMutex.Unlock()
+
+	if blockedTrafficListener == nil {
+		return
+	}
+	l := blockedTrafficListener
+	blockedTrafficListener = nil
+	if err := l.Close(); err != nil {
+		log.Warning(fmt.Errorf("failed to stop blocked traffic monitor: %w", err))
+	}
+}
+
+// findProcessBySocket looking for the process which owns the socket bound to the local port
+func findProcessBySocket(protocol string, isIPv6 bool, localPort int) (pid int, processPath string) {
+	file := "/proc/net/" + protocol
+	if isIPv6 {
+		file += "6"
+	}
+
+	inode := findSocketInode(file, localPort)
+	if len(inode) == 0 && !isIPv6 {
+		// IPv4 packets can be sent by IPv6 socket (dual-stack)
+		inode = findSocketInode(file+"6", localPort)
+	}
+	if len(inode) == 0 {
+		return 0, ""
+	}
+
+	pid = findPidBySocketInode(inode)
+	if pid <= 0 {
+		return 0, ""
+	}
+	if exe, err := filepath.EvalSymlinks(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		processPath = exe
+	}
+	return pid, processPath
+}
+
+// findSocketInode returns inode of the socket bound to the local port.
+// The file format (/proc/net/tcp, /proc/net/udp ...):
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	0: 0100007F:0035 00000000:0000 0A 00000000:00000000 00:00000000 00000000   0        0 12345 ...
+func findSocketInode(file string, localPort int) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	portHex := fmt.Sprintf(":%04X", localPort)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		cols := strings.Fields(scanner.Text())
+		if len(cols) < 10 {
+			continue
+		}
+		if strings.HasSuffix(cols[1], portHex) && cols[9] != "0" {
+			return cols[9]
+		}
+	}
+	return ""
+}
+
+func findPidBySocketInode(inode string) int {
+	link := "socket:[" + inode + "]"
+
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if l, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && l == link {
+				return pid
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"testing"
+)
+
+func TestParsePacket(t *testing.T) {
+	// IPv4 + UDP header: 10.0.0.2:40000 -> 1.1.1.1:53
+	ipv4udp := []byte{
+		0x45, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x40, 0x00, 0x40, 0x11, 0x00, 0x00,
+		10, 0, 0, 2,
+		1, 1, 1, 1,
+		0x9c, 0x40, 0x00, 0x35, 0x00, 0x08, 0x00, 0x00,
+	}
+	p, ok := parsePacket(ipv4udp)
+	if !ok || p.isIPv6 || p.protocol != "udp" || p.dstIP.String() != "1.1.1.1" || p.srcPort != 40000 || p.dstPort != 53 {
+		t.Errorf("unexpected IPv4 parse result: %+v (ok=%v)", p, ok)
+	}
+
+	// IPv6 + TCP header: fd00::2:40000 -> 2001:db8::1:443
+	ipv6tcp := make([]byte, 40+20)
+	ipv6tcp[0] = 0x60
+	ipv6tcp[6] = 6 // next header: TCP
+	ipv6tcp[8], ipv6tcp[9], ipv6tcp[23] = 0xfd, 0x00, 0x02
+	ipv6tcp[24], ipv6tcp[25], ipv6tcp[26], ipv6tcp[27], ipv6tcp[39] = 0x20, 0x01, 0x0d, 0xb8, 0x01
+	ipv6tcp[40], ipv6tcp[41], ipv6tcp[42], ipv6tcp[43] = 0x9c, 0x40, 0x01, 0xbb
+	p, ok = parsePacket(ipv6tcp)
+	if !ok || !p.isIPv6 || p.protocol != "tcp" || p.dstIP.String() != "2001:db8::1" || p.srcPort != 40000 || p.dstPort != 443 {
+		t.Errorf("unexpected IPv6 parse result: %+v (ok=%v)", p, ok)
+	}
+
+	// malformed packets
+	for _, data := range [][]byte{nil, {0x45, 0x00}, {0x70, 0x00, 0x00, 0x00}} {
+		if _, ok := parsePacket(data); ok {
+			t.Errorf("malformed packet parsed successfully: %v", data)
+		}
+	}
+}
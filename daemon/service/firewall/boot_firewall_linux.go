@@ -0,0 +1,247 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// Boot-time firewall.
+//
+// When the firewall is persistent, the daemon installs a standalone nftables ruleset and a systemd unit
+// which loads it before 'network-pre.target' (before any network interface is configured).
+// The ruleset blocks all the traffic from the earliest boot stage until the daemon takes over:
+// the daemon removes the boot-time nftables table as soon as its own firewall rules are applied.
+// When the persistence is disabled - the unit and the ruleset are uninstalled.
+
+const (
+	bootFwNftTable    = "ivpn_boot"
+	bootFwUnitName    = "ivpn-boot-firewall.service"
+	bootFwSystemdPath = "/etc/systemd/system"
+)
+
+func bootFwUnitFile() string {
+	return filepath.Join(bootFwSystemdPath, bootFwUnitName)
+}
+
+// bootFwInstall generates boot-time firewall ruleset and installs systemd unit which applies it on system boot
+func bootFwInstall() error {
+	if platform.GetSnapEnvs() != nil {
+		return fmt.Errorf("boot-time firewall is not supported in SNAP environment")
+	}
+
+	nftBin, err := exec.LookPath("nft")
+	if err != nil {
+		return fmt.Errorf("nftables binary ('nft') not found: %w", err)
+	}
+	systemctlBin, err := exec.LookPath("systemctl")
+	if err != nil {
+		return fmt.Errorf("'systemctl' not found: %w", err)
+	}
+
+	if err := bootFwUpdateRules(); err != nil {
+		return err
+	}
+
+	unit := bootFwGenerateUnit(nftBin, platform.BootFirewallRulesFile())
+	if existing, err := os.ReadFile(bootFwUnitFile()); err == nil && string(existing) == unit {
+		return nil // already installed
+	}
+
+	if err := os.WriteFile(bootFwUnitFile(), []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to save boot-time firewall unit: %w", err)
+	}
+	if err := shell.Exec(log, systemctlBin, "daemon-reload"); err != nil {
+		log.Warning(fmt.Errorf("'systemctl daemon-reload' failed: %w", err))
+	}
+	if err := shell.Exec(log, systemctlBin, "enable", bootFwUnitName); err != nil {
+		return fmt.Errorf("failed to enable boot-time firewall unit: %w", err)
+	}
+
+	log.Info("Boot-time firewall installed")
+	return nil
+}
+
+// bootFwUpdateRules re-generates the boot-time firewall ruleset (if the boot-time firewall is in use)
+// Note: the persistent exceptions (e.g. LAN) and user exceptions are copied into the ruleset.
+func bootFwUpdateRules() error {
+	if !isPersistant {
+		return nil
+	}
+
+	_, persistentHosts := getAllowedIpExceptions()
+	for _, e := range getUserExceptions(true, true) {
+		persistentHosts = append(persistentHosts, e.String())
+	}
+
+	if err := os.WriteFile(platform.BootFirewallRulesFile(), []byte(bootFwGenerateRules(persistentHosts)), 0600); err != nil {
+		return fmt.Errorf("failed to save boot-time firewall rules: %w", err)
+	}
+	return nil
+}
+
+// bootFwUninstall disables and removes systemd unit and ruleset of the boot-time firewall
+func bootFwUninstall() error {
+	bootFwRelease()
+
+	_, errUnit := os.Stat(bootFwUnitFile())
+	_, errRules := os.Stat(platform.BootFirewallRulesFile())
+	if os.IsNotExist(errUnit) && os.IsNotExist(errRules) {
+		return nil // not installed
+	}
+
+	var retErr error
+	if !os.IsNotExist(errUnit) {
+		if systemctlBin, err := exec.LookPath("systemctl"); err == nil {
+			if err := shell.Exec(log, systemctlBin, "disable", bootFwUnitName); err != nil {
+				log.Warning(fmt.Errorf("failed to disable boot-time firewall unit: %w", err))
+			}
+		}
+		if err := os.Remove(bootFwUnitFile()); err != nil && !os.IsNotExist(err) {
+			retErr = fmt.Errorf("failed to remove boot-time firewall unit: %w", err)
+		}
+		if systemctlBin, err := exec.LookPath("systemctl"); err == nil {
+			shell.Exec(log, systemctlBin, "daemon-reload")
+		}
+	}
+	if err := os.Remove(platform.BootFirewallRulesFile()); err != nil && !os.IsNotExist(err) {
+		retErr = fmt.Errorf("failed to remove boot-time firewall rules: %w", err)
+	}
+
+	if retErr == nil {
+		log.Info("Boot-time firewall uninstalled")
+	}
+	return retErr
+}
+
+// bootFwRelease removes boot-time firewall rules from the kernel (the daemon takes over the traffic control)
+func bootFwRelease() {
+	nftBin, err := exec.LookPath("nft")
+	if err != nil {
+		return
+	}
+	// check if the table exists
+	if err := shell.Exec(nil, nftBin, "list", "table", "inet", bootFwNftTable); err != nil {
+		return
+	}
+	if err := shell.Exec(log, nftBin, "delete", "table", "inet", bootFwNftTable); err != nil {
+		log.Error(fmt.Errorf("failed to remove boot-time firewall rules: %w", err))
+		return
+	}
+	log.Info("Boot-time firewall rules released")
+}
+
+func bootFwGenerateUnit(nftBin, rulesFile string) string {
+	return fmt.Sprintf(`# Generated by IVPN daemon. Do not edit.
+# The unit is installed when IVPN firewall is 'persistent' (always-on).
+[Unit]
+Description=IVPN boot-time firewall (blocks traffic until IVPN daemon starts)
+DefaultDependencies=no
+Before=network-pre.target
+Wants=network-pre.target
+RequiresMountsFor=%[2]s
+ConditionPathExists=%[2]s
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=%[1]s -f %[2]s
+
+[Install]
+WantedBy=sysinit.target
+`, nftBin, rulesFile)
+}
+
+// bootFwGenerateRules returns nftables ruleset which blocks all the traffic except:
+// loopback, DHCP, IPv6 neighbor discovery and the defined hosts/networks
+func bootFwGenerateRules(allowedHosts []string) string {
+	var hostsV4, hostsV6 []string
+	for _, h := range allowedHosts {
+		ip, n, err := net.ParseCIDR(h)
+		if err != nil {
+			if ip = net.ParseIP(h); ip == nil {
+				continue
+			}
+		} else {
+			h = n.String()
+		}
+		if ip.To4() != nil {
+			hostsV4 = append(hostsV4, h)
+		} else {
+			hostsV6 = append(hostsV6, h)
+		}
+	}
+	sort.Strings(hostsV4)
+	sort.Strings(hostsV6)
+
+	var exceptionsIn, exceptionsOut strings.Builder
+	if len(hostsV4) > 0 {
+		fmt.Fprintf(&exceptionsIn, "\t\tip saddr { %s } accept\n", strings.Join(hostsV4, ", "))
+		fmt.Fprintf(&exceptionsOut, "\t\tip daddr { %s } accept\n", strings.Join(hostsV4, ", "))
+	}
+	if len(hostsV6) > 0 {
+		fmt.Fprintf(&exceptionsIn, "\t\tip6 saddr { %s } accept\n", strings.Join(hostsV6, ", "))
+		fmt.Fprintf(&exceptionsOut, "\t\tip6 daddr { %s } accept\n", strings.Join(hostsV6, ", "))
+	}
+
+	return fmt.Sprintf(`#!/usr/sbin/nft -f
+# Generated by IVPN daemon. Do not edit.
+# Blocks all the traffic from the earliest boot stage until the IVPN daemon takes over.
+
+table inet %[1]s
+delete table inet %[1]s
+
+table inet %[1]s {
+	chain input {
+		type filter hook input priority -10; policy drop;
+		iif "lo" accept
+		ct state established,related accept
+		udp sport 67 udp dport 68 accept
+		udp sport 547 udp dport 546 accept
+		icmpv6 type { nd-router-advert, nd-neighbor-solicit, nd-neighbor-advert, nd-redirect } accept
+%[2]s	}
+
+	chain output {
+		type filter hook output priority -10; policy drop;
+		oif "lo" accept
+		ct state established,related accept
+		udp sport 68 udp dport 67 accept
+		udp sport 546 udp dport 547 accept
+		icmpv6 type { nd-router-solicit, nd-neighbor-solicit, nd-neighbor-advert } accept
+%[3]s	}
+
+	chain forward {
+		type filter hook forward priority -10; policy drop;
+	}
+}
+`, bootFwNftTable, exceptionsIn.String(), exceptionsOut.String())
+}
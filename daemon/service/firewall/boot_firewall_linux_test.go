@@ -0,0 +1,51 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBootFwGenerateRules(t *testing.T) {
+	rules := bootFwGenerateRules([]string{"192.168.0.0/16", "10.0.0.1", "fe80::/10", "bad-value"})
+
+	for _, expected := range []string{
+		"table inet " + bootFwNftTable + " {",
+		"ip saddr { 10.0.0.1, 192.168.0.0/16 } accept",
+		"ip daddr { 10.0.0.1, 192.168.0.0/16 } accept",
+		"ip6 saddr { fe80::/10 } accept",
+		"ip6 daddr { fe80::/10 } accept",
+	} {
+		if !strings.Contains(rules, expected) {
+			t.Errorf("expected '%s' in rules:\n%s", expected, rules)
+		}
+	}
+	if strings.Contains(rules, "bad-value") {
+		t.Error("unexpected bad value in rules")
+	}
+
+	if rules := bootFwGenerateRules(nil); strings.Contains(rules, "saddr") || strings.Contains(rules, "daddr") {
+		t.Errorf("unexpected exceptions in rules:\n%s", rules)
+	}
+}
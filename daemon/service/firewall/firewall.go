@@ -31,6 +31,7 @@ import (
 
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/types"
 )
 
 var log *logger.Logger
@@ -51,10 +52,19 @@ var (
 	dnsConfig                    *dns.DnsSettings
 
 	// List of IP masks that are allowed for any communication
+	// (combination of 'userExceptionsCfg' and 'splitTunExceptions')
 	userExceptions []net.IPNet
+	// IP masks defined by user (see SetUserExceptions())
+	userExceptionsCfg []net.IPNet
+	// Split Tunnel destinations which bypass the VPN tunnel (see SetSplitTunnelExceptions())
+	splitTunExceptions []net.IPNet
 
 	stateAllowLan          bool
 	stateAllowLanMulticast bool
+
+	// Applications (paths to binaries) which outgoing communication is blocked/allowed by the firewall
+	appsBlocked []string
+	appsAllowed []string
 )
 
 // Initialize is doing initialization stuff
@@ -96,6 +106,19 @@ func SetEnabled(enable bool) error {
 	return err
 }
 
+// RemoveBootRules uninstalls the boot-time firewall rules (if any).
+// Must be called on daemon start when the firewall is not persistent.
+func RemoveBootRules() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	err := implRemoveBootRules()
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
 // SetPersistant - set persistant firewall state and enable it if necessary
 func SetPersistant(persistant bool) error {
 	mutex.Lock()
@@ -262,13 +285,30 @@ func GetDnsInfo() (dns.DnsSettings, bool) {
 	return *dnsConfig, true
 }
 
-// getDnsIpAddresses - return list of IP addresses that are used in current DNS configuration without encryption
+// getDnsIpAddresses - return list of IP addresses that are allowed for DNS communication in current DNS configuration
 func getDnsIpAddresses() (addr []net.IP, isInternal bool) {
-	cfg := dnsConfig
-	if cfg != nil {
-		return cfg.GetUnencryptedServersAddresses(), cfg.Metadata().IsInternalDnsConfig
+	return dnsAllowedAddresses(dnsConfig)
+}
+
+// dnsAllowedAddresses - return list of IP addresses that are allowed for DNS communication by the DNS configuration:
+// servers without encryption, servers defined by split DNS rules and the hosts defined by DNS stamps (e.g. DNSCrypt relays).
+// For DoH/DoT - no sense to allow DNS port (53)
+func dnsAllowedAddresses(cfg *dns.DnsSettings) (addr []net.IP, isInternal bool) {
+	if cfg == nil {
+		return nil, false
 	}
-	return nil, false
+	addr = cfg.GetUnencryptedServersAddresses()
+	others := append(cfg.GetDomainRulesServersAddresses(), cfg.GetStampsServersAddresses()...)
+nextIp:
+	for _, ip := range others {
+		for _, existing := range addr {
+			if existing.Equal(ip) {
+				continue nextIp
+			}
+		}
+		addr = append(addr, ip)
+	}
+	return addr, cfg.Metadata().IsInternalDnsConfig
 }
 
 // OnChangeDNS - must be called on each DNS change (to update firewall rules according to new DNS configuration)
@@ -286,15 +326,7 @@ func OnChangeDNS(newDnsCfg *dns.DnsSettings) error {
 		return nil
 	}
 
-	var addresses []net.IP = nil
-	var isInternal bool = false
-	if newDnsCfg != nil {
-		// for DoH/DoT - no sense to allow DNS port (53)
-		addresses = newDnsCfg.GetUnencryptedServersAddresses()
-		isInternal = newDnsCfg.Metadata().IsInternalDnsConfig
-	}
-
-	err := implOnChangeDNS(addresses, isInternal)
+	err := implOnChangeDNS(dnsAllowedAddresses(newDnsCfg))
 	if err != nil {
 		log.Error(err)
 	} else {
@@ -308,7 +340,7 @@ func OnChangeDNS(newDnsCfg *dns.DnsSettings) error {
 // Parameters:
 //   - exceptions - comma separated list of IP addresses in format: x.x.x.x[/xx]
 func SetUserExceptions(exceptions string, ignoreParseErrors bool) error {
-	userExceptions = []net.IPNet{}
+	exceptionsCfg := []net.IPNet{}
 
 	splitFunc := func(c rune) bool {
 		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && c != rune('/') && c != rune('.') && c != rune(':')
@@ -342,8 +374,73 @@ func SetUserExceptions(exceptions string, ignoreParseErrors bool) error {
 			}
 			continue
 		}
-		userExceptions = append(userExceptions, *n)
+		exceptionsCfg = append(exceptionsCfg, *n)
 	}
 
+	userExceptionsCfg = exceptionsCfg
+	return onExceptionsUpdated()
+}
+
+// SetSplitTunnelExceptions set the networks to be excluded from FW block:
+// the Split Tunnel destinations which always bypass the VPN tunnel
+func SetSplitTunnelExceptions(exceptions []net.IPNet) error {
+	splitTunExceptions = exceptions
+	return onExceptionsUpdated()
+}
+
+func onExceptionsUpdated() error {
+	exceptions := append([]net.IPNet{}, userExceptionsCfg...)
+	exceptions = append(exceptions, splitTunExceptions...)
+	userExceptions = exceptions
 	return implOnUserExceptionsUpdated()
 }
+
+// SetAppsRules set the lists of applications (paths to binaries) which outgoing communication
+// must be blocked (even when VPN is connected) or allowed (even when VPN is disconnected) by the firewall.
+// The rules take effect when the firewall is enabled.
+func SetAppsRules(blockedApps, allowedApps []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, b := range blockedApps {
+		for _, a := range allowedApps {
+			if b == a {
+				return fmt.Errorf("the application can not be blocked and allowed at the same time: '%s'", b)
+			}
+		}
+	}
+
+	log.Info(fmt.Sprintf("Apps rules: blocked=%v allowed=%v", blockedApps, allowedApps))
+
+	err := implSetAppsRules(blockedApps, allowedApps)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	appsBlocked = blockedApps
+	appsAllowed = allowedApps
+	return nil
+}
+
+// SetLocalInterfaces set the configuration for trusted local interfaces
+// (e.g. bridge interfaces of containers and VMs: docker0, virbr0, lxcbr0 ...)
+// The rules take effect when the firewall is enabled.
+func SetLocalInterfaces(ifaces []types.LocalInterface) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, i := range ifaces {
+		if err := i.Validate(); err != nil {
+			return err
+		}
+	}
+
+	log.Info(fmt.Sprintf("Local interfaces: %v", ifaces))
+
+	err := implSetLocalInterfaces(ifaces)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,327 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/applist"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/cgroup"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+	"github.com/ivpn/desktop-app/daemon/splittun"
+)
+
+// Per-application rules (cgroup v2).
+//
+// The processes of blocked/allowed applications are moved into the dedicated cgroups at the moment they start
+// (the daemon monitors the new processes using the netlink process events connector);
+// the child processes stay in the cgroup of the parent.
+// The applications are defined in the same way as for Split Tunnel (see applist.Matcher).
+// The packets of the processes are matched by nftables rules ('socket cgroupv2'):
+//   - packets of blocked applications are dropped;
+//   - connections of allowed applications are marked by the connection mark which is accepted by the firewall (see firewall.sh).
+const (
+	appsCgroupBlocked = "ivpn-fw-block"
+	appsCgroupAllowed = "ivpn-fw-allow"
+	appsNftTable      = "ivpn_apps"
+	// connection mark for connections of allowed applications (must be the same as '_apps_allowed_connmark' in firewall.sh)
+	appsAllowedConnmark = 0x49564641
+)
+
+var (
+	appsMutex          sync.Mutex
+	appsBlockedMatcher *applist.Matcher // blocked applications
+	appsAllowedMatcher *applist.Matcher // allowed applications
+	appsMonitorStopCh  chan struct{}    // nil - the monitor is not running
+	// original cgroups of the processes moved to the apps cgroups (map[<PID>]<cgroup path>)
+	appsOriginalCgroups = map[int]string{}
+)
+
+func implSetAppsRules(blockedApps, allowedApps []string) error {
+	blocked, skipped := applist.NewMatcher(blockedApps)
+	allowed, skippedAllowed := applist.NewMatcher(allowedApps)
+	for _, err := range append(skipped, skippedAllowed...) {
+		log.Warning("Apps rules: ", err)
+	}
+
+	appsMutex.Lock()
+	appsBlockedMatcher, appsAllowedMatcher = blocked, allowed
+	appsMutex.Unlock()
+
+	return appsMonitorUpdate()
+}
+
+// appsMonitorUpdate starts/stops the monitor (according to the firewall state and the applications configuration)
+// or re-classifies the running processes (if the monitor is already running)
+func appsMonitorUpdate() error {
+	appsMutex.Lock()
+	defer appsMutex.Unlock()
+
+	isRequired := curStateEnabled && (!appsBlockedMatcher.IsEmpty() || !appsAllowedMatcher.IsEmpty())
+
+	if !isRequired {
+		appsStop()
+		return nil
+	}
+
+	if appsMonitorStopCh != nil {
+		// monitor is already running: re-classify the processes according to the new configuration
+		appsClassifyRunningProcesses()
+		return nil
+	}
+
+	if err := appsInit(); err != nil {
+		appsStop()
+		return fmt.Errorf("failed to initialize per-application firewall rules: %w", err)
+	}
+
+	listener, err := netlink.CreateProcEventsListener()
+	if err != nil {
+		appsStop()
+		return fmt.Errorf("failed to start monitoring of the processes for per-application firewall rules: %w", err)
+	}
+	appsMonitorStopCh = make(chan struct{})
+	go appsMonitor(listener, appsMonitorStopCh)
+	log.Info("Apps rules monitor started")
+
+	// classify processes which are already running
+	appsClassifyRunningProcesses()
+	return nil
+}
+
+// appsMonitorStop stops the monitor, moves the processes back to their original cgroups and removes the rules
+func appsMonitorStop() {
+	appsMutex.Lock()
+	defer appsMutex.Unlock()
+	appsStop()
+}
+
+// appsInit creates the cgroups and applies nftables rules
+// Must be called under locked 'appsMutex'
+func appsInit() error {
+	if !cgroup.IsCgroup2Mount(cgroup.UnifiedRoot) {
+		return fmt.Errorf("cgroup v2 unified hierarchy is not mounted on '%s'", cgroup.UnifiedRoot)
+	}
+	if _, err := exec.LookPath("nft"); err != nil {
+		return fmt.Errorf("nftables binary ('nft') not found")
+	}
+	for _, cg := range []string{appsCgroupBlocked, appsCgroupAllowed} {
+		if err := os.Mkdir(filepath.Join(cgroup.UnifiedRoot, cg), 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("failed to create cgroup: %w", err)
+		}
+	}
+	// Note: the cgroups must exist before applying the rules
+	if err := appsNft(appsNftRules()); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+	return nil
+}
+
+// appsStop stops the monitor and erases the environment of per-application rules
+// Must be called under locked 'appsMutex'
+func appsStop() {
+	if appsMonitorStopCh != nil {
+		close(appsMonitorStopCh)
+		appsMonitorStopCh = nil
+		log.Info("Apps rules monitor stopped")
+	}
+
+	// move all processes back to their original cgroups
+	for _, cg := range []string{appsCgroupBlocked, appsCgroupAllowed} {
+		pids, _ := cgroup.Pids(filepath.Join(cgroup.UnifiedRoot, cg))
+		for _, pid := range pids {
+			if err := appsRestoreCgroup(pid); err != nil {
+				log.Warning(fmt.Errorf("failed to move process (PID=%d) out of cgroup '%s': %w", pid, cg, err))
+			}
+		}
+	}
+	appsOriginalCgroups = map[int]string{}
+
+	if _, err := exec.LookPath("nft"); err == nil {
+		if err := appsNft(fmt.Sprintf("table inet %[1]s\ndelete table inet %[1]s\n", appsNftTable)); err != nil {
+			log.Warning(fmt.Errorf("failed to remove nftables rules: %w", err))
+		}
+	}
+
+	// Note: the cgroup will be removed only in case when no active process are in it
+	for _, cg := range []string{appsCgroupBlocked, appsCgroupAllowed} {
+		os.Remove(filepath.Join(cgroup.UnifiedRoot, cg))
+	}
+}
+
+func appsMonitor(listener *netlink.ProcEventsListener, stop <-chan struct{}) {
+	defer listener.Close()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		pids, err := listener.ReadExecEvents()
+		if err != nil {
+			// Events may be lost (e.g. ENOBUFS when the socket buffer overflows).
+			// Re-classify all running processes: otherwise, the blocked application may stay unblocked.
+			log.Error(fmt.Errorf("apps rules monitor: %w", err))
+			appsMutex.Lock()
+			if appsMonitorStopCh == stop {
+				appsClassifyRunningProcesses()
+			}
+			appsMutex.Unlock()
+			continue
+		}
+		if len(pids) == 0 {
+			continue
+		}
+
+		appsMutex.Lock()
+		if appsMonitorStopCh == stop {
+			for _, pid := range pids {
+				appsClassifyProcess(pid)
+			}
+		}
+		appsMutex.Unlock()
+	}
+}
+
+// appsClassifyRunningProcesses classifies all running processes
+// Must be called under locked 'appsMutex'
+func appsClassifyRunningProcesses() {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		log.Error(fmt.Errorf("apps rules monitor: %w", err))
+		return
+	}
+
+	running := make(map[int]struct{}, len(procs))
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid <= 0 {
+			continue
+		}
+		running[pid] = struct{}{}
+		appsClassifyProcess(pid)
+	}
+
+	// forget finished processes
+	for pid := range appsOriginalCgroups {
+		if _, ok := running[pid]; !ok {
+			delete(appsOriginalCgroups, pid)
+		}
+	}
+}
+
+// appsClassifyProcess moves the process of blocked/allowed application to the corresponding cgroup
+// and moves back to the original cgroup the process which is not blocked/allowed anymore.
+// The child processes (not moved by the daemon) are kept in the cgroup inherited from the parent.
+// Must be called under locked 'appsMutex'
+func appsClassifyProcess(pid int) {
+	required := ""
+	app, ok := appsBlockedMatcher.Match(pid)
+	if ok {
+		required = "/" + appsCgroupBlocked
+	} else if app, ok = appsAllowedMatcher.Match(pid); ok {
+		required = "/" + appsCgroupAllowed
+	}
+
+	cur := cgroup.ProcessCgroup(pid)
+	if len(cur) == 0 || cur == required {
+		return
+	}
+	isManaged := cur == "/"+appsCgroupBlocked || cur == "/"+appsCgroupAllowed
+
+	if len(required) == 0 {
+		if _, isMoved := appsOriginalCgroups[pid]; isMoved && isManaged {
+			if err := appsRestoreCgroup(pid); err != nil {
+				log.Warning(fmt.Errorf("failed to remove firewall rules for process (PID=%d): %w", pid, err))
+				return
+			}
+			log.Info(fmt.Sprintf("Apps rules: process moved out of cgroup '%s' (PID=%d)", cur, pid))
+		}
+		return
+	}
+
+	// do not touch processes which are in Split Tunnel environment
+	if splittun.IsPidInSplitTunnel(pid) {
+		return
+	}
+	if !isManaged {
+		appsOriginalCgroups[pid] = cur
+	}
+	if err := cgroup.MoveProcess(pid, filepath.Join(cgroup.UnifiedRoot, required)); err != nil {
+		log.Warning(fmt.Errorf("failed to apply firewall rules for process (PID=%d; %s): %w", pid, app, err))
+		return
+	}
+	log.Info(fmt.Sprintf("Apps rules: process moved to cgroup '%s' (PID=%d; %s)", required, pid, app))
+}
+
+// appsRestoreCgroup moves the process back to its original cgroup (or to the root cgroup, if the original does not exist anymore)
+func appsRestoreCgroup(pid int) error {
+	original, ok := appsOriginalCgroups[pid]
+	delete(appsOriginalCgroups, pid)
+	if ok {
+		if err := cgroup.MoveProcess(pid, filepath.Join(cgroup.UnifiedRoot, original)); err == nil {
+			return nil
+		}
+	}
+	return cgroup.MoveProcess(pid, cgroup.UnifiedRoot)
+}
+
+// appsNftRules returns nftables rules for per-application rules.
+// The chain is processed after the connection tracking and before the firewall rules (iptables 'filter' table).
+func appsNftRules() string {
+	return fmt.Sprintf(`table inet %[1]s
+delete table inet %[1]s
+
+table inet %[1]s {
+	chain output {
+		type filter hook output priority filter - 10; policy accept;
+		socket cgroupv2 level 1 "%[2]s" drop
+		socket cgroupv2 level 1 "%[3]s" ct mark set %#[4]x
+	}
+}
+`, appsNftTable, appsCgroupBlocked, appsCgroupAllowed, appsAllowedConnmark)
+}
+
+// appsNft applies nftables ruleset
+func appsNft(ruleset string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(ruleset)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errText := strings.TrimSpace(stderr.String()); len(errText) > 0 {
+			return fmt.Errorf("%w: %s", err, errText)
+		}
+		return err
+	}
+	return nil
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/ivpn/desktop-app/daemon/netinfo"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/shell"
 )
 
@@ -92,6 +93,10 @@ func implSetPersistant(persistant bool) error {
 	return nil
 }
 
+func implRemoveBootRules() error {
+	return nil
+}
+
 // ClientConnected - allow communication for local vpn/client IP address
 func implClientConnected(clientLocalIPAddress net.IP, clientLocalIPv6Address net.IP, clientPort int, serverIP net.IP, serverPort int, isTCP bool) error {
 	inf, err := netinfo.InterfaceByIPAddr(clientLocalIPAddress)
@@ -372,3 +377,21 @@ func implSingleDnsRuleOff() (retErr error) {
 func implSingleDnsRuleOn(dnsAddr []net.IP) (retErr error) {
 	return nil // nothing to do for this platform
 }
+
+func implIsBlockedTrafficSupported() error {
+	return fmt.Errorf("blocked traffic logging is not supported on this platform")
+}
+
+func implSetAppsRules(blockedApps, allowedApps []string) error {
+	if len(blockedApps) > 0 || len(allowedApps) > 0 {
+		return fmt.Errorf("per-application firewall rules are not supported on this platform")
+	}
+	return nil
+}
+
+func implSetLocalInterfaces(ifaces []types.LocalInterface) error {
+	if len(ifaces) > 0 {
+		return fmt.Errorf("local interfaces configuration is not supported on this platform")
+	}
+	return nil
+}
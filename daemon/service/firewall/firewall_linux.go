@@ -31,6 +31,7 @@ import (
 
 	"github.com/ivpn/desktop-app/daemon/netinfo"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/shell"
 )
 
@@ -40,11 +41,12 @@ var (
 	allowedHosts   map[string]bool
 	allowedForICMP map[string]struct{} // IP addresses allowed for ICMP
 
-	curAllowedLanIPs          []string // IP addresses allowed for LAN
-	curStateAllowLAN          bool     // Allow LAN is enabled
-	curStateAllowLanMulticast bool     // Allow Multicast is enabled
-	curStateEnabled           bool     // Firewall is enabled
-	isPersistant              bool     // Firewall is persistant
+	curAllowedLanIPs          []string               // IP addresses allowed for LAN
+	curStateAllowLAN          bool                   // Allow LAN is enabled
+	curStateAllowLanMulticast bool                   // Allow Multicast is enabled
+	curStateEnabled           bool                   // Firewall is enabled
+	curLocalInterfaces        []types.LocalInterface // Trusted local interfaces configuration
+	isPersistant              bool                   // Firewall is persistant
 	mutexInternal             sync.Mutex
 )
 
@@ -81,15 +83,28 @@ func implSetEnabled(isEnabled bool) error {
 			return fmt.Errorf("failed to execute shell command: %w", err)
 		}
 
+		// the daemon rules are applied: boot-time firewall rules are not required anymore
+		bootFwRelease()
+
+		// start collecting info about packets dropped by the firewall
+		startBlockedTrafficMonitor()
+
 		// To fulfill such flow (example): Connected -> FWDisable -> FWEnable
 		// Here we should restore all exceptions (all hosts which are allowed)
-		return reApplyExceptions()
+		if err := reApplyExceptions(); err != nil {
+			return err
+		}
+
+		// start classification of processes of blocked/allowed applications
+		return appsMonitorUpdate()
 	}
 
 	// disable FW ...
 	curAllowedLanIPs = nil // forget allowed LAN IP addresses
 	isPersistant = false
 	allowedForICMP = nil
+	stopBlockedTrafficMonitor()
+	appsMonitorStop()
 	return shell.Exec(nil, platform.FirewallScript(), "-disable")
 }
 
@@ -104,6 +119,11 @@ func implSetPersistant(persistant bool) error {
 		// Just ensure that firewall is enabled
 		ret := implSetEnabled(true)
 
+		// Install boot-time firewall: it blocks the traffic on system boot until the daemon starts
+		if err := bootFwInstall(); err != nil {
+			log.Error(fmt.Errorf("failed to install boot-time firewall: %w", err))
+		}
+
 		// Some Linux distributions erasing IVPN rules during system boot
 		// During some period of time (60 seconds should be enough)
 		// check if FW rules still exist (if not - re-apply them)
@@ -111,7 +131,11 @@ func implSetPersistant(persistant bool) error {
 
 		return ret
 	}
-	return nil
+	return bootFwUninstall()
+}
+
+func implRemoveBootRules() error {
+	return bootFwUninstall()
 }
 
 // Some Linux distributions erasing IVPN rules during system boot
@@ -189,10 +213,25 @@ func doAllowLAN(isAllowLAN, isAllowLanMulticast bool) error {
 	curStateAllowLAN = isAllowLAN
 	curStateAllowLanMulticast = isAllowLanMulticast
 
+	// Trusted local interfaces are not a part of LAN: they have own rules which do not depend on 'Allow LAN' state
+	// (the rules are processed before LAN exceptions, so the 'block' mode is effective even when LAN allowed)
+	if curStateEnabled {
+		if err := applyLocalInterfaces(); err != nil {
+			log.Warning(fmt.Errorf("failed to apply rules for local interfaces: %w", err))
+		}
+	}
+
 	if isAllowLAN && !curStateEnabled {
 		return nil // do nothing if firewall disabled
 	}
 
+	// LAN exceptions are a part of boot-time firewall rules
+	defer func() {
+		if err := bootFwUpdateRules(); err != nil {
+			log.Warning(err)
+		}
+	}()
+
 	// constants
 	const persistant = true
 	const notOnlyForICMP = false
@@ -226,6 +265,26 @@ func doAllowLAN(isAllowLAN, isAllowLanMulticast bool) error {
 	return addHostsToExceptions(curAllowedLanIPs, persistant, notOnlyForICMP)
 }
 
+func implSetLocalInterfaces(ifaces []types.LocalInterface) error {
+	mutexInternal.Lock()
+	defer mutexInternal.Unlock()
+
+	curLocalInterfaces = ifaces
+	if !curStateEnabled {
+		return nil // do nothing if firewall disabled
+	}
+	return applyLocalInterfaces()
+}
+
+func applyLocalInterfaces() error {
+	args := make([]string, 0, len(curLocalInterfaces)+1)
+	args = append(args, "-set_local_interfaces")
+	for _, i := range curLocalInterfaces {
+		args = append(args, i.Name+":"+i.Mode.String())
+	}
+	return shell.Exec(log, platform.FirewallScript(), args...)
+}
+
 // implAddHostsToExceptions - allow communication with this hosts
 // Note: if isPersistent == false -> all added hosts will be removed from exceptions after client disconnection (after call 'ClientDisconnected()')
 // Arguments:
@@ -300,6 +359,11 @@ func implOnUserExceptionsUpdated() error {
 		return shell.Exec(nil, platform.FirewallScript(), scriptCommand, ipList)
 	}
 
+	// user exceptions are a part of boot-time firewall rules
+	if err := bootFwUpdateRules(); err != nil {
+		log.Warning(err)
+	}
+
 	err := applyFunc(false)
 	errIpv6 := applyFunc(true)
 	if err == nil && errIpv6 != nil {
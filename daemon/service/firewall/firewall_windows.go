@@ -32,6 +32,7 @@ import (
 	"github.com/ivpn/desktop-app/daemon/netinfo"
 	"github.com/ivpn/desktop-app/daemon/service/firewall/winlib"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/types"
 )
 
 var (
@@ -135,6 +136,10 @@ func implSetPersistant(persistant bool) (retErr error) {
 	return doEnable()
 }
 
+func implRemoveBootRules() error {
+	return nil
+}
+
 // ClientConnected - allow communication for local vpn/client IP address
 func implClientConnected(clientLocalIPAddress net.IP, clientLocalIPv6Address net.IP, clientPort int, serverIP net.IP, serverPort int, isTCP bool) (retErr error) {
 	// start / commit transaction
@@ -454,12 +459,6 @@ func doEnable() (retErr error) {
 		if err != nil {
 			return fmt.Errorf("failed to add filter 'allow application - V2Ray': %w", err)
 		}
-		// allow dnscrypt-proxy
-		dnscryptProxyBin, _, _ := platform.DnsCryptProxyInfo()
-		_, err = manager.AddFilter(winlib.NewFilterAllowApplication(providerKey, layer, sublayerKey, sublayerDName, "", dnscryptProxyBin, isPersistant))
-		if err != nil {
-			return fmt.Errorf("failed to add filter 'allow application - dnscrypt-proxy': %w", err)
-		}
 
 		_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIP(providerKey, layer, sublayerKey, filterDName, "", net.ParseIP("127.0.0.1"), net.IPv4(255, 255, 255, 255), isPersistant))
 		if err != nil {
@@ -777,6 +776,10 @@ func implSingleDnsRuleOn(dnsAddresses []net.IP) (retErr error) {
 	return nil
 }
 
+func implIsBlockedTrafficSupported() error {
+	return fmt.Errorf("blocked traffic logging is not supported on this platform")
+}
+
 func checkIpAddrVersionPresence(ipAddresses []net.IP) (hasIPv4, hasIPv6 bool) {
 	for _, ip := range ipAddresses {
 		if ip.To4() != nil {
@@ -787,3 +790,17 @@ func checkIpAddrVersionPresence(ipAddresses []net.IP) (hasIPv4, hasIPv6 bool) {
 	}
 	return
 }
+
+func implSetAppsRules(blockedApps, allowedApps []string) error {
+	if len(blockedApps) > 0 || len(allowedApps) > 0 {
+		return fmt.Errorf("per-application firewall rules are not supported on this platform")
+	}
+	return nil
+}
+
+func implSetLocalInterfaces(ifaces []types.LocalInterface) error {
+	if len(ifaces) > 0 {
+		return fmt.Errorf("local interfaces configuration is not supported on this platform")
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ import (
 	"net"
 
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
 	service_types "github.com/ivpn/desktop-app/daemon/service/types"
 	"github.com/ivpn/desktop-app/daemon/service/wgkeys"
@@ -77,6 +78,8 @@ type IServiceEventsReceiver interface {
 	OnServiceSessionChanged()
 	OnSessionStatus(sessionToken string, sessionData preferences.SessionMutableData)
 	OnKillSwitchStateChanged()
+	OnKillSwitchBlockedTraffic()
+	OnDnsFailover(dns.DnsFailoverInfo)
 	OnWiFiChanged(wifiNotifier.WifiInfo, error)
 	OnPingStatus(retMap map[string]int)
 	OnServersUpdated(*api_types.ServersInfoResponse)
@@ -0,0 +1,53 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package leaktest
+
+import (
+	"net"
+	"syscall"
+)
+
+// Constants from netinet/in.h and netinet6/in6.h
+const (
+	_IP_BOUND_IF   = 25
+	_IPV6_BOUND_IF = 125
+)
+
+// bindToInterfaceFunc returns the dialer 'Control' function which binds the socket to the interface
+func bindToInterfaceFunc(iface *net.Interface) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var opErr error
+		err := c.Control(func(fd uintptr) {
+			switch network {
+			case "tcp6", "udp6":
+				opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, _IPV6_BOUND_IF, iface.Index)
+			default:
+				opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, _IP_BOUND_IF, iface.Index)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return opErr
+	}
+}
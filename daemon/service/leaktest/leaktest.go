@@ -0,0 +1,420 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package leaktest implements diagnostic checks of the VPN connection:
+// DNS leaks, IPv6 leaks, routing leaks and the kill-switch effectiveness.
+//
+// All the probe targets (DNS resolvers, hosts) and the non-tunnel interface can be redefined,
+// so the test can be performed offline against local stand-ins (e.g. a test resolver in a network namespace).
+package leaktest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("leak")
+}
+
+const (
+	CheckDns        = "dns"
+	CheckIPv6       = "ipv6"
+	CheckRoute      = "route"
+	CheckKillSwitch = "killswitch"
+
+	defaultDnsQueryName = "ivpn.net."
+	defaultDnsPort      = 53
+	defaultTimeout      = time.Second * 2
+)
+
+var (
+	defaultProbeDnsServers = []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("9.9.9.9"), net.ParseIP("2606:4700:4700::1111")}
+	defaultProbeHosts      = []string{"1.1.1.1:443", "9.9.9.9:443", "[2606:4700:4700::1111]:443"}
+)
+
+// Config - the leak test configuration
+type Config struct {
+	TunnelIPv4        net.IP // local IPv4 address of the VPN interface
+	TunnelIPv6        net.IP // local IPv6 address of the VPN interface (if IPv6 is in tunnel)
+	IsIPv6InTunnel    bool
+	IsFirewallEnabled bool
+
+	// DNS servers configured by the daemon (the expected resolvers)
+	DnsServers []net.IP
+
+	// Probe targets. If not defined - the public resolvers/hosts are in use.
+	ProbeDnsServers []net.IP // DNS resolvers (UDP port 53)
+	ProbeHosts      []string // TCP hosts in format "IP:port"
+	DnsQueryName    string   // the name to resolve in DNS probes
+
+	// The non-tunnel interface name (used to send probes outside the tunnel).
+	// If not defined - the interface of the default gateway is in use.
+	Interface string
+	// Timeout for a single probe
+	Timeout time.Duration
+}
+
+// Run performs all the checks and returns the report
+func Run(cfg Config) (types.LeakTestReport, error) {
+	if cfg.TunnelIPv4 == nil && cfg.TunnelIPv6 == nil {
+		return types.LeakTestReport{}, fmt.Errorf("tunnel address is not defined")
+	}
+
+	if len(cfg.ProbeDnsServers) == 0 {
+		cfg.ProbeDnsServers = defaultProbeDnsServers
+	}
+	if len(cfg.ProbeHosts) == 0 {
+		cfg.ProbeHosts = defaultProbeHosts
+	}
+	if len(cfg.DnsQueryName) == 0 {
+		cfg.DnsQueryName = defaultDnsQueryName
+	}
+	if cfg.DnsQueryName[len(cfg.DnsQueryName)-1] != '.' {
+		cfg.DnsQueryName += "."
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	probeHosts := make([]*net.TCPAddr, 0, len(cfg.ProbeHosts))
+	for _, h := range cfg.ProbeHosts {
+		addr, err := net.ResolveTCPAddr("tcp", h)
+		if err != nil || addr.IP == nil {
+			return types.LeakTestReport{}, fmt.Errorf("bad probe host '%s' (expected format 'IP:port')", h)
+		}
+		probeHosts = append(probeHosts, addr)
+	}
+
+	var outIface *net.Interface
+	if len(cfg.Interface) > 0 {
+		iface, err := net.InterfaceByName(cfg.Interface)
+		if err != nil {
+			return types.LeakTestReport{}, fmt.Errorf("interface '%s' not found: %w", cfg.Interface, err)
+		}
+		outIface = iface
+	} else {
+		iface, err := defaultGatewayInterface()
+		if err != nil {
+			log.Warning(fmt.Errorf("unable to detect non-tunnel interface: %w", err))
+		}
+		outIface = iface
+	}
+
+	t := tester{cfg: cfg, probeHosts: probeHosts, outIface: outIface, dnsPort: defaultDnsPort}
+
+	report := types.LeakTestReport{
+		Time:              time.Now().Unix(),
+		IsFirewallEnabled: cfg.IsFirewallEnabled,
+		IsIPv6InTunnel:    cfg.IsIPv6InTunnel,
+		Checks:            []types.LeakTestCheck{t.checkRoute(), t.checkDns(), t.checkIPv6(), t.checkKillSwitch()},
+	}
+	if cfg.TunnelIPv4 != nil {
+		report.TunnelIPv4 = cfg.TunnelIPv4.String()
+	}
+	if cfg.TunnelIPv6 != nil {
+		report.TunnelIPv6 = cfg.TunnelIPv6.String()
+	}
+	if outIface != nil {
+		report.Interface = outIface.Name
+	}
+
+	log.Info(fmt.Sprintf("Leak test done (passed=%v)", report.IsPassed()))
+	return report, nil
+}
+
+type tester struct {
+	cfg        Config
+	probeHosts []*net.TCPAddr
+	outIface   *net.Interface
+	dnsPort    int
+}
+
+// isIPv6Skipped returns 'true' for IPv6 targets when IPv6 is not in tunnel (such targets are tested by IPv6 check)
+func (t *tester) isIPv6Skipped(ip net.IP) bool {
+	return ip.To4() == nil && !t.cfg.IsIPv6InTunnel
+}
+
+// isRoutedThroughTunnel checks which local address is used to reach the 'ip'
+func (t *tester) isRoutedThroughTunnel(ip net.IP) (isTunnel bool, localAddr net.IP, err error) {
+	if ip.IsLoopback() {
+		return true, ip, nil
+	}
+	localAddr, err = netinfo.GetOutboundIPEx(ip)
+	if err != nil {
+		return false, nil, err
+	}
+	return localAddr.Equal(t.cfg.TunnelIPv4) || localAddr.Equal(t.cfg.TunnelIPv6), localAddr, nil
+}
+
+// checkRoute: all the probe targets must be routed through the tunnel
+func (t *tester) checkRoute() types.LeakTestCheck {
+	ret := types.LeakTestCheck{Name: CheckRoute, Status: types.LeakTestPassed}
+
+	targets := make([]net.IP, 0, len(t.probeHosts)+len(t.cfg.ProbeDnsServers))
+	for _, h := range t.probeHosts {
+		targets = append(targets, h.IP)
+	}
+	targets = append(targets, t.cfg.ProbeDnsServers...)
+
+	for _, ip := range targets {
+		if t.isIPv6Skipped(ip) {
+			continue
+		}
+		isTunnel, localAddr, err := t.isRoutedThroughTunnel(ip)
+		switch {
+		case err != nil:
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: no route", ip))
+		case isTunnel:
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: routed through tunnel (%s)", ip, localAddr))
+		default:
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: routed outside tunnel (%s)", ip, localAddr))
+		}
+	}
+	return ret
+}
+
+// checkDns: DNS queries must not reach the resolvers outside the tunnel
+func (t *tester) checkDns() types.LeakTestCheck {
+	ret := types.LeakTestCheck{Name: CheckDns, Status: types.LeakTestPassed}
+
+	// The DNS servers configured by the daemon must be reachable only through the tunnel
+	for _, ip := range t.cfg.DnsServers {
+		if t.isIPv6Skipped(ip) {
+			continue
+		}
+		isTunnel, localAddr, err := t.isRoutedThroughTunnel(ip)
+		switch {
+		case err != nil:
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("configured DNS %s: no route", ip))
+		case !isTunnel:
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("configured DNS %s: queries are routed outside tunnel (%s)", ip, localAddr))
+		default:
+			ret.Details = append(ret.Details, fmt.Sprintf("configured DNS %s: routed through tunnel", ip))
+		}
+	}
+
+	for _, ip := range t.cfg.ProbeDnsServers {
+		if t.isIPv6Skipped(ip) {
+			continue
+		}
+
+		// query using default routing
+		isTunnel, localAddr, _ := t.isRoutedThroughTunnel(ip)
+		if err := t.dnsQuery(ip, nil); err == nil {
+			if isTunnel {
+				ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: answered through tunnel", ip))
+			} else {
+				ret.Status = types.LeakTestFailed
+				ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: answered outside tunnel (%s)", ip, localAddr))
+			}
+		} else {
+			ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: no answer (%s)", ip, err))
+		}
+
+		// query outside the tunnel
+		if t.outIface == nil {
+			continue
+		}
+		if err := t.dnsQuery(ip, t.outIface); err == nil {
+			if t.cfg.IsFirewallEnabled {
+				ret.Status = types.LeakTestFailed
+				ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: answered via '%s' (not blocked by firewall)", ip, t.outIface.Name))
+			} else {
+				ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: answered via '%s' (firewall disabled)", ip, t.outIface.Name))
+			}
+		} else {
+			ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: blocked via '%s'", ip, t.outIface.Name))
+		}
+	}
+	return ret
+}
+
+// checkIPv6: when IPv6 is not in tunnel - the IPv6 traffic must not be able to reach the Internet
+func (t *tester) checkIPv6() types.LeakTestCheck {
+	ret := types.LeakTestCheck{Name: CheckIPv6, Status: types.LeakTestPassed}
+	if t.cfg.IsIPv6InTunnel {
+		ret.Status = types.LeakTestSkipped
+		ret.Details = append(ret.Details, "IPv6 is in tunnel (checked by 'route' test)")
+		return ret
+	}
+
+	for _, h := range t.probeHosts {
+		if h.IP.To4() != nil {
+			continue
+		}
+		localAddr, err := netinfo.GetOutboundIPEx(h.IP)
+		if err != nil {
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: no IPv6 route", h))
+			continue
+		}
+		if err := t.tcpConnect(h, nil); err == nil {
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: connected outside tunnel (%s)", h, localAddr))
+		} else {
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: blocked (%s)", h, err))
+		}
+	}
+	for _, ip := range t.cfg.ProbeDnsServers {
+		if ip.To4() != nil {
+			continue
+		}
+		if err := t.dnsQuery(ip, nil); err == nil {
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: answered outside tunnel", ip))
+		} else {
+			ret.Details = append(ret.Details, fmt.Sprintf("DNS %s: blocked", ip))
+		}
+	}
+
+	if len(ret.Details) == 0 {
+		ret.Status = types.LeakTestSkipped
+		ret.Details = append(ret.Details, "no IPv6 probe targets defined")
+	}
+	return ret
+}
+
+// checkKillSwitch: the connections outside the tunnel interface must be blocked
+func (t *tester) checkKillSwitch() types.LeakTestCheck {
+	ret := types.LeakTestCheck{Name: CheckKillSwitch, Status: types.LeakTestPassed}
+	if !t.cfg.IsFirewallEnabled {
+		ret.Status = types.LeakTestSkipped
+		ret.Details = append(ret.Details, "firewall disabled")
+		return ret
+	}
+	if t.outIface == nil {
+		ret.Status = types.LeakTestSkipped
+		ret.Details = append(ret.Details, "non-tunnel interface not detected")
+		return ret
+	}
+
+	for _, h := range t.probeHosts {
+		if err := t.tcpConnect(h, t.outIface); err == nil {
+			ret.Status = types.LeakTestFailed
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: connected via '%s'", h, t.outIface.Name))
+		} else {
+			ret.Details = append(ret.Details, fmt.Sprintf("%s: blocked via '%s' (%s)", h, t.outIface.Name, err))
+		}
+	}
+	return ret
+}
+
+func (t *tester) dialer(iface *net.Interface) *net.Dialer {
+	d := &net.Dialer{Timeout: t.cfg.Timeout}
+	if iface != nil {
+		d.Control = bindToInterfaceFunc(iface)
+	}
+	return d
+}
+
+func (t *tester) tcpConnect(addr *net.TCPAddr, iface *net.Interface) error {
+	conn, err := t.dialer(iface).Dial("tcp", addr.String())
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// dnsQuery sends DNS query to the resolver (UDP) and waits for the answer.
+// Any valid DNS response (even with error code) means the resolver is reachable.
+func (t *tester) dnsQuery(server net.IP, iface *net.Interface) error {
+	name, err := dnsmessage.NewName(t.cfg.DnsQueryName)
+	if err != nil {
+		return err
+	}
+	id := uint16(rand.Intn(0xffff))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.Timeout)
+	defer cancel()
+
+	conn, err := t.dialer(iface).DialContext(ctx, "udp", net.JoinHostPort(server.String(), strconv.Itoa(t.dnsPort)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(t.cfg.Timeout))
+	if _, err := conn.Write(packet); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.ID != id || !h.Response {
+			continue // not our response
+		}
+		return nil
+	}
+}
+
+// defaultGatewayInterface returns the interface which network contains the default gateway
+func defaultGatewayInterface() (*net.Interface, error) {
+	gw, err := netinfo.DefaultGatewayIP()
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.Contains(gw) {
+				i := iface
+				return &i, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("interface for default gateway %s not found", gw)
+}
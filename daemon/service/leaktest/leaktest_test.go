@@ -0,0 +1,93 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package leaktest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/types"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startTestResolver starts a local DNS stand-in which answers all queries with NXDOMAIN
+func startTestResolver(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			resp := dnsmessage.Message{Header: dnsmessage.Header{ID: h.ID, Response: true, RCode: dnsmessage.RCodeNameError}}
+			if packet, err := resp.Pack(); err == nil {
+				conn.WriteToUDP(packet, addr)
+			}
+		}
+	}()
+	return conn
+}
+
+func TestDnsQuery(t *testing.T) {
+	resolver := startTestResolver(t)
+	defer resolver.Close()
+
+	tst := tester{
+		cfg:     Config{DnsQueryName: defaultDnsQueryName, Timeout: time.Second},
+		dnsPort: resolver.LocalAddr().(*net.UDPAddr).Port,
+	}
+	if err := tst.dnsQuery(net.IPv4(127, 0, 0, 1), nil); err != nil {
+		t.Errorf("expected DNS answer from local resolver: %v", err)
+	}
+
+	resolver.Close()
+	if err := tst.dnsQuery(net.IPv4(127, 0, 0, 1), nil); err == nil {
+		t.Error("expected DNS query to fail when resolver is stopped")
+	}
+}
+
+func TestCheckRouteLoopback(t *testing.T) {
+	tst := tester{
+		cfg:        Config{TunnelIPv4: net.IPv4(10, 0, 0, 1), ProbeDnsServers: []net.IP{net.IPv4(127, 0, 0, 1)}},
+		probeHosts: []*net.TCPAddr{{IP: net.IPv4(127, 0, 0, 2), Port: 443}},
+	}
+	if c := tst.checkRoute(); c.Status != types.LeakTestPassed {
+		t.Errorf("loopback targets must not be reported as leaks: %v", c.Details)
+	}
+}
+
+func TestRunNoTunnel(t *testing.T) {
+	if _, err := Run(Config{}); err == nil {
+		t.Error("expected error when tunnel address is not defined")
+	}
+}
@@ -41,6 +41,7 @@ var (
 	paranoidModeSecretFile string
 
 	settingsFile    string
+	secretsFile     string // (Linux) encrypted storage of the secret data; empty - the secret data is kept in the settings file
 	servicePortFile string
 	serversFile     string
 	logFile         string
@@ -64,12 +65,9 @@ var (
 	wgBinaryPath     string
 	wgToolBinaryPath string
 	wgConfigFilePath string
+	awgBinaryPath    string // AmneziaWG userspace implementation (e.g. 'amneziawg-go'); not defined when AmneziaWG is not supported
 
 	kemHelperBinaryPath string
-
-	dnscryptproxyBinPath        string
-	dnscryptproxyConfigTemplate string
-	dnscryptproxyConfigDir      string // for temporary dnscryptproxy config files
 )
 
 func init() {
@@ -146,22 +144,23 @@ func Init() (warnings []string, errors []error, logInfo []string) {
 	}
 
 	// checling availability of WireGuard binaries
-	if err := checkFileAccessRightsExecutable("wgBinaryPath", wgBinaryPath); err != nil {
-		warnings = append(warnings, fmt.Errorf("WireGuard functionality not accessible: %w", err).Error())
+	if len(wgBinaryPath) > 0 { // not defined on Linux
+		if err := checkFileAccessRightsExecutable("wgBinaryPath", wgBinaryPath); err != nil {
+			warnings = append(warnings, fmt.Errorf("WireGuard functionality not accessible: %w", err).Error())
+		}
 	}
 	if err := checkFileAccessRightsExecutable("wgToolBinaryPath", wgToolBinaryPath); err != nil {
 		warnings = append(warnings, fmt.Errorf("WireGuard functionality not accessible: %w", err).Error())
 	}
 
-	if err := checkFileAccessRightsExecutable("kemHelperBinaryPath", kemHelperBinaryPath); err != nil {
-		warnings = append(warnings, fmt.Errorf("KEM functionality not accessible: %w", err).Error())
+	if len(awgBinaryPath) > 0 {
+		if err := checkFileAccessRightsExecutable("awgBinaryPath", awgBinaryPath); err != nil {
+			warnings = append(warnings, fmt.Errorf("AmneziaWG functionality not accessible: %w", err).Error())
+		}
 	}
 
-	if err := checkFileAccessRightsExecutable("dnscryptproxyBinPath", dnscryptproxyBinPath); err != nil {
-		errors = append(errors, err)
-	}
-	if err := checkFileAccessRightsStaticConfig("dnscryptproxyConfigTemplate", dnscryptproxyConfigTemplate); err != nil {
-		errors = append(errors, err)
+	if err := checkFileAccessRightsExecutable("kemHelperBinaryPath", kemHelperBinaryPath); err != nil {
+		warnings = append(warnings, fmt.Errorf("KEM functionality not accessible: %w", err).Error())
 	}
 
 	if len(routeCommand) > 0 {
@@ -250,6 +249,12 @@ func SettingsFile() string {
 	return settingsFile
 }
 
+// SecretsFile path to the encrypted storage of the secret data (WireGuard keys, session token)
+// Empty value means the secret data is kept in the settings file
+func SecretsFile() string {
+	return secretsFile
+}
+
 // ServicePortFile path to service port file
 func ServicePortFile() string {
 	return servicePortFile
@@ -267,6 +272,11 @@ func ServersFile() string {
 	return serversFile
 }
 
+// DnsBlocklistsCacheDir path to the directory where downloaded DNS block lists are cached
+func DnsBlocklistsCacheDir() string {
+	return filepath.Join(filepath.Dir(serversFile), "dns-blocklists")
+}
+
 // LogFile path to log-file
 func LogFile() string {
 	return logFile
@@ -335,6 +345,7 @@ func RouteCommand() string {
 }
 
 // WgBinaryPath path to WireGuard binary
+// (empty on Linux: the daemon configures WireGuard interface itself)
 func WgBinaryPath() string {
 	return wgBinaryPath
 }
@@ -344,15 +355,17 @@ func WgToolBinaryPath() string {
 	return wgToolBinaryPath
 }
 
+// AwgBinaryPath path to AmneziaWG userspace implementation binary
+// (empty when AmneziaWG is not supported on this platform)
+func AwgBinaryPath() string {
+	return awgBinaryPath
+}
+
 // WGConfigFilePath path to WireGuard configuration file
 func WGConfigFilePath() string {
 	return wgConfigFilePath
 }
 
-func DnsCryptProxyInfo() (binPath, configPathTemplate, configDir string) {
-	return dnscryptproxyBinPath, dnscryptproxyConfigTemplate, dnscryptproxyConfigDir
-}
-
 func KemHelperBinaryPath() string {
 	return kemHelperBinaryPath
 }
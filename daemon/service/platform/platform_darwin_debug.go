@@ -79,10 +79,7 @@ func doOsInitForBuild() (warnings []string, errors []error) {
 
 	wgBinaryPath = path.Join(installDir, "References/macOS/_deps/wg_inst/wireguard-go")
 	wgToolBinaryPath = path.Join(installDir, "References/macOS/_deps/wg_inst/wg")
-
-	dnscryptproxyBinPath = path.Join(installDir, "References/macOS/_deps/dnscryptproxy_inst/dnscrypt-proxy")
-	dnscryptproxyConfigTemplate = path.Join(installDir, "References/common/etc/dnscrypt-proxy-template.toml")
-	dnscryptproxyConfigDir = settingsDir
+	awgBinaryPath = path.Join(installDir, "References/macOS/_deps/amneziawg_inst/amneziawg-go")
 
 	kemHelperBinaryPath = path.Join(installDir, "References/macOS/_deps/kem-helper/kem-helper-bin/kem-helper")
 
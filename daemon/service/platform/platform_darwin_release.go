@@ -65,10 +65,7 @@ func doOsInitForBuild() (warnings []string, errors []error) {
 
 	wgBinaryPath = "/Applications/IVPN.app/Contents/MacOS/WireGuard/wireguard-go"
 	wgToolBinaryPath = "/Applications/IVPN.app/Contents/MacOS/WireGuard/wg"
-
-	dnscryptproxyBinPath = "/Applications/IVPN.app/Contents/MacOS/dnscrypt-proxy/dnscrypt-proxy"
-	dnscryptproxyConfigTemplate = "/Applications/IVPN.app/Contents/Resources/etc/dnscrypt-proxy-template.toml"
-	dnscryptproxyConfigDir = settingsDir
+	awgBinaryPath = "/Applications/IVPN.app/Contents/MacOS/WireGuard/amneziawg-go"
 
 	kemHelperBinaryPath = "/Applications/IVPN.app/Contents/MacOS/kem/kem-helper"
 
@@ -45,6 +45,12 @@ var (
 
 	// path to the readonly servers.json file bundled into the package
 	serversFileBundled string
+
+	// nftables ruleset applied on system boot (when firewall is persistent)
+	bootFirewallRulesFile string
+
+	// original NetworkManager global DNS configuration (saved when the daemon changes it)
+	nmGlobalDnsBackupFile string
 )
 
 const (
@@ -142,10 +148,14 @@ func doInitConstants() {
 	serversFile = path.Join(tmpDir, "servers.json")
 	servicePortFile = path.Join(tmpDir, "port.txt")
 	paranoidModeSecretFile = path.Join(tmpDir, "eaa")
+	secretsFile = path.Join(tmpDir, "secrets")
 
 	logFile = path.Join(logDir, "IVPN_Agent.log")
 
 	openvpnUserParamsFile = path.Join(tmpDir, "ovpn_extra_params.txt")
+
+	bootFirewallRulesFile = path.Join(tmpDir, "boot-firewall.nft")
+	nmGlobalDnsBackupFile = path.Join(tmpDir, "nm-global-dns.json")
 }
 
 func doOsInit() (warnings []string, errors []error, logInfo []string) {
@@ -238,6 +248,17 @@ func FirewallScript() string {
 	return firewallScript
 }
 
+// BootFirewallRulesFile returns path to nftables ruleset which is applied on system boot (when firewall is persistent)
+func BootFirewallRulesFile() string {
+	return bootFirewallRulesFile
+}
+
+// NmGlobalDnsBackupFile returns path to the file which keeps the original NetworkManager global DNS configuration
+// (it is restored on disconnection or on the daemon start, if the daemon was not stopped correctly)
+func NmGlobalDnsBackupFile() string {
+	return nmGlobalDnsBackupFile
+}
+
 // SplitTunScript returns path to script which control split-tunneling functionality
 func SplitTunScript() string {
 	return splitTunScript
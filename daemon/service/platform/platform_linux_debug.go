@@ -71,12 +71,9 @@ func doOsInitForBuild() (warnings []string, errors []error, logInfo []string) {
 	v2rayBinaryPath = path.Join(installDir, "_deps/v2ray_inst/v2ray")
 	v2rayConfigTmpFile = path.Join(tmpDir, "v2ray.json")
 
-	wgBinaryPath = path.Join(installDir, "_deps/wireguard-tools_inst/wg-quick")
+	// wgBinaryPath is not defined: the daemon configures WireGuard interface itself ('wg-quick' is not required)
 	wgToolBinaryPath = path.Join(installDir, "_deps/wireguard-tools_inst/wg")
-
-	dnscryptproxyBinPath = path.Join(installDir, "_deps/dnscryptproxy_inst/dnscrypt-proxy")
-	dnscryptproxyConfigTemplate = path.Join(etcDirCommon, "dnscrypt-proxy-template.toml")
-	dnscryptproxyConfigDir = tmpDir
+	awgBinaryPath = path.Join(installDir, "_deps/amneziawg_inst/amneziawg-go")
 
 	kemHelperBinaryPath = path.Join(installDir, "_deps/kem-helper/kem-helper-bin/kem-helper")
 
@@ -55,12 +55,9 @@ func doOsInitForBuild() (warnings []string, errors []error, logInfo []string) {
 	v2rayBinaryPath = path.Join(installDir, "v2ray/v2ray")
 	v2rayConfigTmpFile = path.Join(tmpDir, "v2ray.json")
 
-	wgBinaryPath = path.Join(installDir, "wireguard-tools/wg-quick")
+	// wgBinaryPath is not defined: the daemon configures WireGuard interface itself ('wg-quick' is not required)
 	wgToolBinaryPath = path.Join(installDir, "wireguard-tools/wg")
-
-	dnscryptproxyBinPath = path.Join(installDir, "dnscrypt-proxy/dnscrypt-proxy")
-	dnscryptproxyConfigTemplate = path.Join(installDir, "etc/dnscrypt-proxy-template.toml")
-	dnscryptproxyConfigDir = tmpDir
+	awgBinaryPath = path.Join(installDir, "amneziawg/amneziawg-go")
 
 	kemHelperBinaryPath = path.Join(installDir, "kem/kem-helper")
 
@@ -109,10 +109,6 @@ func doOsInit() (warnings []string, errors []error, logInfo []string) {
 	wgBinaryPath = path.Join(_installDir, "WireGuard", _wgArchDir, "wireguard.exe")
 	wgToolBinaryPath = path.Join(_installDir, "WireGuard", _wgArchDir, "wg.exe")
 
-	dnscryptproxyBinPath = path.Join(_installDir, "dnscrypt-proxy/dnscrypt-proxy.exe")
-	dnscryptproxyConfigTemplate = path.Join(settingsDirCommon, "dnscrypt-proxy-template.toml")
-	dnscryptproxyConfigDir = path.Join(_installDir, "dnscrypt-proxy")
-
 	kemHelperBinaryPath = path.Join(_installDir, "kem/kem-helper.exe")
 
 	if _, err := os.Stat(wfpDllPath); err != nil {
@@ -59,6 +59,9 @@ type LinuxSpecificUserPrefs struct {
 	// If true - use old style DNS management mechanism
 	// by direct modifying file '/etc/resolv.conf'
 	IsDnsMgmtOldStyle bool
+	// DNS management method (see dns.LinuxDnsMgmt...): "" - auto, "resolvectl", "resolved-dbus", "networkmanager"
+	// Ignored when IsDnsMgmtOldStyle is true
+	DnsMgmtMethod string
 }
 
 // UserPreferences - IVPN service preferences which can be exposed to client
@@ -83,9 +86,17 @@ type Preferences struct {
 	IsFwAllowLAN             bool
 	IsFwAllowLANMulticast    bool
 	IsFwAllowApiServers      bool
-	FwUserExceptions         string // Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	FwUserExceptions         string                         // Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	FwAppsBlocked            []string                       // Firewall: applications (paths to binaries) which are not allowed to communicate
+	FwAppsAllowed            []string                       // Firewall: applications (paths to binaries) which are allowed to communicate even when VPN is disconnected
+	FwLocalInterfaces        []service_types.LocalInterface // Firewall: trusted local interfaces (e.g. bridge interfaces of containers and VMs)
 	IsStopOnClientDisconnect bool
 
+	// DNS: user-defined block lists, allowlist and static hosts entries (enforced by the local DNS forwarder)
+	DnsLocalFilter service_types.DnsLocalFilter
+	// DNS: if 'true' - all DNS queries are processed by the local DNS forwarder to collect DNS statistics
+	DnsStatsCollect bool
+
 	// IsAutoconnectOnLaunch: if 'true' - daemon will perform automatic connection (see 'IsAutoconnectOnLaunchDaemon' for details)
 	IsAutoconnectOnLaunch bool
 	// IsAutoconnectOnLaunchDaemon:
@@ -102,6 +113,12 @@ type Preferences struct {
 	SplitTunnelInversed       bool // Inverse Split Tunnel: only 'splitted' apps use VPN tunnel (applicable only when IsSplitTunnel=true)
 	SplitTunnelAnyDns         bool // (only for Inverse Split Tunnel) When false: Allow only DNS servers specified by the IVPN application
 	SplitTunnelAllowWhenNoVpn bool // (only for Inverse Split Tunnel) Allow connectivity for Split Tunnel apps when VPN is disabled
+	// Destinations (CIDRs or domain names) which always bypass the VPN tunnel
+	// (in Inverse Split Tunnel mode: which always use the VPN tunnel)
+	SplitTunnelDestinations []string
+	// (Linux) Users and groups (names or numeric IDs) which traffic is 'splitted' the same way as traffic of Split Tunnel apps
+	SplitTunnelUsers  []string
+	SplitTunnelGroups []string
 
 	// last known account status
 	Session SessionStatus
@@ -112,6 +129,18 @@ type Preferences struct {
 
 	LastConnectionParams service_types.ConnectionParams
 	WiFiControl          WiFiParams
+
+	// WireGuard: automatically detected MTU values (per network)
+	WgMtuPerNetwork map[string]WgDetectedMtu
+	// WireGuard: KEM algorithms policy for post-quantum PresharedKey exchange (see kem.ParsePolicy()). Empty - default policy.
+	WgKemPolicy string
+}
+
+// WgDetectedMtu - the result of automatic MTU detection for WireGuard connection
+// (see service_types.ConnectionParams.WireGuardParameters.MtuAutoDetect)
+type WgDetectedMtu struct {
+	Mtu  int
+	Time time.Time // time of detection
 }
 
 type SessionMutableData struct {
@@ -173,8 +202,10 @@ func (p *Preferences) UpdateSessionData(sData SessionMutableData) {
 }
 
 // UpdateWgCredentials save wireguard credentials
-func (p *Preferences) UpdateWgCredentials(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPresharedKey string) {
+// ('kemError' - the reason why the configured KEM policy is not applied to the keys; empty - applied)
+func (p *Preferences) UpdateWgCredentials(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPresharedKey string, kemError string) {
 	p.Session.updateWgCredentials(wgPublicKey, wgPrivateKey, wgLocalIP, wgPresharedKey)
+	p.Session.WGKemError = kemError
 	p.SavePreferences()
 }
 
@@ -189,7 +220,9 @@ func (p *Preferences) SavePreferences() error {
 
 	p.Version = version.Version()
 
-	data, err := json.Marshal(p)
+	// the secret data is not saved into the settings file (if the secret store is available)
+	prefs := p.withoutSecrets()
+	data, err := json.Marshal(prefs)
 	if err != nil {
 		return fmt.Errorf("failed to save preferences file (json marshal error): %w", err)
 	}
@@ -218,6 +251,18 @@ func (p *Preferences) SavePreferences() error {
 
 // LoadPreferences loads preferences
 func (p *Preferences) LoadPreferences() error {
+	isSecretsMigrationRequired := false
+	defer func() {
+		// the settings file contains the secret data: move it into the secret store
+		// (must be done after mutexRW unlocked)
+		if isSecretsMigrationRequired {
+			log.Info("Moving the secret data from the settings file into the secret store...")
+			if err := p.SavePreferences(); err != nil {
+				log.Error(fmt.Sprintf("failed to save preferences: %v", err))
+			}
+		}
+	}()
+
 	mutexRW.RLock()
 	defer mutexRW.RUnlock()
 
@@ -247,6 +292,9 @@ func (p *Preferences) LoadPreferences() error {
 		log.Info("Preferences file was restored from temporary file")
 	}
 
+	// read the secret data (session token, WireGuard keys) from the secret store
+	isSecretsMigrationRequired = p.loadSecrets()
+
 	// init WG properties
 	if len(p.Session.WGPublicKey) == 0 || len(p.Session.WGPrivateKey) == 0 || len(p.Session.WGLocalIP) == 0 {
 		p.Session.WGKeyGenerated = time.Time{}
@@ -0,0 +1,118 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/secrets"
+)
+
+// The secret data of the session (session token, WireGuard keys) is kept in the secret store (if supported by the platform)
+// instead of the settings file. The settings files of old versions are migrated on loading.
+
+const (
+	secretSession        = "session"
+	secretWGPrivateKey   = "wg_private_key"
+	secretWGPresharedKey = "wg_preshared_key"
+)
+
+var (
+	secretStore     secrets.Store
+	secretStoreOnce sync.Once
+)
+
+func getSecretStore() secrets.Store {
+	secretStoreOnce.Do(func() {
+		secretStore = secrets.Open(platform.SecretsFile())
+	})
+	return secretStore
+}
+
+// secretFields returns the secret fields of the session (name in the secret store -> field)
+func (s *SessionStatus) secretFields() map[string]*string {
+	return map[string]*string{
+		secretSession:        &s.Session,
+		secretWGPrivateKey:   &s.WGPrivateKey,
+		secretWGPresharedKey: &s.WGPresharedKey,
+	}
+}
+
+// withoutSecrets saves the secret data into the secret store
+// and returns the copy of preferences without secret data (to be saved in the settings file).
+// If the secret store is not available - returns the unchanged copy of preferences.
+func (p *Preferences) withoutSecrets() Preferences {
+	ret := *p
+
+	store := getSecretStore()
+	if store == nil {
+		return ret
+	}
+
+	for name, v := range p.Session.secretFields() {
+		var err error
+		if len(*v) == 0 {
+			err = store.Delete(name)
+		} else {
+			err = store.Set(name, *v)
+		}
+		if err != nil {
+			// keep the secret data in the settings file: it must not be lost
+			log.Error(fmt.Sprintf("failed to save '%s' into the secret store: %s", name, err))
+			return ret
+		}
+	}
+
+	for _, v := range ret.Session.secretFields() {
+		*v = ""
+	}
+	return ret
+}
+
+// loadSecrets reads the secret data from the secret store.
+// Returns 'true' if the settings file contains the secret data (e.g. saved by previous version) which must be moved to the secret store.
+func (p *Preferences) loadSecrets() (isMigrationRequired bool) {
+	store := getSecretStore()
+	if store == nil {
+		return false
+	}
+
+	for name, v := range p.Session.secretFields() {
+		if len(*v) > 0 {
+			isMigrationRequired = true
+			continue
+		}
+		value, err := store.Get(name)
+		if err != nil {
+			if !errors.Is(err, secrets.ErrNotFound) {
+				log.Error(fmt.Sprintf("failed to read '%s' from the secret store: %s", name, err))
+			}
+			continue
+		}
+		*v = value
+	}
+	return isMigrationRequired
+}
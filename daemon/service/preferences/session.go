@@ -38,6 +38,7 @@ type SessionStatus struct {
 	WGPublicKey        string
 	WGPrivateKey       string `json:",omitempty"`
 	WGPresharedKey     string `json:",omitempty"`
+	WGKemError         string `json:",omitempty"` // the reason why the configured KEM policy is not applied to the current WG keys (empty - applied)
 	WGLocalIP          string
 	WGKeyGenerated     time.Time
 	WGKeysRegenInerval time.Duration // syntax error in variable name. Keeping it as is for compatibility with previous versions
@@ -68,6 +69,7 @@ func (s *SessionStatus) updateWgCredentials(wgPublicKey string, wgPrivateKey str
 	s.WGPrivateKey = strings.TrimSpace(wgPrivateKey)
 	s.WGPresharedKey = strings.TrimSpace(wgPresharedKey)
 	s.WGLocalIP = strings.TrimSpace(wgLocalIP)
+	s.WGKemError = ""
 
 	if len(s.WGPublicKey) > 0 && len(s.WGPrivateKey) > 0 && len(s.WGLocalIP) > 0 {
 		s.WGKeyGenerated = time.Now()
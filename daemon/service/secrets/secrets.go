@@ -0,0 +1,57 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package secrets
+
+import (
+	"errors"
+
+	"github.com/ivpn/desktop-app/daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("secret")
+}
+
+// ErrNotFound - the secret does not exist in the store
+var ErrNotFound = errors.New("secret not found")
+
+// Store - storage of the secret data (private keys, session tokens ...)
+type Store interface {
+	// Get returns the secret value (ErrNotFound - if the secret does not exist)
+	Get(name string) (string, error)
+	Set(name string, value string) error
+	// Delete removes the secret (no error if the secret does not exist)
+	Delete(name string) error
+}
+
+// Open returns the secret store supported by the current platform.
+// 'filePath' - the file for the encrypted storage of the secrets.
+// Returns nil if the secret store is not supported (the secrets have to be kept by the caller as before).
+func Open(filePath string) Store {
+	if len(filePath) == 0 {
+		return nil
+	}
+	return openPlatformStore(filePath)
+}
@@ -0,0 +1,147 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package secrets
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// linuxStore keeps the secrets in the kernel keyring.
+// The kernel keyring is not persistent, so the secrets are also saved in the encrypted file (bound to the hardware of the machine):
+// it is in use to restore the keyring after reboot and as a fallback when the kernel keyring is not available.
+// If the machine-bound key is not available - the file is not in use (the secrets are kept in the kernel keyring only
+// and have to be restored by the user after reboot, e.g. log in again).
+type linuxStore struct {
+	keyring *keyringStore // nil - the kernel keyring is not available
+	file    *fileStore    // nil - the machine-bound key is not available
+}
+
+func openPlatformStore(filePath string) Store {
+	keyring := newKeyringStore()
+
+	key, err := machineBoundKey()
+	if err != nil {
+		if keyring == nil {
+			log.Error(fmt.Sprintf("Secret store is not available (kernel keyring is not available and %s)", err))
+			return nil
+		}
+		// the file is not removed: the key may be available again later (e.g. after reboot)
+		log.Warning(fmt.Sprintf("The secrets are kept in the kernel keyring only (they are not persistent across reboots): %s", err))
+		return &linuxStore{keyring: keyring}
+	}
+	return &linuxStore{keyring: keyring, file: newFileStore(filePath, key)}
+}
+
+func (s *linuxStore) Get(name string) (string, error) {
+	if s.keyring != nil {
+		v, err := s.keyring.Get(name)
+		if err == nil || s.file == nil {
+			return v, err
+		}
+		if !errors.Is(err, ErrNotFound) {
+			log.Warning(err)
+		}
+	}
+
+	v, err := s.file.Get(name)
+	if err != nil {
+		return "", err
+	}
+	if s.keyring != nil {
+		// restore the keyring (e.g. after reboot)
+		if err := s.keyring.Set(name, v); err != nil {
+			log.Warning(err)
+		}
+	}
+	return v, nil
+}
+
+func (s *linuxStore) Set(name string, value string) error {
+	if s.keyring != nil {
+		err := s.keyring.Set(name, value)
+		if s.file == nil {
+			return err
+		}
+		if err != nil {
+			log.Warning(err)
+		}
+	}
+	return s.file.Set(name, value)
+}
+
+func (s *linuxStore) Delete(name string) error {
+	if s.keyring != nil {
+		err := s.keyring.Delete(name)
+		if s.file == nil {
+			return err
+		}
+		if err != nil {
+			log.Warning(err)
+		}
+	}
+	return s.file.Delete(name)
+}
+
+// machineBoundKey returns the encryption key which is unique for the machine.
+// It is based on the machine ID and the hardware ID (readable only by root) which is not a part of the system backup
+// (the machine ID from '/etc' alone is a part of the backup of '/etc').
+// When the hardware ID is not available (e.g. some VMs, containers, ARM boards) the key is derived from the machine ID only.
+// Returns error when neither of them is available.
+func machineBoundKey() ([]byte, error) {
+	machineID, err := os.ReadFile("/etc/machine-id")
+	if err != nil {
+		machineID, _ = os.ReadFile("/var/lib/dbus/machine-id")
+	}
+	machineIDStr := strings.TrimSpace(string(machineID))
+
+	hwIDStr, err := hardwareID()
+	if err != nil {
+		if len(machineIDStr) == 0 {
+			return nil, fmt.Errorf("machine ID is not defined and %w", err)
+		}
+		log.Warning(fmt.Sprintf("The secrets file key is derived from the machine ID only (%s)", err))
+	}
+
+	h := sha256.New()
+	h.Write([]byte("ivpn-secrets\n"))
+	h.Write([]byte(machineIDStr + "\n"))
+	h.Write([]byte(hwIDStr))
+	return h.Sum(nil), nil // 32 bytes: AES-256 key
+}
+
+// hardwareID returns the hardware ID of the machine (the system UUID from DMI)
+func hardwareID() (string, error) {
+	hwID, err := os.ReadFile("/sys/class/dmi/id/product_uuid")
+	if err != nil {
+		return "", fmt.Errorf("unable to read hardware ID: %w", err)
+	}
+	hwIDStr := strings.TrimSpace(string(hwID))
+	if len(hwIDStr) == 0 || strings.Trim(hwIDStr, "0-") == "" {
+		return "", fmt.Errorf("hardware ID is not defined")
+	}
+	return hwIDStr, nil
+}
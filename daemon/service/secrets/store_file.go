@@ -0,0 +1,189 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ivpn/desktop-app/daemon/helpers"
+)
+
+// errUndecryptable - the secrets file is damaged or encrypted by another key
+var errUndecryptable = errors.New("failed to decrypt secrets file")
+
+// fileStore - the secrets are saved in the file encrypted (AES-256-GCM) by the key which is bound to the machine
+// (the file is useless when copied to another machine, e.g. from a backup)
+type fileStore struct {
+	mutex    sync.Mutex
+	filePath string
+	key      []byte
+	secrets  map[string]string // nil - not loaded yet
+}
+
+func newFileStore(filePath string, key []byte) *fileStore {
+	return &fileStore{filePath: filePath, key: key}
+}
+
+func (s *fileStore) Get(name string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	v, ok := s.secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *fileStore) Set(name string, value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadOrDiscard(); err != nil {
+		return err
+	}
+	if v, ok := s.secrets[name]; ok && v == value {
+		return nil
+	}
+	s.secrets[name] = value
+	return s.save()
+}
+
+func (s *fileStore) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadOrDiscard(); err != nil {
+		return err
+	}
+	if _, ok := s.secrets[name]; !ok {
+		return nil
+	}
+	delete(s.secrets, name)
+	return s.save()
+}
+
+// loadOrDiscard loads the secrets before modification.
+// If the file can not be decrypted (damaged or encrypted by another key) - its content is discarded (the file will be overwritten).
+// Other errors (e.g. the file is not readable) are returned: the file must not be overwritten.
+func (s *fileStore) loadOrDiscard() error {
+	err := s.load()
+	if err == nil || !errors.Is(err, errUndecryptable) {
+		return err
+	}
+	log.Error(fmt.Sprintf("%s. The stored session and WireGuard keys are discarded!", err))
+	s.secrets = make(map[string]string)
+	return nil
+}
+
+func (s *fileStore) load() error {
+	if s.secrets != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.secrets = make(map[string]string)
+			return nil
+		}
+		return fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	decrypted, err := decrypt(s.key, data)
+	if err != nil {
+		// the data is damaged or encrypted by another key (e.g. the file was copied from another machine)
+		return fmt.Errorf("%w: %s", errUndecryptable, err)
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(decrypted, &secrets); err != nil {
+		return fmt.Errorf("%w: %s", errUndecryptable, err)
+	}
+	s.secrets = secrets
+	return nil
+}
+
+func (s *fileStore) save() error {
+	data, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to save secrets: %w", err)
+	}
+	encrypted, err := encrypt(s.key, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	// write to the temporary file first: the file must not be damaged if the process is interrupted
+	tmpFile := s.filePath + ".tmp"
+	if err := helpers.WriteFile(tmpFile, encrypted, 0600); err != nil { // read\write only for privileged user
+		return fmt.Errorf("failed to save secrets file: %w", err)
+	}
+	if err := os.Rename(tmpFile, s.filePath); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to save secrets file: %w", err)
+	}
+	return nil
+}
+
+// encrypt returns the data encrypted by AES-GCM (format: nonce | ciphertext | tag)
+func encrypt(key []byte, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt returns the data encrypted by encrypt() (error - if the data is damaged or encrypted by another key)
+func decrypt(key []byte, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("data is too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,88 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package secrets
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "secrets")
+	key := sha256.Sum256([]byte("test key"))
+
+	s := newFileStore(filePath, key[:])
+	if _, err := s.Get("name"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+	if err := s.Set("name", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("name2", "value2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("name2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// read by new object
+	s = newFileStore(filePath, key[:])
+	if v, err := s.Get("name"); err != nil || v != "value" {
+		t.Fatalf("expected 'value', got: '%s' (%v)", v, err)
+	}
+	if _, err := s.Get("name2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+
+	// the file can not be read with another key
+	anotherKey := sha256.Sum256([]byte("another key"))
+	s = newFileStore(filePath, anotherKey[:])
+	if v, err := s.Get("name"); err == nil {
+		t.Fatalf("expected error, got: '%s'", v)
+	}
+
+	// the damaged file can not be read
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s = newFileStore(filePath, key[:])
+	if v, err := s.Get("name"); !errors.Is(err, errUndecryptable) {
+		t.Fatalf("expected errUndecryptable, got: '%s' (%v)", v, err)
+	}
+	// ... but it is overwritten by new data
+	if err := s.Set("name", "value3"); err != nil {
+		t.Fatal(err)
+	}
+	s = newFileStore(filePath, key[:])
+	if v, err := s.Get("name"); err != nil || v != "value3" {
+		t.Fatalf("expected 'value3', got: '%s' (%v)", v, err)
+	}
+}
@@ -0,0 +1,108 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	keyringKeyType   = "user"
+	keyringKeyPrefix = "ivpn:"
+	// permissions of the keys: all operations for the possessor and for the owner (root); nothing for others
+	keyringKeyPerm = 0x3f3f0000
+)
+
+// keyringStore - the secrets are kept in the Linux kernel keyring (the user keyring of the daemon user: root).
+// Note: the kernel keyring is not persistent (it is cleared on reboot).
+type keyringStore struct{}
+
+// newKeyringStore returns nil if the kernel keyring is not available (e.g. disabled in the kernel or blocked by seccomp)
+func newKeyringStore() *keyringStore {
+	if _, err := unix.KeyctlGetKeyringID(unix.KEY_SPEC_USER_KEYRING, true); err != nil {
+		log.Info(fmt.Sprintf("Kernel keyring is not available: %s", err))
+		return nil
+	}
+	return &keyringStore{}
+}
+
+func (s *keyringStore) Get(name string) (string, error) {
+	id, err := s.find(name)
+	if err != nil {
+		return "", err
+	}
+
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key '%s' from kernel keyring: %w", name, err)
+	}
+	buf := make([]byte, size)
+	size, err = unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key '%s' from kernel keyring: %w", name, err)
+	}
+	if size > len(buf) {
+		return "", fmt.Errorf("failed to read key '%s' from kernel keyring: key changed", name)
+	}
+	return string(buf[:size]), nil
+}
+
+func (s *keyringStore) Set(name string, value string) error {
+	// the existing key is updated
+	id, err := unix.AddKey(keyringKeyType, keyringKeyPrefix+name, []byte(value), unix.KEY_SPEC_USER_KEYRING)
+	if err != nil {
+		return fmt.Errorf("failed to add key '%s' to kernel keyring: %w", name, err)
+	}
+	if err := unix.KeyctlSetperm(id, keyringKeyPerm); err != nil {
+		return fmt.Errorf("failed to set permissions for key '%s' in kernel keyring: %w", name, err)
+	}
+	return nil
+}
+
+func (s *keyringStore) Delete(name string) error {
+	id, err := s.find(name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, id, unix.KEY_SPEC_USER_KEYRING, 0, 0); err != nil {
+		return fmt.Errorf("failed to remove key '%s' from kernel keyring: %w", name, err)
+	}
+	return nil
+}
+
+func (s *keyringStore) find(name string) (int, error) {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, keyringKeyType, keyringKeyPrefix+name, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYREVOKED) || errors.Is(err, unix.EKEYEXPIRED) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to search key '%s' in kernel keyring: %w", name, err)
+	}
+	return id, nil
+}
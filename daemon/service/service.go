@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -135,6 +136,23 @@ type Service struct {
 	// (UI may send us new connection settings while VPN is connected, e.g., when the user changes connection settings in the UI)
 	_tmpParams      types.ConnectionParams
 	_tmpParamsMutex sync.Mutex
+
+	// local DNS filter: status of the block lists (see service_dnsfilter.go)
+	_dnsFilter struct {
+		_mutex      sync.Mutex
+		_blocklists []types.DnsBlocklistStatus
+	}
+
+	// automatic MTU detection for WireGuard connections (see service_wg_mtu.go)
+	_wgMtu wgMtuState
+
+	// Split Tunnel destination rules: IP addresses of the domain names from DNS answers (see service_splittun_destinations.go)
+	_splitTunDest struct {
+		_mutex         sync.Mutex
+		_applyMutex    sync.Mutex
+		_answers       map[string]*splitTunDestAnswers // [domain]IP addresses
+		_isNotifierSet bool                            // true - the local DNS resolver answers are monitored
+	}
 }
 
 // VpnSessionInfo - Additional information about current VPN connection
@@ -222,11 +240,17 @@ func (s *Service) init() error {
 
 	// initialize dns functionality
 	funcGetDnsExtraSettings := func() dns.DnsExtraSettings {
-		return dns.DnsExtraSettings{Linux_IsDnsMgmtOldStyle: s._preferences.UserPrefs.Linux.IsDnsMgmtOldStyle}
+		return dns.DnsExtraSettings{
+			Linux_IsDnsMgmtOldStyle: s._preferences.UserPrefs.Linux.IsDnsMgmtOldStyle,
+			Linux_DnsMgmtMethod:     s._preferences.UserPrefs.Linux.DnsMgmtMethod,
+		}
 	}
 	if err := dns.Initialize(firewall.OnChangeDNS, funcGetDnsExtraSettings); err != nil {
 		log.Error(fmt.Sprintf("failed to initialize DNS : %s", err))
 	}
+	dns.SetStatsCollection(s._preferences.DnsStatsCollect)
+	// load local DNS block lists (can take time to download remote lists)
+	go s.dnsLocalFilterUpdater()
 
 	// initialize split-tunnel functionality
 	if err := splittun.Initialize(); err != nil {
@@ -234,15 +258,23 @@ func (s *Service) init() error {
 	} else {
 		go func() {
 			<-s._ipStackInitializationWaiter // Wait for IP stack initialization
+			// monitor DNS answers for the domain names of the Split Tunnel destination rules
+			s.splitTunnelDestinationsInitNotifier()
 			// apply Split Tunneling configuration
 			s.splitTunnelling_ApplyConfig()
 		}()
+		go s.splitTunnelDestinationsUpdater()
 	}
 
 	// Logging mus be already initialized (by launcher). Do nothing here.
 	// Init logger (if not initialized before)
 	//logger.Enable(s._preferences.IsLogging)
 
+	// notify clients about packets dropped by the firewall
+	firewall.SetBlockedTrafficNotifier(s._evtReceiver.OnKillSwitchBlockedTraffic)
+	// notify clients when the local DNS forwarder switched to another DNS server
+	dns.SetFailoverNotifier(s._evtReceiver.OnDnsFailover)
+
 	// firewall initial values
 	if err := firewall.AllowLAN(s._preferences.IsFwAllowLAN, s._preferences.IsFwAllowLANMulticast); err != nil {
 		log.Error("Failed to initialize firewall with AllowLAN preference value: ", err)
@@ -253,11 +285,23 @@ func (s *Service) init() error {
 		log.Error("Failed to apply firewall exceptions: ", err)
 	}
 
+	if err := firewall.SetAppsRules(s._preferences.FwAppsBlocked, s._preferences.FwAppsAllowed); err != nil {
+		log.Error("Failed to apply firewall rules for applications: ", err)
+	}
+
+	if err := firewall.SetLocalInterfaces(s._preferences.FwLocalInterfaces); err != nil {
+		log.Error("Failed to apply firewall rules for local interfaces: ", err)
+	}
+
 	if s._preferences.IsFwPersistant {
 		log.Info("Enabling firewal (persistant configuration)")
 		if err := firewall.SetPersistant(true); err != nil {
 			log.Error("Failed to enable firewall: ", err)
 		}
+	} else {
+		if err := firewall.RemoveBootRules(); err != nil {
+			log.Error("Failed to remove boot-time firewall rules: ", err)
+		}
 	}
 
 	// start WireGuard keys rotation
@@ -386,6 +430,18 @@ func (s *Service) unInitialise() error {
 		log.Error(err)
 		updateRetErr(err)
 	}
+	if err := splittun.ApplyUsers(nil, nil); err != nil {
+		log.Error(err)
+		updateRetErr(err)
+	}
+	if err := firewall.SetSplitTunnelExceptions(nil); err != nil {
+		log.Error(err)
+		updateRetErr(err)
+	}
+	if err := splittun.ApplyDestinations(false, false, nil); err != nil {
+		log.Error(err)
+		updateRetErr(err)
+	}
 
 	return retErr
 }
@@ -514,14 +570,24 @@ func (s *Service) GetDisabledFunctions() protocolTypes.DisabledFunctionality {
 		v2rayErr = fmt.Errorf("V2Ray config file path not defined")
 	}
 
-	if err := filerights.CheckFileAccessRightsExecutable(platform.WgBinaryPath()); err != nil {
-		wgErr = fmt.Errorf("WireGuard binary: %w", err)
-	} else {
+	if wgBinary := platform.WgBinaryPath(); len(wgBinary) > 0 { // not defined on Linux
+		if err := filerights.CheckFileAccessRightsExecutable(wgBinary); err != nil {
+			wgErr = fmt.Errorf("WireGuard binary: %w", err)
+		}
+	}
+	if wgErr == nil {
 		if err := filerights.CheckFileAccessRightsExecutable(platform.WgToolBinaryPath()); err != nil {
 			wgErr = fmt.Errorf("WireGuard tools binary: %w", err)
 		}
 	}
 
+	var awgErr error
+	if awgBinary := platform.AwgBinaryPath(); len(awgBinary) == 0 {
+		awgErr = fmt.Errorf("AmneziaWG is not supported on this platform")
+	} else if err := filerights.CheckFileAccessRightsExecutable(awgBinary); err != nil {
+		awgErr = fmt.Errorf("AmneziaWG binary: %w", err)
+	}
+
 	// returns non-nil error object if Split-Tunneling functionality not available
 	splitTunErr, splitTunInversedErr = splittun.GetFuncNotAvailableError()
 
@@ -549,6 +615,9 @@ func (s *Service) GetDisabledFunctions() protocolTypes.DisabledFunctionality {
 	if v2rayErr != nil {
 		ret.V2RayError = v2rayErr.Error()
 	}
+	if awgErr != nil {
+		ret.AmneziaWGError = awgErr.Error()
+	}
 	if splitTunErr != nil {
 		ret.SplitTunnelError = splitTunErr.Error()
 	}
@@ -828,7 +897,9 @@ func (s *Service) GetActiveDNS() (dnsCfg dns.DnsSettings, err error) {
 		return manualDns, nil
 	}
 
-	return dns.DnsSettingsCreate(vpnObj.DefaultDNS()), nil
+	dnsCfg = dns.DnsSettingsCreate(vpnObj.DefaultDNS())
+	dnsCfg.DomainRules = manualDns.DomainRules // split DNS rules (if defined) are applied also for default DNS
+	return dnsCfg, nil
 }
 
 // GetDefaultManualDnsParams returns default manual DNS parameters
@@ -846,6 +917,7 @@ func (s *Service) GetDefaultManualDnsParams() (manualDnsCfg dns.DnsSettings, ant
 
 	if antiTrackerCfg.Enabled {
 		realDnsValue, err = s.getAntiTrackerDns(antiTrackerCfg.Hardcore, antiTrackerCfg.AntiTrackerBlockListName)
+		realDnsValue.DomainRules = manualDnsCfg.DomainRules // split DNS rules are applied also for AntiTracker
 	}
 
 	return manualDnsCfg, antiTrackerCfg, realDnsValue, err
@@ -855,7 +927,7 @@ func (s *Service) GetDefaultManualDnsParams() (manualDnsCfg dns.DnsSettings, ant
 // If 'antiTracker' is enabled - the 'dnsCfg' will be ignored
 func (s *Service) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker types.AntiTrackerMetadata) (changedDns dns.DnsSettings, retErr error) {
 	prefs := s.Preferences()
-	if !dnsCfg.IsEmpty() || antiTracker.Enabled {
+	if !dnsCfg.IsEmpty() || len(dnsCfg.DomainRules) > 0 || antiTracker.Enabled {
 		if prefs.IsInverseSplitTunneling() && prefs.SplitTunnelAnyDns {
 			return dns.DnsSettings{}, fmt.Errorf("custom DNS or AntiTracker cannot be enabled while allowing all DNS for Inverse Split Tunnel mode; please block non-IVPN DNS first in the Inverse Split Tunnel configuration")
 		}
@@ -897,6 +969,7 @@ func (s *Service) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker types.AntiTra
 		if err != err {
 			return dns.DnsSettings{}, err
 		}
+		atDns.DomainRules = dnsCfg.DomainRules // split DNS rules are applied also for AntiTracker
 		changedDns = atDns
 	}
 
@@ -906,8 +979,21 @@ func (s *Service) SetManualDNS(dnsCfg dns.DnsSettings, antiTracker types.AntiTra
 		return changedDns, nil
 	}
 
+	if antiTracker.Enabled {
+		// domains from the local allowlist are resolved by the default DNS of the VPN connection (bypassing AntiTracker block lists)
+		changedDns.DomainRules = append(append([]dns.DnsDomainRule{}, changedDns.DomainRules...), s.dnsLocalFilterAllowlistRules(vpn.DefaultDNS())...)
+	}
+
 	if dnsCfg.IsEmpty() && !antiTracker.Enabled {
-		return dns.DnsSettings{}, vpn.ResetManualDNS()
+		if len(dnsCfg.DomainRules) == 0 {
+			return dns.DnsSettings{}, vpn.ResetManualDNS()
+		}
+		// only split DNS rules defined: all other queries are sent to the default DNS of the VPN connection
+		changedDns = dns.DnsSettingsCreate(vpn.DefaultDNS())
+		changedDns.DomainRules = dnsCfg.DomainRules
+	} else if !antiTracker.Enabled {
+		// custom DNS servers: when all of them are unreachable - the default DNS of the VPN connection is in use
+		changedDns = changedDns.WithFallback(vpn.DefaultDNS())
 	}
 	return changedDns, vpn.SetManualDNS(changedDns)
 }
@@ -1086,10 +1172,18 @@ func (s *Service) KillSwitchState() (status types.KillSwitchStatus, err error) {
 		IsAllowMulticast:  prefs.IsFwAllowLANMulticast,
 		IsAllowApiServers: prefs.IsFwAllowApiServers,
 		UserExceptions:    prefs.FwUserExceptions,
+		AppsBlocked:       prefs.FwAppsBlocked,
+		AppsAllowed:       prefs.FwAppsAllowed,
+		LocalInterfaces:   prefs.FwLocalInterfaces,
 		StateLanAllowed:   isLanAllowed,
 	}, err
 }
 
+// KillSwitchBlockedTraffic returns info about recent outgoing packets dropped by the kill-switch
+func (s *Service) KillSwitchBlockedTraffic() ([]types.BlockedTrafficInfo, error) {
+	return firewall.GetBlockedTraffic()
+}
+
 // SetKillSwitchIsPersistent change kill-switch value
 func (s *Service) SetKillSwitchIsPersistent(isPersistant bool) error {
 	if s.IsPaused() {
@@ -1179,6 +1273,83 @@ func (s *Service) SetKillSwitchUserExceptions(exceptions string, ignoreParsingEr
 	return err
 }
 
+// SetKillSwitchAppsRules set the lists of applications (paths to binaries) which outgoing communication
+// must be blocked or allowed by the firewall
+func (s *Service) SetKillSwitchAppsRules(blockedApps, allowedApps []string) error {
+	normalize := func(apps []string) ([]string, error) {
+		ret := make([]string, 0, len(apps))
+		added := make(map[string]struct{}, len(apps))
+		for _, a := range apps {
+			a = strings.TrimSpace(a)
+			if len(a) == 0 {
+				continue
+			}
+			if !filepath.IsAbs(a) {
+				return nil, fmt.Errorf("the application path must be absolute: '%s'", a)
+			}
+			a = filepath.Clean(a)
+			if _, ok := added[a]; ok {
+				continue
+			}
+			added[a] = struct{}{}
+			ret = append(ret, a)
+		}
+		return ret, nil
+	}
+
+	blockedApps, err := normalize(blockedApps)
+	if err != nil {
+		return err
+	}
+	allowedApps, err = normalize(allowedApps)
+	if err != nil {
+		return err
+	}
+
+	if err := firewall.SetAppsRules(blockedApps, allowedApps); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.FwAppsBlocked = blockedApps
+	prefs.FwAppsAllowed = allowedApps
+	s.setPreferences(prefs)
+
+	s.onKillSwitchStateChanged()
+	return nil
+}
+
+// SetKillSwitchLocalInterfaces set the configuration for trusted local interfaces
+// (e.g. bridge interfaces of containers and VMs: docker0, virbr0, lxcbr0 ...)
+func (s *Service) SetKillSwitchLocalInterfaces(ifaces []types.LocalInterface) error {
+	// remove duplicates (the last configuration for the interface is in use)
+	normalized := make([]types.LocalInterface, 0, len(ifaces))
+	for _, i := range ifaces {
+		i.Name = strings.TrimSpace(i.Name)
+		if err := i.Validate(); err != nil {
+			return err
+		}
+		for idx, n := range normalized {
+			if n.Name == i.Name {
+				normalized = append(normalized[:idx], normalized[idx+1:]...)
+				break
+			}
+		}
+		normalized = append(normalized, i)
+	}
+
+	if err := firewall.SetLocalInterfaces(normalized); err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.FwLocalInterfaces = normalized
+	s.setPreferences(prefs)
+
+	s.onKillSwitchStateChanged()
+	return nil
+}
+
 //////////////////////////////////////////////////////////
 // PREFERENCES
 //////////////////////////////////////////////////////////
@@ -1213,6 +1384,20 @@ func (s *Service) SetPreference(key protocolTypes.ServicePreference, val string)
 			prefs.IsAutoconnectOnLaunchDaemon = val
 		}
 
+	case protocolTypes.Prefs_WgKemPolicy:
+		policy, err := kem.ParsePolicy(val)
+		if err != nil {
+			return false, err
+		}
+		if len(strings.TrimSpace(val)) > 0 {
+			val = policy.String()
+		} else {
+			val = ""
+		}
+		isChanged = val != prefs.WgKemPolicy
+		prefs.WgKemPolicy = val
+		// new policy will be applied on next WireGuard keys generation
+
 	default:
 		log.Warning(fmt.Sprintf("Preference key '%s' not supported", key))
 	}
@@ -1352,7 +1537,10 @@ func (s *Service) SplitTunnelling_GetStatus() (protocolTypes.SplitTunnelStatus,
 		IsAllowWhenNoVpn:            isAllowWhenNoVpn,
 		IsCanGetAppIconForBinary:    oshelpers.IsCanGetAppIconForBinary(),
 		SplitTunnelApps:             prefs.SplitTunnelApps,
-		RunningApps:                 runningProcesses}
+		RunningApps:                 runningProcesses,
+		Destinations:                s.splitTunnelDestinationsStatus(prefs.SplitTunnelDestinations),
+		Users:                       prefs.SplitTunnelUsers,
+		Groups:                      prefs.SplitTunnelGroups}
 
 	return ret, nil
 }
@@ -1406,6 +1594,42 @@ func (s *Service) SplitTunnelling_SetConfig(isEnabled, isInversed, isAnyDns, isA
 
 	return ret
 }
+
+// SplitTunnelling_SetUsers sets the users and groups (names or numeric IDs) which traffic is 'splitted'
+// the same way as traffic of the applications in Split Tunnel environment (applicable for Linux)
+func (s *Service) SplitTunnelling_SetUsers(users, groups []string) error {
+	if stErr, _ := splittun.GetFuncNotAvailableError(); stErr != nil {
+		return stErr
+	}
+
+	normalize := func(names []string) []string {
+		ret := make([]string, 0, len(names))
+		for _, n := range names {
+			n = strings.TrimSpace(n)
+			if len(n) > 0 && !containsString(ret, n) {
+				ret = append(ret, n)
+			}
+		}
+		return ret
+	}
+
+	prefsOld := s._preferences
+	prefs := prefsOld
+	prefs.SplitTunnelUsers = normalize(users)
+	prefs.SplitTunnelGroups = normalize(groups)
+	s.setPreferences(prefs)
+
+	ret := s.splitTunnelling_ApplyConfig()
+	if ret != nil {
+		// if error - restore old preferences and apply configuration
+		s.setPreferences(prefsOld)
+		if err := s.splitTunnelling_ApplyConfig(); err != nil {
+			log.Error(fmt.Errorf("failed to restore SplitTunnel configuration: %w", err))
+		}
+	}
+	return ret
+}
+
 func (s *Service) splitTunnelling_Reset() error {
 	prefs := s._preferences
 	prefs.IsSplitTunnel = false
@@ -1413,6 +1637,9 @@ func (s *Service) splitTunnelling_Reset() error {
 	prefs.SplitTunnelAnyDns = false
 	prefs.SplitTunnelAllowWhenNoVpn = false
 	prefs.SplitTunnelApps = make([]string, 0)
+	prefs.SplitTunnelDestinations = nil
+	prefs.SplitTunnelUsers = nil
+	prefs.SplitTunnelGroups = nil
 	s.setPreferences(prefs)
 
 	splittun.Reset()
@@ -1481,7 +1708,8 @@ func (s *Service) splitTunnelling_ApplyConfig() (retError error) {
 			return fmt.Errorf("failed to apply the firewall rule to allow DNS requests only to the IVPN server: %w", err)
 		}
 		if !dnsCfg.IsEmpty() {
-			dnsAddrUnencrypted := dnsCfg.GetUnencryptedServersAddresses()
+			// DNSCrypt servers and relays defined by DNS stamps can use DNS port too
+			dnsAddrUnencrypted := append(dnsCfg.GetUnencryptedServersAddresses(), dnsCfg.GetStampsServersAddresses()...)
 			if len(dnsAddrUnencrypted) > 0 {
 				if err := firewall.SingleDnsRuleOn(dnsAddrUnencrypted); err != nil {
 					return fmt.Errorf("failed to apply the firewall rule to allow DNS requests only to the IVPN server: %w", err)
@@ -1491,7 +1719,19 @@ func (s *Service) splitTunnelling_ApplyConfig() (retError error) {
 	}
 
 	// Apply Split-Tun config
-	return splittun.ApplyConfig(prefs.IsSplitTunnel, prefs.IsInverseSplitTunneling(), prefs.SplitTunnelAllowWhenNoVpn, isVpnConnected, addressesCfg, prefs.SplitTunnelApps)
+	if err := splittun.ApplyConfig(prefs.IsSplitTunnel, prefs.IsInverseSplitTunneling(), prefs.SplitTunnelAllowWhenNoVpn, isVpnConnected, addressesCfg, prefs.SplitTunnelApps); err != nil {
+		return err
+	}
+	// Apply Split-Tun users rules
+	if prefs.IsSplitTunnel {
+		if err := splittun.ApplyUsers(prefs.SplitTunnelUsers, prefs.SplitTunnelGroups); err != nil {
+			return err
+		}
+	} else if err := splittun.ApplyUsers(nil, nil); err != nil {
+		return err
+	}
+	// Apply Split-Tun destination rules
+	return s.splitTunnelDestinationsApply()
 }
 
 func (s *Service) SplitTunnelling_AddApp(exec string) (cmdToExecute string, isAlreadyRunning bool, err error) {
@@ -1503,6 +1743,18 @@ func (s *Service) SplitTunnelling_AddApp(exec string) (cmdToExecute string, isAl
 	return s.implSplitTunnelling_AddApp(exec)
 }
 
+// SplitTunnelling_AddAppPath adds the application to the Split Tunnel configuration.
+// Linux: path to the binary or '.desktop' file; the application is added to Split Tunnel environment automatically each time it starts.
+// Windows: path to the binary (the same as SplitTunnelling_AddApp()).
+func (s *Service) SplitTunnelling_AddAppPath(path string) error {
+	if !s._preferences.IsSplitTunnel {
+		return fmt.Errorf("unable to add application to Split Tunnel configuration: Split Tunnel is disabled")
+	}
+	// apply ST configuration after function ends
+	defer s.splitTunnelling_ApplyConfig()
+	return s.implSplitTunnelling_AddAppPath(path)
+}
+
 func (s *Service) SplitTunnelling_RemoveApp(pid int, exec string) (err error) {
 	// apply ST configuration after function ends
 	defer s.splitTunnelling_ApplyConfig()
@@ -1527,7 +1779,7 @@ func (s *Service) SplitTunnelling_AddedPidInfo(pid int, exec string, cmdToExecut
 // SESSIONS
 //////////////////////////////////////////////////////////
 
-func (s *Service) setCredentials(accountInfo preferences.AccountStatus, accountID, session, deviceName, vpnUser, vpnPass, wgPublicKey, wgPrivateKey, wgLocalIP string, wgKeyGenerated int64, wgPreSharedKey string) error {
+func (s *Service) setCredentials(accountInfo preferences.AccountStatus, accountID, session, deviceName, vpnUser, vpnPass, wgPublicKey, wgPrivateKey, wgLocalIP string, wgKeyGenerated int64, wgPreSharedKey string, kemError string) error {
 	// save session info
 	s._preferences.SetSession(accountInfo,
 		accountID,
@@ -1546,6 +1798,12 @@ func (s *Service) setCredentials(accountInfo preferences.AccountStatus, accountI
 		s._preferences.SavePreferences()
 	}
 
+	// manually set info about KEM policy failure
+	if len(kemError) > 0 {
+		s._preferences.Session.WGKemError = kemError
+		s._preferences.SavePreferences()
+	}
+
 	// notify clients about session update
 	s._evtReceiver.OnServiceSessionChanged()
 
@@ -1588,21 +1846,7 @@ func (s *Service) SessionNew(accountID string, forceLogin bool, captchaID string
 	}
 
 	// Generate keys for Key Encapsulation Mechanism using post-quantum cryptographic algorithms
-	var kemKeys api_types.KemPublicKeys
-	kemHelper, err := kem.CreateHelper(platform.KemHelperBinaryPath(), kem.GetDefaultKemAlgorithms())
-	if err != nil {
-		log.Error("Failed to generate KEM keys: ", err)
-	} else {
-		kemKeys.KemLibraryVersion = kemHelper.GetPublicKeyLiboqsVersion()
-		kemKeys.KemPublicKey_Kyber1024, err = kemHelper.GetPublicKey(kem.AlgName_Kyber1024)
-		if err != nil {
-			log.Error(err)
-		}
-		kemKeys.KemPublicKey_ClassicMcEliece348864, err = kemHelper.GetPublicKey(kem.AlgName_ClassicMcEliece348864)
-		if err != nil {
-			log.Error(err)
-		}
-	}
+	kemHelper, kemKeys, kemErr := createKemKeys(s.WgKemPolicy())
 
 	log.Info("Logging in...")
 	defer func() {
@@ -1660,18 +1904,29 @@ func (s *Service) SessionNew(accountID string, forceLogin bool, captchaID string
 		}
 
 		if kemHelper != nil {
-			if len(successResp.WireGuard.KemCipher_Kyber1024) == 0 && len(successResp.WireGuard.KemCipher_ClassicMcEliece348864) == 0 {
+			if len(successResp.WireGuard.KemCipher_Lattice) == 0 && len(successResp.WireGuard.KemCipher_ClassicMcEliece348864) == 0 {
+				kemErr = fmt.Errorf("the server did not respond with KEM ciphers")
 				log.Warning("The server did not respond with KEM ciphers. The WireGuard PresharedKey has not been initialized!")
 			} else {
-				if err := kemHelper.SetCipher(kem.AlgName_Kyber1024, successResp.WireGuard.KemCipher_Kyber1024); err != nil {
-					log.Error(err)
-				}
-				if err := kemHelper.SetCipher(kem.AlgName_ClassicMcEliece348864, successResp.WireGuard.KemCipher_ClassicMcEliece348864); err != nil {
+				if err := kemHelper.SetCiphers(successResp.WireGuard.KemCipher_Lattice, successResp.WireGuard.KemAlgorithm_Lattice, successResp.WireGuard.KemCipher_ClassicMcEliece348864); err != nil {
+					if fallback, ok := kemHelper.Policy().KyberFallback(); ok {
+						// the server does not support the lattice algorithm: log in again using Kyber1024
+						log.Warning(fmt.Sprintf("%s. Retry Log-in using KEM policy '%s'...", err, fallback))
+						kemHelper, kemKeys, kemErr = createKemKeys(fallback)
+						if kemErr == nil {
+							kemErr = fmt.Errorf("%w (KEM policy '%s' is in use)", err, fallback)
+						}
+						if err := s.SessionDelete(true); err != nil {
+							log.Error("Creating new session (retry with KEM fallback) -> Failed to delete active session: ", err)
+						}
+						continue
+					}
 					log.Error(err)
 				}
 
 				wgPresharedKey, err = kemHelper.CalculatePresharedKey()
 				if err != nil {
+					kemErr = fmt.Errorf("failed to decode KEM ciphers: %w", err)
 					log.Error(fmt.Sprintf("Failed to decode KEM ciphers! (%s). Retry Log-in without WireGuard PresharedKey...", err))
 					kemHelper = nil
 					kemKeys = api_types.KemPublicKeys{}
@@ -1687,6 +1942,10 @@ func (s *Service) SessionNew(accountID string, forceLogin bool, captchaID string
 	// get account status info
 	accountInfo = s.createAccountStatus(successResp.ServiceStatus)
 
+	kemErrStr := ""
+	if kemErr != nil {
+		kemErrStr = kemErr.Error()
+	}
 	s.setCredentials(accountInfo,
 		accountID,
 		successResp.Token,
@@ -1695,7 +1954,7 @@ func (s *Service) SessionNew(accountID string, forceLogin bool, captchaID string
 		successResp.VpnPassword,
 		publicKey,
 		privateKey,
-		successResp.WireGuard.IPAddress, 0, wgPresharedKey)
+		successResp.WireGuard.IPAddress, 0, wgPresharedKey, kemErrStr)
 
 	log.Info(fmt.Sprintf("(logging in) WG keys updated (%s:%s; psk:%v)", successResp.WireGuard.IPAddress, publicKey, len(wgPresharedKey) > 0))
 
@@ -1931,27 +2190,42 @@ func (s *Service) stopSessionChecker() {
 //////////////////////////////////////////////////////////
 
 // WireGuardSaveNewKeys saves WG keys
-func (s *Service) WireGuardSaveNewKeys(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPresharedKey string) {
-	s._preferences.UpdateWgCredentials(wgPublicKey, wgPrivateKey, wgLocalIP, wgPresharedKey)
+// ('kemError' - the reason why the configured KEM policy is not applied to the keys; empty - applied)
+func (s *Service) WireGuardSaveNewKeys(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPresharedKey string, kemError string) {
+	s._preferences.UpdateWgCredentials(wgPublicKey, wgPrivateKey, wgLocalIP, wgPresharedKey, kemError)
 
 	// notify clients about session (wg keys) update
 	s._evtReceiver.OnServiceSessionChanged()
 
 	go func() {
-		// reconnect in separate routine (do not block current thread)
+		// apply new keys (or reconnect) in separate routine (do not block current thread)
 		vpnObj := s._vpn
 		if vpnObj == nil {
 			return
 		}
-		if vpnObj.Type() != vpn.WireGuard {
+		wgObj, ok := vpnObj.(*wireguard.WireGuard)
+		if !ok || !s.Connected() {
+			return
+		}
+
+		// Apply the new keys to the active connection (the tunnel is not dropped).
+		// The current session is kept with the previous keys until the new key is propagated to the VPN servers (it may take few minutes).
+		// IMPORTANT! : WireGuard 'pause/resume' state is based on complete VPN disconnection and connection back (on all platforms),
+		// so in 'pause' state the new keys are applied on resume.
+		err := wgObj.UpdateCredentials(wgPrivateKey, wgPresharedKey, net.ParseIP(wgLocalIP))
+		if err == nil {
+			log.Info("New WireGuard credentials applied to the active connection")
+			return
+		}
+		var reconnectErr *vpn.ReconnectionRequiredError
+		if !errors.As(err, &reconnectErr) {
+			log.Warning(fmt.Sprintf("%s. The new credentials will be in use for the next connection", err))
 			return
 		}
-		if !s.Connected() || (s.Connected() && s.IsPaused()) {
-			// IMPORTANT! : WireGuard 'pause/resume' state is based on complete VPN disconnection and connection back (on all platforms)
-			// If this will be changed (e.g. just changing routing) - it will be necessary to implement reconnection even in 'pause' state
+		if s.IsPaused() {
 			return
 		}
-		log.Info("Reconnecting WireGuard connection with new credentials...")
+		log.Info(fmt.Sprintf("Unable to apply new credentials to the active connection (%s). Reconnecting WireGuard connection with new credentials...", err))
 		s.reconnect()
 	}()
 }
@@ -1982,6 +2256,33 @@ func (s *Service) WireGuardGetKeys() (session, wgPublicKey, wgPrivateKey, wgLoca
 		p.Session.WGKeysRegenInerval
 }
 
+// WgKemPolicy returns KEM algorithms policy for WireGuard PresharedKey exchange
+func (s *Service) WgKemPolicy() kem.Policy {
+	policy, err := kem.ParsePolicy(s._preferences.WgKemPolicy)
+	if err != nil {
+		log.Warning(fmt.Sprintf("%v; using default KEM policy", err))
+		return kem.DefaultPolicy()
+	}
+	return policy
+}
+
+// createKemKeys generates KEM keys for the policy.
+// Returns nil helper and empty keys (the WireGuard PresharedKey will not be initialized) when failed.
+func createKemKeys(policy kem.Policy) (*kem.KemHelper, api_types.KemPublicKeys, error) {
+	var kemKeys api_types.KemPublicKeys
+	kemHelper, err := kem.CreateHelperForPolicy(platform.KemHelperBinaryPath(), policy)
+	if err == nil {
+		kemKeys.KemLibraryVersion = kemHelper.GetLibraryVersion()
+		kemKeys.KemAlgorithm_Lattice = kemHelper.GetLatticeAlgorithm()
+		kemKeys.KemPublicKey_Lattice, kemKeys.KemPublicKey_ClassicMcEliece348864, err = kemHelper.GetPublicKeys()
+	}
+	if err != nil {
+		log.Error("Failed to generate KEM keys: ", err)
+		return nil, api_types.KemPublicKeys{}, fmt.Errorf("failed to generate KEM keys: %w", err)
+	}
+	return kemHelper, kemKeys, nil
+}
+
 // WireGuardGenerateKeys - generate new wireguard keys
 func (s *Service) WireGuardGenerateKeys(updateIfNecessary bool) error {
 	if !s._preferences.Session.IsLoggedIn() {
@@ -35,9 +35,11 @@ import (
 	"time"
 
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/awg"
 	"github.com/ivpn/desktop-app/daemon/helpers"
 	"github.com/ivpn/desktop-app/daemon/netinfo"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
+	"github.com/ivpn/desktop-app/daemon/ptransport"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
@@ -154,6 +156,13 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 	//  We need this info to notify correct data about vpn.CONNECTED state: for V2Ray connection the original parameters are overwriten by local V2Ray proxy params ('127.0.0.1:local_port')
 	var originalEntryServerInfo *svrConnInfo
 	var v2RayWrapper *v2r.V2RayWrapper
+	if params.AmneziaWG() != awg.None && params.V2Ray() != v2r.None {
+		return fmt.Errorf("AmneziaWG obfuscation can not be used together with V2Ray")
+	}
+	if params.VpnType == vpn.OpenVPN && params.OpenVpnParameters.PluggableTransport.IsDefined() &&
+		(params.V2Ray() != v2r.None || params.OpenVpnParameters.Obfs4proxy.IsObfsproxy()) {
+		return fmt.Errorf("pluggable transport can not be used together with V2Ray or obfsproxy")
+	}
 	if params.V2Ray() == v2r.QUIC || params.V2Ray() == v2r.TCP {
 		disabledFuncs := s.GetDisabledFunctions()
 		if len(disabledFuncs.V2RayError) > 0 {
@@ -242,7 +251,7 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 			params.OpenVpnParameters.Obfs4proxy = obfsproxy.Config{}
 		}
 
-		return s.connectOpenVPN(originalEntryServerInfo, connectionParams, params.ManualDNS, params.Metadata.AntiTracker, params.FirewallOn, params.FirewallOnDuringConnection, params.OpenVpnParameters.Obfs4proxy, v2RayWrapper)
+		return s.connectOpenVPN(originalEntryServerInfo, connectionParams, params.ManualDNS, params.Metadata.AntiTracker, params.FirewallOn, params.FirewallOnDuringConnection, params.OpenVpnParameters.Obfs4proxy, params.OpenVpnParameters.PluggableTransport, v2RayWrapper)
 
 	} else if vpn.Type(params.VpnType) == vpn.WireGuard {
 		if len(params.WireGuardParameters.EntryVpnServer.Hosts) < 1 {
@@ -297,14 +306,43 @@ func (s *Service) Connect(params types.ConnectionParams) (err error) {
 				params.WireGuardParameters.Mtu)
 		}
 
-		return s.connectWireGuard(originalEntryServerInfo, connectionParams, params.ManualDNS, params.Metadata.AntiTracker, params.FirewallOn, params.FirewallOnDuringConnection, v2RayWrapper)
+		// AmneziaWG obfuscation
+		if params.AmneziaWG() != awg.None {
+			if disabledFuncs := s.GetDisabledFunctions(); len(disabledFuncs.AmneziaWGError) > 0 {
+				return errors.New(disabledFuncs.AmneziaWGError)
+			}
+			if hostValue.AmneziaWG == nil {
+				return fmt.Errorf("AmneziaWG obfuscation parameters are not defined for the host")
+			}
+			if err := hostValue.AmneziaWG.Validate(); err != nil {
+				return err
+			}
+			connectionParams.SetAmneziaWG(*hostValue.AmneziaWG)
+		}
+
+		// Automatic MTU detection (only when MTU is not defined by user)
+		var mtuProbeParams *wgMtuProbeParams
+		if params.WireGuardParameters.MtuAutoDetect && params.WireGuardParameters.Mtu <= 0 {
+			mtuProbeParams = &wgMtuProbeParams{host: net.ParseIP(hostValue.Host)}
+			if v2RayWrapper != nil {
+				// the WireGuard traffic is encapsulated by V2Ray: probing the path to the V2Ray server
+				v2RayRemoteHost, _, err := v2RayWrapper.GetRemoteEndpoint()
+				if err != nil {
+					return fmt.Errorf("failed to get V2Ray remote endpoint: %w", err)
+				}
+				mtuProbeParams.host = v2RayRemoteHost
+				mtuProbeParams.v2rayType = originalEntryServerInfo.V2RayProxyType
+			}
+		}
+
+		return s.connectWireGuard(originalEntryServerInfo, connectionParams, params.ManualDNS, params.Metadata.AntiTracker, params.FirewallOn, params.FirewallOnDuringConnection, v2RayWrapper, mtuProbeParams)
 	}
 
 	return fmt.Errorf("unexpected VPN type to connect (%v)", params.VpnType)
 }
 
 // connectOpenVPN start OpenVPN connection
-func (s *Service) connectOpenVPN(originalEntryServerInfo *svrConnInfo, connectionParams openvpn.ConnectionParams, manualDNS dns.DnsSettings, antiTracker types.AntiTrackerMetadata, firewallOn bool, firewallDuringConnection bool, obfsproxyConfig obfsproxy.Config, v2rayWrapper *v2r.V2RayWrapper) error {
+func (s *Service) connectOpenVPN(originalEntryServerInfo *svrConnInfo, connectionParams openvpn.ConnectionParams, manualDNS dns.DnsSettings, antiTracker types.AntiTrackerMetadata, firewallOn bool, firewallDuringConnection bool, obfsproxyConfig obfsproxy.Config, ptConfig ptransport.Config, v2rayWrapper *v2r.V2RayWrapper) error {
 
 	createVpnObjfunc := func() (vpn.Process, error) {
 		prefs := s.Preferences()
@@ -317,6 +355,15 @@ func (s *Service) connectOpenVPN(originalEntryServerInfo *svrConnInfo, connectio
 		if obfsproxyConfig.IsObfsproxy() && len(disabledFuncs.ObfsproxyError) > 0 {
 			return nil, fmt.Errorf(disabledFuncs.ObfsproxyError)
 		}
+		if ptConfig.IsDefined() {
+			if err := ptConfig.Validate(); err != nil {
+				return nil, err
+			}
+			// the PT binary is running with privileges of the daemon; it must not be modifiable by non-privileged users
+			if err := filerights.CheckFileAccessRightsExecutable(ptConfig.BinaryPath); err != nil {
+				return nil, fmt.Errorf("pluggable transport binary is not allowed: %w", err)
+			}
+		}
 
 		connectionParams.SetCredentials(prefs.Session.OpenVPNUser, prefs.Session.OpenVPNPass)
 
@@ -370,7 +417,15 @@ func (s *Service) connectOpenVPN(originalEntryServerInfo *svrConnInfo, connectio
 		}
 
 		// initialize obfsproxy parameters
-		obfsParams := openvpn.ObfsParams{Config: obfsproxyConfig}
+		obfsParams := openvpn.ObfsParams{Config: obfsproxyConfig, Transport: ptConfig}
+		if obfsParams.Transport.IsDefined() {
+			// the hosts which the transport connects to (e.g. 'webtunnel' server) must be allowed by firewall and routed outside the tunnel
+			endpoints, err := obfsParams.Transport.ResolveEndpoints()
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize pluggable transport configuration: %w", err)
+			}
+			obfsParams.TransportEndpoints = endpoints
+		}
 		if obfsParams.Config.IsObfsproxy() {
 			svrs, err := s.ServersList()
 			if err != nil {
@@ -434,7 +489,7 @@ func (s *Service) connectOpenVPN(originalEntryServerInfo *svrConnInfo, connectio
 }
 
 // connectWireGuard start WireGuard connection
-func (s *Service) connectWireGuard(originalEntryServerInfo *svrConnInfo, connectionParams wireguard.ConnectionParams, manualDNS dns.DnsSettings, antiTracker types.AntiTrackerMetadata, firewallOn bool, firewallDuringConnection bool, v2rayWrapper *v2r.V2RayWrapper) error {
+func (s *Service) connectWireGuard(originalEntryServerInfo *svrConnInfo, connectionParams wireguard.ConnectionParams, manualDNS dns.DnsSettings, antiTracker types.AntiTrackerMetadata, firewallOn bool, firewallDuringConnection bool, v2rayWrapper *v2r.V2RayWrapper, mtuProbeParams *wgMtuProbeParams) error {
 	// stop active connection (if exists)
 	if err := s.Disconnect(); err != nil {
 		return fmt.Errorf("failed to connect. Unable to stop active connection: %w", err)
@@ -475,6 +530,11 @@ func (s *Service) connectWireGuard(originalEntryServerInfo *svrConnInfo, connect
 		}
 		connectionParams.SetCredentials(session.WGPrivateKey, session.WGPresharedKey, localip)
 
+		if mtuProbeParams != nil {
+			// detecting MTU on each (re)connection: the network could be changed
+			connectionParams.SetMtu(s.wgMtuDetect(*mtuProbeParams))
+		}
+
 		vpnObj, err := wireguard.NewWireGuardObject(
 			platform.WgBinaryPath(),
 			platform.WgToolBinaryPath(),
@@ -487,6 +547,7 @@ func (s *Service) connectWireGuard(originalEntryServerInfo *svrConnInfo, connect
 		return vpnObj, nil
 	}
 
+	defer s.wgMtuReset()
 	return s.keepConnection(originalEntryServerInfo, createVpnObjfunc, manualDNS, antiTracker, firewallOn, firewallDuringConnection, v2rayWrapper)
 }
 
@@ -510,6 +571,8 @@ func (s *Service) keepConnection(originalEntryServerInfo *svrConnInfo, createVpn
 
 	// save initial DNS configuration
 	s.saveDefaultDnsParams(initialManualDNS, initialAntiTracker)
+	// DNS statistics are collected per connection session
+	dns.StatsReset()
 
 	// Not necessary to keep connection until we are not connected
 	// So just 'Connect' required for now
@@ -692,6 +755,10 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 	destinationIpAddresses := make([]net.IP, 0)
 	// Add VPN server IP to firewall exceptions
 	destinationIpAddresses = append(destinationIpAddresses, vpnProc.DestinationIP())
+	if ovpn, ok := vpnProc.(*openvpn.OpenVPN); ok {
+		// Add to firewall exceptions the hosts which the pluggable transport connects to
+		destinationIpAddresses = append(destinationIpAddresses, ovpn.TransportEndpoints()...)
+	}
 
 	if v2rayWrapper != nil {
 		// Configure firewall to allow V2Ray remote IP
@@ -844,12 +911,14 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 
 		for isRuning := true; isRuning; {
 			needToReconnect := false // true - need to reconnect VPN
+			isRoaming := false       // true - the network changed but the VPN connection is kept (e.g. WireGuard)
 			var routeMsg INetChangeDetectorMessage
 
 			select {
 			case routeMsg = <-routesChangedChan:
 				if routeMsg.IsInterfaceLeak() {
 					needToReconnect = s._vpn.IsReconnectRequiredOnRoutingChange()
+					isRoaming = !needToReconnect
 				}
 			case <-stopChannel:
 				isRuning = false
@@ -883,19 +952,37 @@ func (s *Service) connect(originalEntryServerInfo *svrConnInfo, vpnProc vpn.Proc
 				}
 			}
 
+			if isRoaming {
+				log.Info("Route change detected. Keeping the VPN connection over the new network...")
+				// ensure the VPN server is still accessible over the new network (firewall exceptions)
+				const onlyForICMP = false
+				const isPersistent = false
+				if err := firewall.AddHostsToExceptions(destinationIpAddresses, onlyForICMP, isPersistent); err != nil {
+					log.Error("Unable to add host to firewall exceptions:", err.Error())
+				}
+			}
+
 			// If V2Ray is in use - we must update route to V2Ray server each time when default gateway IP was chnaged
 			// Must be done before 's._vpn.OnRoutingChanged()' because it can change the default route
 			var v2RayErr error = nil
 			if v2rayWrapper != nil {
-				force := routeMsg.NewDefaultGateway() != nil
+				force := routeMsg.NewDefaultGateway() != nil || isRoaming
 				v2RayErr = s.updateV2RayRoute(v2rayWrapper, force)
 			}
 
+			// Check the MTU if the network changed (if automatic MTU detection is in use)
+			go s.wgMtuOnNetworkChanged()
+
 			// Notify VPN object about routing changes
-			// Currently, it is in use for macOS + WireGuard
+			// Currently, it is in use for WireGuard: keeping the connection over the new network (roaming)
 			// Note: it can change the default route!
 			if v2RayErr == nil {
-				s._vpn.OnRoutingChanged()
+				var reconnectErr *vpn.ReconnectionRequiredError
+				if err := s._vpn.OnRoutingChanged(); errors.As(err, &reconnectErr) && !s._vpn.IsPaused() {
+					log.Info(fmt.Sprintf("Unable to keep the VPN connection after the route change (%s). Reconnecting...", err))
+					go s.reconnect()
+					continue
+				}
 			}
 
 			// Ensure that current DNS configuration is correct. If not - it re-apply the required configuration.
@@ -57,6 +57,9 @@ func (s *Service) implSplitTunnelling_AddApp(binaryFile string) (requiredCmdToEx
 	// Split Tunneling is not implemented for macOS
 	return "", false, nil
 }
+func (s *Service) implSplitTunnelling_AddAppPath(binaryFile string) error {
+	return fmt.Errorf("function not applicable for this platform")
+}
 func (s *Service) implSplitTunnelling_RemoveApp(pid int, binaryPath string) (err error) {
 	// Split Tunneling is not implemented for macOS
 	return nil
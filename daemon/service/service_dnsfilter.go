@@ -0,0 +1,208 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/dns/blocklist"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+// DnsLocalFilter returns the local DNS filter configuration (block lists, allowlist, static hosts)
+// and the status of the block lists
+func (s *Service) DnsLocalFilter() (types.DnsLocalFilter, []types.DnsBlocklistStatus) {
+	s._dnsFilter._mutex.Lock()
+	defer s._dnsFilter._mutex.Unlock()
+
+	status := append([]types.DnsBlocklistStatus{}, s._dnsFilter._blocklists...)
+	return s._preferences.DnsLocalFilter, status
+}
+
+// SetDnsLocalFilter updates the local DNS filter configuration.
+// The rules are enforced by the local DNS forwarder in front of the configured DNS servers (or AntiTracker)
+func (s *Service) SetDnsLocalFilter(cfg types.DnsLocalFilter) error {
+	cfg, err := cfg.Normalize()
+	if err != nil {
+		return err
+	}
+
+	prefs := s._preferences
+	prefs.DnsLocalFilter = cfg
+	s.setPreferences(prefs)
+
+	return s.dnsLocalFilterApply(false, true)
+}
+
+// UpdateDnsLocalBlocklists downloads the remote block lists (even if the cached copies are not outdated)
+func (s *Service) UpdateDnsLocalBlocklists() error {
+	return s.dnsLocalFilterApply(true, false)
+}
+
+// dnsLocalFilterUpdater applies the local DNS filter on the daemon start
+// and periodically updates the remote block lists
+func (s *Service) dnsLocalFilterUpdater() {
+	<-s._ipStackInitializationWaiter // Wait for IP stack initialization
+
+	if err := s.dnsLocalFilterApply(false, false); err != nil {
+		log.Error(fmt.Errorf("failed to apply local DNS filter: %w", err))
+	}
+
+	for range time.Tick(blocklist.CacheMaxAge) {
+		hasRemoteLists := false
+		for _, src := range s._preferences.DnsLocalFilter.Blocklists {
+			if types.IsDnsBlocklistUrl(src) {
+				hasRemoteLists = true
+				break
+			}
+		}
+		if !hasRemoteLists {
+			continue
+		}
+		if err := s.dnsLocalFilterApply(false, false); err != nil {
+			log.Error(fmt.Errorf("failed to update local DNS filter: %w", err))
+		}
+	}
+}
+
+// dnsLocalFilterApply loads the block lists and applies the filtering rules to the local DNS forwarder.
+// The DNS configuration of the current VPN connection is re-applied when 'reapplyDns' is true
+// or when the local forwarder must be started/stopped.
+func (s *Service) dnsLocalFilterApply(forceUpdate, reapplyDns bool) error {
+	s._dnsFilter._mutex.Lock()
+
+	cfg := s._preferences.DnsLocalFilter
+	cacheDir := platform.DnsBlocklistsCacheDir()
+
+	var (
+		blocked    []string
+		statuses   []types.DnsBlocklistStatus
+		remoteSrcs []string
+	)
+	for _, src := range cfg.Blocklists {
+		status := types.DnsBlocklistStatus{Source: src}
+		domains, updated, err := blocklist.Load(src, cacheDir, forceUpdate)
+		if err != nil {
+			log.Warning(fmt.Errorf("failed to load DNS block list '%s': %w", src, err))
+			status.Error = err.Error()
+		} else {
+			status.Domains = len(domains)
+			status.Updated = updated.Unix()
+			blocked = append(blocked, domains...)
+		}
+		statuses = append(statuses, status)
+
+		if types.IsDnsBlocklistUrl(src) {
+			remoteSrcs = append(remoteSrcs, src)
+		}
+	}
+	// remove cached copies of the lists which are not in use anymore
+	blocklist.RemoveCache(cacheDir, remoteSrcs)
+
+	hosts := make(map[string][]net.IP, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		for _, ipStr := range h.IPs {
+			if ip := net.ParseIP(ipStr); ip != nil {
+				hosts[h.Domain] = append(hosts[h.Domain], ip)
+			}
+		}
+	}
+
+	wasEnabled := dns.IsFilterEnabled()
+	dns.SetFilter(blocked, cfg.Allowlist, hosts)
+	s._dnsFilter._blocklists = statuses
+
+	s._dnsFilter._mutex.Unlock()
+
+	if len(blocked) > 0 || len(cfg.Allowlist) > 0 || len(hosts) > 0 {
+		log.Info(fmt.Sprintf("Local DNS filter: %d blocked domains; %d allowed domains; %d hosts entries", len(blocked), len(cfg.Allowlist), len(hosts)))
+	}
+
+	if !reapplyDns && wasEnabled == dns.IsFilterEnabled() {
+		return nil
+	}
+	if s._vpn == nil {
+		return nil // no active VPN connection: the rules will be applied on connection
+	}
+	manualDns, antiTracker, _, err := s.GetDefaultManualDnsParams()
+	if err != nil {
+		return err
+	}
+	_, err = s.SetManualDNS(manualDns, antiTracker)
+	return err
+}
+
+// dnsLocalFilterAllowlistRules returns split DNS rules which forward queries for the allowlisted domains
+// to the default DNS of the VPN connection.
+// Used when AntiTracker is enabled: allows to unblock domains blocked by AntiTracker block lists.
+func (s *Service) dnsLocalFilterAllowlistRules(defaultDns net.IP) []dns.DnsDomainRule {
+	if defaultDns == nil {
+		return nil
+	}
+	allowlist := s._preferences.DnsLocalFilter.Allowlist
+	rules := make([]dns.DnsDomainRule, 0, len(allowlist))
+	for _, d := range allowlist {
+		rules = append(rules, dns.DnsDomainRule{Domain: d, Servers: []string{defaultDns.String()}})
+	}
+	return rules
+}
+
+// DnsStats returns DNS query statistics of the current connection session
+// 'topBlockedCount' - max number of the most blocked domains to return
+func (s *Service) DnsStats(topBlockedCount int) dns.DnsStats {
+	return dns.GetStats(topBlockedCount)
+}
+
+// DnsResolversHealth returns the health status of DNS servers used by the local DNS forwarder
+// (empty - if the local DNS forwarder is not in use)
+func (s *Service) DnsResolversHealth() []dns.DnsResolverHealth {
+	return dns.GetResolversHealth()
+}
+
+// SetDnsStatsCollection enables/disables forced collection of DNS statistics.
+// When enabled - all DNS queries are processed by the local DNS forwarder (even if it is not required by the DNS configuration)
+func (s *Service) SetDnsStatsCollection(enable bool) error {
+	if s._preferences.DnsStatsCollect == enable {
+		return nil
+	}
+
+	prefs := s._preferences
+	prefs.DnsStatsCollect = enable
+	s.setPreferences(prefs)
+
+	dns.SetStatsCollection(enable)
+
+	if s._vpn == nil {
+		return nil // no active VPN connection: the configuration will be applied on connection
+	}
+	manualDns, antiTracker, _, err := s.GetDefaultManualDnsParams()
+	if err != nil {
+		return err
+	}
+	_, err = s.SetManualDNS(manualDns, antiTracker)
+	return err
+}
@@ -0,0 +1,86 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2023 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/service/leaktest"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+)
+
+// LeakTest runs diagnostic checks of the current VPN connection (DNS, IPv6, routing and kill-switch leaks).
+// All parameters are optional (empty values mean 'use defaults'):
+//   - probeDnsServers - DNS resolvers to query
+//   - probeHosts - TCP hosts to connect ("IP:port")
+//   - iface - the non-tunnel interface name
+//   - dnsQueryName - the name to resolve in DNS probes
+func (s *Service) LeakTest(probeDnsServers []net.IP, probeHosts []string, iface string, dnsQueryName string) (types.LeakTestReport, error) {
+	vpn := s._vpn
+	if vpn == nil {
+		return types.LeakTestReport{}, fmt.Errorf("VPN is not connected")
+	}
+	if vpn.IsPaused() {
+		return types.LeakTestReport{}, fmt.Errorf("VPN connection is paused")
+	}
+
+	sessionInfo := s.GetVpnSessionInfo()
+	if sessionInfo.VpnLocalIPv4 == nil && sessionInfo.VpnLocalIPv6 == nil {
+		return types.LeakTestReport{}, fmt.Errorf("VPN connection is not established yet")
+	}
+
+	fwEnabled, err := firewall.GetEnabled()
+	if err != nil {
+		log.Warning(fmt.Errorf("unable to check firewall status: %w", err))
+	}
+
+	var dnsServers []net.IP
+	if dnsCfg, ok := firewall.GetDnsInfo(); ok {
+		for _, srv := range dnsCfg.Servers {
+			if ip := srv.Ip(); ip != nil {
+				dnsServers = append(dnsServers, ip)
+			}
+		}
+	}
+
+	cfg := leaktest.Config{
+		TunnelIPv4:        sessionInfo.VpnLocalIPv4,
+		TunnelIPv6:        sessionInfo.VpnLocalIPv6,
+		IsIPv6InTunnel:    vpn.IsIPv6InTunnel(),
+		IsFirewallEnabled: fwEnabled,
+		DnsServers:        dnsServers,
+		ProbeDnsServers:   probeDnsServers,
+		ProbeHosts:        probeHosts,
+		DnsQueryName:      dnsQueryName,
+		Interface:         iface,
+		Timeout:           time.Second * 2,
+	}
+	if !cfg.IsIPv6InTunnel {
+		cfg.TunnelIPv6 = nil
+	}
+
+	return leaktest.Run(cfg)
+}
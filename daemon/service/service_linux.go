@@ -28,12 +28,15 @@ package service
 import (
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/applist"
 	protocolTypes "github.com/ivpn/desktop-app/daemon/protocol/types"
+	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/firewall"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	"github.com/ivpn/desktop-app/daemon/service/preferences"
@@ -51,6 +54,25 @@ func (s *Service) implIsCanApplyUserPreferences(userPrefs preferences.UserPrefer
 		if len(dnsMgmtOldErr) > 0 {
 			return fmt.Errorf("the old-style DNS management is not applicable to the current environment: %s", dnsMgmtOldErr)
 		}
+		return nil
+	}
+
+	switch userPrefs.Linux.DnsMgmtMethod {
+	case dns.LinuxDnsMgmtAuto:
+	case dns.LinuxDnsMgmtResolvectl:
+		if len(platform.ResolvectlBinPath()) <= 0 {
+			return fmt.Errorf("the 'resolvectl' DNS management is not applicable to the current environment")
+		}
+	case dns.LinuxDnsMgmtResolvedDbus:
+		if err := dns.LinuxIsResolvedDbusAvailable(); err != nil {
+			return fmt.Errorf("the systemd-resolved D-Bus DNS management is not applicable to the current environment: %w", err)
+		}
+	case dns.LinuxDnsMgmtNetworkManager:
+		if err := dns.LinuxIsNetworkManagerAvailable(); err != nil {
+			return fmt.Errorf("the NetworkManager DNS management is not applicable to the current environment: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown DNS management method '%s'", userPrefs.Linux.DnsMgmtMethod)
 	}
 	return nil
 }
@@ -64,6 +86,12 @@ func (s *Service) implGetDisabledFuncForPlatform() protocolTypes.DisabledFunctio
 	if envs := platform.GetSnapEnvs(); envs != nil {
 		linuxFuncs.DnsMgmtOldResolvconfError = "it is not allowed to modify 'resolv.conf' from the snap environment"
 	}
+	if err := dns.LinuxIsResolvedDbusAvailable(); err != nil {
+		linuxFuncs.DnsMgmtResolvedDbusError = err.Error()
+	}
+	if err := dns.LinuxIsNetworkManagerAvailable(); err != nil {
+		linuxFuncs.DnsMgmtNetworkManagerError = err.Error()
+	}
 
 	return protocolTypes.DisabledFunctionalityForPlatform{Linux: linuxFuncs}
 }
@@ -102,8 +130,74 @@ func (s *Service) implSplitTunnelling_AddApp(execCmd string) (requiredCmdToExec
 	return fmt.Sprintf("ivpn exclude %s", execCmd), isRunning, nil
 }
 
-func (s *Service) implSplitTunnelling_RemoveApp(pid int, binaryPath string) (err error) {
-	return splittun.RemovePid(pid)
+// implSplitTunnelling_AddAppPath adds the application (path to binary or '.desktop' file) to the Split Tunnel configuration.
+// Sandboxed applications can also be defined by the sandbox identity: 'flatpak:<application ID>' or 'snap:<snap name>'.
+// The daemon adds the application to Split Tunnel environment automatically each time it starts.
+func (s *Service) implSplitTunnelling_AddAppPath(appPath string) error {
+	appPath = strings.TrimSpace(appPath)
+	if sb, ok := applist.ParseSandbox(appPath); ok {
+		return s.splitTunnelling_AddAppToConfig(sb.String())
+	}
+	if !filepath.IsAbs(appPath) {
+		return fmt.Errorf("the path to the application must be absolute: '%s'", appPath)
+	}
+
+	binaryPath := appPath
+	if strings.HasSuffix(appPath, ".desktop") {
+		b, err := applist.GetDesktopEntryBinary(appPath)
+		if err != nil {
+			return fmt.Errorf("unable to get the application binary: %w", err)
+		}
+		binaryPath = b
+	}
+	binaryPath, err := filepath.EvalSymlinks(binaryPath)
+	if err != nil {
+		return err
+	}
+
+	// Ensure no binaries from IVPN package is included into apps list to Split-Tunnel
+	if ex, err := os.Executable(); err == nil && len(ex) > 0 && strings.HasPrefix(binaryPath, filepath.Dir(ex)) {
+		return fmt.Errorf("Split-Tunnelling for IVPN binaries is forbidden (%s)", binaryPath)
+	}
+
+	return s.splitTunnelling_AddAppToConfig(appPath)
+}
+
+func (s *Service) splitTunnelling_AddAppToConfig(appPath string) error {
+	prefs := s._preferences
+	for _, a := range prefs.SplitTunnelApps {
+		if a == appPath {
+			// the application is already in configuration
+			return nil
+		}
+	}
+	prefs.SplitTunnelApps = append(prefs.SplitTunnelApps, appPath)
+	s.setPreferences(prefs)
+	return nil
+}
+
+// implSplitTunnelling_RemoveApp removes the running process from Split Tunnel environment (when 'pid' defined)
+// or removes the application from the Split Tunnel configuration (path to binary or '.desktop' file)
+func (s *Service) implSplitTunnelling_RemoveApp(pid int, appPath string) (err error) {
+	if pid > 0 {
+		return splittun.RemovePid(pid)
+	}
+
+	appPath = strings.TrimSpace(appPath)
+	prefs := s._preferences
+	newStApps := make([]string, 0, len(prefs.SplitTunnelApps))
+	for _, a := range prefs.SplitTunnelApps {
+		if a == appPath {
+			continue
+		}
+		newStApps = append(newStApps, a)
+	}
+	if len(newStApps) == len(prefs.SplitTunnelApps) {
+		return fmt.Errorf("the application not found in Split Tunnel configuration: '%s'", appPath)
+	}
+	prefs.SplitTunnelApps = newStApps
+	s.setPreferences(prefs)
+	return nil
 }
 
 // Inform the daemon about started process in ST environment
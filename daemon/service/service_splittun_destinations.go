@@ -0,0 +1,302 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/service/dns"
+	"github.com/ivpn/desktop-app/daemon/service/firewall"
+	"github.com/ivpn/desktop-app/daemon/service/types"
+	"github.com/ivpn/desktop-app/daemon/splittun"
+)
+
+// The domain names of Split Tunnel destination rules are not resolved by the daemon:
+// the IP addresses are taken from the answers of the local DNS resolver (while the VPN is connected all DNS queries
+// are processed by the local resolver when the domain rules are defined).
+// The routes for the IP addresses are applied before the answer is sent to the client
+// and removed when the TTL of the answer expires.
+
+const (
+	// how often the expiration of the IP addresses of Split Tunnel destination rules is checked
+	splitTunnelDestinationsExpireInterval = 10 * time.Second
+	// how often the routes of Split Tunnel destination rules are re-applied (the default gateway could be changed)
+	splitTunnelDestinationsReapplyInterval = 5 * time.Minute
+	// minimal lifetime of the IP addresses from DNS answers (to avoid routes flapping for answers with very short TTL)
+	splitTunnelDestinationsMinTTL = time.Minute
+)
+
+// splitTunDestAnswers - IP addresses of the domain of Split Tunnel destination rule (from DNS answers)
+type splitTunDestAnswers struct {
+	ips     map[string]time.Time // [IP]expiration time
+	updated time.Time            // time of the last answer
+}
+
+// SplitTunnelling_SetDestinations sets the Split Tunnel destination rules:
+// CIDRs or domain names which always bypass the VPN tunnel (in Inverse Split Tunnel mode: which always use the VPN tunnel)
+func (s *Service) SplitTunnelling_SetDestinations(destinations []string) error {
+	if stErr, _ := splittun.GetFuncNotAvailableError(); stErr != nil {
+		return stErr
+	}
+
+	normalized := make([]string, 0, len(destinations))
+	for _, d := range destinations {
+		d, err := types.NormalizeSplitTunnelDestination(d)
+		if err != nil {
+			return err
+		}
+		if !containsString(normalized, d) {
+			normalized = append(normalized, d)
+		}
+	}
+
+	prefs := s._preferences
+	prefs.SplitTunnelDestinations = normalized
+	s.setPreferences(prefs)
+
+	// forget the IP addresses of the domains which are not in use anymore
+	domains := splitTunnelDestinationsDomains(normalized)
+	s._splitTunDest._mutex.Lock()
+	for d := range s._splitTunDest._answers {
+		if !containsString(domains, d) {
+			delete(s._splitTunDest._answers, d)
+		}
+	}
+	s._splitTunDest._mutex.Unlock()
+
+	isNotifierChanged := s.splitTunnelDestinationsInitNotifier()
+
+	if err := s.splitTunnelling_ApplyConfig(); err != nil {
+		return err
+	}
+
+	if !isNotifierChanged || s._vpn == nil {
+		return nil
+	}
+	// re-apply DNS configuration to start/stop the local resolver
+	manualDns, antiTracker, _, err := s.GetDefaultManualDnsParams()
+	if err != nil {
+		return err
+	}
+	_, err = s.SetManualDNS(manualDns, antiTracker)
+	return err
+}
+
+// splitTunnelDestinationsInitNotifier registers (or unregisters) the handler of the local DNS resolver answers
+// according to the domain names of Split Tunnel destination rules.
+// Returns 'true' when the local resolver must be started/stopped (DNS configuration must be re-applied).
+func (s *Service) splitTunnelDestinationsInitNotifier() (isChanged bool) {
+	isRequired := len(splitTunnelDestinationsDomains(s.Preferences().SplitTunnelDestinations)) > 0
+
+	s._splitTunDest._mutex.Lock()
+	defer s._splitTunDest._mutex.Unlock()
+
+	if isRequired == s._splitTunDest._isNotifierSet {
+		return false
+	}
+	s._splitTunDest._isNotifierSet = isRequired
+	if isRequired {
+		dns.SetAnswersNotifier(s.splitTunnelDestinationsOnDnsAnswer)
+	} else {
+		dns.SetAnswersNotifier(nil)
+	}
+	return true
+}
+
+// splitTunnelDestinationsStatus returns the status of the Split Tunnel destination rules
+func (s *Service) splitTunnelDestinationsStatus(destinations []string) []types.SplitTunnelDestinationStatus {
+	s._splitTunDest._mutex.Lock()
+	defer s._splitTunDest._mutex.Unlock()
+
+	ret := make([]types.SplitTunnelDestinationStatus, 0, len(destinations))
+	for _, d := range destinations {
+		st := types.SplitTunnelDestinationStatus{Destination: d}
+		if a, ok := s._splitTunDest._answers[d]; ok {
+			for ip := range a.ips {
+				st.Resolved = append(st.Resolved, ip)
+			}
+			sort.Strings(st.Resolved)
+			st.Updated = a.updated.Unix()
+		}
+		ret = append(ret, st)
+	}
+	return ret
+}
+
+// splitTunnelDestinationsOnDnsAnswer is called by the local DNS resolver for each answer which contains IP addresses.
+// If the name matches the domain of Split Tunnel destination rule (or its subdomain) - the routes for the new IP addresses
+// are applied immediately (before the answer is sent to the client).
+func (s *Service) splitTunnelDestinationsOnDnsAnswer(name string, ips []net.IP, ttl time.Duration) {
+	prefs := s.Preferences()
+
+	var matched []string
+	for _, d := range splitTunnelDestinationsDomains(prefs.SplitTunnelDestinations) {
+		if name == d || strings.HasSuffix(name, "."+d) {
+			matched = append(matched, d)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	now := time.Now()
+	expiration := now.Add(max(ttl, splitTunnelDestinationsMinTTL))
+	var newIPs []string
+
+	s._splitTunDest._mutex.Lock()
+	if s._splitTunDest._answers == nil {
+		s._splitTunDest._answers = make(map[string]*splitTunDestAnswers)
+	}
+	for _, d := range matched {
+		a, ok := s._splitTunDest._answers[d]
+		if !ok {
+			a = &splitTunDestAnswers{ips: make(map[string]time.Time)}
+			s._splitTunDest._answers[d] = a
+		}
+		a.updated = now
+		for _, ip := range ips {
+			ipStr := ip.String()
+			if exp, ok := a.ips[ipStr]; !ok {
+				newIPs = append(newIPs, ipStr)
+			} else if exp.After(expiration) {
+				continue
+			}
+			a.ips[ipStr] = expiration
+		}
+	}
+	s._splitTunDest._mutex.Unlock()
+
+	if len(newIPs) == 0 {
+		return
+	}
+	log.Info(fmt.Sprintf("Split Tunnel destination %v: %s resolved to %v (TTL %v)", matched, name, newIPs, ttl))
+	if !prefs.IsSplitTunnel {
+		return
+	}
+	if err := s.splitTunnelDestinationsApply(); err != nil {
+		log.Error(fmt.Errorf("failed to apply Split Tunnel destination rules: %w", err))
+	}
+}
+
+// splitTunnelDestinationsUpdater removes the IP addresses of Split Tunnel destination rules which TTL expired
+// and periodically re-applies the routes
+func (s *Service) splitTunnelDestinationsUpdater() {
+	<-s._ipStackInitializationWaiter // Wait for IP stack initialization
+
+	lastApplied := time.Now()
+	for range time.Tick(splitTunnelDestinationsExpireInterval) {
+		isExpired := s.splitTunnelDestinationsExpire()
+
+		prefs := s.Preferences()
+		if !prefs.IsSplitTunnel || len(prefs.SplitTunnelDestinations) == 0 {
+			continue
+		}
+		if !isExpired && time.Since(lastApplied) < splitTunnelDestinationsReapplyInterval {
+			continue
+		}
+		lastApplied = time.Now()
+		if err := s.splitTunnelDestinationsApply(); err != nil {
+			log.Error(fmt.Errorf("failed to apply Split Tunnel destination rules: %w", err))
+		}
+	}
+}
+
+// splitTunnelDestinationsExpire removes the IP addresses which TTL expired.
+// Returns 'true' when any IP address was removed.
+func (s *Service) splitTunnelDestinationsExpire() (isChanged bool) {
+	s._splitTunDest._mutex.Lock()
+	defer s._splitTunDest._mutex.Unlock()
+
+	now := time.Now()
+	for d, a := range s._splitTunDest._answers {
+		for ip, exp := range a.ips {
+			if now.After(exp) {
+				delete(a.ips, ip)
+				isChanged = true
+				log.Info(fmt.Sprintf("Split Tunnel destination '%s': %s expired", d, ip))
+			}
+		}
+		if len(a.ips) == 0 {
+			delete(s._splitTunDest._answers, d)
+		}
+	}
+	return isChanged
+}
+
+// splitTunnelDestinationsDomains returns the domain names of Split Tunnel destination rules
+func splitTunnelDestinationsDomains(destinations []string) []string {
+	var ret []string
+	for _, d := range destinations {
+		if types.SplitTunnelDestinationNet(d) == nil {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// splitTunnelDestinationsApply applies routes and firewall exceptions for the Split Tunnel destination rules
+func (s *Service) splitTunnelDestinationsApply() error {
+	s._splitTunDest._applyMutex.Lock()
+	defer s._splitTunDest._applyMutex.Unlock()
+
+	prefs := s.Preferences()
+	isEnabled := prefs.IsSplitTunnel && prefs.Session.IsLoggedIn()
+	isInverse := prefs.IsInverseSplitTunneling()
+
+	var networks []net.IPNet
+	for _, st := range s.splitTunnelDestinationsStatus(prefs.SplitTunnelDestinations) {
+		if n := types.SplitTunnelDestinationNet(st.Destination); n != nil {
+			networks = append(networks, *n)
+			continue
+		}
+		for _, ipStr := range st.Resolved {
+			if n := types.SplitTunnelDestinationNet(ipStr); n != nil {
+				networks = append(networks, *n)
+			}
+		}
+	}
+
+	// The firewall must not block the traffic to the destinations which bypass the VPN tunnel
+	var fwExceptions []net.IPNet
+	if isEnabled && !isInverse {
+		fwExceptions = networks
+	}
+	if err := firewall.SetSplitTunnelExceptions(fwExceptions); err != nil {
+		return fmt.Errorf("failed to apply firewall exceptions: %w", err)
+	}
+
+	return splittun.ApplyDestinations(isEnabled, isInverse, networks)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
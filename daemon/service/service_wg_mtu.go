@@ -0,0 +1,250 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/service/preferences"
+	"github.com/ivpn/desktop-app/daemon/v2r"
+	"github.com/ivpn/desktop-app/daemon/vpn/wireguard"
+)
+
+// Automatic MTU detection for WireGuard connections.
+// The path MTU to the VPN server (or to the V2Ray server) is probed by ICMP Echo requests with 'Don't Fragment' flag.
+// The tunnel MTU is the path MTU minus the encapsulation overhead.
+// The detected values are saved per network and the path MTU is probed again when the network changes:
+// in this case the new MTU is applied to the active connection (without reconnection).
+
+const (
+	wgMtuProbeTimeout  = time.Millisecond * 500
+	wgMtuCacheValidity = time.Hour * 24 * 7
+	wgMtuCacheMaxSize  = 32
+	wgMtuMin           = 1280 // IPv6 minimum MTU (minimum value acceptable for WireGuard connection)
+	wgMtuProbeMax      = 1500 // Ethernet MTU (bigger packets are not expected to be routable over the Internet)
+
+	wgOverhead = 8 + 32 // UDP header + WireGuard header (the size of outer IP header is added depending on IP version)
+	// V2Ray overhead (in addition to WireGuard header), approximate values:
+	v2rayQuicOverhead = 8 + 25 + 16 + 19 + 18 // UDP header + QUIC short header + QUIC AEAD tag + STREAM frame header + VMess chunk header
+	v2rayTcpOverhead  = 32 + 18               // TCP header (with options) + VMess chunk header
+)
+
+// wgMtuProbeParams - parameters of the path MTU probing
+type wgMtuProbeParams struct {
+	host      net.IP // VPN server (or V2Ray server, when V2Ray is in use)
+	v2rayType v2r.V2RayTransportType
+}
+
+// wgMtuState - the state of automatic MTU detection for the current WireGuard connection
+type wgMtuState struct {
+	mutex   sync.Mutex
+	params  *wgMtuProbeParams // nil - automatic MTU detection is not in use
+	network string            // network ID for which the current MTU was detected
+	mtu     int               // MTU of the current connection (0 - default)
+}
+
+// wgMtuDetect returns the optimal MTU of WireGuard tunnel for the current network.
+// The value is taken from the saved results for the current network or detected by the path MTU probing.
+// Returns 0 (use default MTU) if the detection failed.
+func (s *Service) wgMtuDetect(params wgMtuProbeParams) int {
+	s._wgMtu.mutex.Lock()
+	defer s._wgMtu.mutex.Unlock()
+
+	s._wgMtu.params = &params
+	s._wgMtu.network = wgMtuNetworkID()
+	s._wgMtu.mtu = 0
+
+	if saved, ok := s._preferences.WgMtuPerNetwork[s._wgMtu.network]; ok && time.Since(saved.Time) < wgMtuCacheValidity {
+		log.Info(fmt.Sprintf("MTU: %d (detected %s for the current network)", saved.Mtu, saved.Time.Format(time.RFC3339)))
+		s._wgMtu.mtu = saved.Mtu
+		return saved.Mtu
+	}
+
+	mtu, err := s.wgMtuProbe(params, 0)
+	if err != nil {
+		log.Warning(fmt.Sprintf("MTU detection failed (using default MTU): %s", err))
+		return 0
+	}
+	s.wgMtuSave(s._wgMtu.network, mtu)
+	s._wgMtu.mtu = mtu
+	return mtu
+}
+
+// wgMtuReset must be called after the WireGuard connection is finished
+func (s *Service) wgMtuReset() {
+	s._wgMtu.mutex.Lock()
+	defer s._wgMtu.mutex.Unlock()
+	s._wgMtu.params = nil
+	s._wgMtu.network = ""
+	s._wgMtu.mtu = 0
+}
+
+// wgMtuOnNetworkChanged checks the MTU of the current connection after the network change.
+// The path MTU for the new network is probed outside the VPN tunnel (if it is not known yet)
+// and the new MTU is applied to the active connection (without reconnection).
+func (s *Service) wgMtuOnNetworkChanged() {
+	s._wgMtu.mutex.Lock()
+	defer s._wgMtu.mutex.Unlock()
+
+	if s._wgMtu.params == nil {
+		return
+	}
+	network := wgMtuNetworkID()
+	if len(network) == 0 || network == s._wgMtu.network {
+		return
+	}
+	s._wgMtu.network = network
+
+	wgObj, ok := s._vpn.(*wireguard.WireGuard)
+	if !ok {
+		return
+	}
+
+	mtu := 0
+	if saved, ok := s._preferences.WgMtuPerNetwork[network]; ok && time.Since(saved.Time) < wgMtuCacheValidity {
+		mtu = saved.Mtu
+	} else {
+		log.Info("Network changed. Detecting MTU for the new network...")
+		// The probes must bypass the tunnel (e.g. Linux with policy-based routing)
+		detected, err := s.wgMtuProbe(*s._wgMtu.params, wgObj.BypassFwmark())
+		if err != nil {
+			log.Warning(fmt.Sprintf("MTU detection failed (MTU of the current connection is not changed): %s", err))
+			return
+		}
+		s.wgMtuSave(network, detected)
+		mtu = detected
+	}
+
+	if mtu == s._wgMtu.mtu {
+		return
+	}
+	if err := wgObj.SetMtu(mtu); err != nil {
+		log.Warning(fmt.Sprintf("Failed to apply MTU %d to the active connection: %s", mtu, err))
+		return
+	}
+	log.Info(fmt.Sprintf("MTU of the active connection changed: %d", mtu))
+	s._wgMtu.mtu = mtu
+}
+
+// wgMtuProbe detects the optimal MTU of WireGuard tunnel by probing the path MTU to the host.
+// 'fwmark' - firewall mark of the probes (to route them outside the VPN tunnel); 0 - not marked
+func (s *Service) wgMtuProbe(params wgMtuProbeParams, fwmark uint32) (int, error) {
+	if params.host == nil {
+		return 0, fmt.Errorf("host not defined")
+	}
+
+	// the host must be accessible (e.g. when firewall is enabled)
+	hosts := []net.IP{params.host}
+	if err := s.implPingServersStarting(hosts); err != nil {
+		log.Error("implPingServersStarting failed: " + err.Error())
+	}
+	defer func() {
+		if err := s.implPingServersStopped(hosts); err != nil {
+			log.Error("implPingServersStopped failed: " + err.Error())
+		}
+	}()
+
+	ipHeaderSize, minProbe := 20, 576
+	if params.host.To4() == nil {
+		ipHeaderSize, minProbe = 40, wgMtuMin
+	}
+	maxProbe := wgMtuProbeMax
+	outboundTo := params.host
+	if fwmark != 0 {
+		// the marked probes are routed via the default gateway (the route to the host may point to the tunnel interface)
+		if gw, err := netinfo.DefaultGatewayIP(); err == nil {
+			outboundTo = gw
+		}
+	}
+	if iface, _, err := netinfo.GetOutboundInterface(outboundTo); err == nil && iface.MTU >= minProbe && iface.MTU < maxProbe {
+		maxProbe = iface.MTU
+	}
+
+	startTime := time.Now()
+	pmtu, err := netinfo.ProbePathMTUWithMark(params.host, minProbe, maxProbe, wgMtuProbeTimeout, fwmark)
+	if err != nil {
+		return 0, err
+	}
+
+	overhead := ipHeaderSize + wgOverhead
+	switch params.v2rayType {
+	case v2r.QUIC:
+		overhead += v2rayQuicOverhead
+	case v2r.TCP:
+		overhead += v2rayTcpOverhead
+	}
+
+	mtu := pmtu - overhead
+	if mtu < wgMtuMin {
+		log.Warning(fmt.Sprintf("Detected path MTU %d is too small for WireGuard connection. Using minimum MTU %d", pmtu, wgMtuMin))
+		mtu = wgMtuMin
+	}
+	log.Info(fmt.Sprintf("MTU detected: %d (path MTU to %s: %d; probing time: %s)", mtu, params.host, pmtu, time.Since(startTime).Round(time.Millisecond)))
+	return mtu, nil
+}
+
+// wgMtuSave saves the detected MTU for the network (outdated values are removed)
+func (s *Service) wgMtuSave(network string, mtu int) {
+	if len(network) == 0 {
+		return
+	}
+
+	prefs := s.Preferences()
+	saved := make(map[string]preferences.WgDetectedMtu, len(prefs.WgMtuPerNetwork)+1)
+	oldestNetwork := ""
+	for n, v := range prefs.WgMtuPerNetwork {
+		if time.Since(v.Time) >= wgMtuCacheValidity {
+			continue
+		}
+		saved[n] = v
+		if len(oldestNetwork) == 0 || v.Time.Before(saved[oldestNetwork].Time) {
+			oldestNetwork = n
+		}
+	}
+	if len(saved) >= wgMtuCacheMaxSize {
+		delete(saved, oldestNetwork)
+	}
+	saved[network] = preferences.WgDetectedMtu{Mtu: mtu, Time: time.Now()}
+
+	prefs.WgMtuPerNetwork = saved
+	s.setPreferences(prefs)
+}
+
+// wgMtuNetworkID returns the identifier of the current network:
+// the default gateway, the interface in use to access it and its local network (e.g. 'wlan0/192.168.1.0/24/192.168.1.1').
+// Note: the gateway is in the local network, so it is accessible outside the VPN tunnel even when the VPN is connected.
+func wgMtuNetworkID() string {
+	gw, err := netinfo.DefaultGatewayIP()
+	if err != nil {
+		return ""
+	}
+	iface, localNet, err := netinfo.GetOutboundInterface(gw)
+	if err != nil || localNet == nil {
+		return ""
+	}
+	return iface.Name + "/" + localNet.String() + "/" + gw.String()
+}
@@ -91,6 +91,11 @@ func (s *Service) implSplitTunnelling_AddApp(binaryFile string) (requiredCmdToEx
 	return "", false, nil
 }
 
+func (s *Service) implSplitTunnelling_AddAppPath(binaryFile string) error {
+	_, _, err := s.implSplitTunnelling_AddApp(binaryFile)
+	return err
+}
+
 func (s *Service) implSplitTunnelling_RemoveApp(pid int, binaryPath string) (err error) {
 	binaryPath = strings.TrimSpace(binaryPath)
 	if len(binaryPath) <= 0 {
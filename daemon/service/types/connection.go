@@ -28,7 +28,9 @@ import (
 	"math/big"
 
 	api_types "github.com/ivpn/desktop-app/daemon/api/types"
+	"github.com/ivpn/desktop-app/daemon/awg"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
+	"github.com/ivpn/desktop-app/daemon/ptransport"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/v2r"
 	"github.com/ivpn/desktop-app/daemon/vpn"
@@ -102,8 +104,15 @@ type ConnectionParams struct {
 		MultihopExitServer MultiHopExitServer_WireGuard
 
 		Mtu int // Set 0 to use default MTU value
+		// Detect MTU automatically: the path MTU to the VPN server is probed for each network
+		// (ignored when 'Mtu' is defined)
+		MtuAutoDetect bool
 
 		V2RayProxy v2r.V2RayTransportType // V2Ray config
+
+		// AmneziaWG obfuscation transport (can not be used together with 'V2RayProxy').
+		// The obfuscation parameters are defined by the server list (see 'EntryVpnServer.Hosts[].AmneziaWG')
+		AmneziaWG awg.AmneziaWGTransportType
 	}
 
 	OpenVpnParameters struct {
@@ -129,6 +138,8 @@ type ConnectionParams struct {
 
 		Obfs4proxy obfsproxy.Config       // Obfsproxy config (ignored when 'V2RayProxy' defined)
 		V2RayProxy v2r.V2RayTransportType // V2Ray config (this option takes precedence over the 'Obfs4proxy')
+		// Generic pluggable transport (Tor PT v1 compliant binary). Can not be used together with 'Obfs4proxy' or 'V2RayProxy'.
+		PluggableTransport ptransport.Config
 	}
 }
 
@@ -166,6 +177,13 @@ func (p ConnectionParams) V2Ray() v2r.V2RayTransportType {
 	return p.OpenVpnParameters.V2RayProxy
 }
 
+func (p ConnectionParams) AmneziaWG() awg.AmneziaWGTransportType {
+	if p.VpnType == vpn.WireGuard {
+		return p.WireGuardParameters.AmneziaWG
+	}
+	return awg.None
+}
+
 // NormalizeHosts - normalize hosts list
 // 1) in case of multiple entry hosts - take random host from the list
 // 2) in case of multiple exit hosts - take random host from the list
@@ -245,6 +263,20 @@ func (p *ConnectionParams) NormalizeHosts() error {
 			}
 		}
 
+		// AmneziaWG: use only entry hosts which support obfuscation
+		if p.WireGuardParameters.AmneziaWG != awg.None {
+			var awgHosts []api_types.WireGuardServerHostInfo
+			for _, h := range p.WireGuardParameters.EntryVpnServer.Hosts {
+				if h.AmneziaWG != nil && h.AmneziaWG.IsDefined() {
+					awgHosts = append(awgHosts, h)
+				}
+			}
+			if len(awgHosts) == 0 {
+				return fmt.Errorf("unable to make AmneziaWG connection. Server does not support AmneziaWG obfuscation")
+			}
+			p.WireGuardParameters.EntryVpnServer.Hosts = awgHosts
+		}
+
 		// in case of multiple entry hosts - take random host from the list
 		if len(p.WireGuardParameters.EntryVpnServer.Hosts) > 1 {
 			rndHost := p.WireGuardParameters.EntryVpnServer.Hosts[0]
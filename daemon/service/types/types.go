@@ -22,13 +22,285 @@
 
 package types
 
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
 type KillSwitchStatus struct {
-	IsEnabled         bool   // FW state
-	IsPersistent      bool   // configuration: true - when persistent
-	IsAllowLAN        bool   // configuration: 'Allow LAN'
-	IsAllowMulticast  bool   // configuration: 'Allow multicast'
-	IsAllowApiServers bool   // configuration: 'Allow API servers'
-	UserExceptions    string // configuration: Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	IsEnabled         bool             // FW state
+	IsPersistent      bool             // configuration: true - when persistent
+	IsAllowLAN        bool             // configuration: 'Allow LAN'
+	IsAllowMulticast  bool             // configuration: 'Allow multicast'
+	IsAllowApiServers bool             // configuration: 'Allow API servers'
+	UserExceptions    string           // configuration: Firewall exceptions: comma separated list of IP addresses (masks) in format: x.x.x.x[/xx]
+	AppsBlocked       []string         // configuration: applications (paths to binaries) which are not allowed to communicate
+	AppsAllowed       []string         // configuration: applications (paths to binaries) which are allowed to communicate even when VPN is disconnected
+	LocalInterfaces   []LocalInterface // configuration: trusted local interfaces (e.g. bridge interfaces of containers and VMs)
 
 	StateLanAllowed bool // real state of 'Allow LAN'
 }
+
+// BlockedTrafficInfo contains aggregated information about outgoing packets dropped by the firewall.
+// Packets are aggregated by protocol, destination address, destination port and process.
+type BlockedTrafficInfo struct {
+	Protocol    string // "tcp", "udp", "icmp" ...
+	DstIP       string
+	DstPort     int    // 0 - if not applicable for the protocol
+	Pid         int    // 0 - if the process is unknown
+	ProcessPath string // empty - if the process is unknown
+	Uid         int    // -1 - if unknown
+	Count       uint64 // number of dropped packets
+	FirstSeen   int64  // Unix time
+	LastSeen    int64  // Unix time
+}
+
+// LocalInterfaceMode defines how the firewall handles the traffic of a trusted local interface
+// (e.g. bridge interface of containers or virtual machines: docker0, virbr0, lxcbr0 ...)
+type LocalInterfaceMode int
+
+const (
+	LocalInterfaceAllowLocal LocalInterfaceMode = 0 // allow communication between the interface and the host only
+	LocalInterfaceTunnel     LocalInterfaceMode = 1 // allow communication with the host and forward the interface traffic through the VPN tunnel (NAT)
+	LocalInterfaceBlock      LocalInterfaceMode = 2 // block any communication of the interface
+)
+
+func (m LocalInterfaceMode) String() string {
+	switch m {
+	case LocalInterfaceAllowLocal:
+		return "allow-local"
+	case LocalInterfaceTunnel:
+		return "tunnel"
+	case LocalInterfaceBlock:
+		return "block"
+	}
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+// ParseLocalInterfaceMode converts text representation ("allow-local", "tunnel", "block") to LocalInterfaceMode
+func ParseLocalInterfaceMode(s string) (LocalInterfaceMode, error) {
+	for _, m := range []LocalInterfaceMode{LocalInterfaceAllowLocal, LocalInterfaceTunnel, LocalInterfaceBlock} {
+		if strings.EqualFold(strings.TrimSpace(s), m.String()) {
+			return m, nil
+		}
+	}
+	return LocalInterfaceAllowLocal, fmt.Errorf("unknown local interface mode '%s' (expected: allow-local, tunnel or block)", s)
+}
+
+// LocalInterface - the firewall configuration for a trusted local interface
+type LocalInterface struct {
+	// Interface name. The '+' suffix matches all interfaces with such name prefix (e.g. "br-+", "veth+")
+	Name string
+	Mode LocalInterfaceMode
+}
+
+var localInterfaceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.@-]{1,15}\+?$`)
+
+// Validate returns error when the interface configuration is not valid
+func (i LocalInterface) Validate() error {
+	if !localInterfaceNameRegexp.MatchString(i.Name) {
+		return fmt.Errorf("invalid local interface name '%s'", i.Name)
+	}
+	if i.Mode < LocalInterfaceAllowLocal || i.Mode > LocalInterfaceBlock {
+		return fmt.Errorf("invalid mode for local interface '%s'", i.Name)
+	}
+	return nil
+}
+
+// LeakTestStatus - the result of a single leak test check
+type LeakTestStatus int
+
+const (
+	LeakTestPassed  LeakTestStatus = 0
+	LeakTestFailed  LeakTestStatus = 1
+	LeakTestSkipped LeakTestStatus = 2 // the check is not applicable for current configuration
+)
+
+func (s LeakTestStatus) String() string {
+	switch s {
+	case LeakTestPassed:
+		return "PASSED"
+	case LeakTestFailed:
+		return "FAILED"
+	case LeakTestSkipped:
+		return "SKIPPED"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// LeakTestCheck - the result of a single leak test check
+type LeakTestCheck struct {
+	Name    string // "dns", "ipv6", "route", "killswitch"
+	Status  LeakTestStatus
+	Details []string // human-readable results of the probes
+}
+
+// LeakTestReport - the results of the leak test
+type LeakTestReport struct {
+	Time              int64 // Unix time of the test
+	IsFirewallEnabled bool
+	TunnelIPv4        string
+	TunnelIPv6        string
+	IsIPv6InTunnel    bool
+	Interface         string // the non-tunnel interface used to send probes outside the tunnel
+	Checks            []LeakTestCheck
+}
+
+// IsPassed returns 'true' when no any check failed
+func (r LeakTestReport) IsPassed() bool {
+	for _, c := range r.Checks {
+		if c.Status == LeakTestFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// DnsLocalFilter - user-defined DNS filtering configuration.
+// The rules are enforced by the daemon's local DNS forwarder (in front of the configured upstream DNS or AntiTracker).
+type DnsLocalFilter struct {
+	Blocklists []string       // block lists: paths to local files or http(s) URLs (hosts-format or domain lists)
+	Allowlist  []string       // domains (including subdomains) which are never blocked (overrides block lists and AntiTracker)
+	Hosts      []DnsHostEntry // static hosts entries
+}
+
+// DnsHostEntry - static hosts entry
+type DnsHostEntry struct {
+	Domain string
+	IPs    []string
+}
+
+// IsEmpty returns 'true' when no filtering rules defined
+func (f DnsLocalFilter) IsEmpty() bool {
+	return len(f.Blocklists) == 0 && len(f.Allowlist) == 0 && len(f.Hosts) == 0
+}
+
+// Normalize validates the configuration, removes duplicates and converts domain names to lowercase
+func (f DnsLocalFilter) Normalize() (DnsLocalFilter, error) {
+	var ret DnsLocalFilter
+
+	for _, src := range f.Blocklists {
+		src = strings.TrimSpace(src)
+		if len(src) == 0 || containsString(ret.Blocklists, src) {
+			continue
+		}
+		if !IsDnsBlocklistUrl(src) && !filepath.IsAbs(src) {
+			return ret, fmt.Errorf("block list must be an absolute file path or http(s) URL: '%s'", src)
+		}
+		ret.Blocklists = append(ret.Blocklists, src)
+	}
+
+	for _, d := range f.Allowlist {
+		d, err := normalizeDnsDomain(d)
+		if err != nil {
+			return ret, err
+		}
+		if !containsString(ret.Allowlist, d) {
+			ret.Allowlist = append(ret.Allowlist, d)
+		}
+	}
+
+	for _, h := range f.Hosts {
+		d, err := normalizeDnsDomain(h.Domain)
+		if err != nil {
+			return ret, err
+		}
+		if len(h.IPs) == 0 {
+			return ret, fmt.Errorf("no IP addresses defined for host '%s'", d)
+		}
+		entry := DnsHostEntry{Domain: d}
+		for _, ipStr := range h.IPs {
+			ip := net.ParseIP(strings.TrimSpace(ipStr))
+			if ip == nil {
+				return ret, fmt.Errorf("invalid IP address for host '%s': '%s'", d, ipStr)
+			}
+			entry.IPs = append(entry.IPs, ip.String())
+		}
+		// the last entry for the domain is in use
+		for i, e := range ret.Hosts {
+			if e.Domain == d {
+				ret.Hosts = append(ret.Hosts[:i], ret.Hosts[i+1:]...)
+				break
+			}
+		}
+		ret.Hosts = append(ret.Hosts, entry)
+	}
+
+	return ret, nil
+}
+
+// IsDnsBlocklistUrl returns 'true' when the block list source is http(s) URL (otherwise - it is a local file path)
+func IsDnsBlocklistUrl(src string) bool {
+	s := strings.ToLower(src)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// DnsBlocklistStatus - the status of the local DNS block list
+type DnsBlocklistStatus struct {
+	Source  string
+	Domains int    // number of domains loaded from the list
+	Updated int64  // Unix time of the last successful update (download for URLs; read for local files)
+	Error   string // the last error (if any)
+}
+
+// SplitTunnelDestinationStatus - the status of the Split Tunnel destination rule
+type SplitTunnelDestinationStatus struct {
+	Destination string   // CIDR or domain name
+	Resolved    []string // (only for domain names) the IP addresses of the domain from DNS answers (not expired)
+	Updated     int64    // (only for domain names) Unix time of the last DNS answer
+}
+
+// NormalizeSplitTunnelDestination validates the Split Tunnel destination rule (CIDR, IP address or domain name)
+// and converts it to the canonical form (IP addresses are converted to CIDR)
+func NormalizeSplitTunnelDestination(dest string) (string, error) {
+	dest = strings.TrimSpace(dest)
+	if n := SplitTunnelDestinationNet(dest); n != nil {
+		return n.String(), nil
+	}
+	if strings.Contains(dest, "/") {
+		return "", fmt.Errorf("invalid network: '%s'", dest)
+	}
+	return normalizeDnsDomain(dest)
+}
+
+// SplitTunnelDestinationNet returns the network of the Split Tunnel destination rule
+// (nil - when the destination is not a CIDR or IP address)
+func SplitTunnelDestinationNet(dest string) *net.IPNet {
+	if strings.Contains(dest, "/") {
+		_, n, err := net.ParseCIDR(dest)
+		if err != nil {
+			return nil
+		}
+		return n
+	}
+	ip := net.ParseIP(dest)
+	if ip == nil {
+		return nil
+	}
+	if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+var regexpDnsDomain = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?(\.[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?)*$`)
+
+func normalizeDnsDomain(d string) (string, error) {
+	ret := strings.Trim(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*."), ".")
+	if len(ret) == 0 || len(ret) > 253 || !regexpDnsDomain.MatchString(ret) {
+		return "", fmt.Errorf("invalid domain name: '%s'", d)
+	}
+	return ret, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
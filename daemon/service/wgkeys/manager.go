@@ -49,13 +49,14 @@ func init() {
 
 // IWgKeysChangeReceiver WG key update handler
 type IWgKeysChangeReceiver interface {
-	WireGuardSaveNewKeys(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPreSharedKey string)
+	WireGuardSaveNewKeys(wgPublicKey string, wgPrivateKey string, wgLocalIP string, wgPreSharedKey string, kemError string)
 	WireGuardGetKeys() (session, wgPublicKey, wgPrivateKey, wgLocalIP string, generatedTime time.Time, updateInterval time.Duration)
 	FirewallEnabled() (bool, error)
 	Connected() bool
 	ConnectedType() (isConnected bool, connectedVpnType vpn.Type)
 	IsConnectivityBlocked() (err error) // IsConnectivityBlocked - returns nil if connectivity NOT blocked
 	OnSessionNotFound()
+	WgKemPolicy() kem.Policy
 }
 
 // CreateKeysManager create WireGuard keys manager
@@ -200,8 +201,21 @@ func (m *KeysManager) UpdateKeysIfNecessary() (retErr error) {
 	return m.generateKeys(true)
 }
 
-func createKemHelper() (*kem.KemHelper, error) {
-	return kem.CreateHelper(platform.KemHelperBinaryPath(), kem.GetDefaultKemAlgorithms())
+// createKemKeys generates KEM keys for the policy.
+// Returns nil helper and empty keys (the WireGuard PresharedKey will not be initialized) when failed.
+func createKemKeys(policy kem.Policy) (*kem.KemHelper, types.KemPublicKeys, error) {
+	var kemKeys types.KemPublicKeys
+	kemHelper, err := kem.CreateHelperForPolicy(platform.KemHelperBinaryPath(), policy)
+	if err == nil {
+		kemKeys.KemLibraryVersion = kemHelper.GetLibraryVersion()
+		kemKeys.KemAlgorithm_Lattice = kemHelper.GetLatticeAlgorithm()
+		kemKeys.KemPublicKey_Lattice, kemKeys.KemPublicKey_ClassicMcEliece348864, err = kemHelper.GetPublicKeys()
+	}
+	if err != nil {
+		log.Error("Failed to generate KEM keys: ", err)
+		return nil, types.KemPublicKeys{}, fmt.Errorf("failed to generate KEM keys: %w", err)
+	}
+	return kemHelper, kemKeys, nil
 }
 
 func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
@@ -247,21 +261,7 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 	}
 
 	// Generate keys for Key Encapsulation Mechanism using post-quantum cryptographic algorithms
-	var kemKeys types.KemPublicKeys
-	kemHelper, err := createKemHelper()
-	if err != nil {
-		log.Error("Failed to generate KEM keys: ", err)
-	} else {
-		kemKeys.KemLibraryVersion = kemHelper.GetPublicKeyLiboqsVersion()
-		kemKeys.KemPublicKey_Kyber1024, err = kemHelper.GetPublicKey(kem.AlgName_Kyber1024)
-		if err != nil {
-			log.Error(err)
-		}
-		kemKeys.KemPublicKey_ClassicMcEliece348864, err = kemHelper.GetPublicKey(kem.AlgName_ClassicMcEliece348864)
-		if err != nil {
-			log.Error(err)
-		}
-	}
+	kemHelper, kemKeys, kemErr := createKemKeys(m.service.WgKemPolicy())
 
 	var (
 		pub  string
@@ -270,6 +270,7 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 		wgPresharedKey string
 		localIP        net.IP
 		resp           types.SessionsWireGuardResponse
+		err            error
 	)
 	for {
 		pub, priv, err = wireguard.GenerateKeys(m.wgToolBinPath)
@@ -290,7 +291,7 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 			if len(activePublicKey) == 0 {
 				// IMPORTANT! As soon as server receive request with empty 'activePublicKey' - it clears all keys
 				// Therefore, we have to ensure that local keys are not using anymore (we have to clear them independently from we received response or not)
-				m.service.WireGuardSaveNewKeys("", "", "", "")
+				m.service.WireGuardSaveNewKeys("", "", "", "", "")
 			}
 			log.Info("WG keys not updated: ", err)
 
@@ -305,18 +306,26 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 		}
 
 		if kemHelper != nil {
-			if len(resp.KemCipher_Kyber1024) == 0 && len(resp.KemCipher_ClassicMcEliece348864) == 0 {
+			if len(resp.KemCipher_Lattice) == 0 && len(resp.KemCipher_ClassicMcEliece348864) == 0 {
+				kemErr = fmt.Errorf("the server did not respond with KEM ciphers")
 				log.Warning("The server did not respond with KEM ciphers. The WireGuard PresharedKey has not been initialized!")
 			} else {
-				if err := kemHelper.SetCipher(kem.AlgName_Kyber1024, resp.KemCipher_Kyber1024); err != nil {
-					log.Error(err)
-				}
-				if err := kemHelper.SetCipher(kem.AlgName_ClassicMcEliece348864, resp.KemCipher_ClassicMcEliece348864); err != nil {
+				if err := kemHelper.SetCiphers(resp.KemCipher_Lattice, resp.KemAlgorithm_Lattice, resp.KemCipher_ClassicMcEliece348864); err != nil {
+					if fallback, ok := kemHelper.Policy().KyberFallback(); ok {
+						// the server does not support the lattice algorithm: generate new keys using Kyber1024
+						log.Warning(fmt.Sprintf("%s. Generating new keys using KEM policy '%s'...", err, fallback))
+						kemHelper, kemKeys, kemErr = createKemKeys(fallback)
+						if kemErr == nil {
+							kemErr = fmt.Errorf("%w (KEM policy '%s' is in use)", err, fallback)
+						}
+						continue
+					}
 					log.Error(err)
 				}
 
 				wgPresharedKey, err = kemHelper.CalculatePresharedKey()
 				if err != nil {
+					kemErr = fmt.Errorf("failed to decode KEM ciphers: %w", err)
 					log.Error(fmt.Sprintf("Failed to decode KEM ciphers! (%s). Generating new keys without PresharedKey...", err))
 					kemHelper = nil
 					kemKeys = types.KemPublicKeys{}
@@ -327,7 +336,11 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 		break
 	}
 	// notify service about new keys
-	m.service.WireGuardSaveNewKeys(pub, priv, localIP.String(), wgPresharedKey)
+	kemErrStr := ""
+	if kemErr != nil {
+		kemErrStr = kemErr.Error()
+	}
+	m.service.WireGuardSaveNewKeys(pub, priv, localIP.String(), wgPresharedKey, kemErrStr)
 
 	log.Info(fmt.Sprintf("WG keys updated (%s:%s; psk:%v) ", localIP.String(), pub, len(wgPresharedKey) > 0))
 
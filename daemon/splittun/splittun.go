@@ -107,6 +107,20 @@ func ApplyConfig(isStEnabled, isStInverse, isStInverseAllowWhenNoVpn, isVpnEnabl
 	return retErr
 }
 
+// ApplyUsers sets the users and groups (names or numeric IDs) which traffic is 'splitted' the same way
+// as traffic of the applications in Split Tunnel environment
+// (applicable for Linux)
+func ApplyUsers(users, groups []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	retErr := implApplyUsers(users, groups)
+	if retErr != nil {
+		log.Error(retErr)
+	}
+	return retErr
+}
+
 // AddPid add process to Split-Tunnel environment
 // (applicable for Linux)
 func AddPid(pid int, commandToExecute string) error {
@@ -24,6 +24,7 @@ package splittun
 
 import (
 	"fmt"
+	"net"
 )
 
 var (
@@ -57,3 +58,29 @@ func implRemovePid(pid int) error {
 func implGetRunningApps() ([]RunningApp, error) {
 	return nil, notImplementedError
 }
+
+func implDestinationsGateway(isIPv6 bool) (gateway net.IP, ifIndex int, err error) {
+	return nil, 0, notImplementedError
+}
+
+func implDestinationRouteAdd(r destRoute) error {
+	return notImplementedError
+}
+
+func implDestinationRouteDel(r destRoute) error {
+	return notImplementedError
+}
+
+func implApplyVpnDestinations(destinations []net.IPNet) error {
+	if len(destinations) > 0 {
+		return notImplementedError
+	}
+	return nil
+}
+
+func implApplyUsers(users, groups []string) error {
+	if len(users) > 0 || len(groups) > 0 {
+		return notImplementedError
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package splittun
+
+import (
+	"fmt"
+	"net"
+)
+
+// destRoute - the route applied for the Split Tunnel destination
+type destRoute struct {
+	dst     net.IPNet
+	gateway net.IP
+	ifIndex int
+}
+
+var (
+	// the networks which bypass the VPN tunnel (nil - when not in use)
+	destBypass []net.IPNet
+	// the routes applied for the 'destBypass' networks (map[<CIDR>]<route>)
+	destRoutes = map[string]destRoute{}
+)
+
+// ApplyDestinations configures destination rules of Split Tunnel:
+// the networks which always bypass the VPN tunnel or (in Inverse mode) which always use the VPN tunnel.
+// The traffic is forwarded by the routes over the default (non-VPN) gateway.
+// Note: the firewall exceptions for the destinations must be configured separately.
+func ApplyDestinations(isStEnabled, isStInverse bool, destinations []net.IPNet) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var bypass, useVpn []net.IPNet
+	if isStEnabled {
+		if isStInverse {
+			useVpn = destinations
+		} else {
+			bypass = destinations
+		}
+	}
+
+	destBypass = bypass
+	retErr := destinationsUpdateRoutes()
+	if err := implApplyVpnDestinations(useVpn); err != nil {
+		log.Error(err)
+		if retErr == nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
+
+// destinationsUpdateRoutes applies the routes for the 'destBypass' networks.
+// The routes with outdated gateway (e.g. after the network change) are re-created.
+// Must be called under locked 'mutex'.
+func destinationsUpdateRoutes() (retErr error) {
+	type gateway struct {
+		ip      net.IP
+		ifIndex int
+	}
+	gateways := map[bool]*gateway{}
+	for _, isIPv6 := range []bool{false, true} {
+		if gw, ifIndex, err := implDestinationsGateway(isIPv6); err == nil && gw != nil {
+			gateways[isIPv6] = &gateway{ip: gw, ifIndex: ifIndex}
+		}
+	}
+
+	wanted := map[string]net.IPNet{}
+	for _, n := range destBypass {
+		wanted[n.String()] = n
+	}
+
+	// remove routes which are not required anymore (or which gateway was changed)
+	for key, r := range destRoutes {
+		gw := gateways[r.dst.IP.To4() == nil]
+		if _, ok := wanted[key]; ok && gw != nil && gw.ip.Equal(r.gateway) && gw.ifIndex == r.ifIndex {
+			continue
+		}
+		if err := implDestinationRouteDel(r); err != nil {
+			log.Warning(err)
+		}
+		delete(destRoutes, key)
+	}
+
+	// add new routes
+	for key, n := range wanted {
+		if _, ok := destRoutes[key]; ok {
+			continue
+		}
+		isIPv6 := n.IP.To4() == nil
+		gw := gateways[isIPv6]
+		if gw == nil {
+			log.Warning(fmt.Sprintf("Unable to apply route for Split Tunnel destination %s: default gateway not defined (IPv6=%v)", key, isIPv6))
+			continue
+		}
+		r := destRoute{dst: n, gateway: gw.ip, ifIndex: gw.ifIndex}
+		if err := implDestinationRouteAdd(r); err != nil {
+			log.Error(err)
+			retErr = err
+			continue
+		}
+		destRoutes[key] = r
+	}
+	return retErr
+}
@@ -26,12 +26,17 @@
 package splittun
 
 import (
+	"bufio"
 	"fmt"
+	"net"
 	"os"
+	"os/user"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
@@ -62,32 +67,17 @@ func implInitialize() error {
 		return funcNotAvailableError
 	}
 
-	stScriptPath = platform.SplitTunScript()
-	if len(stScriptPath) <= 0 {
-		funcNotAvailableError = fmt.Errorf("Split-Tunnelling script is not defined")
-		return funcNotAvailableError
-	}
-
-	// Hardcoded text for detection of inverse mode not available error
-	const inverseModeErrorDetectionText = "Warning: Inverse mode for IVPN Split Tunnel functionality is not applicable."
-	// check if ST functionality accessible
-	outProcessFunc := func(text string, isError bool) {
-		if strings.HasPrefix(text, inverseModeErrorDetectionText) {
-			text = strings.TrimSpace(strings.TrimPrefix(text, "Warning: "))
-			inverseModeNotAvailableError = fmt.Errorf("%s", text)
-			log.Warning(text)
-			return
-		}
-		if isError {
-			log.Error("Split Tunnel test: " + text)
-		} else {
-			log.Info("Split Tunnel test: " + text)
+	// The native implementation is in use on systems with cgroup v2 unified hierarchy;
+	// otherwise - the Split-Tunnelling script is in use
+	if err := nativeInitialize(); err == nil {
+		log.Info("Using native Split Tunnel implementation (cgroup v2, nftables)")
+	} else {
+		log.Info(fmt.Sprintf("Native Split Tunnel implementation not applicable (%s); using script", err))
+		funcNotAvailableError = scriptInitialize()
+		if len(stScriptPath) <= 0 {
+			return funcNotAvailableError
 		}
 	}
-	err := shell.ExecAndProcessOutput(nil, outProcessFunc, "", stScriptPath, "test")
-	if err != nil {
-		funcNotAvailableError = err
-	}
 
 	// Ensure that ST is disable on daemon startup
 	enable(false, false, false, false, false)
@@ -121,10 +111,22 @@ func implInitialize() error {
 					// We can receive many 'lan change' events in a short period of time
 					// but we update routes not more often than once per 2 seconds.
 					timerDelay = time.AfterFunc(time.Second*2, func() {
-						err := shell.Exec(nil, stScriptPath, "update-routes")
+						var err error
+						if isNative() {
+							mutex.Lock()
+							err = nativeUpdateRoutes()
+							mutex.Unlock()
+						} else {
+							err = shell.Exec(nil, stScriptPath, "update-routes")
+						}
 						if err != nil {
 							log.Error("failed to update routes for SplitTunneling functionality")
 						}
+						mutex.Lock()
+						if err := destinationsUpdateRoutes(); err != nil {
+							log.Error("failed to update routes for Split Tunnel destinations")
+						}
+						mutex.Unlock()
 					})
 				}
 			}
@@ -134,6 +136,32 @@ func implInitialize() error {
 	return funcNotAvailableError
 }
 
+// scriptInitialize checks if Split-Tunnelling script is applicable
+func scriptInitialize() error {
+	stScriptPath = platform.SplitTunScript()
+	if len(stScriptPath) <= 0 {
+		return fmt.Errorf("Split-Tunnelling script is not defined")
+	}
+
+	// Hardcoded text for detection of inverse mode not available error
+	const inverseModeErrorDetectionText = "Warning: Inverse mode for IVPN Split Tunnel functionality is not applicable."
+	// check if ST functionality accessible
+	outProcessFunc := func(text string, isError bool) {
+		if strings.HasPrefix(text, inverseModeErrorDetectionText) {
+			text = strings.TrimSpace(strings.TrimPrefix(text, "Warning: "))
+			inverseModeNotAvailableError = fmt.Errorf("%s", text)
+			log.Warning(text)
+			return
+		}
+		if isError {
+			log.Error("Split Tunnel test: " + text)
+		} else {
+			log.Info("Split Tunnel test: " + text)
+		}
+	}
+	return shell.ExecAndProcessOutput(nil, outProcessFunc, "", stScriptPath, "test")
+}
+
 func implFuncNotAvailableError() (generalStError, inversedStError error) {
 	return funcNotAvailableError, inverseModeNotAvailableError
 }
@@ -141,6 +169,9 @@ func implFuncNotAvailableError() (generalStError, inversedStError error) {
 func implReset() error {
 	log.Info("Removing all PIDs")
 
+	if isNative() {
+		return nativeReset()
+	}
 	return shell.Exec(nil, stScriptPath, "reset")
 }
 
@@ -154,6 +185,13 @@ func implApplyConfig(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpn
 	err := enable(isStEnabled, isStInversed, isStInverseAllowWhenNoVpn, isVpnEnabled, vpnNoIPv6)
 	if err != nil {
 		log.Error(err)
+		isStEnabled = false
+	}
+	if appsErr := appsApplyConfig(isStEnabled, splitTunnelApps); appsErr != nil {
+		log.Error(appsErr)
+		if err == nil {
+			err = appsErr
+		}
 	}
 	return err
 }
@@ -172,7 +210,11 @@ func implAddPid(pid int, commandToExecute string) error {
 		return fmt.Errorf("the Split Tunnel is disabled")
 	}
 
-	err = shell.Exec(nil, stScriptPath, "addpid", strconv.Itoa(pid))
+	if isNative() {
+		err = nativeAddPid(pid)
+	} else {
+		err = shell.Exec(nil, stScriptPath, "addpid", strconv.Itoa(pid))
+	}
 	if err == nil {
 		_addedRootProcesses[pid] = commandToExecute
 	}
@@ -208,7 +250,12 @@ func implRemovePid(pid int) error {
 	// remove all required pids
 	for pidToRemove := range pids {
 		log.Info(fmt.Sprintf("Removing PID:%d", pidToRemove))
-		err := shell.Exec(nil, stScriptPath, "removepid", strconv.Itoa(pidToRemove))
+		var err error
+		if isNative() {
+			err = nativeRemovePid(pidToRemove)
+		} else {
+			err = shell.Exec(nil, stScriptPath, "removepid", strconv.Itoa(pidToRemove))
+		}
 		if err != nil && retErr == nil {
 			retErr = err
 		}
@@ -223,7 +270,11 @@ func implGetRunningApps() (allProcesses []RunningApp, err error) {
 	// https://man7.org/linux/man-pages/man5/proc.5.html
 
 	// read all PIDs which are active in ST environment
-	bytes, err := os.ReadFile(stPidsFile)
+	pidsFile := stPidsFile
+	if isNative() {
+		pidsFile = nativePidsFile()
+	}
+	bytes, err := os.ReadFile(pidsFile)
 	if err != nil {
 		return nil, err
 	}
@@ -373,6 +424,9 @@ func implGetRunningApps() (allProcesses []RunningApp, err error) {
 }
 
 func isEnabled() (bool, error) {
+	if isNative() {
+		return nativeIsEnabled(), nil
+	}
 	err := shell.Exec(nil, stScriptPath, "status")
 	if err != nil {
 		return false, nil
@@ -381,6 +435,10 @@ func isEnabled() (bool, error) {
 }
 
 func enable(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, vpnNoIPv6 bool) error {
+	if isNative() {
+		return enableNative(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, vpnNoIPv6)
+	}
+
 	if !isEnable {
 		enabled, err := isEnabled()
 		if err == nil && !enabled {
@@ -422,6 +480,157 @@ func enable(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, v
 	return nil
 }
 
+func enableNative(isEnable, isStInversed, isStInverseAllowWhenNoVpn, isVpnConnected, vpnNoIPv6 bool) error {
+	if !isEnable {
+		wasEnabled := nativeIsEnabled()
+		nativeDisable() // erase everything (e.g. the rules left after the daemon crash)
+		if wasEnabled {
+			log.Info("Split Tunnel disabled")
+		}
+		isActive = false
+		return nil
+	}
+
+	p := nativeParams{isInversed: isStInversed, uids: nativeUids, gids: nativeGids}
+	if isStInversed {
+		p.vpnDestinations = nativeVpnDestinations
+		// Block 'inversed' apps when VPN is not connected
+		// or block IPv6 connectivity for 'splitted' apps if VPN does not support IPv6
+		p.inverseBlock = !isVpnConnected && !isStInverseAllowWhenNoVpn
+		p.inverseBlockIPv6 = isVpnConnected && vpnNoIPv6
+	}
+	if err := nativeEnable(p); err != nil {
+		return fmt.Errorf("failed to enable Split Tunnel: %w", err)
+	}
+	log.Info("Split Tunnel enabled")
+	isActive = true
+	return nil
+}
+
+func implApplyUsers(users, groups []string) error {
+	var uids, gids []uint32
+	for _, u := range users {
+		id, err := resolveUserID(u, false)
+		if err != nil {
+			return err
+		}
+		uids = append(uids, id)
+	}
+	for _, g := range groups {
+		id, err := resolveUserID(g, true)
+		if err != nil {
+			return err
+		}
+		gids = append(gids, id)
+
+		// 'meta skgid' matches only the primary GID of the socket owner.
+		// The members of the group (the group is supplementary for them) are matched by UID.
+		for _, uid := range groupMembersUIDs(id) {
+			if !slices.Contains(uids, uid) {
+				uids = append(uids, uid)
+			}
+		}
+	}
+
+	if !isNative() {
+		if len(uids) > 0 || len(gids) > 0 {
+			return fmt.Errorf("users-based Split Tunnel rules are not supported by the current implementation (cgroup v2 is required)")
+		}
+		return nil
+	}
+	return nativeSetUsers(uids, gids)
+}
+
+// resolveUserID returns UID (or GID when 'isGroup') by the user (group) name or numeric ID
+func resolveUserID(name string, isGroup bool) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	idStr := ""
+	if isGroup {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = g.Gid
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = u.Uid
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected ID of '%s': %w", name, err)
+	}
+	return uint32(id), nil
+}
+
+// groupMembersUIDs returns UIDs of the users which are members of the group (according to '/etc/group').
+// Note: the group members are resolved only when the configuration is applied.
+func groupMembersUIDs(gid uint32) (uids []uint32) {
+	data, err := os.ReadFile("/etc/group")
+	if err != nil {
+		log.Warning(fmt.Sprintf("unable to read group members: %s", err))
+		return nil
+	}
+	for _, name := range parseGroupMembers(string(data), gid) {
+		uid, err := resolveUserID(name, false)
+		if err != nil {
+			log.Warning(fmt.Sprintf("unable to resolve member '%s' of the group %d: %s", name, gid, err))
+			continue
+		}
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// parseGroupMembers returns names of the group members from the content of '/etc/group'
+// (line format: "group_name:password:GID:user1,user2")
+func parseGroupMembers(content string, gid uint32) (members []string) {
+	gidStr := strconv.FormatUint(uint64(gid), 10)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 4 || fields[2] != gidStr {
+			continue
+		}
+		for _, m := range strings.Split(fields[3], ",") {
+			if m = strings.TrimSpace(m); len(m) > 0 && !slices.Contains(members, m) {
+				members = append(members, m)
+			}
+		}
+	}
+	return members
+}
+
+func implDestinationsGateway(isIPv6 bool) (gateway net.IP, ifIndex int, err error) {
+	return netlink.GetDefaultRoute(isIPv6)
+}
+
+func implDestinationRouteAdd(r destRoute) error {
+	return netlink.RouteReplace(destNetlinkRoute(r))
+}
+
+func implDestinationRouteDel(r destRoute) error {
+	return netlink.RouteDel(destNetlinkRoute(r))
+}
+
+func destNetlinkRoute(r destRoute) netlink.Route {
+	dst := r.dst
+	return netlink.Route{IsIPv6: r.dst.IP.To4() == nil, Table: syscall.RT_TABLE_MAIN, Dst: &dst, Gateway: r.gateway, OutIface: r.ifIndex}
+}
+
+func implApplyVpnDestinations(destinations []net.IPNet) error {
+	if !isNative() {
+		if len(destinations) > 0 {
+			return fmt.Errorf("destination rules are not supported in Inverse Split Tunnel mode by the current implementation (cgroup v2 is required)")
+		}
+		return nil
+	}
+	return nativeSetVpnDestinations(destinations)
+}
+
 func getRootPid(p RunningApp, allPids map[int]RunningApp) (rootPid int, isKnownRoot bool) {
 	if _, ok := _addedRootProcesses[p.Ppid]; ok {
 		return p.Ppid, true
@@ -468,7 +677,7 @@ func readProcEnvVarIvpnId(pid int) (int, error) {
 	}
 
 	id := 0
-	vars := strings.Split(string(bytes), string(0))
+	vars := strings.Split(string(bytes), "\x00")
 	for _, line := range vars {
 		cols := strings.Split(line, "=")
 		if len(cols) != 2 {
@@ -481,3 +690,25 @@ func readProcEnvVarIvpnId(pid int) (int, error) {
 
 	return id, nil
 }
+
+// IsPidInSplitTunnel returns true if the process belongs to Split Tunnel cgroup
+// (e.g. the child process of the process which is in Split Tunnel environment)
+// The file format (/proc/<pid>/cgroup): "<hierarchy-ID>:<controllers>:<cgroup path>"
+func IsPidInSplitTunnel(pid int) bool {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// the same cgroup name is in use by the native implementation and by the Split Tunnel script
+	cgroupPath := "/" + nativeCgroupName
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		cols := strings.SplitN(scanner.Text(), ":", 3)
+		if len(cols) == 3 && cols[2] == cgroupPath {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/applist"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// The applications from the Split Tunnel configuration (paths to binaries or '.desktop' files) are added
+// to Split Tunnel environment automatically: the daemon monitors the new processes (netlink process events connector)
+// and moves the processes which executed the configured binaries into Split Tunnel cgroup.
+// Sandboxed applications (Flatpak, Snap) are started through wrappers, so their processes are matched
+// by the sandbox identity (Flatpak application ID, snap name) instead of the binary path.
+
+var (
+	// the configured applications (nil - monitor is not running)
+	appsMatcher *applist.Matcher
+	// channel to stop the process events monitor (nil - monitor is not running)
+	appsMonitorStop chan struct{}
+)
+
+// appsApplyConfig starts (or stops) monitoring of the configured applications
+// Must be called under locked 'mutex'
+func appsApplyConfig(isEnabled bool, apps []string) error {
+	matcher, skipped := applist.NewMatcher(apps)
+	for _, err := range skipped {
+		log.Warning("Split Tunnel ", err)
+	}
+	if !isEnabled || matcher.IsEmpty() {
+		appsMatcher = nil
+		if appsMonitorStop != nil {
+			close(appsMonitorStop)
+			appsMonitorStop = nil
+			log.Info("Applications monitor stopped")
+		}
+		return nil
+	}
+
+	appsMatcher = matcher
+	if appsMonitorStop == nil {
+		listener, err := netlink.CreateProcEventsListener()
+		if err != nil {
+			return fmt.Errorf("failed to start monitoring of the Split Tunnel applications: %w", err)
+		}
+		appsMonitorStop = make(chan struct{})
+		go appsMonitor(listener, appsMonitorStop)
+		log.Info("Applications monitor started")
+	}
+
+	// add applications which are already running
+	appsCheckRunningProcesses()
+	return nil
+}
+
+func appsMonitor(listener *netlink.ProcEventsListener, stop <-chan struct{}) {
+	defer listener.Close()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		pids, err := listener.ReadExecEvents()
+		if err != nil {
+			// Events may be lost (e.g. ENOBUFS when the socket buffer overflows).
+			// Re-check all running processes to not miss any process of the configured applications.
+			log.Error(err)
+			mutex.Lock()
+			appsCheckRunningProcesses()
+			mutex.Unlock()
+			continue
+		}
+		if len(pids) == 0 {
+			continue
+		}
+
+		mutex.Lock()
+		for _, pid := range pids {
+			appsCheckProcess(pid)
+		}
+		mutex.Unlock()
+	}
+}
+
+// appsCheckRunningProcesses adds to Split Tunnel environment all running processes of the configured applications
+// Must be called under locked 'mutex'
+func appsCheckRunningProcesses() {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			appsCheckProcess(pid)
+		}
+	}
+}
+
+// appsCheckProcess adds the process to Split Tunnel environment if it executes binary of the configured application
+// (or if it belongs to the sandbox of the configured application)
+// Must be called under locked 'mutex'
+func appsCheckProcess(pid int) {
+	app, ok := appsMatcher.Match(pid)
+	if !ok || IsPidInSplitTunnel(pid) {
+		return
+	}
+
+	var err error
+	if isNative() {
+		err = nativeAddPid(pid)
+	} else {
+		err = shell.Exec(nil, stScriptPath, "addpid", strconv.Itoa(pid))
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("Failed to add PID:%d (%s) to Split Tunnel: %s", pid, app, err))
+		return
+	}
+	_addedRootProcesses[pid] = app
+	log.Info(fmt.Sprintf("Added PID:%d (%s)", pid, app))
+}
@@ -0,0 +1,506 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/cgroup"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+	"github.com/ivpn/desktop-app/daemon/shell"
+)
+
+// Native Split Tunnel implementation (cgroup v2 aware).
+//
+// It is in use on systems with the unified cgroup v2 hierarchy (where the 'net_cls' cgroup v1 controller,
+// required by 'splittun.sh', may be not available):
+//   - the processes are classified by the cgroup v2 path: '/sys/fs/cgroup/ivpn-exclude';
+//   - the packets of the processes are marked by nftables rules ('socket cgroupv2' matching);
+//   - the marked packets are routed using a separate routing table (the routing rules are managed via netlink).
+//
+// The behaviour is the same as of 'splittun.sh' (see the script for details).
+
+const (
+	nativeCgroupName    = "ivpn-exclude"
+	nativeNftTable      = "ivpn_splittun"
+	nativeRoutingTable  = 17     // must be the same as '_routing_table_weight' in splittun.sh
+	nativePacketsFwmark = 0xca6c // the same as WireGuard uses for its packets (see '_packets_fwmark_value' in splittun.sh)
+	nativeCgroupProcs   = "cgroup.procs"
+)
+
+type nativeParams struct {
+	isInversed       bool
+	inverseBlock     bool
+	inverseBlockIPv6 bool
+	// (only for Inverse mode) destinations which always use the VPN tunnel
+	vpnDestinations []net.IPNet
+	// users and groups which traffic is 'splitted' (the same as traffic of the processes in Split Tunnel cgroup)
+	uids, gids []uint32
+}
+
+var (
+	// cgroup v2 mount point (empty - the native implementation is not in use)
+	nativeCgroupRoot string
+	// the parameters of the active configuration (nil - Split Tunnel is disabled)
+	nativeActive *nativeParams
+	// names of default interfaces in use by the active configuration
+	nativeIfaceV4, nativeIfaceV6 string
+	// original values of '/proc/sys/net/ipv4/conf/<interface>/rp_filter'
+	nativeRpFilterBackup = map[string]string{}
+	// original cgroups of the processes added to Split Tunnel (map[<PID>]<cgroup path>)
+	nativeOriginalCgroups      = map[int]string{}
+	nativeOriginalCgroupsMutex sync.Mutex
+	// (only for Inverse mode) destinations which always use the VPN tunnel
+	nativeVpnDestinations []net.IPNet
+	// users and groups which traffic is 'splitted'
+	nativeUids, nativeGids []uint32
+)
+
+func isNative() bool {
+	return len(nativeCgroupRoot) > 0
+}
+
+// nativeInitialize checks if the native implementation is applicable for the current system
+func nativeInitialize() error {
+	if _, err := os.Stat(filepath.Join(cgroup.UnifiedRoot, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("cgroup v2 unified hierarchy not detected")
+	}
+	if !cgroup.IsCgroup2Mount(cgroup.UnifiedRoot) {
+		return fmt.Errorf("cgroup v2 is not mounted on '%s'", cgroup.UnifiedRoot)
+	}
+	if _, err := exec.LookPath("nft"); err != nil {
+		return fmt.Errorf("nftables binary ('nft') not found")
+	}
+
+	// check if the kernel supports required nftables features (the cgroup must exist to check the rules)
+	cgroupPath := filepath.Join(cgroup.UnifiedRoot, nativeCgroupName)
+	_, statErr := os.Stat(cgroupPath)
+	if err := os.Mkdir(cgroupPath, 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	err := nativeNft(true, nativeNftRules(nativeParams{isInversed: true, inverseBlock: true}, "lo", ""))
+	if os.IsNotExist(statErr) {
+		os.Remove(cgroupPath)
+	}
+	if err != nil {
+		return fmt.Errorf("nftables does not support required features: %w", err)
+	}
+
+	nativeCgroupRoot = cgroup.UnifiedRoot
+	return nil
+}
+
+func nativeCgroupPath() string {
+	return filepath.Join(nativeCgroupRoot, nativeCgroupName)
+}
+
+func nativePidsFile() string {
+	return filepath.Join(nativeCgroupPath(), nativeCgroupProcs)
+}
+
+func nativeIsEnabled() bool {
+	return nativeActive != nil
+}
+
+// nativeEnable initializes Split Tunnel environment (the previous configuration is erased)
+func nativeEnable(p nativeParams) (retErr error) {
+	nativeDisable()
+
+	gwV4, ifaceV4, err := nativeDefaultRoute(false)
+	if err != nil {
+		return fmt.Errorf("default network interface is not defined; please, check internet connectivity (%w)", err)
+	}
+	gwV6, ifaceV6, errV6 := nativeDefaultRoute(true)
+	if errV6 != nil {
+		log.Warning(fmt.Sprintf("Default IPv6 route is not defined (%s)", errV6))
+	}
+
+	defer func() {
+		if retErr != nil {
+			nativeDisable()
+		}
+	}()
+
+	nativeActive = &p
+	nativeIfaceV4, nativeIfaceV6 = ifaceV4, ifaceV6
+
+	// Set required reverse path filtering parameter
+	rpFilterFile := fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/rp_filter", ifaceV4)
+	if val, err := os.ReadFile(rpFilterFile); err == nil {
+		nativeRpFilterBackup[ifaceV4] = strings.TrimSpace(string(val))
+		if err := os.WriteFile(rpFilterFile, []byte("2"), 0644); err != nil {
+			log.Warning(fmt.Sprintf("failed to set rp_filter for '%s': %s", ifaceV4, err))
+		}
+	}
+
+	if err := os.Mkdir(nativeCgroupPath(), 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+
+	if err := nativeNft(false, nativeNftRules(p, ifaceV4, ifaceV6)); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+
+	// Packets with mark will use Split Tunnel routing table
+	if err := netlink.RuleAdd(nativeRule(false)); err != nil {
+		return err
+	}
+	if gwV6 != nil {
+		if err := netlink.RuleAdd(nativeRule(true)); err != nil {
+			return err
+		}
+	}
+	if err := nativeSetRoutes(gwV4, ifaceV4, gwV6, ifaceV6); err != nil {
+		return err
+	}
+
+	// Compatibility with WireGuard rules (see details in splittun.sh):
+	// ensure the rule 'from all lookup main suppress_prefixlength 0' has higher priority than WireGuard rule
+	// 'not from all fwmark 0xca6c lookup 51820'
+	for _, isIPv6 := range []bool{false, true} {
+		if nativeIsWireGuardRuleExists(isIPv6) {
+			suppress := netlink.Rule{IsIPv6: isIPv6, Table: syscall.RT_TABLE_MAIN, SuppressPrefixLen: 0}
+			netlink.RuleDel(suppress)
+			if err := netlink.RuleAdd(suppress); err != nil {
+				log.Warning(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// nativeDisable erases Split Tunnel environment
+// (the processes are not moved out of the cgroup: they will be in Split Tunnel environment on the next enabling)
+func nativeDisable() {
+	nativeActive = nil
+
+	if err := shell.Exec(nil, "nft", "list", "table", "inet", nativeNftTable); err == nil {
+		if err := shell.Exec(log, "nft", "delete", "table", "inet", nativeNftTable); err != nil {
+			log.Error(fmt.Errorf("failed to remove nftables rules: %w", err))
+		}
+	}
+
+	for _, isIPv6 := range []bool{false, true} {
+		if err := netlink.RuleDel(nativeRule(isIPv6)); err != nil {
+			log.Warning(err)
+		}
+		if err := netlink.RouteFlushTable(isIPv6, nativeRoutingTable); err != nil {
+			log.Warning(err)
+		}
+	}
+
+	for iface, val := range nativeRpFilterBackup {
+		if err := os.WriteFile(fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/rp_filter", iface), []byte(val), 0644); err != nil {
+			log.Warning(fmt.Sprintf("failed to restore rp_filter for '%s': %s", iface, err))
+		}
+		delete(nativeRpFilterBackup, iface)
+	}
+
+	// Note: the cgroup will be removed only in case when no active process are in it
+	os.Remove(nativeCgroupPath())
+	nativeIfaceV4, nativeIfaceV6 = "", ""
+}
+
+// nativeUpdateRoutes updates the routing table of Split Tunnel according to the current default routes
+// (the OS erases the routes when the default network interface disappears)
+func nativeUpdateRoutes() error {
+	if nativeActive == nil {
+		return nil
+	}
+
+	gwV4, ifaceV4, _ := nativeDefaultRoute(false)
+	gwV6, ifaceV6, _ := nativeDefaultRoute(true)
+	if gwV4 == nil {
+		return nil // no connectivity; waiting for the next network change
+	}
+	if ifaceV4 != nativeIfaceV4 || ifaceV6 != nativeIfaceV6 {
+		// the default interface changed: the whole configuration must be re-applied
+		log.Info(fmt.Sprintf("Default interface changed ('%s' -> '%s'); re-applying configuration", nativeIfaceV4, ifaceV4))
+		return nativeEnable(*nativeActive)
+	}
+	return nativeSetRoutes(gwV4, ifaceV4, gwV6, ifaceV6)
+}
+
+func nativeSetRoutes(gwV4 net.IP, ifaceV4 string, gwV6 net.IP, ifaceV6 string) error {
+	if gwV4 != nil {
+		if err := nativeRouteReplace(false, gwV4, ifaceV4); err != nil {
+			return err
+		}
+	}
+	if gwV6 != nil {
+		if err := nativeRouteReplace(true, gwV6, ifaceV6); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nativeRouteReplace(isIPv6 bool, gw net.IP, ifaceName string) error {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return err
+	}
+	return netlink.RouteReplace(netlink.Route{IsIPv6: isIPv6, Table: nativeRoutingTable, Gateway: gw, OutIface: iface.Index})
+}
+
+func nativeRule(isIPv6 bool) netlink.Rule {
+	return netlink.Rule{IsIPv6: isIPv6, Table: nativeRoutingTable, Mark: nativePacketsFwmark, SuppressPrefixLen: -1}
+}
+
+func nativeIsWireGuardRuleExists(isIPv6 bool) bool {
+	rules, err := netlink.GetRules(isIPv6)
+	if err != nil {
+		return false
+	}
+	for _, r := range rules {
+		if r.Invert && r.Mark == nativePacketsFwmark {
+			return true
+		}
+	}
+	return false
+}
+
+func nativeDefaultRoute(isIPv6 bool) (gw net.IP, ifaceName string, err error) {
+	gw, ifaceIdx, err := netlink.GetDefaultRoute(isIPv6)
+	if err != nil {
+		return nil, "", err
+	}
+	iface, err := net.InterfaceByIndex(ifaceIdx)
+	if err != nil {
+		return nil, "", err
+	}
+	if gw == nil {
+		return nil, "", fmt.Errorf("default gateway is not defined (interface '%s')", iface.Name)
+	}
+	return gw, iface.Name, nil
+}
+
+// nativeNftRules returns nftables ruleset for Split Tunnel (the same logic as iptables rules in splittun.sh).
+// When 'ifaceV6' is empty - IPv6 connectivity is blocked for the Split Tunnel processes.
+func nativeNftRules(p nativeParams, ifaceV4, ifaceV6 string) string {
+	// in Inverse mode - the rules are inversed:
+	// 'splitted' apps use only VPN connection, all the rest apps use default connection settings (bypassing VPN)
+	cgroupMatch := fmt.Sprintf(`socket cgroupv2 level 1 "%s"`, nativeCgroupName)
+	usersMatch := func(op string) (ret []string) {
+		if len(p.uids) > 0 {
+			ret = append(ret, fmt.Sprintf("meta skuid %s{ %s }", op, joinUint32(p.uids)))
+		}
+		if len(p.gids) > 0 {
+			ret = append(ret, fmt.Sprintf("meta skgid %s{ %s }", op, joinUint32(p.gids)))
+		}
+		return ret
+	}
+
+	// 'splitMatches' - the packets of 'splitted' apps and users (any of the matches)
+	// 'stMatches' - the packets which bypass the VPN tunnel (any of the matches)
+	splitMatches := append([]string{cgroupMatch}, usersMatch("")...)
+	stMatches := splitMatches
+	if p.isInversed {
+		stMatches = []string{strings.Join(append([]string{fmt.Sprintf(`socket cgroupv2 level 1 != "%s"`, nativeCgroupName)}, usersMatch("!= ")...), " ")}
+	}
+
+	var nat, filter, route, mark strings.Builder
+	if p.isInversed {
+		// destinations which always use the VPN tunnel: packets are not marked (the main routing table is in use)
+		var destV4, destV6 []string
+		for _, n := range p.vpnDestinations {
+			if n.IP.To4() != nil {
+				destV4 = append(destV4, n.String())
+			} else {
+				destV6 = append(destV6, n.String())
+			}
+		}
+		if len(destV4) > 0 {
+			fmt.Fprintf(&route, "\t\tip daddr { %s } return\n", strings.Join(destV4, ", "))
+		}
+		if len(destV6) > 0 {
+			fmt.Fprintf(&route, "\t\tip6 daddr { %s } return\n", strings.Join(destV6, ", "))
+		}
+	}
+
+	for _, stMatch := range stMatches {
+		fmt.Fprintf(&mark, "\t\t%s meta mark set %#x\n", stMatch, nativePacketsFwmark)
+		fmt.Fprintf(&nat, "\t\tmeta nfproto ipv4 oifname \"%s\" %s masquerade\n", ifaceV4, stMatch)
+		if len(ifaceV6) > 0 {
+			fmt.Fprintf(&nat, "\t\tmeta nfproto ipv6 oifname \"%s\" %s masquerade\n", ifaceV6, stMatch)
+		}
+	}
+
+	if p.isInversed {
+		// do not block DNS requests
+		filter.WriteString("\t\tmeta l4proto { tcp, udp } th dport 53 accept\n")
+		for _, splitMatch := range splitMatches {
+			if p.inverseBlock {
+				fmt.Fprintf(&filter, "\t\t%s drop\n", splitMatch)
+			} else if p.inverseBlockIPv6 {
+				fmt.Fprintf(&filter, "\t\tmeta nfproto ipv6 %s drop\n", splitMatch)
+			}
+		}
+	}
+	if len(ifaceV6) == 0 {
+		// IPv6 interface is not defined - block IPv6 packets of Split Tunnel processes
+		for _, stMatch := range stMatches {
+			fmt.Fprintf(&filter, "\t\tmeta nfproto ipv6 %s drop\n", stMatch)
+		}
+	}
+
+	return fmt.Sprintf(`table inet %[1]s
+delete table inet %[1]s
+
+table inet %[1]s {
+	chain output_route {
+		type route hook output priority mangle; policy accept;
+		# DNS requests are not marked
+		meta l4proto { tcp, udp } th dport 53 return
+%[6]s%[2]s	}
+
+	chain postrouting_mangle {
+		type filter hook postrouting priority mangle; policy accept;
+		# save packets mark (to be able to restore mark for incoming packets of the same connection)
+		meta mark %#[3]x ct mark set meta mark
+	}
+
+	chain prerouting_mangle {
+		type filter hook prerouting priority mangle; policy accept;
+		ct mark %#[3]x meta mark set ct mark
+	}
+
+	chain postrouting_nat {
+		type nat hook postrouting priority srcnat; policy accept;
+		# change the source IP address of packets to the IP address of the interface they're going out on
+%[4]s	}
+
+	chain output_filter {
+		type filter hook output priority filter; policy accept;
+		oif "lo" accept
+%[5]s	}
+}
+`, nativeNftTable, mark.String(), nativePacketsFwmark, nat.String(), filter.String(), route.String())
+}
+
+func joinUint32(values []uint32) string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, strconv.FormatUint(uint64(v), 10))
+	}
+	return strings.Join(strs, ", ")
+}
+
+// nativeSetVpnDestinations updates the destinations which always use the VPN tunnel (only for Inverse mode)
+func nativeSetVpnDestinations(destinations []net.IPNet) error {
+	nativeVpnDestinations = destinations
+	if nativeActive == nil || !nativeActive.isInversed {
+		return nil
+	}
+	nativeActive.vpnDestinations = destinations
+	if err := nativeNft(false, nativeNftRules(*nativeActive, nativeIfaceV4, nativeIfaceV6)); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+	return nil
+}
+
+// nativeSetUsers updates the users and groups which traffic is 'splitted'
+func nativeSetUsers(uids, gids []uint32) error {
+	nativeUids, nativeGids = uids, gids
+	if nativeActive == nil {
+		return nil
+	}
+	nativeActive.uids, nativeActive.gids = uids, gids
+	if err := nativeNft(false, nativeNftRules(*nativeActive, nativeIfaceV4, nativeIfaceV6)); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+	return nil
+}
+
+// nativeNft applies nftables ruleset ('isCheckOnly' - only check the rules, without applying)
+func nativeNft(isCheckOnly bool, ruleset string) error {
+	args := []string{"-f", "-"}
+	if isCheckOnly {
+		args = append([]string{"-c"}, args...)
+	}
+	cmd := exec.Command("nft", args...)
+	cmd.Stdin = strings.NewReader(ruleset)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errText := strings.TrimSpace(stderr.String()); len(errText) > 0 {
+			return fmt.Errorf("%w: %s", err, errText)
+		}
+		return err
+	}
+	return nil
+}
+
+// nativeAddPid moves the process to Split Tunnel cgroup (the original cgroup is saved to restore it on removing)
+func nativeAddPid(pid int) error {
+	nativeOriginalCgroupsMutex.Lock()
+	defer nativeOriginalCgroupsMutex.Unlock()
+
+	if cg := cgroup.ProcessCgroup(pid); len(cg) > 0 && cg != "/"+nativeCgroupName {
+		nativeOriginalCgroups[pid] = cg
+	}
+	return cgroup.MoveProcess(pid, nativeCgroupPath())
+}
+
+// nativeRemovePid moves the process back to its original cgroup (or to the root cgroup, if the original does not exist anymore)
+func nativeRemovePid(pid int) error {
+	nativeOriginalCgroupsMutex.Lock()
+	defer nativeOriginalCgroupsMutex.Unlock()
+
+	original, ok := nativeOriginalCgroups[pid]
+	delete(nativeOriginalCgroups, pid)
+	if ok {
+		if err := cgroup.MoveProcess(pid, filepath.Join(nativeCgroupRoot, original)); err == nil {
+			return nil
+		}
+	}
+	return cgroup.MoveProcess(pid, nativeCgroupRoot)
+}
+
+// nativeReset removes all processes from Split Tunnel cgroup
+func nativeReset() error {
+	pids, err := cgroup.Pids(nativeCgroupPath())
+	if err != nil {
+		return err
+	}
+	var retErr error
+	for _, pid := range pids {
+		if err := nativeRemovePid(pid); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
@@ -0,0 +1,117 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+//go:build linux
+// +build linux
+
+package splittun
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNativeNftRules(t *testing.T) {
+	contains := func(rules string, expected ...string) {
+		t.Helper()
+		for _, e := range expected {
+			if !strings.Contains(rules, e) {
+				t.Errorf("rule not found: '%s'\n%s", e, rules)
+			}
+		}
+	}
+	notContains := func(rules string, unexpected ...string) {
+		t.Helper()
+		for _, e := range unexpected {
+			if strings.Contains(rules, e) {
+				t.Errorf("unexpected rule: '%s'\n%s", e, rules)
+			}
+		}
+	}
+
+	rules := nativeNftRules(nativeParams{}, "eth0", "eth1")
+	contains(rules,
+		`socket cgroupv2 level 1 "ivpn-exclude" meta mark set 0xca6c`,
+		`meta nfproto ipv4 oifname "eth0" socket cgroupv2 level 1 "ivpn-exclude" masquerade`,
+		`meta nfproto ipv6 oifname "eth1" socket cgroupv2 level 1 "ivpn-exclude" masquerade`)
+	notContains(rules, "drop", "!=")
+
+	// no IPv6 default route: IPv6 is blocked for Split Tunnel processes
+	rules = nativeNftRules(nativeParams{}, "eth0", "")
+	contains(rules, `meta nfproto ipv6 socket cgroupv2 level 1 "ivpn-exclude" drop`)
+	notContains(rules, "meta nfproto ipv6 oifname")
+
+	// inverse mode
+	rules = nativeNftRules(nativeParams{isInversed: true}, "eth0", "eth0")
+	contains(rules,
+		`socket cgroupv2 level 1 != "ivpn-exclude" meta mark set 0xca6c`,
+		`meta nfproto ipv4 oifname "eth0" socket cgroupv2 level 1 != "ivpn-exclude" masquerade`,
+		"th dport 53 accept")
+	notContains(rules, "drop")
+
+	rules = nativeNftRules(nativeParams{isInversed: true, inverseBlock: true}, "eth0", "eth0")
+	contains(rules, "\t\t"+`socket cgroupv2 level 1 "ivpn-exclude" drop`)
+
+	rules = nativeNftRules(nativeParams{isInversed: true, inverseBlockIPv6: true}, "eth0", "eth0")
+	contains(rules, `meta nfproto ipv6 socket cgroupv2 level 1 "ivpn-exclude" drop`)
+	notContains(rules, "\t\t"+`socket cgroupv2 level 1 "ivpn-exclude" drop`)
+
+	// destinations which always use the VPN tunnel (only for inverse mode)
+	_, n1, _ := net.ParseCIDR("10.10.0.0/16")
+	_, n2, _ := net.ParseCIDR("1.1.1.1/32")
+	_, n3, _ := net.ParseCIDR("2001:db8::/32")
+	dests := []net.IPNet{*n1, *n2, *n3}
+	rules = nativeNftRules(nativeParams{isInversed: true, vpnDestinations: dests}, "eth0", "eth0")
+	contains(rules,
+		"ip daddr { 10.10.0.0/16, 1.1.1.1/32 } return",
+		"ip6 daddr { 2001:db8::/32 } return")
+	rules = nativeNftRules(nativeParams{vpnDestinations: dests}, "eth0", "eth0")
+	notContains(rules, "daddr")
+
+	// users and groups
+	rules = nativeNftRules(nativeParams{uids: []uint32{1001, 1002}, gids: []uint32{50}}, "eth0", "eth1")
+	contains(rules,
+		"meta skuid { 1001, 1002 } meta mark set 0xca6c",
+		"meta skgid { 50 } meta mark set 0xca6c",
+		`meta nfproto ipv4 oifname "eth0" meta skuid { 1001, 1002 } masquerade`,
+		`meta nfproto ipv6 oifname "eth1" meta skgid { 50 } masquerade`)
+
+	rules = nativeNftRules(nativeParams{isInversed: true, inverseBlock: true, uids: []uint32{1001}}, "eth0", "eth0")
+	contains(rules,
+		`socket cgroupv2 level 1 != "ivpn-exclude" meta skuid != { 1001 } meta mark set 0xca6c`,
+		"\t\tmeta skuid { 1001 } drop")
+	notContains(rules, "meta skuid { 1001 } meta mark")
+}
+
+func TestParseGroupMembers(t *testing.T) {
+	content := "root:x:0:\nusers:x:100:alice,bob\n# comment\nvpn:x:1050: carol , alice\nbad line\n"
+	if m := parseGroupMembers(content, 100); strings.Join(m, ",") != "alice,bob" {
+		t.Errorf("unexpected members: %v", m)
+	}
+	if m := parseGroupMembers(content, 1050); strings.Join(m, ",") != "carol,alice" {
+		t.Errorf("unexpected members: %v", m)
+	}
+	if m := parseGroupMembers(content, 0); len(m) != 0 {
+		t.Errorf("unexpected members: %v", m)
+	}
+}
@@ -284,6 +284,49 @@ func implGetRunningApps() ([]RunningApp, error) {
 	return nil, fmt.Errorf("operation not applicable for current platform")
 }
 
+func implApplyUsers(users, groups []string) error {
+	if len(users) > 0 || len(groups) > 0 {
+		return fmt.Errorf("users-based Split Tunnel rules are not supported for this platform")
+	}
+	return nil
+}
+
+func implDestinationsGateway(isIPv6 bool) (gateway net.IP, ifIndex int, err error) {
+	gw, inf, err := netinfo.DefaultGatewayEx(isIPv6)
+	if err != nil {
+		return nil, 0, err
+	}
+	return gw, inf.Index, nil
+}
+
+func implDestinationRouteAdd(r destRoute) error {
+	if routeBinaryPath == "" {
+		return fmt.Errorf("route.exe location not specified")
+	}
+	// route add <network> <gw> if <interface_idx>
+	if err := shell.Exec(log, routeBinaryPath, "add", r.dst.String(), r.gateway.String(), "if", fmt.Sprintf("%d", r.ifIndex)); err != nil {
+		return fmt.Errorf("failed to add route for Split Tunnel destination %s: %w", r.dst.String(), err)
+	}
+	return nil
+}
+
+func implDestinationRouteDel(r destRoute) error {
+	if routeBinaryPath == "" {
+		return fmt.Errorf("route.exe location not specified")
+	}
+	if err := shell.Exec(log, routeBinaryPath, "delete", r.dst.String(), r.gateway.String()); err != nil {
+		return fmt.Errorf("failed to remove route for Split Tunnel destination %s: %w", r.dst.String(), err)
+	}
+	return nil
+}
+
+func implApplyVpnDestinations(destinations []net.IPNet) error {
+	if len(destinations) > 0 {
+		return fmt.Errorf("destination rules are not supported in Inverse Split Tunnel mode for this platform")
+	}
+	return nil
+}
+
 // Inversed split-tunneling solution for Windows (no changes in Split-Tunnel driver implementation required):
 // **IVPN daemon:**
 // - Disable monitoring of the default route to the VPN server.
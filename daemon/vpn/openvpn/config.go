@@ -50,6 +50,7 @@ type ConnectionParams struct {
 	proxyPassword        string
 	proxyAuthFileData    string // required for for obfs4 socks(!) proxy `--socks-proxy server [port] [authfile]`. If this parameter is defined - `proxyUsername` and `proxyPassword`` will be ignored.
 	// (e.g. the obfs4 requires the key to be stored in 'authfile': `cert=E50PjFC...6R7jzP0gYQ;iat-mode=0`)
+	transportEndpoints []net.IP // hosts which the pluggable transport connects to (routed outside the tunnel)
 }
 
 func (c *ConnectionParams) IsMultihop() bool {
@@ -265,6 +266,9 @@ func (c *ConnectionParams) generateConfiguration(
 
 		if c.proxyAddress.Equal(net.IPv4(127, 0, 0, 1)) {
 			cfg = append(cfg, fmt.Sprintf("route %s 255.255.255.255 %s", c.hostIP.String(), localGatewayAddress.String()))
+			for _, ip := range c.transportEndpoints {
+				cfg = append(cfg, fmt.Sprintf("route %s 255.255.255.255 %s", ip.String(), localGatewayAddress.String()))
+			}
 		} else {
 			cfg = append(cfg, fmt.Sprintf("route %s 255.255.255.255 %s", c.proxyAddress, localGatewayAddress.String()))
 		}
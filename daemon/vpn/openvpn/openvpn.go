@@ -30,6 +30,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -38,6 +39,7 @@ import (
 
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/obfsproxy"
+	"github.com/ivpn/desktop-app/daemon/ptransport"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/service/platform"
 	"github.com/ivpn/desktop-app/daemon/shell"
@@ -85,9 +87,19 @@ type ObfsParams struct {
 	Config     obfsproxy.Config
 	RemotePort int
 	Obfs4Key   string
+	// Generic pluggable transport (used when obfsproxy is not enabled)
+	Transport ptransport.Config
+	// IP addresses of the hosts which the pluggable transport connects to, except the VPN server (see ptransport.Config.ResolveEndpoints())
+	TransportEndpoints []net.IP
 }
 
 func (obfs ObfsParams) CheckConsistency() error {
+	if obfs.Transport.IsDefined() {
+		if obfs.Config.IsObfsproxy() {
+			return fmt.Errorf("bad configuration (obfsproxy and pluggable transport cannot be used together)")
+		}
+		return obfs.Transport.Validate()
+	}
 	if !obfs.Config.IsObfsproxy() {
 		return nil
 	}
@@ -114,7 +126,7 @@ type OpenVPN struct {
 	connectParams   ConnectionParams
 
 	managementInterface *ManagementInterface
-	obfsproxy           *obfsproxy.Obfsproxy
+	transport           ptransport.Transport // obfsproxy or generic pluggable transport
 
 	// current VPN state
 	state     vpn.State
@@ -169,6 +181,15 @@ func (o *OpenVPN) DestinationIP() net.IP {
 	return o.connectParams.hostIP
 }
 
+// TransportEndpoints - Get IPs of the hosts which the pluggable transport connects to (except the VPN server)
+// This information if required, for example, to allow these addresses in firewall
+func (o *OpenVPN) TransportEndpoints() []net.IP {
+	if !o.obfsProxyParams.Transport.IsDefined() {
+		return nil
+	}
+	return o.obfsProxyParams.TransportEndpoints
+}
+
 // Type just returns VPN type
 func (o *OpenVPN) Type() vpn.Type { return vpn.OpenVPN }
 
@@ -199,7 +220,7 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 	// channel will be analyzed for state change. States will be forwarded to channel above ( to 'stateChan')
 	internalStateChan := make(chan vpn.StateInfo, 1)
 
-	// EXIT: stopping everything: Management interface, Obfsproxy
+	// EXIT: stopping everything: Management interface, Obfsproxy (pluggable transport)
 	defer func() {
 
 		if retErr != nil {
@@ -216,12 +237,12 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 			}
 		}
 
-		obfspxy := o.obfsproxy
-		if obfspxy != nil {
-			obfspxy.Stop()
+		transport := o.transport
+		if transport != nil {
+			transport.Stop()
 		}
 
-		o.obfsproxy = nil
+		o.transport = nil
 
 		if err := o.implOnDisconnected(); err != nil {
 			log.Error(err)
@@ -255,11 +276,15 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 					// notify about correct local IP in VPN network
 					o.clientIP = stateInf.ClientIP
 
-					if o.obfsproxy != nil {
+					if o.transport != nil {
 						// in case of obfsproxy - 'stateInf.ServerIP' returns local IP (IP of obfsproxy 127.0.0.1)
 						// We must notify about real remote ServerIP, therefore we modifying this parameter before notifying about successful connection
 						stateInf.ServerIP = o.connectParams.hostIP
-						stateInf.Obfsproxy = o.obfsproxy.Config()
+						if o.obfsProxyParams.Config.IsObfsproxy() {
+							stateInf.Obfsproxy = o.obfsProxyParams.Config
+						} else {
+							stateInf.PluggableTransport = o.obfsProxyParams.Transport.Name
+						}
 					}
 
 					// Process "on connected" event (if necessary)
@@ -294,15 +319,44 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 	}
 
 	var err error
-	obfsproxyPort := 0
-	// start Obfsproxy (if necessary)
+	if err := o.obfsProxyParams.CheckConsistency(); err != nil {
+		return err
+	}
+
+	// initialize Obfsproxy or generic pluggable transport (if necessary)
+	transportDescription := ""
+	transportRemotePort := 0
+	transportAuthFileData := ""
 	if o.obfsProxyParams.Config.IsObfsproxy() {
-		if err := o.obfsProxyParams.CheckConsistency(); err != nil {
+		obfspxy := obfsproxy.CreateObfsproxy(platform.ObfsproxyStartScript(), o.obfsProxyParams.Config)
+		o.transport = obfspxy
+		transportDescription = "obfsproxy"
+		transportRemotePort = o.obfsProxyParams.RemotePort
+		transportAuthFileData = obfspxy.MakeObfs4AuthFileContent(o.obfsProxyParams.Obfs4Key)
+	} else if o.obfsProxyParams.Transport.IsDefined() {
+		ptCfg := o.obfsProxyParams.Transport
+		// the per-connection arguments of the transport are passed in SOCKS5 username/password fields
+		username, password, err := ptCfg.SocksAuth()
+		if err != nil {
 			return err
 		}
-		o.obfsproxy = obfsproxy.CreateObfsproxy(platform.ObfsproxyStartScript(), o.obfsProxyParams.Config)
-		if obfsproxyPort, err = o.obfsproxy.Start(); err != nil {
-			return errors.New("unable to initialize OpenVPN (obfsproxy not started): " + err.Error())
+		if len(username) > 0 || len(password) > 0 {
+			transportAuthFileData = username + "\n" + password
+		}
+		o.transport = ptransport.NewManagedProxy(ptCfg.BinaryPath, ptCfg.Name, path.Join(platform.LogDir(), "ivpn-pt-state"))
+		transportDescription = fmt.Sprintf("pluggable transport '%s'", ptCfg.Name)
+		transportRemotePort = ptCfg.RemotePort
+		if transportRemotePort <= 0 {
+			transportRemotePort = o.connectParams.hostPort
+		}
+		o.connectParams.transportEndpoints = o.obfsProxyParams.TransportEndpoints
+	}
+
+	// start Obfsproxy (pluggable transport)
+	if o.transport != nil {
+		transportPort, err := o.transport.Start()
+		if err != nil {
+			return fmt.Errorf("unable to initialize OpenVPN (%s not started): %w", transportDescription, err)
 		}
 
 		// update connection parameters according to obfsproxy configuration
@@ -310,11 +364,11 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 		o.connectParams.tcp = true
 		o.connectParams.proxyType = "socks"
 		o.connectParams.proxyAddress = net.IPv4(127, 0, 0, 1) // "127.0.0.1"
-		o.connectParams.proxyPort = obfsproxyPort
+		o.connectParams.proxyPort = transportPort
 		o.connectParams.proxyUsername = ""
 		o.connectParams.proxyPassword = ""
-		o.connectParams.hostPort = o.obfsProxyParams.RemotePort
-		o.connectParams.proxyAuthFileData = o.obfsproxy.MakeObfs4AuthFileContent(o.obfsProxyParams.Obfs4Key)
+		o.connectParams.hostPort = transportRemotePort
+		o.connectParams.proxyAuthFileData = transportAuthFileData
 		//--------------------------------------------------
 
 		// detect obfsproxy process stop
@@ -322,16 +376,16 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 		go func() {
 			defer routinesWaiter.Done()
 
-			opxy := o.obfsproxy
-			if opxy == nil {
+			transport := o.transport
+			if transport == nil {
 				return
 			}
 
 			// wait for obfsproxy stop
-			opxy.Wait()
+			transport.Wait()
 			if !o.isDisconnectRequested {
 				// If obfsproxy stopped unexpectedly - disconnect VPN
-				log.Error("Obfsproxy stopped unexpectedly. Disconnecting VPN...")
+				log.Error(fmt.Sprintf("The %s stopped unexpectedly. Disconnecting VPN...", transportDescription))
 				o.doDisconnect()
 			}
 		}()
@@ -125,12 +125,16 @@ func (o *OpenVPN) implOnResetManualDNS() error {
 }
 
 func (o *OpenVPN) implGetUpDownScriptArgs() string {
-	resolvectlBinPath := platform.ResolvectlBinPath()
-	if len(resolvectlBinPath) > 0 {
-		extraDnsParams := dns.GetExtraSettings()
-		if !extraDnsParams.Linux_IsDnsMgmtOldStyle {
+	switch dns.LinuxDnsMgmtMethodInUse() {
+	case dns.LinuxDnsMgmtNetworkManager:
+		// DNS is configured by the daemon using NetworkManager (see implOnConnected()): the script must not change DNS
+		return "-skip-dns"
+	case dns.LinuxDnsMgmtResolvectl, dns.LinuxDnsMgmtResolvedDbus:
+		resolvectlBinPath := platform.ResolvectlBinPath()
+		if len(resolvectlBinPath) > 0 {
 			return "-use-resolvconf " + resolvectlBinPath
 		}
+		return "-skip-dns"
 	}
 	return ""
 }
@@ -142,19 +142,22 @@ type StateInfo struct {
 	State       State
 	Description string
 
-	VpnType      Type
-	Time         int64                  // unix time (seconds)
-	IsTCP        bool                   // applicable only for 'CONNECTED' state
-	ClientIP     net.IP                 // applicable only for 'CONNECTED' state
-	ClientIPv6   net.IP                 // applicable only for 'CONNECTED' state. Initialized only if protocol supports IPv6 inside tunnel
-	ClientPort   int                    // applicable only for 'CONNECTED' state (source port)
-	ServerIP     net.IP                 // applicable only for 'CONNECTED' state
-	ServerPort   int                    // applicable only for 'CONNECTED' state (destination port)
-	V2RayProxy   v2r.V2RayTransportType // applicable only for 'CONNECTED' state
-	Obfsproxy    obfsproxy.Config       // applicable only for 'CONNECTED' state (OpenVPN)
-	ExitHostname string                 // applicable only for 'CONNECTED' state
-	Mtu          int                    // applicable only for 'CONNECTED' state (WireGuard)
-	IsAuthError  bool                   // applicable only for 'EXITING' state
+	VpnType     Type
+	Time        int64                  // unix time (seconds)
+	IsTCP       bool                   // applicable only for 'CONNECTED' state
+	ClientIP    net.IP                 // applicable only for 'CONNECTED' state
+	ClientIPv6  net.IP                 // applicable only for 'CONNECTED' state. Initialized only if protocol supports IPv6 inside tunnel
+	ClientPort  int                    // applicable only for 'CONNECTED' state (source port)
+	ServerIP    net.IP                 // applicable only for 'CONNECTED' state
+	ServerPort  int                    // applicable only for 'CONNECTED' state (destination port)
+	V2RayProxy  v2r.V2RayTransportType // applicable only for 'CONNECTED' state
+	Obfsproxy   obfsproxy.Config       // applicable only for 'CONNECTED' state (OpenVPN)
+	IsAmneziaWG bool                   // applicable only for 'CONNECTED' state (WireGuard with AmneziaWG obfuscation)
+	// name of the pluggable transport; applicable only for 'CONNECTED' state (OpenVPN)
+	PluggableTransport string
+	ExitHostname       string // applicable only for 'CONNECTED' state
+	Mtu                int    // applicable only for 'CONNECTED' state (WireGuard)
+	IsAuthError        bool   // applicable only for 'EXITING' state
 
 	// TODO: try to avoid using this protocol-specific parameter in future
 	// Currently, in use by OpenVPN connection to inform about "RECONNECTING" reason (e.g. "tls-error", "init_instance"...)
@@ -215,13 +218,15 @@ type Process interface {
 	IsIPv6InTunnel() bool
 
 	IsReconnectRequiredOnRoutingChange() bool // If true, then reconnect required on routing change
-	OnRoutingChanged() error                  // This function must be called when routing changes detected
+	// OnRoutingChanged must be called when routing changes detected.
+	// It returns *ReconnectionRequiredError when the connection can not be kept over the new network
+	OnRoutingChanged() error
 	// If VPN changes "default" route, this function returns gateway IP address of the modified "default route",
 	// otherwise - nil (system default route keeps unchanged for this VPN connection)
 	DefaultRouteGatewayIP() net.IP
 }
 
-// ReconnectionRequiredError object can be returned by vpn.Process.Connect() function
+// ReconnectionRequiredError object can be returned by vpn.Process.Connect() (or vpn.Process.OnRoutingChanged()) function
 // which means that it requesting to do re-connect immediately
 type ReconnectionRequiredError struct {
 	Err error
@@ -0,0 +1,89 @@
+//go:build darwin || linux
+// +build darwin linux
+
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/awg"
+)
+
+// AmneziaWG connection is based on the userspace implementation ('amneziawg-go').
+// It creates the UAPI socket in its own directory ('awgSocketDir'), but the WireGuard tools
+// (the 'wg' binary and 'wgctrl' package, which are in use to configure and to monitor the interface)
+// are looking for the sockets of userspace implementations in 'wgSocketDir'.
+// So, the socket is hard-linked into 'wgSocketDir' (symlinks are ignored by 'wgctrl').
+const (
+	awgSocketDir = "/var/run/amneziawg"
+	wgSocketDir  = "/var/run/wireguard"
+
+	awgSocketWaitTimeout = time.Second * 5
+)
+
+// awgConfigure makes the started AmneziaWG userspace device accessible for WireGuard tools
+// and applies the obfuscation parameters (must be done before the peer configured: to obfuscate the first handshake)
+func awgConfigure(ifname string, params awg.Params) error {
+	socket := filepath.Join(awgSocketDir, ifname+".sock")
+
+	for started := time.Now(); ; time.Sleep(time.Millisecond * 50) {
+		if fi, err := os.Stat(socket); err == nil && fi.Mode().Type() == fs.ModeSocket {
+			break
+		}
+		if time.Since(started) > awgSocketWaitTimeout {
+			return fmt.Errorf("AmneziaWG interface initialization timeout (socket '%s' not available)", socket)
+		}
+	}
+
+	if err := os.MkdirAll(wgSocketDir, 0700); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", wgSocketDir, err)
+	}
+	link := filepath.Join(wgSocketDir, ifname+".sock")
+	os.Remove(link)
+	if err := os.Link(socket, link); err != nil {
+		return fmt.Errorf("failed to link AmneziaWG socket: %w", err)
+	}
+
+	if err := awg.UapiSet(socket, params); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("AmneziaWG obfuscation parameters applied to '%s'", ifname))
+	return nil
+}
+
+// awgCleanup removes the link to AmneziaWG socket (if exists).
+// Note: the link has the same name as the socket of the plain userspace WireGuard implementation ('wireguard-go'),
+// so it must be called only when AmneziaWG is in use for the interface.
+func awgCleanup(ifname string) {
+	link := filepath.Join(wgSocketDir, ifname+".sock")
+	if err := os.Remove(link); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warning(fmt.Sprintf("failed to remove AmneziaWG socket link: %s", err))
+	}
+}
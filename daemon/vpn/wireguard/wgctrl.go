@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 // WaitForFirstHanshake waits for a handshake during 'timeout' time.
@@ -139,3 +140,144 @@ func waitForWgInterfaceChan(tunnelName string, isWaitForDisconnect bool, isStopA
 
 	return retChan
 }
+
+// RestartHandshake initiates the new handshake with the peers of the WireGuard interface and waits until it is performed.
+// In use on network changes (roaming): the peers are re-added with the same configuration, so the current sessions
+// are dropped and the handshake is performed from the new network immediately (the persistent keepalive is sending the first packet).
+// The interface is not reconfigured, so the connections inside the tunnel are not affected.
+func RestartHandshake(tunnelName string, timeout time.Duration) error {
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to restart handshake: %w", err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(tunnelName)
+	if err != nil {
+		return fmt.Errorf("failed to restart handshake for '%s': %w", tunnelName, err)
+	}
+
+	started := time.Now()
+	if err := reinstallPeers(client, tunnelName, dev.Peers); err != nil {
+		return fmt.Errorf("failed to restart handshake: %w", err)
+	}
+	return waitForHandshake(client, tunnelName, started, timeout)
+}
+
+// UpdateKeys switches the running WireGuard interface to the new private key (and the new PresharedKey)
+// and waits for the handshake with the new keys.
+// The peers are updated in place (they are not re-added), so the endpoints, allowed IPs and queued packets are kept;
+// the packets are waiting in the peer queue until the new handshake is done.
+// If the handshake was not performed - the previous keys are restored.
+// Returns isRestored=true when the new keys failed but the connection with the previous keys is established again.
+func UpdateKeys(tunnelName string, privateKey string, presharedKey string, timeout time.Duration) (isRestored bool, err error) {
+	newPrivateKey, err := wgtypes.ParseKey(privateKey)
+	if err != nil {
+		return false, fmt.Errorf("WG private key: %w", err)
+	}
+	var newPresharedKey wgtypes.Key // zero value - no PresharedKey
+	if len(presharedKey) > 0 {
+		if newPresharedKey, err = wgtypes.ParseKey(presharedKey); err != nil {
+			return false, fmt.Errorf("WG PresharedKey: %w", err)
+		}
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return false, fmt.Errorf("failed to update keys: %w", err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(tunnelName)
+	if err != nil {
+		return false, fmt.Errorf("failed to update keys for '%s': %w", tunnelName, err)
+	}
+	if len(dev.Peers) == 0 {
+		return false, fmt.Errorf("failed to update keys for '%s': no peers", tunnelName)
+	}
+
+	started := time.Now()
+	if err = client.ConfigureDevice(tunnelName, keysConfig(newPrivateKey, dev.Peers, &newPresharedKey)); err == nil {
+		if err = waitForHandshake(client, tunnelName, started, timeout); err == nil {
+			return false, nil
+		}
+	}
+
+	// restore the previous keys
+	restoreStarted := time.Now()
+	if rErr := client.ConfigureDevice(tunnelName, keysConfig(dev.PrivateKey, dev.Peers, nil)); rErr != nil {
+		return false, fmt.Errorf("%w (failed to restore previous keys: %s)", err, rErr)
+	}
+	if rErr := waitForHandshake(client, tunnelName, restoreStarted, timeout); rErr != nil {
+		return false, fmt.Errorf("%w (the previous keys restored but: %s)", err, rErr)
+	}
+	return true, err
+}
+
+// keysConfig returns the configuration which changes the private key of the interface and the PresharedKey of the existing peers.
+// The peers are updated in place ('UpdateOnly'). Changing the private key expires the current session, and the new handshake
+// is initiated immediately.
+// If presharedKey is nil - the PresharedKeys from 'peers' are in use.
+func keysConfig(privateKey wgtypes.Key, peers []wgtypes.Peer, presharedKey *wgtypes.Key) wgtypes.Config {
+	cfg := wgtypes.Config{PrivateKey: &privateKey}
+	for _, p := range peers {
+		psk := p.PresharedKey
+		if presharedKey != nil {
+			psk = *presharedKey
+		}
+		cfg.Peers = append(cfg.Peers, wgtypes.PeerConfig{PublicKey: p.PublicKey, UpdateOnly: true, PresharedKey: &psk})
+	}
+	return cfg
+}
+
+// reinstallPeers re-adds the peers with the same configuration (the active sessions of the peers are dropped).
+func reinstallPeers(client *wgctrl.Client, tunnelName string, peers []wgtypes.Peer) error {
+	if len(peers) == 0 {
+		return fmt.Errorf("no peers")
+	}
+
+	removePeers := make([]wgtypes.PeerConfig, 0, len(peers))
+	addPeers := make([]wgtypes.PeerConfig, 0, len(peers))
+	for _, p := range peers {
+		psk := p.PresharedKey
+		keepalive := p.PersistentKeepaliveInterval
+		removePeers = append(removePeers, wgtypes.PeerConfig{PublicKey: p.PublicKey, Remove: true})
+		addPeers = append(addPeers, wgtypes.PeerConfig{
+			PublicKey:                   p.PublicKey,
+			PresharedKey:                &psk,
+			Endpoint:                    p.Endpoint,
+			PersistentKeepaliveInterval: &keepalive,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs:                  p.AllowedIPs,
+		})
+	}
+
+	if err := client.ConfigureDevice(tunnelName, wgtypes.Config{Peers: removePeers}); err != nil {
+		return fmt.Errorf("removing peers: %w", err)
+	}
+	if err := client.ConfigureDevice(tunnelName, wgtypes.Config{Peers: addPeers}); err != nil {
+		return fmt.Errorf("adding peers: %w", err)
+	}
+	return nil
+}
+
+// waitForHandshake waits until all peers of the interface perform the handshake after 'since' time
+func waitForHandshake(client *wgctrl.Client, tunnelName string, since time.Time, timeout time.Duration) error {
+	for ; time.Since(since) < timeout; time.Sleep(time.Millisecond * 100) {
+		dev, err := client.Device(tunnelName)
+		if err != nil {
+			return fmt.Errorf("failed to check handshake info for '%s': %w", tunnelName, err)
+		}
+		isDone := true
+		for _, peer := range dev.Peers {
+			if !peer.LastHandshakeTime.After(since) {
+				isDone = false
+				break
+			}
+		}
+		if isDone {
+			return nil
+		}
+	}
+	return fmt.Errorf("handshake was not performed in %s", timeout)
+}
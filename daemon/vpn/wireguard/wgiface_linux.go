@@ -0,0 +1,397 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2025 IVPN Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ivpn/desktop-app/daemon/netinfo"
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
+	"github.com/ivpn/desktop-app/daemon/service/platform"
+	"github.com/ivpn/desktop-app/daemon/shell"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// The WireGuard interface is configured by the daemon directly (kernel WireGuard module, netlink),
+// the same way as 'wg-quick' does it for the configuration with 'AllowedIPs = 0.0.0.0/0, ::/0':
+//   - packets of the tunnel (encrypted) are marked by 'wgFwmark' and use the main routing table
+//   - all other packets are routed through the WireGuard interface (routing table 'wgRoutingTable'):
+//     32764:	from all lookup main suppress_prefixlength 0
+//     32765:	not from all fwmark 0xca6c lookup 51820
+const (
+	wgFwmark       = 0xca6c // the Split Tunnel and the firewall use the same value to mark the packets which bypass the tunnel
+	wgRoutingTable = 51820
+	wgNftTable     = "ivpn_wireguard"
+	wgDefaultMTU   = 1420
+	// WireGuard overhead: IPv6 header (40) + UDP header (8) + WireGuard header (32)
+	wgOverhead = 80
+)
+
+// wgUp creates and configures the WireGuard interface.
+// All changes are rolled back in case of failure.
+func (wg *WireGuard) wgUp() (retErr error) {
+	ifname := wg.getTunnelName()
+
+	defer func() {
+		if retErr != nil {
+			if err := wg.wgDown(); err != nil {
+				log.Warning(fmt.Sprintf("rollback of WireGuard configuration: %s", err))
+			}
+		}
+	}()
+
+	cfg, err := wg.deviceConfig()
+	if err != nil {
+		return fmt.Errorf("failed to generate WireGuard configuration: %w", err)
+	}
+
+	if wg.connectParams.IsAmneziaWG() {
+		// AmneziaWG: userspace implementation (the kernel WireGuard module does not support obfuscation)
+		if err := wg.awgStart(ifname); err != nil {
+			return err
+		}
+	} else if err := netlink.LinkAdd(ifname, "wireguard"); err != nil {
+		if errors.Is(err, syscall.EOPNOTSUPP) {
+			return fmt.Errorf("%w (WireGuard kernel module not available)", err)
+		}
+		return err
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to configure WireGuard interface: %w", err)
+	}
+	defer client.Close()
+	if err := client.ConfigureDevice(ifname, cfg); err != nil {
+		return fmt.Errorf("failed to configure WireGuard interface: %w", err)
+	}
+
+	ipv6LocalIP := wg.connectParams.GetIPv6ClientLocalIP()
+	if err := netlink.AddrAdd(ifname, net.IPNet{IP: wg.connectParams.clientLocalIP, Mask: net.CIDRMask(32, 32)}); err != nil {
+		return err
+	}
+	if ipv6LocalIP != nil {
+		if err := netlink.AddrAdd(ifname, net.IPNet{IP: ipv6LocalIP, Mask: net.CIDRMask(128, 128)}); err != nil {
+			return err
+		}
+	}
+
+	mtu := wg.connectParams.mtu
+	if mtu <= 0 {
+		mtu = wgAutoMTU()
+	}
+	if err := netlink.LinkSetUp(ifname, mtu); err != nil {
+		return err
+	}
+
+	if err := wg.wgSetRouting(); err != nil {
+		return err
+	}
+
+	// the reply packets of the tunnel must pass the reverse path filter (see 'wgNftRules')
+	if err := os.WriteFile("/proc/sys/net/ipv4/conf/all/src_valid_mark", []byte("1"), 0644); err != nil {
+		log.Warning(fmt.Sprintf("failed to set 'src_valid_mark': %s", err))
+	}
+	if _, err := exec.LookPath("nft"); err != nil {
+		log.Info("'nft' not available: skipped reverse path filtering rules for WireGuard interface")
+	} else if err := wgNft(wgNftRules(ifname, wg.connectParams.clientLocalIP, ipv6LocalIP)); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+
+	// remember the default route in use (see onRoutingChanged())
+	if gateway, ifaceIndex, err := netlink.GetDefaultRoute(false); err == nil {
+		wg.internals.mutex.Lock()
+		wg.internals.defaultRoute = defaultRouteInfo{gateway: gateway, ifaceIndex: ifaceIndex}
+		wg.internals.mutex.Unlock()
+	}
+
+	ipv6LocalIPStr := ""
+	if ipv6LocalIP != nil {
+		ipv6LocalIPStr = ", " + ipv6LocalIP.String()
+	}
+	log.Info(fmt.Sprintf("WireGuard interface '%s' configured (address: %s%s; MTU: %d; local port: %d; endpoint: %s:%d)",
+		ifname, wg.connectParams.clientLocalIP, ipv6LocalIPStr, mtu, wg.localPort, wg.connectParams.hostIP, wg.connectParams.hostPort))
+	return nil
+}
+
+// wgSetRouting ensures that the routes and the routing policy rules of the WireGuard interface are applied
+// (the existing configuration is not modified to not break the traffic of the tunnel)
+func (wg *WireGuard) wgSetRouting() error {
+	iface, err := net.InterfaceByName(wg.getTunnelName())
+	if err != nil {
+		return err
+	}
+	for _, isIPv6 := range []bool{false, true} {
+		if isIPv6 && wg.connectParams.GetIPv6ClientLocalIP() == nil {
+			continue
+		}
+		if err := netlink.RouteReplace(netlink.Route{IsIPv6: isIPv6, Table: wgRoutingTable, OutIface: iface.Index}); err != nil {
+			return err
+		}
+
+		rules := wgRules(isIPv6)
+		existingRules, err := netlink.GetRules(isIPv6)
+		if err != nil {
+			return err
+		}
+		isAllExists := true
+		for _, r := range rules {
+			if !wgIsRuleExists(existingRules, r) {
+				isAllExists = false
+				break
+			}
+		}
+		if isAllExists {
+			continue
+		}
+		// re-apply all rules: the order is important
+		for _, r := range rules {
+			if err := netlink.RuleDel(r); err != nil {
+				log.Warning(err)
+			}
+		}
+		for _, r := range rules {
+			if err := netlink.RuleAdd(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// wgDown removes the WireGuard interface and all related configuration (if exists)
+func (wg *WireGuard) wgDown() error {
+	var retErr error
+
+	for _, isIPv6 := range []bool{false, true} {
+		for _, r := range wgRules(isIPv6) {
+			if err := netlink.RuleDel(r); err != nil {
+				log.Warning(err)
+			}
+		}
+	}
+
+	if _, err := exec.LookPath("nft"); err == nil {
+		if err := shell.Exec(nil, "nft", "list", "table", "inet", wgNftTable); err == nil {
+			if err := shell.Exec(log, "nft", "delete", "table", "inet", wgNftTable); err != nil {
+				log.Warning(fmt.Errorf("failed to remove nftables rules: %w", err))
+			}
+		}
+	}
+
+	// routes of the interface are removed by the OS together with the interface
+	if err := netlink.LinkDel(wg.getTunnelName()); err != nil {
+		retErr = fmt.Errorf("failed to stop WireGuard: %w", err)
+	}
+
+	wg.awgStop()
+	return retErr
+}
+
+// awgStart starts AmneziaWG userspace implementation which creates the interface 'ifname'
+func (wg *WireGuard) awgStart(ifname string) error {
+	binary := platform.AwgBinaryPath()
+	if len(binary) == 0 {
+		return fmt.Errorf("AmneziaWG is not supported")
+	}
+
+	cmd := exec.Command(binary, "-f", ifname)
+	cmd.Env = append(os.Environ(), "LOG_LEVEL=error")
+	var stderr bytes.Buffer
+	cmd.Stdout = &stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start AmneziaWG: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		if errText := strings.TrimSpace(stderr.String()); len(errText) > 0 {
+			log.Info(fmt.Sprintf("AmneziaWG process output: %s", errText))
+		}
+		log.Info(fmt.Sprintf("AmneziaWG process stopped (%v)", err))
+		close(done)
+	}()
+
+	wg.internals.mutex.Lock()
+	wg.internals.awgProcess = cmd.Process
+	wg.internals.awgProcessDone = done
+	wg.internals.mutex.Unlock()
+
+	return awgConfigure(ifname, wg.connectParams.amneziaWG)
+}
+
+// awgStop stops AmneziaWG userspace implementation (if running)
+func (wg *WireGuard) awgStop() {
+	wg.internals.mutex.Lock()
+	process, done := wg.internals.awgProcess, wg.internals.awgProcessDone
+	wg.internals.awgProcess, wg.internals.awgProcessDone = nil, nil
+	wg.internals.mutex.Unlock()
+
+	// the kernel WireGuard does not use UAPI sockets: the link (if exists) is left from AmneziaWG connection
+	awgCleanup(wg.getTunnelName())
+
+	if process == nil {
+		return
+	}
+	// the process exits when the interface removed
+	select {
+	case <-done:
+		return
+	case <-time.After(time.Second * 2):
+	}
+	if err := process.Kill(); err != nil {
+		log.Warning(fmt.Sprintf("failed to stop AmneziaWG process: %s", err))
+	}
+	<-done
+}
+
+// deviceConfig returns the configuration of WireGuard device (keys, peer)
+func (wg *WireGuard) deviceConfig() (wgtypes.Config, error) {
+	privateKey, err := wgtypes.ParseKey(wg.connectParams.clientPrivateKey)
+	if err != nil {
+		return wgtypes.Config{}, fmt.Errorf("WG private key: %w", err)
+	}
+	publicKey, err := wgtypes.ParseKey(wg.connectParams.hostPublicKey)
+	if err != nil {
+		return wgtypes.Config{}, fmt.Errorf("WG public key: %w", err)
+	}
+	var presharedKey *wgtypes.Key
+	if len(wg.connectParams.presharedKey) > 0 {
+		k, err := wgtypes.ParseKey(wg.connectParams.presharedKey)
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("WG PresharedKey: %w", err)
+		}
+		presharedKey = &k
+	}
+
+	localPort, err := netinfo.GetFreeUDPPort()
+	if err != nil {
+		return wgtypes.Config{}, fmt.Errorf("unable to obtain free local port: %w", err)
+	}
+	wg.localPort = localPort
+
+	allowedIPs := []net.IPNet{{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}}
+	if wg.connectParams.GetIPv6ClientLocalIP() != nil {
+		allowedIPs = append(allowedIPs, net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)})
+	}
+
+	fwmark := wgFwmark
+	keepalive := 25 * time.Second
+	return wgtypes.Config{
+		PrivateKey:   &privateKey,
+		ListenPort:   &localPort,
+		FirewallMark: &fwmark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   publicKey,
+			PresharedKey:                presharedKey,
+			Endpoint:                    &net.UDPAddr{IP: wg.connectParams.hostIP, Port: wg.connectParams.hostPort},
+			PersistentKeepaliveInterval: &keepalive,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs:                  allowedIPs,
+		}},
+	}, nil
+}
+
+func wgRules(isIPv6 bool) []netlink.Rule {
+	// the order is important: the rule added last has the highest priority
+	return []netlink.Rule{
+		{IsIPv6: isIPv6, Table: wgRoutingTable, Mark: wgFwmark, Invert: true, SuppressPrefixLen: -1},
+		{IsIPv6: isIPv6, Table: syscall.RT_TABLE_MAIN, SuppressPrefixLen: 0},
+	}
+}
+
+func wgIsRuleExists(rules []netlink.Rule, r netlink.Rule) bool {
+	for _, e := range rules {
+		if e.Table == r.Table && e.Invert == r.Invert && e.Mark == r.Mark && e.SuppressPrefixLen == r.SuppressPrefixLen {
+			return true
+		}
+	}
+	return false
+}
+
+// wgAutoMTU returns MTU of the WireGuard interface based on MTU of the default network interface
+func wgAutoMTU() int {
+	_, ifaceIdx, err := netlink.GetDefaultRoute(false)
+	if err != nil {
+		return wgDefaultMTU
+	}
+	iface, err := net.InterfaceByIndex(ifaceIdx)
+	if err != nil || iface.MTU-wgOverhead < 1280 {
+		return wgDefaultMTU
+	}
+	return iface.MTU - wgOverhead
+}
+
+// wgNftRules returns the nftables rules (the same as 'wg-quick' applies):
+//   - drop the packets to the tunnel address which are not coming from the tunnel interface
+//   - restore the mark of the tunnel reply packets (from the connection mark) to pass the reverse path filter
+func wgNftRules(ifname string, localIP net.IP, localIPv6 net.IP) string {
+	preraw := fmt.Sprintf(`iifname != "%s" ip daddr %s fib saddr type != local drop`, ifname, localIP)
+	if localIPv6 != nil {
+		preraw += fmt.Sprintf("\n\t\tiifname != \"%s\" ip6 daddr %s fib saddr type != local drop", ifname, localIPv6)
+	}
+
+	return fmt.Sprintf(`table inet %[1]s
+delete table inet %[1]s
+table inet %[1]s {
+	chain preraw {
+		type filter hook prerouting priority raw; policy accept;
+		%[2]s
+	}
+	chain premangle {
+		type filter hook prerouting priority mangle; policy accept;
+		meta l4proto udp meta mark set ct mark
+	}
+	chain postmangle {
+		type filter hook postrouting priority mangle; policy accept;
+		meta l4proto udp meta mark 0x%[3]x ct mark set meta mark
+	}
+}
+`, wgNftTable, preraw, wgFwmark)
+}
+
+func wgNft(ruleset string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(ruleset)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errText := strings.TrimSpace(stderr.String()); len(errText) > 0 {
+			return fmt.Errorf("%w: %s", err, errText)
+		}
+		return err
+	}
+	return nil
+}
@@ -28,7 +28,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/ivpn/desktop-app/daemon/awg"
 	"github.com/ivpn/desktop-app/daemon/helpers"
 	"github.com/ivpn/desktop-app/daemon/logger"
 	"github.com/ivpn/desktop-app/daemon/netinfo"
@@ -38,6 +41,22 @@ import (
 
 var log *logger.Logger
 
+// roamingHandshakeTimeout - the time to wait for a new handshake after the network change (see RestartHandshake())
+const roamingHandshakeTimeout = time.Second * 15
+
+const (
+	// keysPropagationDelay - the time to keep the current session with the previous keys after the keys rotation.
+	// The new public key must be propagated to the VPN servers before it can be in use
+	// (the previous key stays valid while connected: see 'connected_public_key' API request field).
+	keysPropagationDelay = time.Minute * 2
+	// keysHandshakeTimeout - the time to wait for the handshake with the new keys (one handshake retransmission is included).
+	// Note: the tunnel traffic is stalled while waiting (the packets are queued until the handshake is done),
+	// so this value must be as short as possible.
+	keysHandshakeTimeout = time.Second * 7
+	// keysUpdateMaxAttempts - the number of attempts to switch to the new keys (the next attempt is after keysPropagationDelay)
+	keysUpdateMaxAttempts = 3
+)
+
 func init() {
 	log = logger.NewLogger("wg")
 }
@@ -52,8 +71,9 @@ type ConnectionParams struct {
 	hostPublicKey        string
 	hostLocalIP          net.IP
 	ipv6Prefix           string
-	multihopExitHostname string // (e.g.: "nl4.wg.ivpn.net") we need it only for informing clients about connection status
-	mtu                  int    // Set 0 to use default MTU value
+	multihopExitHostname string     // (e.g.: "nl4.wg.ivpn.net") we need it only for informing clients about connection status
+	mtu                  int        // Set 0 to use default MTU value
+	amneziaWG            awg.Params // AmneziaWG obfuscation parameters (not defined - plain WireGuard)
 }
 
 func (cp *ConnectionParams) GetIPv6ClientLocalIP() net.IP {
@@ -76,6 +96,21 @@ func (cp *ConnectionParams) SetCredentials(privateKey string, presharedKey strin
 	cp.clientLocalIP = localIP
 }
 
+// SetMtu updates MTU value of the connection (0 - use default MTU value)
+func (cp *ConnectionParams) SetMtu(mtu int) {
+	cp.mtu = mtu
+}
+
+// SetAmneziaWG enables AmneziaWG obfuscation for the connection
+func (cp *ConnectionParams) SetAmneziaWG(params awg.Params) {
+	cp.amneziaWG = params
+}
+
+// IsAmneziaWG returns 'true' when AmneziaWG obfuscation is in use
+func (cp *ConnectionParams) IsAmneziaWG() bool {
+	return cp.amneziaWG.IsDefined()
+}
+
 // CreateConnectionParams initializing connection parameters object
 func CreateConnectionParams(
 	multihopExitHostName string,
@@ -107,6 +142,11 @@ type WireGuard struct {
 
 	isDisconnected        bool
 	isDisconnectRequested bool
+	// isStopped is 'true' when the disconnection is requested or the connection is closed.
+	// Unlike 'isDisconnected'/'isDisconnectRequested' it is safe to read it from other goroutines.
+	isStopped atomic.Bool
+
+	keysUpdateSeq atomic.Uint32 // incremented on each UpdateCredentials() call (the previous call is cancelled)
 
 	// Must be implemented (AND USED) in correspond file for concrete platform. Must contain platform-specified properties (or can be empty struct)
 	internals internalVariables
@@ -159,9 +199,11 @@ func (wg *WireGuard) Connect(stateChan chan<- vpn.StateInfo) error {
 	disconnectDescription := ""
 	wg.isDisconnected = false
 	wg.isDisconnectRequested = false
+	wg.isStopped.Store(false)
 	stateChan <- vpn.NewStateInfo(vpn.CONNECTING, "")
 	defer func() {
 		wg.isDisconnected = true
+		wg.isStopped.Store(true)
 		stateChan <- vpn.NewStateInfo(vpn.DISCONNECTED, disconnectDescription)
 	}()
 
@@ -175,6 +217,13 @@ func (wg *WireGuard) Connect(stateChan chan<- vpn.StateInfo) error {
 			}
 		}
 
+		if wg.connectParams.IsAmneziaWG() {
+			if err := wg.connectParams.amneziaWG.Validate(); err != nil {
+				return err
+			}
+			log.Info("AmneziaWG obfuscation: ", wg.connectParams.amneziaWG)
+		}
+
 		return wg.connect(stateChan)
 	}()
 
@@ -188,6 +237,7 @@ func (wg *WireGuard) Connect(stateChan chan<- vpn.StateInfo) error {
 // Disconnect stops the connection
 func (wg *WireGuard) Disconnect() error {
 	wg.isDisconnectRequested = true
+	wg.isStopped.Store(true)
 	return wg.disconnect()
 }
 
@@ -198,9 +248,9 @@ func (wg *WireGuard) IsPaused() bool {
 
 // Pause doing required operation for Pause (temporary restoring default DNS)
 func (wg *WireGuard) Pause() error {
-	// IMPORTANT! When the WG keys regenerated (see service.WireGuardSaveNewKeys()):
+	// IMPORTANT! When the WG keys regenerated (see service.WireGuardSaveNewKeys() and UpdateCredentials()):
 	// WireGuard 'pause/resume' state is based on complete VPN disconnection and restoring connection back (on all platforms)
-	// If this will be changed (e.g. just changing routing) - it will be necessary to implement reconnection even in 'pause' state (when keys were regenerated)
+	// so the new keys are applied on resume.
 	if ret := wg.pause(); ret != nil {
 		return ret
 	}
@@ -219,6 +269,81 @@ func (wg *WireGuard) Resume() error {
 	return <-WaitForConnectChan(wg.GetTunnelName(), []*bool{&wg.isDisconnectRequested, &wg.isDisconnected})
 }
 
+// UpdateCredentials applies the new credentials (WG keys rotation) to the active connection without reconnection.
+// The current session is kept with the previous keys until the new public key is propagated to the VPN servers (keysPropagationDelay).
+// Then the keys are switched in place; if the handshake with the new keys failed - the previous keys are restored
+// and the switch is retried later.
+// Limitation: the tunnel traffic is stalled during the handshake with the new keys (up to keysHandshakeTimeout);
+// if the new keys are rejected by the server it happens on each attempt (keysUpdateMaxAttempts, every keysPropagationDelay).
+// The method is blocking (it returns when the keys are switched or the update is cancelled).
+// Returns *vpn.ReconnectionRequiredError when the connection can not be kept (e.g. the local IP changed).
+func (wg *WireGuard) UpdateCredentials(privateKey string, presharedKey string, localIP net.IP) error {
+	if !localIP.Equal(wg.connectParams.clientLocalIP) {
+		return &vpn.ReconnectionRequiredError{Err: fmt.Errorf("local IP changed")}
+	}
+
+	seq := wg.keysUpdateSeq.Add(1)
+	for attempt := 1; ; attempt++ {
+		if err := wg.waitKeysPropagation(seq); err != nil {
+			return err
+		}
+
+		if wg.IsPaused() {
+			// the interface is down: the new credentials will be in use after resume
+			wg.connectParams.SetCredentials(privateKey, presharedKey, localIP)
+			return nil
+		}
+
+		isRestored, err := UpdateKeys(wg.GetTunnelName(), privateKey, presharedKey, keysHandshakeTimeout)
+		if err == nil {
+			wg.connectParams.SetCredentials(privateKey, presharedKey, localIP)
+			return nil
+		}
+		if !isRestored {
+			return &vpn.ReconnectionRequiredError{Err: err}
+		}
+		if attempt >= keysUpdateMaxAttempts {
+			return fmt.Errorf("the new keys not applied (the connection is kept with the previous keys): %w", err)
+		}
+		log.Warning(fmt.Sprintf("The new keys not applied (the connection is kept with the previous keys; retry in %v): %s", keysPropagationDelay, err))
+	}
+}
+
+// waitKeysPropagation waits keysPropagationDelay.
+// Returns error when the connection is closed or the keys update with sequence number 'seq' is superseded by the newer one.
+func (wg *WireGuard) waitKeysPropagation(seq uint32) error {
+	for deadline := time.Now().Add(keysPropagationDelay); time.Now().Before(deadline); time.Sleep(time.Millisecond * 500) {
+		if wg.isStopped.Load() {
+			return fmt.Errorf("keys update cancelled: disconnected")
+		}
+		if wg.keysUpdateSeq.Load() != seq {
+			return fmt.Errorf("keys update cancelled: newer keys received")
+		}
+	}
+	return nil
+}
+
+// SetMtu changes MTU of the active connection without reconnection.
+// When the connection is paused - the new value is applied on resume.
+func (wg *WireGuard) SetMtu(mtu int) error {
+	if mtu < 1280 || mtu > 65535 {
+		return fmt.Errorf("bad MTU value (acceptable interval is: [1280 - 65535])")
+	}
+	if !wg.IsPaused() {
+		if err := wg.setMtu(mtu); err != nil {
+			return err
+		}
+	}
+	wg.connectParams.SetMtu(mtu)
+	return nil
+}
+
+// BypassFwmark returns the firewall mark ('fwmark') of the packets which are routed outside the tunnel
+// (0 - when not applicable for the current platform)
+func (wg *WireGuard) BypassFwmark() uint32 {
+	return bypassFwmark()
+}
+
 // SetManualDNS changes DNS to manual IP
 func (wg *WireGuard) SetManualDNS(dnsCfg dns.DnsSettings) error {
 	return wg.setManualDNS(dnsCfg)
@@ -330,6 +455,7 @@ func (wg *WireGuard) newStateInfoConnected() vpn.StateInfo {
 		wg.connectParams.mtu)
 
 	si.ExitHostname = wg.connectParams.multihopExitHostname
+	si.IsAmneziaWG = wg.connectParams.IsAmneziaWG()
 	return si
 }
 
@@ -149,9 +149,18 @@ func (wg *WireGuard) internalConnect(stateChan chan<- vpn.StateInfo) error {
 	}
 	wg.internals.utunName = utunName
 
+	binaryPath := wg.binaryPath
+	if wg.connectParams.IsAmneziaWG() {
+		// AmneziaWG: 'amneziawg-go' is a drop-in replacement of 'wireguard-go' (with obfuscation support)
+		if binaryPath = platform.AwgBinaryPath(); len(binaryPath) == 0 {
+			return fmt.Errorf("AmneziaWG is not supported")
+		}
+		defer awgCleanup(wg.getTunnelName())
+	}
+
 	log.Info("Starting WireGuard in interface ", wg.getTunnelName())
 	// LOG_LEVEL=verbose
-	wg.internals.command = exec.Command(wg.binaryPath, "-f", wg.getTunnelName())
+	wg.internals.command = exec.Command(binaryPath, "-f", wg.getTunnelName())
 	wg.internals.command.Env = os.Environ()
 	wg.internals.command.Env = append(wg.internals.command.Env, "LOG_LEVEL=verbose")
 
@@ -371,6 +380,13 @@ func (wg *WireGuard) initializeConfiguration() error {
 		return fmt.Errorf("failed to initialize interface: %w", err)
 	}
 
+	// AmneziaWG obfuscation parameters must be applied before the peer configuration (to obfuscate the first handshake)
+	if wg.connectParams.IsAmneziaWG() {
+		if err := awgConfigure(wg.getTunnelName(), wg.connectParams.amneziaWG); err != nil {
+			return err
+		}
+	}
+
 	// WireGuard configuration
 	if err := wg.setWgConfiguration(); err != nil {
 		return err
@@ -600,8 +616,16 @@ func (wg *WireGuard) onRoutingChanged() error {
 	wg.removeRoutes()
 	wg.internals.defGateway = defGateway
 	wg.internals.defInterfaceName = defInterfaceName
-	wg.setRoutes()
+	if err := wg.setRoutes(); err != nil {
+		log.Warning(fmt.Sprintf("onRoutingChanged: %s", err))
+	}
 
+	// initiate new handshake over the new network (the interface stays unchanged, so the connections inside the tunnel survive)
+	log.Info("Initiating new handshake...")
+	if err := RestartHandshake(wg.getTunnelName(), roamingHandshakeTimeout); err != nil {
+		return &vpn.ReconnectionRequiredError{Err: err}
+	}
+	log.Info("Handshake performed over the new network")
 	return nil
 }
 
@@ -682,3 +706,14 @@ func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return wg.connectParams.hostLocalIP
 }
+
+func (wg *WireGuard) setMtu(mtu int) error {
+	if err := shell.Exec(log, "/sbin/ifconfig", wg.getTunnelName(), "mtu", strconv.Itoa(mtu)); err != nil {
+		return fmt.Errorf("failed to set MTU (%d): %w", mtu, err)
+	}
+	return nil
+}
+
+func bypassFwmark() uint32 {
+	return 0
+}
@@ -26,16 +26,14 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
-	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ivpn/desktop-app/daemon/oshelpers/linux/netlink"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
-	"github.com/ivpn/desktop-app/daemon/shell"
 	"github.com/ivpn/desktop-app/daemon/vpn"
 )
 
@@ -80,6 +78,18 @@ type internalVariables struct {
 	isPaused             atomic.Bool
 	resumeDisconnectChan chan *operationRequest // control connection pause\resume or disconnect from paused state
 	lastOpRequest        *operationRequest
+	defaultRoute         defaultRouteInfo // the default route (main routing table) in use by the tunnel
+	awgProcess           *os.Process      // AmneziaWG userspace implementation process (nil - kernel WireGuard in use)
+	awgProcessDone       chan struct{}    // closed when 'awgProcess' exited
+}
+
+type defaultRouteInfo struct {
+	gateway    net.IP
+	ifaceIndex int
+}
+
+func (r defaultRouteInfo) String() string {
+	return fmt.Sprintf("gateway %s, interface index %d", r.gateway, r.ifaceIndex)
 }
 
 func (wg *WireGuard) init() error {
@@ -92,22 +102,19 @@ func (wg *WireGuard) init() error {
 	// (e.g. process was terminated)
 	// In such situation, the 'wgivpn' keeps active.
 	// We should close it in this case. Otherwise, new connection would not be established
-	wgInterfaceName := filepath.Base(wg.configFilePath)
-	wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
-	// stop current WG connection (if exists)
-	i, _ := net.InterfaceByName(wgInterfaceName)
-	if i != nil {
-		log.Info(fmt.Sprintf("Stopping WireGuard interface ('%s' expected to be stopped before the new connection)...", wgInterfaceName))
-		err := shell.Exec(log, "ip", "link", "set", "down", wgInterfaceName) // command: sudo ip link set down wgivpn
-		if err != nil {
-			log.Warning(err)
-		}
-		err = shell.Exec(log, "ip", "link", "delete", wgInterfaceName) // command: sudo ip link delete wgivpn
-		if err != nil {
+	if i, _ := net.InterfaceByName(wg.getTunnelName()); i != nil {
+		log.Info(fmt.Sprintf("Stopping WireGuard interface ('%s' expected to be stopped before the new connection)...", wg.getTunnelName()))
+		if err := wg.wgDown(); err != nil {
 			log.Warning(err)
 		}
 	}
 
+	// The configuration file is not in use anymore (the interface is configured by the daemon directly).
+	// Ensure the file (it contains the private key) is not left from the previous versions.
+	if err := os.Remove(wg.configFilePath); err == nil {
+		log.Info("Removed obsolete WireGuard configuration file")
+	}
+
 	return nil
 }
 
@@ -134,11 +141,6 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 			}
 		default:
 		}
-
-		// do not forget to remove config file after finishing configuration
-		if err := os.Remove(wg.configFilePath); err != nil {
-			log.Warning(fmt.Sprintf("failed to remove WG configuration: %s", err))
-		}
 	}()
 
 	internalRestoreDNSFunc := func() {
@@ -148,11 +150,7 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 		}
 	}
 	internalDisconnectFunc := func() error {
-		err := shell.Exec(log, wg.binaryPath, "down", wg.configFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to stop WireGuard: %w", err)
-		}
-		return nil
+		return wg.wgDown()
 	}
 
 	// loop connection initialization (required for pause\resume functionality)
@@ -161,20 +159,9 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 	for {
 		isResumeRequested := false
 
-		// generate configuration
-		err := wg.generateAndSaveConfigFile(wg.configFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to save WG config file: %w", err)
-		}
-
 		// start WG
-		log.Info("Shell exec: ", wg.binaryPath, " up ", wg.configFilePath)
-		cmd := exec.Command(wg.binaryPath, "up", wg.configFilePath)
-		outBytes, err := cmd.CombinedOutput()
+		err := wg.wgUp()
 		if err != nil {
-			if len(outBytes) > 0 {
-				log.Error(fmt.Sprintf("'%s' error. Output: %s", wg.binaryPath, string(outBytes)))
-			}
 			return fmt.Errorf("failed to start WireGuard: %w", err)
 		}
 
@@ -202,8 +189,7 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 				return err
 			}
 
-			wgInterfaceName := filepath.Base(wg.configFilePath)
-			wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
+			wgInterfaceName := wg.getTunnelName()
 
 			// wait until wireguard interface is available
 			func() {
@@ -371,33 +357,63 @@ func (wg *WireGuard) resetManualDNS() error {
 }
 
 func (wg *WireGuard) getOSSpecificConfigParams() (interfaceCfg []string, peerCfg []string) {
-	ipv6LocalIP := wg.connectParams.GetIPv6ClientLocalIP()
-	ipv6LocalIPStr := ""
-	allowedIPsV6 := ""
-	if ipv6LocalIP != nil {
-		ipv6LocalIPStr = ", " + ipv6LocalIP.String()
-		allowedIPsV6 = ", ::/0"
+	// not in use for Linux: the configuration file is not generated (see wgUp())
+	return nil, nil
+}
+
+// onRoutingChanged keeps the connection when the network changes (roaming):
+// the interface and the tunnel routing stay unchanged (the tunnel packets follow the default route of the main routing table),
+// only the new handshake is initiated over the new network.
+func (wg *WireGuard) onRoutingChanged() error {
+	if !wg.isRunning() || wg.isPaused() {
+		return nil
+	}
+	if i, _ := net.InterfaceByName(wg.getTunnelName()); i == nil {
+		return nil // not initialized yet (or already stopped)
 	}
 
-	if wg.connectParams.mtu > 0 {
-		interfaceCfg = append(interfaceCfg, fmt.Sprintf("MTU = %d", wg.connectParams.mtu))
+	// ensure the routing of the tunnel was not modified (e.g. by 3rd party software)
+	if err := wg.wgSetRouting(); err != nil {
+		return &vpn.ReconnectionRequiredError{Err: fmt.Errorf("failed to restore routing: %w", err)}
 	}
-	interfaceCfg = append(interfaceCfg, "Address = "+wg.connectParams.clientLocalIP.String()+"/32"+ipv6LocalIPStr)
-	interfaceCfg = append(interfaceCfg, "SaveConfig = true")
 
-	peerCfg = append(peerCfg, "AllowedIPs = 0.0.0.0/0"+allowedIPsV6)
-	return interfaceCfg, peerCfg
-}
+	gateway, ifaceIndex, err := netlink.GetDefaultRoute(false)
+	if err != nil {
+		log.Info("onRoutingChanged: default route not found (waiting for network connectivity)")
+		return nil
+	}
+	newRoute := defaultRouteInfo{gateway: gateway, ifaceIndex: ifaceIndex}
 
-func (wg *WireGuard) onRoutingChanged() error {
-	// do nothing for Linux
+	wg.internals.mutex.Lock()
+	oldRoute := wg.internals.defaultRoute
+	wg.internals.defaultRoute = newRoute
+	wg.internals.mutex.Unlock()
+
+	if oldRoute.ifaceIndex == newRoute.ifaceIndex && oldRoute.gateway.Equal(newRoute.gateway) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Default route changed: (%s) -> (%s). Initiating new handshake...", oldRoute, newRoute))
+	if err := RestartHandshake(wg.getTunnelName(), roamingHandshakeTimeout); err != nil {
+		return &vpn.ReconnectionRequiredError{Err: err}
+	}
+	log.Info("Handshake performed over the new network")
 	return nil
 }
 
 func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
-	return true
+	// the connection is kept on network changes (see onRoutingChanged())
+	return false
 }
 
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return nil
 }
+
+func (wg *WireGuard) setMtu(mtu int) error {
+	return netlink.LinkSetMTU(wg.getTunnelName(), mtu)
+}
+
+func bypassFwmark() uint32 {
+	return wgFwmark
+}
@@ -31,6 +31,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ivpn/desktop-app/daemon/netinfo"
 	"github.com/ivpn/desktop-app/daemon/service/dns"
 	"github.com/ivpn/desktop-app/daemon/shell"
 	"github.com/ivpn/desktop-app/daemon/vpn"
@@ -38,6 +39,7 @@ import (
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/mgr"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
 var (
@@ -62,6 +64,9 @@ type internalVariables struct {
 	pauseRequireChan      chan operation  // control connection pause\resume or disconnect from paused state
 	isDisconnectRequested bool
 	isPaused              bool
+	// the default route in use by the tunnel (see onRoutingChanged())
+	defGateway    net.IP
+	defIfaceIndex int
 }
 
 const (
@@ -93,6 +98,10 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 	if wg.internals.isDisconnectRequested {
 		return fmt.Errorf("disconnection already requested for this object. To make a new connection, please, initialize new one")
 	}
+	if wg.connectParams.IsAmneziaWG() {
+		// the WireGuard service (embeddable-dll-service) does not support obfuscation
+		return fmt.Errorf("AmneziaWG is not supported on this platform")
+	}
 
 	defer func() {
 		wg.internals.pauseRequireChan = nil
@@ -491,6 +500,11 @@ func (wg *WireGuard) installService(stateChan chan<- vpn.StateInfo) error {
 
 	// Initialised
 
+	// remember the default route in use (see onRoutingChanged())
+	if gw, iface, err := netinfo.DefaultGatewayEx(false); err == nil && iface != nil {
+		wg.internals.defGateway, wg.internals.defIfaceIndex = gw, iface.Index
+	}
+
 	// Wait for hanshake and send 'connected' notification only after 'dns' package informed about correct DNS value
 	err = wg.waitHandshakeAndNotifyConnected(stateChan)
 	if err != nil {
@@ -581,15 +595,74 @@ func (wg *WireGuard) uninstallService() error {
 	return nil
 }
 
+// onRoutingChanged keeps the connection when the network changes (roaming):
+// the WireGuard service binds the tunnel socket to the new default interface by itself,
+// here we only re-apply the custom DNS configuration (it can be applied to non-VPN interfaces) and initiate the new handshake.
 func (wg *WireGuard) onRoutingChanged() error {
-	// do nothing for Windows
+	if wg.internals.isPaused || wg.internals.isDisconnectRequested || wg.internals.pauseRequireChan == nil {
+		return nil
+	}
+
+	gw, iface, err := netinfo.DefaultGatewayEx(false)
+	if err != nil || iface == nil {
+		log.Info("onRoutingChanged: default route not found (waiting for network connectivity)")
+		return nil
+	}
+	if iface.Index == wg.internals.defIfaceIndex && gw.Equal(wg.internals.defGateway) {
+		return nil
+	}
+	log.Info(fmt.Sprintf("Default route changed: %s (interface index %d) -> %s (interface index %d). Initiating new handshake...",
+		wg.internals.defGateway, wg.internals.defIfaceIndex, gw, iface.Index))
+	wg.internals.defGateway, wg.internals.defIfaceIndex = gw, iface.Index
+
+	if manualDNS := wg.internals.manualDNSRequired; !manualDNS.IsEmpty() {
+		if err := wg.setManualDNS(manualDNS); err != nil {
+			log.Warning(fmt.Sprintf("onRoutingChanged: failed to update custom DNS: %s", err))
+		}
+	}
+
+	if err := RestartHandshake(wg.getTunnelName(), roamingHandshakeTimeout); err != nil {
+		return &vpn.ReconnectionRequiredError{Err: err}
+	}
+	log.Info("Handshake performed over the new network")
 	return nil
 }
 
 func (wg *WireGuard) isReconnectRequiredOnRoutingChange() bool {
-	return true
+	// the connection is kept on network changes (see onRoutingChanged())
+	return false
 }
 
 func (wg *WireGuard) defaultRouteGatewayIP() net.IP {
 	return nil
 }
+
+func (wg *WireGuard) setMtu(mtu int) error {
+	iface, err := net.InterfaceByName(wg.getTunnelName())
+	if err != nil {
+		return err
+	}
+	luid, err := winipcfg.LUIDFromIndex(uint32(iface.Index))
+	if err != nil {
+		return err
+	}
+	families := []winipcfg.AddressFamily{windows.AF_INET}
+	if wg.IsIPv6InTunnel() {
+		families = append(families, windows.AF_INET6)
+	}
+	for _, family := range families {
+		ipif, err := luid.IPInterface(family)
+		if err != nil {
+			return err
+		}
+		ipif.NLMTU = uint32(mtu)
+		if err := ipif.Set(); err != nil {
+			return fmt.Errorf("failed to set MTU (%d): %w", mtu, err)
+		}
+	}
+	return nil
+}
+
+func bypassFwmark() uint32 {
+	return 0
+}
@@ -250,10 +250,7 @@ echo "[+] Preparing DMG image: Copying 'WireGuard' binaries..."
 mkdir -p "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/WireGuard"
 cp "${_PATH_ABS_REPO_DAEMON}/References/macOS/_deps/wg_inst/wg" "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/WireGuard/wg" || CheckLastResult
 cp "${_PATH_ABS_REPO_DAEMON}/References/macOS/_deps/wg_inst/wireguard-go" "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/WireGuard/wireguard-go" || CheckLastResult
-
-echo "[+] Preparing DMG image: Copying 'dnscrypt-proxy' binary..."
-mkdir -p "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/dnscrypt-proxy"
-cp "${_PATH_ABS_REPO_DAEMON}/References/macOS/_deps/dnscryptproxy_inst/dnscrypt-proxy" "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/dnscrypt-proxy/dnscrypt-proxy" || CheckLastResult
+cp "${_PATH_ABS_REPO_DAEMON}/References/macOS/_deps/amneziawg_inst/amneziawg-go" "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/WireGuard/amneziawg-go" || CheckLastResult
 
 echo "[+] Preparing DMG image: Copying kem-helper..."
 mkdir -p "${_PATH_UI_COMPILED_IMAGE}/Contents/MacOS/kem"